@@ -3,22 +3,18 @@ package agents
 import (
 	"context"
 	"errors"
+	"slices"
 
 	"github.com/bububa/instructor-go"
-	anthropicClt "github.com/bububa/instructor-go/instructors/anthropic"
-	cohereClt "github.com/bububa/instructor-go/instructors/cohere"
-	"github.com/bububa/instructor-go/instructors/gemini"
-	geminiClt "github.com/bububa/instructor-go/instructors/gemini"
-	openaiClt "github.com/bububa/instructor-go/instructors/openai"
-	cohere "github.com/cohere-ai/cohere-go/v2"
-	anthropic "github.com/liushuangls/go-anthropic/v2"
-	"github.com/openai/openai-go"
-	geminiAPI "google.golang.org/genai"
 
 	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/interceptor"
+	"github.com/bububa/atomic-agents/components/memory"
 	"github.com/bububa/atomic-agents/components/systemprompt"
 	"github.com/bububa/atomic-agents/components/systemprompt/cot"
+	"github.com/bububa/atomic-agents/components/tracing"
 	"github.com/bububa/atomic-agents/schema"
+	"github.com/bububa/atomic-agents/tools"
 )
 
 type MergeResponse = func(*components.LLMResponse)
@@ -58,6 +54,11 @@ type AgentSetter interface {
 	SetMaxTokens(int)
 }
 
+// MemoryManagerSetter is implemented by agents whose memory history could be fitted into a token budget by a memory Manager
+type MemoryManagerSetter interface {
+	SetMemoryManager(*memory.Manager)
+}
+
 // Config represents general agents configuration
 type Config struct {
 	// client Client for interacting with the language model
@@ -68,8 +69,8 @@ type Config struct {
 	// currentUserInput schema.Schema
 	// model llm model
 	model string
-	// temperature Temperature for response generation, typically ranging from 0 to 1.
-	temperature float64
+	// temperature Temperature for response generation, typically ranging from 0 to 1, the provider default if nil.
+	temperature *float64
 	// topP
 	topP float64
 	// topK
@@ -78,6 +79,22 @@ type Config struct {
 	maxTokens int
 	// name is Agent name presentation
 	name string
+	// tools LLM native tools which could be called by the model
+	tools []tools.Function
+	// maxToolIterations Maximum number of LLM calls in a tool calling loop
+	maxToolIterations int
+	// provider drives the client, looked up from registered providers if nil
+	provider Provider
+	// retryPolicy retries failed LLM calls in Run if not nil
+	retryPolicy *RetryPolicy
+	// priceTable computes response cost, components.DefaultPriceTable is used if nil
+	priceTable *components.PriceTable
+	// memoryManager fits memory history into a token budget before each LLM call if not nil
+	memoryManager *memory.Manager
+	// memoryStore loads and flushes session memory around runs if the context carries a session ID
+	memoryStore memory.Store
+	// isolationKey identifies the agent memory in a memory.Isolation, the agent itself if nil
+	isolationKey any
 }
 
 // Agent class for chat agents.
@@ -85,9 +102,9 @@ type Config struct {
 // generating system prompts, and obtaining responses from a language model.
 type Agent[I schema.Schema, O schema.Schema] struct {
 	Config
-	startHook func(context.Context, *Agent[I, O], *I)
-	endHook   func(context.Context, *Agent[I, O], *I, *O, *components.LLMResponse)
-	errorHook func(context.Context, *Agent[I, O], *I, *components.LLMResponse, error)
+	interceptor.Stack
+	// flushErrorHook is the error hook called when a session flush after a consumed stream failed
+	flushErrorHook func(context.Context, *Agent[I, O], *I, *components.LLMResponse, error)
 }
 
 var (
@@ -96,6 +113,7 @@ var (
 	_ AnonymousAgent                                = (*Agent[schema.String, schema.String])(nil)
 	_ AnonymousStreamableAgent                      = (*Agent[schema.String, schema.String])(nil)
 	_ AgentSetter                                   = (*Agent[schema.String, schema.String])(nil)
+	_ MemoryManagerSetter                           = (*Agent[schema.String, schema.String])(nil)
 )
 
 // NewAgent initializes the AgentAgent
@@ -121,17 +139,63 @@ func (a *Agent[I, O]) Memory() *instructor.Memory {
 	return a.client.Memory()
 }
 
-// AddToMemory add message to memory
-func (a *Agent[I, O]) AddToMemory(msg instructor.Message) {
+// AddToMemory add messages to memory
+func (a *Agent[I, O]) AddToMemory(msgs ...instructor.Message) {
 	if memory := a.Memory(); memory != nil {
-		memory.Add(msg)
+		memory.Add(msgs...)
 	}
 }
 
+// AddTurn adds the input and output as a user and an assistant message to the memory the agent runs on in the context,
+// like the session or isolated memory, so that outputs answered without a run, like cache hits, stay in the history
+func (a *Agent[I, O]) AddTurn(ctx context.Context, userInput *I, output *O) error {
+	session, flush, err := a.session(ctx)
+	if err != nil {
+		return err
+	}
+	msg := instructor.Message{Role: instructor.UserRole}
+	schema.ToMessage(*userInput, &msg)
+	session.AddToMemory(msg, instructor.Message{
+		Role: instructor.AssistantRole,
+		Text: schema.Stringify(*output),
+	})
+	return flush(ctx)
+}
+
 func (a *Agent[I, O]) SetClient(clt instructor.Instructor) {
 	a.client = clt
 }
 
+// SetProvider set the Provider which drives the client instead of the registered providers
+func (a *Agent[I, O]) SetProvider(p Provider) {
+	a.provider = p
+}
+
+// SetRetryPolicy set the RetryPolicy of Run, nil disables retry
+func (a *Agent[I, O]) SetRetryPolicy(p *RetryPolicy) {
+	a.retryPolicy = p
+}
+
+// SetPriceTable set the PriceTable computing response cost
+func (a *Agent[I, O]) SetPriceTable(t *components.PriceTable) {
+	a.priceTable = t
+}
+
+// SetMemoryManager set the memory Manager which fits memory history into a token budget, nil disables it
+func (a *Agent[I, O]) SetMemoryManager(m *memory.Manager) {
+	a.memoryManager = m
+}
+
+// SetMemoryStore set the memory Store which persists session memory, nil disables it
+func (a *Agent[I, O]) SetMemoryStore(store memory.Store) {
+	a.memoryStore = store
+}
+
+// MemoryStore returns the memory Store which persists session memory, nil if none
+func (a *Agent[I, O]) MemoryStore() memory.Store {
+	return a.memoryStore
+}
+
 func (a *Agent[I, O]) Client() instructor.Instructor {
 	return a.client
 }
@@ -155,7 +219,7 @@ func (a *Agent[I, O]) SetModel(model string) {
 }
 
 func (a *Agent[I, O]) SetTemperature(temperature float64) {
-	a.temperature = temperature
+	a.temperature = &temperature
 }
 
 func (a *Agent[I, O]) SetTopP(topP float64) {
@@ -170,6 +234,19 @@ func (a *Agent[I, O]) SetMaxTokens(maxTokens int) {
 	a.maxTokens = maxTokens
 }
 
+// SetTools registers LLM native tools, the agent will run a tool calling loop when tools registered
+func (a *Agent[I, O]) SetTools(fns ...tools.Function) {
+	a.tools = fns
+}
+
+func (a *Agent[I, O]) Tools() []tools.Function {
+	return a.tools
+}
+
+func (a *Agent[I, O]) SetMaxToolIterations(n int) {
+	a.maxToolIterations = n
+}
+
 func (a Agent[I, O]) Name() string {
 	return a.name
 }
@@ -178,518 +255,236 @@ func (a *Agent[I, O]) SetName(name string) {
 	a.name = name
 }
 
+// SetStartHook sets the hook calling fn before every run or stream
+//
+// Deprecated: use Use with interceptor.Hooks or interceptor.Before, see interceptor.Stack SetHook
 func (a *Agent[I, O]) SetStartHook(fn func(context.Context, *Agent[I, O], *I)) {
-	a.startHook = fn
+	if fn == nil {
+		a.SetHook(interceptor.StartHook, nil)
+		return
+	}
+	a.SetHook(interceptor.StartHook, interceptor.Hooks[I, O](func(ctx context.Context, input *I) {
+		fn(ctx, a, input)
+	}, nil, nil))
 }
 
+// SetEndHook sets the hook calling fn after every successful run or stream start
+//
+// Deprecated: use Use with interceptor.Hooks or interceptor.After, see interceptor.Stack SetHook
 func (a *Agent[I, O]) SetEndHook(fn func(context.Context, *Agent[I, O], *I, *O, *components.LLMResponse)) {
-	a.endHook = fn
+	if fn == nil {
+		a.SetHook(interceptor.EndHook, nil)
+		return
+	}
+	a.SetHook(interceptor.EndHook, interceptor.Hooks[I, O](nil, func(ctx context.Context, input *I, output *O, apiResp *components.LLMResponse) {
+		fn(ctx, a, input, output, apiResp)
+	}, nil))
 }
 
+// SetErrorHook sets the hook calling fn after every failed run or stream start,
+// fn is also called when the session flush after a consumed stream failed
+//
+// Deprecated: use Use with interceptor.Hooks or interceptor.After, see interceptor.Stack SetHook
 func (a *Agent[I, O]) SetErrorHook(fn func(context.Context, *Agent[I, O], *I, *components.LLMResponse, error)) {
-	a.errorHook = fn
+	a.flushErrorHook = fn
+	if fn == nil {
+		a.SetHook(interceptor.ErrorHook, nil)
+		return
+	}
+	a.SetHook(interceptor.ErrorHook, interceptor.Hooks[I, O](nil, nil, func(ctx context.Context, input *I, apiResp *components.LLMResponse, err error) {
+		fn(ctx, a, input, apiResp, err)
+	}))
+}
+
+// newChatRequest builds provider independent request from system prompt, memory and user input
+func (a *Agent[I, O]) newChatRequest(userInput *I) *ChatRequest {
+	req := &ChatRequest{
+		Model:       a.model,
+		Temperature: a.temperature,
+		TopP:        a.topP,
+		TopK:        a.topK,
+		MaxTokens:   a.maxTokens,
+		System: instructor.Message{
+			Role: instructor.SystemRole,
+			Text: a.systemPromptGenerator.Generate(),
+		},
+		History: a.Memory().List(),
+		Input: instructor.Message{
+			Role: instructor.UserRole,
+		},
+	}
+	if userInput != nil {
+		schema.ToMessage(*userInput, &req.Input)
+		req.InputText = schema.Stringify(*userInput)
+		req.ExtraBody = (*userInput).ExtraBody()
+	}
+	return req
+}
+
+// fitMemory fits memory history into the memory manager token budget, the fitted history replaces memory.
+// System messages kept by the manager, like summaries of older turns, are appended to the request system prompt.
+func (a *Agent[I, O]) fitMemory(ctx context.Context, req *ChatRequest) (*components.LLMUsage, error) {
+	manager := a.memoryManager
+	mem := a.Memory()
+	if manager == nil || mem == nil {
+		return nil, nil
+	}
+	usage := new(components.LLMUsage)
+	history, err := manager.Fit(ctx, mem.List(), manager.Count(req.System, req.Input), usage)
+	if err != nil {
+		return usage, err
+	}
+	mem.Set(history)
+	req.History = make([]instructor.Message, 0, len(history))
+	for _, msg := range mem.List() {
+		if msg.Role == instructor.SystemRole {
+			req.System.Text += "\n\n" + msg.Text
+			continue
+		}
+		req.History = append(req.History, msg)
+	}
+	return usage, nil
+}
+
+// lookupProvider returns the agent Provider, or a registered Provider which could drive the client
+func (a *Agent[I, O]) lookupProvider() (Provider, error) {
+	if a.provider != nil {
+		return a.provider, nil
+	}
+	return LookupProvider(a.client)
 }
 
 // Response obtains a response from the language model synchronously
-func (a *Agent[I, O]) chat(ctx context.Context, userInput *I, response *O, llmResponse *components.LLMResponse) error {
-	sysMsg := instructor.Message{
-		Role: instructor.SystemRole,
-		Text: a.systemPromptGenerator.Generate(),
-	}
-	history := a.Memory().List()
-	messages := make([]instructor.Message, 0, len(history)+2)
-	messages = append(messages, sysMsg)
-	messages = append(messages, history...)
-	inputMsg := instructor.Message{
-		Role: instructor.UserRole,
-	}
-	schema.ToMessage(*userInput, &inputMsg)
-	messages = append(messages, inputMsg)
-	switch clt := a.client.(type) {
-	case instructor.ChatInstructor[openai.ChatCompletionNewParams, openai.ChatCompletion]:
-		chatReq := openai.ChatCompletionNewParams{
-			Model:       a.model,
-			Temperature: openai.Float(a.temperature),
-		}
-		if a.temperature > 1e-15 {
-			chatReq.Temperature = openai.Float(a.temperature)
-		}
-		if a.topP > 1e-15 {
-			chatReq.TopP = openai.Float(a.topP)
-		}
-		if a.topK > 0 {
-			chatReq.TopLogprobs = openai.Int(int64(a.topK))
-		}
-		if a.maxTokens > 0 {
-			chatReq.MaxCompletionTokens = openai.Int(int64(a.maxTokens))
-		}
-		if extraBody := (*userInput).ExtraBody(); extraBody != nil {
-			chatReq.SetExtraFields(map[string]any{
-				"extra_body": extraBody,
-			})
-		}
-		for _, msg := range messages {
-			chunks := openaiClt.ConvertMessageFrom(&msg)
-			if len(chunks) > 0 {
-				chatReq.Messages = append(chatReq.Messages, chunks...)
-			}
-		}
-		res := new(openai.ChatCompletion)
-		if err := clt.Chat(ctx, &chatReq, response, res); err != nil {
-			return err
-		} else if llmResponse != nil {
-			llmResponse.FromOpenAI(res)
-		}
-	case instructor.ChatInstructor[anthropic.MessagesRequest, anthropic.MessagesResponse]:
-		chatReq := anthropic.MessagesRequest{
-			Model: anthropic.Model(a.model),
-		}
-		if v := float32(a.temperature); v > 1e-15 {
-			chatReq.Temperature = &v
-		}
-		if v := float32(a.topP); v > 1e-15 {
-			chatReq.TopP = &v
-		}
-		if v := a.topK; v > 0 {
-			chatReq.TopK = &v
-		}
-		if v := a.maxTokens; v > 0 {
-			chatReq.MaxTokens = v
-		}
-		for _, msg := range messages {
-			var v anthropic.Message
-			anthropicClt.ConvertMessageFrom(&msg, &v)
-			chatReq.Messages = append(chatReq.Messages, v)
-		}
-		res := new(anthropic.MessagesResponse)
-		if err := clt.Chat(ctx, &chatReq, response, res); err != nil {
-			return err
-		} else if llmResponse != nil {
-			llmResponse.FromAnthropic(res)
-		}
-	case instructor.ChatInstructor[cohere.ChatRequest, cohere.NonStreamedChatResponse]:
-		lastHistoryIdx := len(messages) - 1
-		chatReq := cohere.ChatRequest{
-			Model:   &a.model,
-			Message: schema.Stringify(*userInput),
-		}
-		if v := float64(a.temperature); v > 1e-15 {
-			chatReq.Temperature = &v
-		}
-		if v := float64(a.topP); v > 1e-15 {
-			chatReq.P = &v
-		}
-		if v := a.maxTokens; v > 0 {
-			chatReq.MaxTokens = &v
-		}
+func (a *Agent[I, O]) chat(ctx context.Context, req *ChatRequest, response *O, llmResponse *components.LLMResponse) error {
+	p, err := a.lookupProvider()
+	if err != nil {
+		return err
+	}
+	err = p.Chat(ctx, a.client, req, response, llmResponse)
+	a.completeResponse(llmResponse)
+	return err
+}
 
-		for idx, msg := range messages {
-			if idx >= lastHistoryIdx {
-				break
-			}
-			var v cohere.Message
-			cohereClt.ConvertMessageFrom(&msg, &v)
-			chatReq.ChatHistory = append(chatReq.ChatHistory, &v)
-		}
-		res := new(cohere.NonStreamedChatResponse)
-		if err := clt.Chat(ctx, &chatReq, response, res); err != nil {
-			return err
-		} else if llmResponse != nil {
-			llmResponse.FromCohere(res)
-		}
-	case instructor.ChatInstructor[geminiClt.Request, geminiAPI.GenerateContentResponse]:
-		chatReq := geminiClt.Request{
-			Model: a.model,
-		}
-		{
-			var v geminiAPI.Content
-			geminiClt.ConvertMessageFrom(&sysMsg, &v)
-			chatReq.System = &v
-		}
-		chatReq.History = make([]*geminiAPI.Content, 0, len(history))
-		for _, msg := range history {
-			var v geminiAPI.Content
-			geminiClt.ConvertMessageFrom(&msg, &v)
-			chatReq.History = append(chatReq.History, &v)
-		}
-		if userInput != nil {
-			var v geminiAPI.Content
-			geminiClt.ConvertMessageFrom(&inputMsg, &v)
-			chatReq.Parts = append(chatReq.Parts, v.Parts...)
+// completeResponse records the model and provider which served the request, and computes the cost
+func (a *Agent[I, O]) completeResponse(llmResponse *components.LLMResponse) {
+	if llmResponse == nil {
+		return
+	}
+	if llmResponse.Model == "" {
+		llmResponse.Model = a.model
+	}
+	if a.client != nil {
+		llmResponse.Provider = a.client.Provider()
+	}
+	llmResponse.ComputeCost(a.priceTable)
+}
+
+// completeMergeResponse wraps MergeResponse with completeResponse, and records the stream cost into the context CostReport.
+// memoryUsage of the memory manager, recorded by its own agents, is merged after recording.
+func (a *Agent[I, O]) completeMergeResponse(ctx context.Context, mergeResp MergeResponse, memoryUsage *components.LLMUsage) MergeResponse {
+	return func(llmResponse *components.LLMResponse) {
+		if mergeResp != nil {
+			mergeResp(llmResponse)
 		}
-		res := new(geminiAPI.GenerateContentResponse)
-		if err := clt.Chat(ctx, &chatReq, response, res); err != nil {
-			return err
-		} else if llmResponse != nil {
-			llmResponse.FromGemini(res)
+		a.completeResponse(llmResponse)
+		if llmResponse != nil {
+			a.recordCost(ctx, llmResponse)
+			mergeUsage(llmResponse, memoryUsage)
 		}
 	}
-	if llmResponse != nil && llmResponse.Model == "" {
-		llmResponse.Model = a.model
+}
+
+// mergeUsage merges usage into the response usage
+func mergeUsage(llmResponse *components.LLMResponse, usage *components.LLMUsage) {
+	if usage == nil {
+		return
 	}
-	return nil
+	if llmResponse.Usage == nil {
+		llmResponse.Usage = new(components.LLMUsage)
+	}
+	llmResponse.Usage.Merge(usage)
+}
+
+// recordCost records response usage into the context CostReport
+func (a *Agent[I, O]) recordCost(ctx context.Context, llmResponse *components.LLMResponse) {
+	components.RecordCost(ctx, components.ChatCost, a.name, llmResponse.Provider, llmResponse.Model, llmResponse.Usage)
 }
 
 // Response obtains a response from the language model synchronously
 func (a *Agent[I, O]) stream(ctx context.Context, userInput *I) (<-chan instructor.StreamData, MergeResponse, error) {
-	sysMsg := instructor.Message{
-		Role: instructor.SystemRole,
-		Text: a.systemPromptGenerator.Generate(),
-	}
-	inputMsg := instructor.Message{
-		Role: instructor.UserRole,
-	}
-	schema.ToMessage(*userInput, &inputMsg)
-	history := a.Memory().List()
-	messages := make([]instructor.Message, 0, len(history)+2)
-	messages = append(messages, sysMsg)
-	messages = append(messages, history...)
-	messages = append(messages, inputMsg)
-	respType := new(O)
-	switch clt := a.client.(type) {
-	case instructor.StreamInstructor[openai.ChatCompletionNewParams, openai.ChatCompletion]:
-		llmResp := new(openai.ChatCompletion)
-		mergeResp := func(resp *components.LLMResponse) {
-			if resp == nil {
-				return
-			}
-			resp.FromOpenAI(llmResp)
-			if resp.Model == "" {
-				resp.Model = a.model
-			}
-		}
-		chatReq := openai.ChatCompletionNewParams{
-			Model: a.model,
-		}
-		if a.temperature > 1e-15 {
-			chatReq.Temperature = openai.Float(a.temperature)
-		}
-		if a.topP > 1e-15 {
-			chatReq.TopP = openai.Float(a.topP)
-		}
-		if a.topK > 0 {
-			chatReq.TopLogprobs = openai.Int(int64(a.topK))
-		}
-		if a.maxTokens > 0 {
-			chatReq.MaxCompletionTokens = openai.Int(int64(a.maxTokens))
-		}
-		if extraBody := (*userInput).ExtraBody(); extraBody != nil {
-			chatReq.SetExtraFields(map[string]any{
-				"extra_body": extraBody,
-			})
-		}
-		for _, msg := range messages {
-			chunks := openaiClt.ConvertMessageFrom(&msg)
-			if len(chunks) > 0 {
-				chatReq.Messages = append(chatReq.Messages, chunks...)
-			}
-		}
-		ch, err := clt.Stream(ctx, &chatReq, respType, llmResp)
-		if err != nil {
-			return nil, mergeResp, err
-		}
-		return ch, mergeResp, nil
-	case instructor.StreamInstructor[anthropic.MessagesRequest, anthropic.MessagesResponse]:
-		llmResp := new(anthropic.MessagesResponse)
-		mergeResp := func(resp *components.LLMResponse) {
-			if resp == nil {
-				return
-			}
-			resp.FromAnthropic(llmResp)
-			if resp.Model == "" {
-				resp.Model = a.model
-			}
-		}
-		chatReq := anthropic.MessagesRequest{
-			Model: anthropic.Model(a.model),
-		}
-		if v := float32(a.temperature); v > 1e-15 {
-			chatReq.Temperature = &v
-		}
-		if v := float32(a.topP); v > 1e-15 {
-			chatReq.TopP = &v
-		}
-		if v := a.topK; v > 0 {
-			chatReq.TopK = &v
-		}
-		if v := a.maxTokens; v > 0 {
-			chatReq.MaxTokens = v
-		}
-		for _, msg := range messages {
-			var v anthropic.Message
-			anthropicClt.ConvertMessageFrom(&msg, &v)
-			chatReq.Messages = append(chatReq.Messages, v)
-		}
-		ch, err := clt.Stream(ctx, &chatReq, respType, llmResp)
-		if err != nil {
-			return nil, mergeResp, err
-		}
-		return ch, mergeResp, nil
-	case instructor.StreamInstructor[cohere.ChatRequest, cohere.NonStreamedChatResponse]:
-		llmResp := new(cohere.NonStreamedChatResponse)
-		mergeResp := func(resp *components.LLMResponse) {
-			if resp == nil {
-				return
-			}
-			resp.FromCohere(llmResp)
-			if resp.Model == "" {
-				resp.Model = a.model
-			}
-		}
-		lastHistoryIdx := len(messages) - 1
-		chatReq := cohere.ChatRequest{
-			Model:   &a.model,
-			Message: schema.Stringify(*userInput),
-		}
-		if v := float64(a.temperature); v > 1e-15 {
-			chatReq.Temperature = &v
-		}
-		if v := float64(a.topP); v > 1e-15 {
-			chatReq.P = &v
-		}
-		if v := a.maxTokens; v > 0 {
-			chatReq.MaxTokens = &v
-		}
-		for idx, msg := range messages {
-			if idx >= lastHistoryIdx {
-				break
-			}
-			var v cohere.Message
-			cohereClt.ConvertMessageFrom(&msg, &v)
-			chatReq.ChatHistory = append(chatReq.ChatHistory, &v)
-		}
-		ch, err := clt.Stream(ctx, &chatReq, respType, llmResp)
-		if err != nil {
-			return nil, mergeResp, err
-		}
-		return ch, mergeResp, nil
-	case instructor.StreamInstructor[geminiClt.Request, geminiAPI.GenerateContentResponse]:
-		llmResp := new(geminiAPI.GenerateContentResponse)
-		mergeResp := func(resp *components.LLMResponse) {
-			if resp == nil {
-				return
-			}
-			resp.FromGemini(llmResp)
-			if resp.Model == "" {
-				resp.Model = a.model
-			}
-		}
-		chatReq := geminiClt.Request{
-			Model: a.model,
-		}
-		{
-			var v geminiAPI.Content
-			geminiClt.ConvertMessageFrom(&sysMsg, &v)
-			chatReq.System = &v
-		}
-		chatReq.History = make([]*geminiAPI.Content, 0, len(history))
-		for _, msg := range history {
-			var v geminiAPI.Content
-			geminiClt.ConvertMessageFrom(&msg, &v)
-			chatReq.History = append(chatReq.History, &v)
-		}
-		var v geminiAPI.Content
-		geminiClt.ConvertMessageFrom(&inputMsg, &v)
-		chatReq.Parts = append(chatReq.Parts, v.Parts...)
-		ch, err := clt.Stream(ctx, &chatReq, respType, llmResp)
-		if err != nil {
-			return nil, mergeResp, err
-		}
-		return ch, mergeResp, nil
+	p, err := a.lookupProvider()
+	if err != nil {
+		return nil, nil, err
 	}
-	return nil, nil, errors.New("unknown instructor provider")
+	req := a.newChatRequest(userInput)
+	memoryUsage, err := a.fitMemory(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	ch, mergeResp, err := p.Stream(ctx, a.client, req, new(O))
+	return ch, a.completeMergeResponse(ctx, mergeResp, memoryUsage), err
 }
 
 // Response obtains a response from the language model synchronously
 func (a *Agent[I, O]) schemaStream(ctx context.Context, userInput *I) (<-chan any, <-chan instructor.StreamData, MergeResponse, error) {
-	sysMsg := instructor.Message{
-		Role: instructor.SystemRole,
-		Text: a.systemPromptGenerator.Generate(),
-	}
-	inputMsg := instructor.Message{
-		Role: instructor.UserRole,
-	}
-	schema.ToMessage(*userInput, &inputMsg)
-	history := a.Memory().List()
-	messages := make([]instructor.Message, 0, len(history)+2)
-	messages = append(messages, sysMsg)
-	messages = append(messages, history...)
-	messages = append(messages, inputMsg)
-	var responseType O
-	switch clt := a.client.(type) {
-	case instructor.SchemaStreamInstructor[openai.ChatCompletionNewParams, openai.ChatCompletion]:
-		llmResp := new(openai.ChatCompletion)
-		mergeResp := func(resp *components.LLMResponse) {
-			if resp == nil {
-				return
-			}
-			resp.FromOpenAI(llmResp)
-			if resp.Model == "" {
-				resp.Model = a.model
-			}
-		}
-		chatReq := openai.ChatCompletionNewParams{
-			Model: a.model,
-		}
-		if a.temperature > 1e-15 {
-			chatReq.Temperature = openai.Float(a.temperature)
-		}
-		if a.topP > 1e-15 {
-			chatReq.TopP = openai.Float(a.topP)
-		}
-		if a.topK > 0 {
-			chatReq.TopLogprobs = openai.Int(int64(a.topK))
-		}
-		if a.maxTokens > 0 {
-			chatReq.MaxCompletionTokens = openai.Int(int64(a.maxTokens))
-		}
-		if extraBody := (*userInput).ExtraBody(); extraBody != nil {
-			chatReq.SetExtraFields(map[string]any{
-				"extra_body": extraBody,
-			})
-		}
-		for _, msg := range messages {
-			chunks := openaiClt.ConvertMessageFrom(&msg)
-			if len(chunks) > 0 {
-				chatReq.Messages = append(chatReq.Messages, chunks...)
-			}
-		}
-		ch, stream, err := clt.SchemaStream(ctx, &chatReq, responseType, llmResp)
-		if err != nil {
-			return nil, nil, mergeResp, err
-		}
-		return ch, stream, mergeResp, nil
-	case instructor.SchemaStreamInstructor[anthropic.MessagesRequest, anthropic.MessagesResponse]:
-		llmResp := new(anthropic.MessagesResponse)
-		mergeResp := func(resp *components.LLMResponse) {
-			if resp == nil {
-				return
-			}
-			resp.FromAnthropic(llmResp)
-			if resp.Model == "" {
-				resp.Model = a.model
-			}
-		}
-		chatReq := anthropic.MessagesRequest{
-			Model: anthropic.Model(a.model),
-		}
-		if v := float32(a.temperature); v > 1e-15 {
-			chatReq.Temperature = &v
-		}
-		if v := float32(a.topP); v > 1e-15 {
-			chatReq.TopP = &v
-		}
-		if v := a.topK; v > 0 {
-			chatReq.TopK = &v
-		}
-		if v := a.maxTokens; v > 0 {
-			chatReq.MaxTokens = v
-		}
-		for _, msg := range messages {
-			var v anthropic.Message
-			anthropicClt.ConvertMessageFrom(&msg, &v)
-			chatReq.Messages = append(chatReq.Messages, v)
-		}
-		ch, stream, err := clt.SchemaStream(ctx, &chatReq, responseType, llmResp)
-		if err != nil {
-			return nil, nil, mergeResp, err
-		}
-		return ch, stream, mergeResp, nil
-	case instructor.SchemaStreamInstructor[cohere.ChatRequest, cohere.NonStreamedChatResponse]:
-		llmResp := new(cohere.NonStreamedChatResponse)
-		mergeResp := func(resp *components.LLMResponse) {
-			if resp == nil {
-				return
-			}
-			resp.FromCohere(llmResp)
-			if resp.Model == "" {
-				resp.Model = a.model
-			}
-		}
-		lastHistoryIdx := len(messages) - 1
-		chatReq := cohere.ChatRequest{
-			Model:   &a.model,
-			Message: schema.Stringify(*userInput),
-		}
-		if v := float64(a.temperature); v > 1e-15 {
-			chatReq.Temperature = &v
-		}
-		if v := float64(a.topP); v > 1e-15 {
-			chatReq.P = &v
-		}
-		if v := a.maxTokens; v > 0 {
-			chatReq.MaxTokens = &v
-		}
-		for idx, msg := range messages {
-			if idx >= lastHistoryIdx {
-				break
-			}
-			var v cohere.Message
-			cohereClt.ConvertMessageFrom(&msg, &v)
-			chatReq.ChatHistory = append(chatReq.ChatHistory, &v)
-		}
-		ch, stream, err := clt.SchemaStream(ctx, &chatReq, responseType, llmResp)
-		if err != nil {
-			return nil, nil, mergeResp, err
-		}
-		return ch, stream, mergeResp, nil
-	case instructor.SchemaStreamInstructor[geminiClt.Request, geminiAPI.GenerateContentResponse]:
-		llmResp := new(geminiAPI.GenerateContentResponse)
-		mergeResp := func(resp *components.LLMResponse) {
-			if resp == nil {
-				return
-			}
-			resp.FromGemini(llmResp)
-			if resp.Model == "" {
-				resp.Model = a.model
-			}
-		}
-		chatReq := gemini.Request{
-			Model: a.model,
-		}
-		{
-			var v geminiAPI.Content
-			geminiClt.ConvertMessageFrom(&sysMsg, &v)
-			chatReq.System = &v
-		}
-		chatReq.History = make([]*geminiAPI.Content, 0, len(history))
-		for _, msg := range history {
-			var v geminiAPI.Content
-			geminiClt.ConvertMessageFrom(&msg, &v)
-			chatReq.History = append(chatReq.History, &v)
-		}
-		{
-			var v geminiAPI.Content
-			geminiClt.ConvertMessageFrom(&inputMsg, &v)
-			chatReq.Parts = append(chatReq.Parts, v.Parts...)
-		}
-		ch, stream, err := clt.SchemaStream(ctx, &chatReq, responseType, llmResp)
-		if err != nil {
-			return nil, nil, mergeResp, err
-		}
-		return ch, stream, mergeResp, nil
+	p, err := a.lookupProvider()
+	if err != nil {
+		return nil, nil, nil, err
 	}
-	return nil, nil, nil, errors.New("unknown instructor provider")
+	req := a.newChatRequest(userInput)
+	memoryUsage, err := a.fitMemory(ctx, req)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var responseType O
+	ch, stream, mergeResp, err := p.SchemaStream(ctx, a.client, req, responseType)
+	return ch, stream, a.completeMergeResponse(ctx, mergeResp, memoryUsage), err
 }
 
 // Run runs the chat agent with the given user input synchronously.
+// If the context carries a session ID and the agent has a memory store, the session memory is loaded before
+// and flushed after the run.
 func (a *Agent[I, O]) Run(ctx context.Context, userInput *I, output *O, apiResp *components.LLMResponse) error {
-	if fn := a.startHook; fn != nil {
-		fn(ctx, a, userInput)
-	}
+	call := &interceptor.Call{Kind: interceptor.AgentCall, Name: a.name, Target: a}
+	return interceptor.Typed(ctx, call, a.Interceptors(), userInput, output, apiResp, a.invoke)
+}
+
+func (a *Agent[I, O]) invoke(ctx context.Context, userInput *I, output *O, apiResp *components.LLMResponse) error {
 	if apiResp == nil {
 		apiResp = new(components.LLMResponse)
 	}
-	if err := a.chat(ctx, userInput, output, apiResp); err != nil {
-		if fn := a.errorHook; fn != nil {
-			fn(ctx, a, userInput, apiResp, err)
+	ctx, span := tracing.Start(ctx, tracing.AgentRunSpan, tracing.AgentNameKey.String(a.name), tracing.RequestModelKey.String(a.model))
+	session, flush, err := a.session(ctx)
+	if err == nil {
+		if err = session.run(ctx, userInput, output, apiResp, runMessagesFor(ctx, a)...); err == nil {
+			err = flush(ctx)
 		}
-		return err
 	}
-	if fn := a.endHook; fn != nil {
-		fn(ctx, a, userInput, output, apiResp)
+	span.SetResponse(apiResp)
+	span.EndErr(err)
+	return err
+}
+
+// run obtains a response from the language model with memory fitted and retry policy applied.
+// runMessages are sent after the history for this run only, they are never added to memory.
+func (a *Agent[I, O]) run(ctx context.Context, userInput *I, output *O, apiResp *components.LLMResponse, runMessages ...instructor.Message) error {
+	chat := a.chat
+	if len(a.tools) > 0 {
+		chat = a.chatWithTools
 	}
-	return nil
+	req := a.newChatRequest(userInput)
+	memoryUsage, err := a.fitMemory(ctx, req)
+	if len(runMessages) > 0 {
+		// history could be the memory list, it must not be appended in place
+		req.History = append(slices.Clip(req.History), runMessages...)
+	}
+	if err == nil {
+		err = a.chatWithRetry(ctx, chat, req, output, apiResp)
+		a.recordCost(ctx, apiResp)
+	}
+	// memory manager agents record their own cost
+	mergeUsage(apiResp, memoryUsage)
+	return err
 }
 
 // Run runs the chat agent with the given user input for chain.
@@ -705,22 +500,27 @@ func (a *Agent[I, O]) RunAnonymous(ctx context.Context, userInput any, apiResp *
 	return out, nil
 }
 
-// Run runs the chat agent with the given user input synchronously.
+// Stream streams the chat agent response to the given user input.
+// Interceptors run around the start of the stream, see interceptor.Call Stream.
 func (a *Agent[I, O]) Stream(ctx context.Context, userInput *I) (<-chan instructor.StreamData, MergeResponse, error) {
-	if fn := a.startHook; fn != nil {
-		fn(ctx, a, userInput)
+	call := &interceptor.Call{Kind: interceptor.AgentCall, Name: a.name, Target: a}
+	s, err := interceptStream[I, O](ctx, call, a.Interceptors(), userInput, func(ctx context.Context, userInput *I) (*startedStream, error) {
+		ch, mergeResp, err := a.invokeStream(ctx, userInput)
+		return &startedStream{ch: ch, mergeResp: mergeResp}, err
+	})
+	return s.ch, s.mergeResp, err
+}
+
+func (a *Agent[I, O]) invokeStream(ctx context.Context, userInput *I) (<-chan instructor.StreamData, MergeResponse, error) {
+	session, flush, err := a.session(ctx)
+	if err != nil {
+		return nil, nil, err
 	}
-	ch, mergeResp, err := a.stream(ctx, userInput)
+	ch, mergeResp, err := session.stream(ctx, userInput)
 	if err != nil {
-		if fn := a.errorHook; fn != nil {
-			fn(ctx, a, userInput, nil, err)
-		}
 		return nil, mergeResp, err
 	}
-	if fn := a.endHook; fn != nil {
-		fn(ctx, a, userInput, nil, nil)
-	}
-	return ch, mergeResp, nil
+	return ch, a.flushMergeResponse(ctx, userInput, mergeResp, flush), nil
 }
 
 func (a *Agent[I, O]) StreamAnonymous(ctx context.Context, userInput any) (<-chan instructor.StreamData, MergeResponse, error) {
@@ -735,22 +535,27 @@ func (a *Agent[I, O]) StreamAnonymous(ctx context.Context, userInput any) (<-cha
 	return ch, mergeResp, nil
 }
 
-// Run runs the chat agent with the given user input synchronously.
+// SchemaStream streams the chat agent response to the given user input with the partial outputs.
+// Interceptors run around the start of the stream, see interceptor.Call Stream.
 func (a *Agent[I, O]) SchemaStream(ctx context.Context, userInput *I) (<-chan any, <-chan instructor.StreamData, MergeResponse, error) {
-	if fn := a.startHook; fn != nil {
-		fn(ctx, a, userInput)
+	call := &interceptor.Call{Kind: interceptor.AgentCall, Name: a.name, Target: a}
+	s, err := interceptStream[I, O](ctx, call, a.Interceptors(), userInput, func(ctx context.Context, userInput *I) (*startedStream, error) {
+		schemaCh, ch, mergeResp, err := a.invokeSchemaStream(ctx, userInput)
+		return &startedStream{ch: ch, schemaCh: schemaCh, mergeResp: mergeResp}, err
+	})
+	return s.schemaCh, s.ch, s.mergeResp, err
+}
+
+func (a *Agent[I, O]) invokeSchemaStream(ctx context.Context, userInput *I) (<-chan any, <-chan instructor.StreamData, MergeResponse, error) {
+	session, flush, err := a.session(ctx)
+	if err != nil {
+		return nil, nil, nil, err
 	}
-	ch, stream, mergeResp, err := a.schemaStream(ctx, userInput)
+	ch, stream, mergeResp, err := session.schemaStream(ctx, userInput)
 	if err != nil {
-		if fn := a.errorHook; fn != nil {
-			fn(ctx, a, userInput, nil, err)
-		}
 		return nil, nil, mergeResp, err
 	}
-	if fn := a.endHook; fn != nil {
-		fn(ctx, a, userInput, nil, nil)
-	}
-	return ch, stream, mergeResp, nil
+	return ch, stream, a.flushMergeResponse(ctx, userInput, mergeResp, flush), nil
 }
 
 // SystemPromptContextProvider returns agent systemPromptGenerator's context provider
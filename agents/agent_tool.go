@@ -0,0 +1,144 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+
+	"github.com/bububa/instructor-go"
+	"github.com/invopop/jsonschema"
+
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/memory"
+	"github.com/bububa/atomic-agents/schema"
+	"github.com/bububa/atomic-agents/tools"
+)
+
+// AgentTool wraps an agent accepting *I and returning *O, like Agent, Chain or RAG, as a tool,
+// so that a manager agent could delegate to it through ToolAgent, orchestration.Tool or native tool calling.
+// Every call runs in a new memory.Isolation by default, so delegations never leak into the history of the manager
+// and concurrent calls never share memory, the agent and its nested agents get their own memories.
+// Costs are recorded under the tool title cost scope.
+type AgentTool[I schema.Schema, O schema.Schema] struct {
+	tools.Config
+	agent      AnonymousAgent
+	parameters *jsonschema.Schema
+	isolation  *memory.Isolation
+	mu         sync.Mutex
+	usage      components.LLMUsage
+}
+
+var (
+	_ tools.Tool[schema.String, schema.String] = (*AgentTool[schema.String, schema.String])(nil)
+	_ tools.Function                           = (*AgentTool[schema.String, schema.String])(nil)
+)
+
+// NewAgentTool returns a new AgentTool of the agent, the title defaults to the agent name
+func NewAgentTool[I schema.Schema, O schema.Schema](agent AnonymousAgent, opts ...tools.Option) *AgentTool[I, O] {
+	ret := &AgentTool[I, O]{
+		agent:      agent,
+		parameters: instructor.JSONSchema(reflect.TypeFor[I](), true, nil),
+	}
+	for _, opt := range opts {
+		opt(&ret.Config)
+	}
+	if ret.Title() == "" {
+		ret.SetTitle(agent.Name())
+	}
+	return ret
+}
+
+// Agent returns the wrapped agent
+func (t *AgentTool[I, O]) Agent() AnonymousAgent {
+	return t.agent
+}
+
+// Isolation returns the Isolation kept across calls, nil if every call runs in a new Isolation
+func (t *AgentTool[I, O]) Isolation() *memory.Isolation {
+	return t.isolation
+}
+
+// SetIsolation keeps the memories of the agents in the Isolation across calls, so that the agent remembers
+// former delegations. Concurrent calls on a kept Isolation are not safe. nil runs every call in a new Isolation.
+func (t *AgentTool[I, O]) SetIsolation(isolation *memory.Isolation) *AgentTool[I, O] {
+	t.isolation = isolation
+	return t
+}
+
+// Usage returns the usage summed across calls
+func (t *AgentTool[I, O]) Usage() components.LLMUsage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.usage
+}
+
+func (t *AgentTool[I, O]) Parameters() *jsonschema.Schema {
+	return t.parameters
+}
+
+func (t *AgentTool[I, O]) NewInput() any {
+	return new(I)
+}
+
+// Run runs the agent with the given input
+func (t *AgentTool[I, O]) Run(ctx context.Context, input *I, output *O) error {
+	return t.run(ctx, input, output, new(components.LLMResponse))
+}
+
+// RunAgent runs the agent with the given input, the usage of the call is reported in apiResp
+func (t *AgentTool[I, O]) RunAgent(ctx context.Context, input *I, output *O, apiResp *components.LLMResponse) error {
+	if apiResp == nil {
+		apiResp = new(components.LLMResponse)
+	}
+	return t.run(ctx, input, output, apiResp)
+}
+
+func (t *AgentTool[I, O]) run(ctx context.Context, input *I, output *O, apiResp *components.LLMResponse) error {
+	isolation := t.isolation
+	if isolation == nil {
+		isolation = memory.NewIsolation()
+	}
+	ctx = memory.WithIsolation(components.WithCostScope(ctx, t.Title()), isolation)
+	ret, err := t.agent.RunAnonymous(ctx, input, apiResp)
+	if apiResp.Usage != nil {
+		t.mu.Lock()
+		t.usage.Merge(apiResp.Usage)
+		t.mu.Unlock()
+	}
+	if err != nil {
+		return err
+	}
+	out, ok := ret.(*O)
+	if !ok {
+		return errors.New("invalid agent output schema")
+	}
+	*output = *out
+	return nil
+}
+
+// RunAnonymous runs the agent for tools orchestration
+func (t *AgentTool[I, O]) RunAnonymous(ctx context.Context, input any) (any, error) {
+	if fn := t.StartHook(); fn != nil {
+		fn(ctx, t, input)
+	}
+	in, ok := input.(*I)
+	if !ok {
+		err := errors.New("invalid tool input schema")
+		if fn := t.ErrorHook(); fn != nil {
+			fn(ctx, t, input, err)
+		}
+		return nil, err
+	}
+	out := new(O)
+	if err := t.run(ctx, in, out, new(components.LLMResponse)); err != nil {
+		if fn := t.ErrorHook(); fn != nil {
+			fn(ctx, t, input, err)
+		}
+		return nil, err
+	}
+	if fn := t.EndHook(); fn != nil {
+		fn(ctx, t, input, out)
+	}
+	return out, nil
+}
@@ -0,0 +1,64 @@
+package agents
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/bububa/instructor-go"
+
+	"github.com/bububa/atomic-agents/components/memory"
+	"github.com/bububa/atomic-agents/schema"
+	"github.com/bububa/atomic-agents/tools"
+)
+
+// replyMessageCount replies the number of messages received, system prompt included
+func TestAgentTool(t *testing.T) {
+	srv := startOpenAICompatibleServer(t, replyMessageCount)
+	// the manager and the chain steps share the client and its memory
+	options := []Option{
+		WithClient(newOpenAICompatibleTestClient(instructor.ModeJSON)),
+		WithModel("llama3"),
+		WithOpenAICompatible(OpenAICompatible{BaseURL: srv.URL}),
+	}
+	manager := NewAgent[schema.Input, schema.Output](options...)
+	draft := NewAgent[schema.Input, schema.Output](options...)
+	review := NewAgent[schema.Output, schema.Output](options...)
+	chain := NewChain[schema.Input, schema.Output](draft, review)
+	tool := NewAgentTool[schema.Input, schema.Output](chain, tools.WithTitle("delegate"), tools.WithDescription("delegates to the specialists"))
+
+	ctx := context.Background()
+	if err := manager.Run(ctx, schema.NewInput("hi"), new(schema.Output), nil); err != nil {
+		t.Fatalf("run manager failed: %v", err)
+	}
+	for range 2 {
+		out, err := tools.CallFunction(ctx, tool, `{"chat_message":"hi"}`)
+		if err != nil || !strings.Contains(out, `"chat_message":"2"`) {
+			t.Fatalf("expect every call run on fresh memories, got %s, %v", out, err)
+		}
+	}
+	if len(manager.Memory().List()) != 2 {
+		t.Errorf("expect manager memory untouched by delegations, got %d messages", len(manager.Memory().List()))
+	}
+	if usage := tool.Usage(); usage.InputTokens != 48 || usage.OutputTokens != 20 {
+		t.Errorf("expect usage summed across steps and calls, got %+v", usage)
+	}
+
+	isolation := memory.NewIsolation()
+	tool.SetIsolation(isolation)
+	for range 2 {
+		if _, err := tool.RunAnonymous(ctx, schema.NewInput("again")); err != nil {
+			t.Fatalf("run tool failed: %v", err)
+		}
+	}
+	// every step keeps its own history, so the second draft sees its former turn only
+	if got := []int{len(isolation.Memory(draft).List()), len(isolation.Memory(review).List())}; got[0] != 4 || got[1] != 4 {
+		t.Errorf("expect chain steps isolated from each other, got %v messages", got)
+	}
+	if msg := isolation.Memory(review).List()[3].Text; !strings.Contains(msg, `"4"`) {
+		t.Errorf("expect review answered on its own history, got %s", msg)
+	}
+	if _, err := tool.RunAnonymous(ctx, schema.NewString("hi")); err == nil {
+		t.Error("expect invalid tool input schema")
+	}
+}
@@ -0,0 +1,332 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/bububa/atomic-agents/agents"
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/embedder"
+	"github.com/bububa/atomic-agents/components/interceptor"
+	"github.com/bububa/atomic-agents/components/vectordb"
+	"github.com/bububa/atomic-agents/schema"
+)
+
+const (
+	// DefaultCollection is the default vectordb collection of cached answers
+	DefaultCollection = "semantic_cache"
+	// DefaultThreshold is the default minimum similarity of a cache hit
+	DefaultThreshold = 0.95
+	// DefaultNamespace is the namespace of agents without name
+	DefaultNamespace = "default"
+)
+
+// meta keys of cached records
+const (
+	namespaceMeta = "namespace"
+	createdAtMeta = "created_at"
+	outputMeta    = "output"
+)
+
+type bypassKey struct{}
+
+// WithBypass returns a context bypassing the cache, the agent runs without cache lookup nor store
+func WithBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassKey{}, true)
+}
+
+// IsBypassed reports whether the context bypasses the cache
+func IsBypassed(ctx context.Context) bool {
+	v, _ := ctx.Value(bypassKey{}).(bool)
+	return v
+}
+
+// Similarity returns the similarity of a searched record to the query vector, the higher the more similar
+type Similarity func(query []float64, record *vectordb.Record) float64
+
+type Options struct {
+	collection string
+	namespace  string
+	threshold  float64
+	ttl        time.Duration
+	topK       int
+	similarity Similarity
+	now        func() time.Time
+}
+
+type Option func(*Options)
+
+// WithCollection set the vectordb collection of cached answers
+func WithCollection(name string) Option {
+	return func(o *Options) {
+		o.collection = name
+	}
+}
+
+// WithNamespace set the namespace isolating cached answers of the agent, the agent name is used by default
+func WithNamespace(namespace string) Option {
+	return func(o *Options) {
+		o.namespace = namespace
+	}
+}
+
+// WithThreshold set the minimum similarity of a cache hit
+func WithThreshold(threshold float64) Option {
+	return func(o *Options) {
+		o.threshold = threshold
+	}
+}
+
+// WithTTL set the time to live of cached answers, answers never expire if not positive
+func WithTTL(ttl time.Duration) Option {
+	return func(o *Options) {
+		o.ttl = ttl
+	}
+}
+
+// WithTopK set the number of candidates searched for a hit
+func WithTopK(topK int) Option {
+	return func(o *Options) {
+		o.topK = topK
+	}
+}
+
+// WithSimilarity set the function computing the similarity of searched records
+func WithSimilarity(fn Similarity) Option {
+	return func(o *Options) {
+		o.similarity = fn
+	}
+}
+
+// SemanticCache wraps an agent, answers of semantically similar inputs are served from a vectordb.
+// The stringified input is embedded and searched in the namespace of the agent, a hit above the threshold
+// returns the stored output with zero usage, a miss runs the agent and stores its output.
+// A hit is added as a turn to the memory of agents with AddTurn, like agents.Agent, so the history keeps it.
+// Expired answers are deleted on lookup if the vectordb is a vectordb.Deleter, the search is widened past them otherwise.
+// Embedder usage is recorded into the context CostReport.
+type SemanticCache[I schema.Schema, O schema.Schema] struct {
+	interceptor.Stack
+	agent    agents.TypeableAgent[I, O]
+	embedder embedder.Embedder
+	vectordb vectordb.Engine
+	Options
+	hitHook func(context.Context, *SemanticCache[I, O], *I, *O, float64)
+}
+
+var (
+	_ agents.TypeableAgent[schema.String, schema.String] = (*SemanticCache[schema.String, schema.String])(nil)
+	_ agents.AnonymousAgent                              = (*SemanticCache[schema.String, schema.String])(nil)
+)
+
+// New returns a new SemanticCache wrapping the agent
+func New[I schema.Schema, O schema.Schema](agent agents.TypeableAgent[I, O], e embedder.Embedder, db vectordb.Engine, opts ...Option) *SemanticCache[I, O] {
+	ret := &SemanticCache[I, O]{
+		agent:    agent,
+		embedder: e,
+		vectordb: db,
+		Options: Options{
+			collection: DefaultCollection,
+			namespace:  agent.Name(),
+			threshold:  DefaultThreshold,
+			topK:       3,
+			similarity: CosineSimilarity,
+			now:        time.Now,
+		},
+	}
+	for _, opt := range opts {
+		opt(&ret.Options)
+	}
+	if ret.namespace == "" {
+		ret.namespace = DefaultNamespace
+	}
+	return ret
+}
+
+func (c *SemanticCache[I, O]) Name() string {
+	return c.agent.Name()
+}
+
+// Agent returns the wrapped agent
+func (c *SemanticCache[I, O]) Agent() agents.TypeableAgent[I, O] {
+	return c.agent
+}
+
+// SetHitHook set the hook called with the cached output and its similarity on cache hit
+func (c *SemanticCache[I, O]) SetHitHook(fn func(context.Context, *SemanticCache[I, O], *I, *O, float64)) {
+	c.hitHook = fn
+}
+
+// Run returns the cached answer of a similar input, or runs the agent and caches its answer
+func (c *SemanticCache[I, O]) Run(ctx context.Context, input *I, output *O, apiResp *components.LLMResponse) error {
+	call := &interceptor.Call{Kind: interceptor.AgentCall, Name: c.Name(), Target: c}
+	return interceptor.Typed(ctx, call, c.Interceptors(), input, output, apiResp, c.invoke)
+}
+
+func (c *SemanticCache[I, O]) invoke(ctx context.Context, input *I, output *O, apiResp *components.LLMResponse) error {
+	if apiResp == nil {
+		apiResp = new(components.LLMResponse)
+	}
+	if IsBypassed(ctx) {
+		return c.agent.Run(ctx, input, output, apiResp)
+	}
+	query := schema.Stringify(*input)
+	embedding := new(embedder.Embedding)
+	if err := c.embed(ctx, query, embedding); err != nil {
+		return err
+	}
+	if hit, score, err := c.lookup(ctx, embedding.Embedding, output); err != nil {
+		return err
+	} else if hit {
+		*apiResp = components.LLMResponse{Usage: new(components.LLMUsage)}
+		if v, ok := c.agent.(turnAdder[I, O]); ok {
+			if err := v.AddTurn(ctx, input, output); err != nil {
+				return err
+			}
+		}
+		if fn := c.hitHook; fn != nil {
+			fn(ctx, c, input, output, score)
+		}
+		return nil
+	}
+	if err := c.agent.Run(ctx, input, output, apiResp); err != nil {
+		return err
+	}
+	return c.store(ctx, embedding, output)
+}
+
+func (c *SemanticCache[I, O]) embed(ctx context.Context, query string, embedding *embedder.Embedding) error {
+	// embedders record their own cost
+	return c.embedder.Embed(ctx, query, embedding, new(components.LLMUsage))
+}
+
+// turnAdder is implemented by agents adding an answered turn to their memory, like agents.Agent
+type turnAdder[I schema.Schema, O schema.Schema] interface {
+	AddTurn(ctx context.Context, input *I, output *O) error
+}
+
+// lookup decodes the most similar unexpired answer above threshold into output.
+// Expired records are deleted if the vectordb is a vectordb.Deleter, and the search is widened past them
+// until a hit is found or the namespace is exhausted.
+func (c *SemanticCache[I, O]) lookup(ctx context.Context, vector []float64, output *O) (bool, float64, error) {
+	for topK := c.topK; ; {
+		records, err := c.vectordb.Search(ctx, vector,
+			vectordb.SearchWithCollection(c.collection),
+			vectordb.SearchWithTopK(topK),
+			vectordb.SearchWithMeta(map[string]string{namespaceMeta: c.namespace}),
+		)
+		if err != nil {
+			return false, 0, err
+		}
+		var (
+			best      *vectordb.Record
+			bestScore float64
+			expired   []string
+		)
+		for idx := range records {
+			record := &records[idx]
+			if c.expired(record) {
+				expired = append(expired, record.ID)
+				continue
+			}
+			if score := c.similarity(vector, record); score >= c.threshold && (best == nil || score > bestScore) {
+				best, bestScore = record, score
+			}
+		}
+		if deleter, ok := c.vectordb.(vectordb.Deleter); ok && len(expired) > 0 {
+			if err := deleter.Delete(ctx, c.collection, expired...); err != nil {
+				return false, 0, err
+			}
+		}
+		if best != nil {
+			if err := decodeOutput([]byte(best.Embedding.Meta[outputMeta]), output); err != nil {
+				return false, 0, err
+			}
+			return true, bestScore, nil
+		}
+		if len(expired) == 0 || len(records) < topK {
+			return false, 0, nil
+		}
+		topK += len(expired)
+	}
+}
+
+func (c *SemanticCache[I, O]) expired(record *vectordb.Record) bool {
+	if c.ttl <= 0 {
+		return false
+	}
+	createdAt, err := strconv.ParseInt(record.Embedding.Meta[createdAtMeta], 10, 64)
+	if err != nil {
+		return true
+	}
+	return c.now().Sub(time.Unix(createdAt, 0)) >= c.ttl
+}
+
+func (c *SemanticCache[I, O]) store(ctx context.Context, embedding *embedder.Embedding, output *O) error {
+	bs, err := encodeOutput(output)
+	if err != nil {
+		return err
+	}
+	embedding.Meta = map[string]string{
+		namespaceMeta: c.namespace,
+		createdAtMeta: strconv.FormatInt(c.now().Unix(), 10),
+		outputMeta:    string(bs),
+	}
+	return c.vectordb.Insert(ctx, c.collection, vectordb.Record{Embedding: *embedding})
+}
+
+// RunAnonymous runs the cached agent with the given user input for chain.
+func (c *SemanticCache[I, O]) RunAnonymous(ctx context.Context, input any, apiResp *components.LLMResponse) (any, error) {
+	in, ok := input.(*I)
+	if !ok {
+		return nil, errors.New("invalid agent input schema")
+	}
+	out := new(O)
+	if err := c.Run(ctx, in, out, apiResp); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// unmarshaler is implemented by schemas decoded from raw text, like schema.String
+type unmarshaler interface {
+	Unmarshal([]byte) error
+}
+
+func encodeOutput(output any) ([]byte, error) {
+	if _, ok := output.(unmarshaler); ok {
+		if v, ok := output.(schema.Stringer); ok {
+			return []byte(v.String()), nil
+		}
+	}
+	return json.Marshal(output)
+}
+
+func decodeOutput(bs []byte, output any) error {
+	if v, ok := output.(unmarshaler); ok {
+		return v.Unmarshal(bs)
+	}
+	return json.Unmarshal(bs, output)
+}
+
+// CosineSimilarity returns the cosine similarity of the query and the record embedding,
+// the record score is used if the vectordb does not return embeddings
+func CosineSimilarity(query []float64, record *vectordb.Record) float64 {
+	vector := record.Embedding.Embedding
+	if len(vector) == 0 || len(vector) != len(query) {
+		return record.Score
+	}
+	var dot, qNorm, vNorm float64
+	for idx, v := range vector {
+		dot += query[idx] * v
+		qNorm += query[idx] * query[idx]
+		vNorm += v * v
+	}
+	if qNorm == 0 || vNorm == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(qNorm) * math.Sqrt(vNorm))
+}
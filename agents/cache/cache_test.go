@@ -0,0 +1,136 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bububa/instructor-go"
+	openaiClt "github.com/bububa/instructor-go/instructors/openai"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+
+	"github.com/bububa/atomic-agents/agents"
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/embedder"
+	"github.com/bububa/atomic-agents/components/vectordb/engines/memory"
+	"github.com/bububa/atomic-agents/internal/llmtest"
+	"github.com/bububa/atomic-agents/schema"
+)
+
+// vowelEmbedder embeds text by its vowel counts
+type vowelEmbedder struct{}
+
+func (vowelEmbedder) Provider() embedder.Provider { return "test" }
+
+func (vowelEmbedder) Model() string { return "vowels" }
+
+func (e vowelEmbedder) Embed(_ context.Context, text string, embedding *embedder.Embedding, usage *components.LLMUsage) error {
+	vector := make([]float64, 5)
+	for _, r := range strings.ToLower(text) {
+		if idx := strings.IndexRune("aeiou", r); idx >= 0 {
+			vector[idx]++
+		}
+	}
+	embedding.Object = text
+	embedding.Embedding = vector
+	usage.InputTokens += 1
+	return nil
+}
+
+func (e vowelEmbedder) BatchEmbed(ctx context.Context, parts []string, usage *components.LLMUsage) ([]embedder.Embedding, error) {
+	ret := make([]embedder.Embedding, len(parts))
+	for idx, part := range parts {
+		e.Embed(ctx, part, &ret[idx], usage)
+	}
+	return ret, nil
+}
+
+func (vowelEmbedder) DotProduct(_ context.Context, a *embedder.Embedding, b *embedder.Embedding) (float64, error) {
+	return a.DotProduct(b)
+}
+
+// answerAgent answers with the number of runs
+type answerAgent struct {
+	runs int
+}
+
+func (a *answerAgent) Name() string { return "answer" }
+
+func (a *answerAgent) Run(_ context.Context, input *schema.String, output *schema.String, apiResp *components.LLMResponse) error {
+	a.runs++
+	*output = *schema.NewString(input.String() + " #" + string(rune('0'+a.runs)))
+	apiResp.Usage = &components.LLMUsage{InputTokens: 10, OutputTokens: 2}
+	return nil
+}
+
+func TestSemanticCache(t *testing.T) {
+	db, _ := memory.New()
+	agent := new(answerAgent)
+	now := time.Now()
+	c := New(agent, vowelEmbedder{}, db, WithTTL(time.Hour))
+	c.now = func() time.Time { return now }
+	var hits int
+	c.SetHitHook(func(context.Context, *SemanticCache[schema.String, schema.String], *schema.String, *schema.String, float64) {
+		hits++
+	})
+	run := func(ctx context.Context, q string) (string, *components.LLMUsage) {
+		output := new(schema.String)
+		apiResp := new(components.LLMResponse)
+		if err := c.Run(ctx, schema.NewString(q), output, apiResp); err != nil {
+			t.Fatalf("run cache failed: %v", err)
+		}
+		return output.String(), apiResp.Usage
+	}
+	ctx := context.Background()
+	if out, usage := run(ctx, "what is my balance"); out != "what is my balance #1" || usage.InputTokens != 10 {
+		t.Errorf("expect agent answer on miss, got %q, %+v", out, usage)
+	}
+	if out, usage := run(ctx, "What is my balance?"); out != "what is my balance #1" || usage.InputTokens != 0 || hits != 1 {
+		t.Errorf("expect cached answer with zero usage on similar input, got %q, %+v", out, usage)
+	}
+	if out, _ := run(ctx, "cancel subscription"); out != "cancel subscription #2" {
+		t.Errorf("expect agent answer on dissimilar input, got %q", out)
+	}
+	if out, _ := run(WithBypass(ctx), "what is my balance"); out != "what is my balance #3" {
+		t.Errorf("expect cache bypassed, got %q", out)
+	}
+	now = now.Add(2 * time.Hour)
+	if out, _ := run(ctx, "what is my balance"); out != "what is my balance #4" {
+		t.Errorf("expect expired answer refreshed, got %q", out)
+	}
+	col, _ := db.Collection(ctx, DefaultCollection)
+	if records := col.Records(); len(records) != 1 || records[0].Embedding.Object != "what is my balance" {
+		t.Errorf("expect expired answers deleted on lookup, got %d records", len(records))
+	}
+	other := New(agent, vowelEmbedder{}, db, WithNamespace("other"))
+	output := new(schema.String)
+	if err := other.Run(ctx, schema.NewString("cancel subscription"), output, nil); err != nil || output.String() != "cancel subscription #5" {
+		t.Errorf("expect namespaces isolated, got %q, %v", output.String(), err)
+	}
+}
+
+func TestSemanticCacheMemory(t *testing.T) {
+	srv := llmtest.NewServer(t, llmtest.Text(`{"chat_message":"42"}`))
+	clt := openai.NewClient(option.WithBaseURL(srv.URL), option.WithAPIKey("test"), option.WithMaxRetries(0))
+	agent := agents.NewAgent[schema.Input, schema.Output](
+		agents.WithClient(openaiClt.New(&clt, instructor.WithMode(instructor.ModeJSON), instructor.WithMaxRetries(0))),
+		agents.WithModel(llmtest.Model),
+	)
+	db, _ := memory.New()
+	c := New(agent, vowelEmbedder{}, db)
+	ctx := context.Background()
+	for _, q := range []string{"what is my balance", "What is my balance?"} {
+		if err := c.Run(ctx, schema.NewInput(q), new(schema.Output), nil); err != nil {
+			t.Fatalf("run cache failed: %v", err)
+		}
+	}
+	if n := len(srv.Requests()); n != 1 {
+		t.Fatalf("expect second question answered from cache, got %d requests", n)
+	}
+	history := agent.Memory().List()
+	if len(history) != 4 || history[2].Role != instructor.UserRole || history[3].Role != instructor.AssistantRole || !strings.Contains(history[3].Text, "42") {
+		t.Errorf("expect cache hit added as a turn to the agent memory, got %+v", history)
+	}
+}
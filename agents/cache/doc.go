@@ -0,0 +1,2 @@
+// Package cache is a semantic response cache of agents
+package cache
@@ -5,16 +5,16 @@ import (
 	"errors"
 
 	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/interceptor"
+	"github.com/bububa/atomic-agents/components/tracing"
 	"github.com/bububa/atomic-agents/schema"
 )
 
 // Chain agents chain
 type Chain[I schema.Schema, O schema.Schema] struct {
-	name      string
-	agents    []AnonymousAgent
-	startHook func(context.Context, *Chain[I, O], *I)
-	endHook   func(context.Context, *Chain[I, O], *I, *O, []components.LLMResponse)
-	errorHook func(context.Context, *Chain[I, O], *I, []components.LLMResponse, error)
+	interceptor.Stack
+	name   string
+	agents []AnonymousAgent
 }
 
 // NewChain returns a new Chain instance
@@ -32,57 +32,117 @@ func (c *Chain[I, O]) SetName(name string) {
 	c.name = name
 }
 
+// SetStartHook sets the hook calling fn before every run or stream
+//
+// Deprecated: use Use with interceptor.Hooks or interceptor.Before, see interceptor.Stack SetHook
 func (c *Chain[I, O]) SetStartHook(fn func(context.Context, *Chain[I, O], *I)) {
-	c.startHook = fn
+	if fn == nil {
+		c.SetHook(interceptor.StartHook, nil)
+		return
+	}
+	c.SetHook(interceptor.StartHook, interceptor.Hooks[I, O](func(ctx context.Context, input *I) {
+		fn(ctx, c, input)
+	}, nil, nil))
 }
 
+// SetEndHook sets the hook calling fn with the step responses after every successful run or stream start
+//
+// Deprecated: use Use with interceptor.Hooks or interceptor.After, see interceptor.Stack SetHook
 func (c *Chain[I, O]) SetEndHook(fn func(context.Context, *Chain[I, O], *I, *O, []components.LLMResponse)) {
-	c.endHook = fn
+	if fn == nil {
+		c.SetHook(interceptor.EndHook, nil)
+		return
+	}
+	c.SetHook(interceptor.EndHook, interceptor.Hooks[I, O](nil, func(ctx context.Context, input *I, output *O, _ *components.LLMResponse) {
+		fn(ctx, c, input, output, stepResponses(ctx))
+	}, nil))
 }
 
+// SetErrorHook sets the hook calling fn with the step responses after every failed run or stream start
+//
+// Deprecated: use Use with interceptor.Hooks or interceptor.After, see interceptor.Stack SetHook
 func (c *Chain[I, O]) SetErrorHook(fn func(context.Context, *Chain[I, O], *I, []components.LLMResponse, error)) {
-	c.errorHook = fn
+	if fn == nil {
+		c.SetHook(interceptor.ErrorHook, nil)
+		return
+	}
+	c.SetHook(interceptor.ErrorHook, interceptor.Hooks[I, O](nil, nil, func(ctx context.Context, input *I, _ *components.LLMResponse, err error) {
+		fn(ctx, c, input, stepResponses(ctx), err)
+	}))
+}
+
+type stepResponsesKey struct{}
+
+// withStepResponses returns a context carrying the step responses of a chain call for its hooks
+func withStepResponses(ctx context.Context, apiRespList *[]components.LLMResponse) context.Context {
+	return context.WithValue(ctx, stepResponsesKey{}, apiRespList)
+}
+
+// stepResponses returns the step responses of the chain call in context
+func stepResponses(ctx context.Context) []components.LLMResponse {
+	if v, ok := ctx.Value(stepResponsesKey{}).(*[]components.LLMResponse); ok {
+		return *v
+	}
+	return nil
 }
 
 // Run runs the chat agents with the given user input synchronously.
 func (c *Chain[I, O]) Run(ctx context.Context, input *I, output *O) ([]components.LLMResponse, error) {
-	if fn := c.startHook; fn != nil {
-		fn(ctx, c, input)
-	}
+	var apiRespList []components.LLMResponse
+	ctx = withStepResponses(ctx, &apiRespList)
+	call := &interceptor.Call{Kind: interceptor.ChainCall, Name: c.name, Target: c}
+	err := interceptor.Typed(ctx, call, c.Interceptors(), input, output, new(components.LLMResponse), func(ctx context.Context, input *I, output *O, apiResp *components.LLMResponse) error {
+		var err error
+		apiRespList, err = c.invoke(ctx, input, output)
+		apiResp.Usage = sumUsage(apiRespList)
+		return err
+	})
+	return apiRespList, err
+}
+
+func (c *Chain[I, O]) invoke(ctx context.Context, input *I, output *O) ([]components.LLMResponse, error) {
+	ctx = components.WithCostScope(ctx, c.name)
+	ctx, span := tracing.Start(ctx, tracing.ChainRunSpan, tracing.ChainNameKey.String(c.name))
+	apiRespList, err := c.run(ctx, input, output)
+	span.SetUsage(sumUsage(apiRespList))
+	span.EndErr(err)
+	return apiRespList, err
+}
+
+func (c *Chain[I, O]) run(ctx context.Context, input *I, output *O) ([]components.LLMResponse, error) {
 	l := len(c.agents)
 	apiRespList := make([]components.LLMResponse, 0, l)
 	var (
 		in  any = input
 		out any
 	)
-	for _, agent := range c.agents {
+	for idx, agent := range c.agents {
 		apiResp := new(components.LLMResponse)
-		if ret, err := agent.RunAnonymous(ctx, in, apiResp); err != nil {
-			if fn := c.errorHook; fn != nil {
-				fn(ctx, c, input, apiRespList, err)
-			}
+		ret, err := runStep(ctx, idx, agent, in, apiResp)
+		if err != nil {
 			return apiRespList, err
-		} else {
-			in = ret
-			out = ret
 		}
+		in = ret
+		out = ret
 		apiRespList = append(apiRespList, *apiResp)
 	}
-	if outO, ok := out.(*O); !ok {
-		err := errors.New("invalid agent output schema")
-		if fn := c.errorHook; fn != nil {
-			fn(ctx, c, input, apiRespList, err)
-		}
-		return apiRespList, err
-	} else {
-		*output = *outO
-	}
-	if fn := c.endHook; fn != nil {
-		fn(ctx, c, input, output, apiRespList)
+	outO, ok := out.(*O)
+	if !ok {
+		return apiRespList, errors.New("invalid agent output schema")
 	}
+	*output = *outO
 	return apiRespList, nil
 }
 
+// runStep runs a chain step in a step span
+func runStep(ctx context.Context, idx int, agent AnonymousAgent, input any, apiResp *components.LLMResponse) (any, error) {
+	ctx, span := tracing.Start(ctx, tracing.ChainStepSpan, tracing.StepKey.Int(idx), tracing.AgentNameKey.String(agent.Name()))
+	ret, err := agent.RunAnonymous(ctx, input, apiResp)
+	span.SetResponse(apiResp)
+	span.EndErr(err)
+	return ret, err
+}
+
 // Run runs the chat agents with the given user input synchronously.
 func (c *Chain[I, O]) RunAnonymous(ctx context.Context, input any, apiResp *components.LLMResponse) (any, error) {
 	in, ok := input.(*I)
@@ -101,8 +161,7 @@ func (c *Chain[I, O]) RunAnonymous(ctx context.Context, input any, apiResp *comp
 		if apiResp.Usage == nil {
 			apiResp.Usage = new(components.LLMUsage)
 		}
-		apiResp.Usage.InputTokens = v.Usage.InputTokens
-		apiResp.Usage.OutputTokens = v.Usage.OutputTokens
+		apiResp.Usage.Merge(v.Usage)
 	}
 	return out, nil
 }
@@ -0,0 +1,198 @@
+package agents
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bububa/instructor-go"
+
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/interceptor"
+	"github.com/bububa/atomic-agents/components/tracing"
+	"github.com/bububa/atomic-agents/schema"
+)
+
+var (
+	_ StreamableAgent[schema.String, schema.String] = (*Chain[schema.String, schema.String])(nil)
+	_ AnonymousStreamableAgent                      = (*Chain[schema.String, schema.String])(nil)
+)
+
+// ChainProgress is a progress event of a Chain step running before the streamed last step
+type ChainProgress struct {
+	// Step is the index of the step in the chain
+	Step int
+	// Steps is the number of steps in the chain
+	Steps int
+	Agent AnonymousAgent
+	// Done is false when the step starts, true when the step completed
+	Done bool
+	// Output is the step output, set when the step completed
+	Output any
+	// Response is the step LLMResponse, set when the step completed
+	Response *components.LLMResponse
+}
+
+// Stream runs the steps before the last one synchronously, then streams the last step which must be an AnonymousStreamableAgent.
+// MergeResponse merges usage of every step and ends the chain stream span, call it once the stream is consumed.
+// Interceptors run around the start of the stream, see interceptor.Call Stream.
+func (c *Chain[I, O]) Stream(ctx context.Context, input *I) (<-chan instructor.StreamData, MergeResponse, error) {
+	var apiRespList []components.LLMResponse
+	ctx = withStepResponses(ctx, &apiRespList)
+	call := &interceptor.Call{Kind: interceptor.ChainCall, Name: c.name, Target: c}
+	s, err := interceptStream[I, O](ctx, call, c.Interceptors(), input, func(ctx context.Context, input *I) (*startedStream, error) {
+		var (
+			ch        <-chan instructor.StreamData
+			mergeResp MergeResponse
+			err       error
+		)
+		ch, mergeResp, apiRespList, err = c.invokeStream(ctx, input)
+		return &startedStream{ch: ch, mergeResp: mergeResp}, err
+	})
+	return s.ch, s.mergeResp, err
+}
+
+func (c *Chain[I, O]) invokeStream(ctx context.Context, input *I) (<-chan instructor.StreamData, MergeResponse, []components.LLMResponse, error) {
+	ctx = components.WithCostScope(ctx, c.name)
+	ctx, span := c.startStreamSpan(ctx)
+	ch, mergeResp, apiRespList, err := c.stream(ctx, input, nil)
+	if err != nil {
+		span.end(&components.LLMResponse{Usage: sumUsage(apiRespList)}, err)
+		return nil, mergeResp, apiRespList, err
+	}
+	return ch, span.mergeResponse(mergeResp), apiRespList, nil
+}
+
+// ProgressStream returns immediately, runs the steps before the last one in background while emitting ChainProgress
+// events on the progress channel, then streams the last step. The progress channel is closed before the last step streams,
+// failures are sent to the stream channel as instructor.ErrorStream data. Interceptors and the span are the ones of Stream.
+func (c *Chain[I, O]) ProgressStream(ctx context.Context, input *I) (<-chan ChainProgress, <-chan instructor.StreamData, MergeResponse, error) {
+	if len(c.agents) == 0 {
+		return nil, nil, nil, errors.New("empty chain")
+	}
+	call := &interceptor.Call{Kind: interceptor.ChainCall, Name: c.name, Target: c}
+	s, err := interceptStream[I, O](ctx, call, c.Interceptors(), input, func(ctx context.Context, input *I) (*startedStream, error) {
+		progress, ch, mergeResp := c.progressStream(ctx, input)
+		return &startedStream{ch: ch, progress: progress, mergeResp: mergeResp}, nil
+	})
+	return s.progress, s.ch, s.mergeResp, err
+}
+
+func (c *Chain[I, O]) progressStream(ctx context.Context, input *I) (<-chan ChainProgress, <-chan instructor.StreamData, MergeResponse) {
+	ctx = components.WithCostScope(ctx, c.name)
+	ctx, span := c.startStreamSpan(ctx)
+	var (
+		progress  = make(chan ChainProgress, 2*(len(c.agents)-1))
+		ch        = make(chan instructor.StreamData)
+		mergeFn   MergeResponse
+		usageList []components.LLMResponse
+	)
+	go func() {
+		defer close(ch)
+		stream, mergeResp, apiRespList, err := c.stream(ctx, input, progress)
+		mergeFn, usageList = mergeResp, apiRespList
+		if err != nil {
+			span.end(&components.LLMResponse{Usage: sumUsage(apiRespList)}, err)
+			select {
+			case ch <- instructor.StreamData{Type: instructor.ErrorStream, Err: err}:
+			case <-ctx.Done():
+			}
+			return
+		}
+		for v := range stream {
+			select {
+			case ch <- v:
+			case <-ctx.Done():
+				// drain the last step stream, so that its producer doesn't block on the abandoned channel
+				for range stream {
+				}
+				return
+			}
+		}
+	}()
+	// merge response should be called after the stream channel is closed
+	mergeResp := func(resp *components.LLMResponse) {
+		if mergeFn != nil {
+			mergeFn(resp)
+			return
+		}
+		resp.Usage = sumUsage(usageList)
+	}
+	return progress, ch, span.mergeResponse(mergeResp)
+}
+
+// startStreamSpan starts the chain stream span
+func (c *Chain[I, O]) startStreamSpan(ctx context.Context) (context.Context, *streamSpan) {
+	ctx, span := tracing.Start(ctx, tracing.ChainStreamSpan, tracing.ChainNameKey.String(c.name))
+	return ctx, &streamSpan{span: span}
+}
+
+// stream runs the steps before the last one and starts streaming the last step, closes progress if not nil
+func (c *Chain[I, O]) stream(ctx context.Context, input *I, progress chan<- ChainProgress) (<-chan instructor.StreamData, MergeResponse, []components.LLMResponse, error) {
+	closeProgress := func() {
+		if progress != nil {
+			close(progress)
+			progress = nil
+		}
+	}
+	defer closeProgress()
+	l := len(c.agents)
+	if l == 0 {
+		return nil, nil, nil, errors.New("empty chain")
+	}
+	last, ok := c.agents[l-1].(AnonymousStreamableAgent)
+	if !ok {
+		return nil, nil, nil, errors.New("chain last agent is not streamable")
+	}
+	apiRespList := make([]components.LLMResponse, 0, l)
+	mergeResp := func(resp *components.LLMResponse) {
+		resp.Usage = sumUsage(apiRespList)
+	}
+	var in any = input
+	for idx, agent := range c.agents[:l-1] {
+		if progress != nil {
+			progress <- ChainProgress{Step: idx, Steps: l, Agent: agent}
+		}
+		apiResp := new(components.LLMResponse)
+		ret, err := runStep(ctx, idx, agent, in, apiResp)
+		if err != nil {
+			return nil, mergeResp, apiRespList, err
+		}
+		apiRespList = append(apiRespList, *apiResp)
+		if progress != nil {
+			progress <- ChainProgress{Step: idx, Steps: l, Agent: agent, Done: true, Output: ret, Response: apiResp}
+		}
+		in = ret
+	}
+	closeProgress()
+	ch, lastMerge, err := last.StreamAnonymous(ctx, in)
+	merged := func(resp *components.LLMResponse) {
+		if lastMerge != nil {
+			lastMerge(resp)
+		}
+		usage := sumUsage(apiRespList)
+		usage.Merge(resp.Usage)
+		resp.Usage = usage
+	}
+	if err != nil {
+		return nil, merged, apiRespList, err
+	}
+	return ch, merged, apiRespList, nil
+}
+
+// StreamAnonymous streams the chain with the given user input for chain.
+func (c *Chain[I, O]) StreamAnonymous(ctx context.Context, input any) (<-chan instructor.StreamData, MergeResponse, error) {
+	in, ok := input.(*I)
+	if !ok {
+		return nil, nil, errors.New("invalid agent input schema")
+	}
+	return c.Stream(ctx, in)
+}
+
+// sumUsage sums usage of responses
+func sumUsage(list []components.LLMResponse) *components.LLMUsage {
+	usage := new(components.LLMUsage)
+	for _, v := range list {
+		usage.Merge(v.Usage)
+	}
+	return usage
+}
@@ -0,0 +1,168 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bububa/instructor-go"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/interceptor"
+	"github.com/bububa/atomic-agents/components/tracing"
+	"github.com/bububa/atomic-agents/components/tracing/otel"
+	"github.com/bububa/atomic-agents/schema"
+)
+
+// streamAgent is an AnonymousStreamableAgent streaming its string input word by word
+type streamAgent struct {
+	funcAgent
+}
+
+func (a *streamAgent) StreamAnonymous(_ context.Context, input any) (<-chan instructor.StreamData, MergeResponse, error) {
+	in, ok := input.(*schema.String)
+	if !ok {
+		return nil, nil, errors.New("invalid input schema")
+	}
+	words := strings.Fields(in.String())
+	ch := make(chan instructor.StreamData, len(words))
+	for _, w := range words {
+		ch <- instructor.StreamData{Type: instructor.ContentStream, Content: w}
+	}
+	close(ch)
+	return ch, func(resp *components.LLMResponse) {
+		resp.Usage = &components.LLMUsage{InputTokens: 5, OutputTokens: int64(len(words))}
+	}, nil
+}
+
+// unbufferedStreamAgent is an AnonymousStreamableAgent streaming its string input word by word on an unbuffered channel,
+// done is closed once every word was sent
+type unbufferedStreamAgent struct {
+	funcAgent
+	done chan struct{}
+}
+
+func (a *unbufferedStreamAgent) StreamAnonymous(_ context.Context, input any) (<-chan instructor.StreamData, MergeResponse, error) {
+	words := strings.Fields(input.(*schema.String).String())
+	ch := make(chan instructor.StreamData)
+	go func() {
+		defer close(a.done)
+		defer close(ch)
+		for _, w := range words {
+			ch <- instructor.StreamData{Type: instructor.ContentStream, Content: w}
+		}
+	}()
+	return ch, nil, nil
+}
+
+func TestChainProgressStream(t *testing.T) {
+	upper := &funcAgent{name: "upper", fn: func(_ context.Context, input any, apiResp *components.LLMResponse) (any, error) {
+		apiResp.Usage = &components.LLMUsage{InputTokens: 10, OutputTokens: 2}
+		return schema.NewString(strings.ToUpper(input.(*schema.String).String())), nil
+	}}
+	chain := NewChain[schema.String, schema.String](upper, upper, &streamAgent{funcAgent{name: "writer"}})
+	progress, ch, mergeResp, err := chain.ProgressStream(context.Background(), schema.NewString("hello chain"))
+	if err != nil {
+		t.Fatalf("stream chain failed: %v", err)
+	}
+	var events []ChainProgress
+	for v := range progress {
+		events = append(events, v)
+	}
+	var words []string
+	for v := range ch {
+		if v.Err != nil {
+			t.Fatalf("stream chain failed: %v", v.Err)
+		}
+		words = append(words, v.Content)
+	}
+	if len(events) != 4 || events[0].Done || !events[3].Done || events[3].Step != 1 || events[3].Steps != 3 {
+		t.Errorf("expect start and done events of intermediate steps, got %+v", events)
+	}
+	if strings.Join(words, " ") != "HELLO CHAIN" {
+		t.Errorf("expect last step streamed, got %v", words)
+	}
+	apiResp := new(components.LLMResponse)
+	mergeResp(apiResp)
+	if apiResp.Usage.InputTokens != 25 || apiResp.Usage.OutputTokens != 6 {
+		t.Errorf("expect usage merged across steps, got %+v", apiResp.Usage)
+	}
+
+	failing := &funcAgent{name: "failing", fn: func(context.Context, any, *components.LLMResponse) (any, error) {
+		return nil, errors.New("boom")
+	}}
+	_, ch, _, _ = NewChain[schema.String, schema.String](failing, &streamAgent{}).ProgressStream(context.Background(), schema.NewString("hi"))
+	if v := <-ch; v.Type != instructor.ErrorStream || v.Err == nil {
+		t.Errorf("expect step failure sent as error stream data, got %+v", v)
+	}
+}
+
+func TestChainProgressStreamCanceled(t *testing.T) {
+	writer := &unbufferedStreamAgent{funcAgent: funcAgent{name: "writer"}, done: make(chan struct{})}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, ch, _, err := NewChain[schema.String, schema.String](writer).ProgressStream(ctx, schema.NewString("one two three four"))
+	if err != nil {
+		t.Fatalf("stream chain failed: %v", err)
+	}
+	if v := <-ch; v.Content != "one" {
+		t.Fatalf("expect first word streamed, got %+v", v)
+	}
+	cancel()
+	select {
+	case <-writer.done:
+	case <-time.After(time.Second):
+		t.Fatal("expect last step stream drained after cancellation")
+	}
+	for range ch {
+	}
+}
+
+func TestChainStreamInterceptors(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tracing.SetTracer(otel.New(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))))
+	defer tracing.SetTracer(nil)
+	chain := NewChain[schema.String, schema.String](&streamAgent{funcAgent{name: "writer"}})
+	chain.SetName("writing")
+	var calls []interceptor.Call
+	chain.Use(interceptor.Before(func(_ context.Context, call *interceptor.Call, input any) (any, any, error) {
+		calls = append(calls, *call)
+		in := input.(*schema.String)
+		if in.String() == "cached" {
+			return input, schema.NewString("from cache"), nil
+		}
+		return schema.NewString(strings.ToUpper(in.String())), nil, nil
+	}))
+	collect := func(ch <-chan instructor.StreamData) string {
+		var words []string
+		for v := range ch {
+			words = append(words, v.Content)
+		}
+		return strings.Join(words, " ")
+	}
+	ch, mergeResp, err := chain.Stream(context.Background(), schema.NewString("hello chain"))
+	if err != nil {
+		t.Fatalf("stream chain failed: %v", err)
+	}
+	if got := collect(ch); got != "HELLO CHAIN" {
+		t.Errorf("expect intercepted input streamed, got %q", got)
+	}
+	mergeResp(new(components.LLMResponse))
+	if spans := exporter.GetSpans(); len(spans) != 1 || spans[0].Name != tracing.ChainStreamSpan {
+		t.Errorf("expect chain stream span ended on merge, got %+v", spans)
+	}
+	_, ch, _, err = chain.ProgressStream(context.Background(), schema.NewString("cached"))
+	if err != nil {
+		t.Fatalf("stream chain failed: %v", err)
+	}
+	if got := collect(ch); got != "from cache" {
+		t.Errorf("expect short-circuited output streamed as a whole, got %q", got)
+	}
+	if len(calls) != 2 || !calls[0].Stream || calls[0].Kind != interceptor.ChainCall || calls[0].Name != "writing" {
+		t.Errorf("expect stream calls intercepted, got %+v", calls)
+	}
+}
@@ -0,0 +1,354 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"github.com/bububa/atomic-agents/agents"
+	"github.com/bububa/atomic-agents/agents/rag"
+	"github.com/bububa/atomic-agents/components/vectordb"
+	"github.com/bububa/atomic-agents/schema"
+	"github.com/bububa/atomic-agents/tools"
+)
+
+var (
+	// ErrNotFound returns when a name is not found in the Document or the Set
+	ErrNotFound = errors.New("not found")
+	// ErrNotRegistered returns when a schema, provider, engine or tool type is not registered
+	ErrNotRegistered = errors.New("not registered")
+	// ErrCycle returns when chains refer to themselves
+	ErrCycle = errors.New("reference cycle")
+	// ErrTypeMismatch returns when a built agent is not of the requested type
+	ErrTypeMismatch = errors.New("type mismatch")
+)
+
+// Set holds the agents, chains, RAG pipelines and tool sets built from a Document
+type Set struct {
+	agents   map[string]agents.AnonymousAgent
+	toolsets map[string][]tools.Function
+}
+
+// Anonymous returns the agent, chain or RAG pipeline of the name
+func (s *Set) Anonymous(name string) (agents.AnonymousAgent, bool) {
+	v, ok := s.agents[name]
+	return v, ok
+}
+
+// Toolset returns the tool set of the name
+func (s *Set) Toolset(name string) ([]tools.Function, bool) {
+	v, ok := s.toolsets[name]
+	return v, ok
+}
+
+// Agent returns the agent of the name with I input and O output schemas
+func Agent[I schema.Schema, O schema.Schema](s *Set, name string) (*agents.Agent[I, O], error) {
+	return lookup[*agents.Agent[I, O]](s, name)
+}
+
+// Chain returns the chain of the name with I input and O output schemas
+func Chain[I schema.Schema, O schema.Schema](s *Set, name string) (*agents.Chain[I, O], error) {
+	return lookup[*agents.Chain[I, O]](s, name)
+}
+
+// RAG returns the RAG pipeline of the name with O output schema
+func RAG[O schema.Schema](s *Set, name string) (*rag.RAG[O], error) {
+	return lookup[*rag.RAG[O]](s, name)
+}
+
+func lookup[T agents.AnonymousAgent](s *Set, name string) (T, error) {
+	var zero T
+	v, ok := s.agents[name]
+	if !ok {
+		return zero, fmt.Errorf("agent %s %w", name, ErrNotFound)
+	}
+	ret, ok := v.(T)
+	if !ok {
+		return zero, fmt.Errorf("agent %s %w: %T is not %T", name, ErrTypeMismatch, v, zero)
+	}
+	return ret, nil
+}
+
+// Builder builds a Set from a Document
+type Builder struct {
+	registry *Registry
+	opts     []agents.Option
+}
+
+// NewBuilder returns a Builder resolving names with the registry, DefaultRegistry if nil
+func NewBuilder(registry *Registry) *Builder {
+	if registry == nil {
+		registry = DefaultRegistry
+	}
+	return &Builder{
+		registry: registry,
+	}
+}
+
+// SetAgentOptions set the options applied to every agent before its config, like WithPriceTable or WithMemoryManager
+func (b *Builder) SetAgentOptions(opts ...agents.Option) *Builder {
+	b.opts = opts
+	return b
+}
+
+// Build builds all the agents, chains, RAG pipelines and tool sets of the document
+func (b *Builder) Build(ctx context.Context, doc *Document) (*Set, error) {
+	st := &state{
+		Builder:  b,
+		doc:      doc,
+		set:      &Set{agents: make(map[string]agents.AnonymousAgent), toolsets: make(map[string][]tools.Function)},
+		building: make(map[string]struct{}),
+	}
+	for name := range doc.Toolsets {
+		if _, err := st.toolset(name); err != nil {
+			return nil, err
+		}
+	}
+	for _, names := range [][]string{keys(doc.Agents), keys(doc.Chains), keys(doc.RAGs)} {
+		for _, name := range names {
+			if _, err := st.anonymous(ctx, name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return st.set, nil
+}
+
+// Build builds the document with DefaultRegistry
+func Build(ctx context.Context, doc *Document) (*Set, error) {
+	return NewBuilder(nil).Build(ctx, doc)
+}
+
+// state is the state of a Build, agents are built once on their first reference
+type state struct {
+	*Builder
+	doc      *Document
+	set      *Set
+	building map[string]struct{}
+}
+
+func (s *state) anonymous(ctx context.Context, name string) (agents.AnonymousAgent, error) {
+	if v, ok := s.set.agents[name]; ok {
+		return v, nil
+	}
+	if _, ok := s.building[name]; ok {
+		return nil, fmt.Errorf("agent %s: %w", name, ErrCycle)
+	}
+	s.building[name] = struct{}{}
+	defer delete(s.building, name)
+	var (
+		ret agents.AnonymousAgent
+		err error
+	)
+	if cfg, ok := s.doc.Agents[name]; ok {
+		ret, err = s.agent(ctx, name, &cfg)
+	} else if cfg, ok := s.doc.Chains[name]; ok {
+		ret, err = s.chain(ctx, name, &cfg)
+	} else if cfg, ok := s.doc.RAGs[name]; ok {
+		ret, err = s.rag(ctx, name, &cfg)
+	} else {
+		return nil, fmt.Errorf("agent %s %w", name, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("agent %s: %w", name, err)
+	}
+	s.set.agents[name] = ret
+	return ret, nil
+}
+
+func (s *state) agent(ctx context.Context, name string, cfg *AgentConfig) (agents.AnonymousAgent, error) {
+	typ, err := s.agentType(cfg.Input, cfg.Output)
+	if err != nil {
+		return nil, err
+	}
+	clientCfg, ok := s.doc.Clients[cfg.Client]
+	if !ok {
+		return nil, fmt.Errorf("client %s %w", cfg.Client, ErrNotFound)
+	}
+	newClient, ok := s.registry.client(clientCfg.Provider)
+	if !ok {
+		return nil, fmt.Errorf("client provider %s %w", clientCfg.Provider, ErrNotRegistered)
+	}
+	clt, err := newClient(ctx, &clientCfg)
+	if err != nil {
+		return nil, err
+	}
+	opts := make([]agents.Option, 0, len(s.opts)+10)
+	opts = append(opts, s.opts...)
+	opts = append(opts,
+		agents.WithName(name),
+		agents.WithClient(clt),
+		agents.WithModel(cfg.Model),
+		agents.WithTopP(cfg.TopP),
+		agents.WithTopK(cfg.TopK),
+		agents.WithMaxTokens(cfg.MaxTokens),
+	)
+	if cfg.Temperature != nil {
+		opts = append(opts, agents.WithTemperature(*cfg.Temperature))
+	}
+	if cfg.SystemPrompt != nil {
+		generator, err := cfg.SystemPrompt.Generator()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, agents.WithSystemPromptGenerator(generator))
+	}
+	if cfg.Toolset != "" {
+		fns, err := s.toolset(cfg.Toolset)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, agents.WithTools(fns...))
+	}
+	if cfg.MaxToolIterations > 0 {
+		opts = append(opts, agents.WithMaxToolIterations(cfg.MaxToolIterations))
+	}
+	return typ.newAgent(opts...), nil
+}
+
+func (s *state) chain(ctx context.Context, name string, cfg *ChainConfig) (agents.AnonymousAgent, error) {
+	typ, err := s.agentType(cfg.Input, cfg.Output)
+	if err != nil {
+		return nil, err
+	}
+	steps := make([]agents.AnonymousAgent, 0, len(cfg.Steps))
+	for _, step := range cfg.Steps {
+		v, err := s.anonymous(ctx, step)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, v)
+	}
+	ret := typ.newChain(steps...)
+	if v, ok := ret.(interface{ SetName(string) }); ok {
+		v.SetName(name)
+	}
+	return ret, nil
+}
+
+func (s *state) rag(ctx context.Context, name string, cfg *RAGConfig) (agents.AnonymousAgent, error) {
+	agent, err := s.anonymous(ctx, cfg.Agent)
+	if err != nil {
+		return nil, err
+	}
+	agentCfg, ok := s.doc.Agents[cfg.Agent]
+	if !ok {
+		return nil, fmt.Errorf("rag agent %s %w", cfg.Agent, ErrNotFound)
+	}
+	output, err := s.schema(agentCfg.Output, reflect.TypeFor[schema.Output]())
+	if err != nil {
+		return nil, err
+	}
+	newEmbedder, ok := s.registry.embedder(cfg.Embedder.Provider)
+	if !ok {
+		return nil, fmt.Errorf("embedder provider %s %w", cfg.Embedder.Provider, ErrNotRegistered)
+	}
+	e, err := newEmbedder(ctx, &cfg.Embedder)
+	if err != nil {
+		return nil, err
+	}
+	newEngine, ok := s.registry.vectordb(cfg.VectorDB.Engine)
+	if !ok {
+		return nil, fmt.Errorf("vectordb engine %s %w", cfg.VectorDB.Engine, ErrNotRegistered)
+	}
+	engine, err := newEngine(ctx, &cfg.VectorDB)
+	if err != nil {
+		return nil, err
+	}
+	opts := []rag.Option{
+		rag.WithName(name),
+		rag.WithEmbedder(e),
+		rag.WithVectorDB(engine),
+	}
+	var searchOpts []vectordb.SearchOption
+	if cfg.Collection != "" {
+		searchOpts = append(searchOpts, vectordb.SearchWithCollection(cfg.Collection))
+	}
+	if cfg.TopK > 0 {
+		searchOpts = append(searchOpts, vectordb.SearchWithTopK(cfg.TopK))
+	}
+	if len(searchOpts) > 0 {
+		opts = append(opts, rag.WithSearchOptions(searchOpts...))
+	}
+	if cfg.EnhanceQueryAgent != "" {
+		v, err := s.anonymous(ctx, cfg.EnhanceQueryAgent)
+		if err != nil {
+			return nil, err
+		}
+		enhancer, ok := v.(agents.TypeableAgent[schema.String, schema.String])
+		if !ok {
+			return nil, fmt.Errorf("enhance query agent %s %w: input and output must be schema.String", cfg.EnhanceQueryAgent, ErrTypeMismatch)
+		}
+		opts = append(opts, rag.WithEhanceQueryAgent(enhancer))
+	}
+	ret, ok := output.newRAG(agent, opts...)
+	if !ok {
+		return nil, fmt.Errorf("rag agent %s %w: input must be schema.String", cfg.Agent, ErrTypeMismatch)
+	}
+	return ret, nil
+}
+
+func (s *state) toolset(name string) ([]tools.Function, error) {
+	if v, ok := s.set.toolsets[name]; ok {
+		return v, nil
+	}
+	cfgs, ok := s.doc.Toolsets[name]
+	if !ok {
+		return nil, fmt.Errorf("toolset %s %w", name, ErrNotFound)
+	}
+	ret := make([]tools.Function, 0, len(cfgs))
+	for idx := range cfgs {
+		newTool, ok := s.registry.tool(cfgs[idx].Type)
+		if !ok {
+			return nil, fmt.Errorf("toolset %s: tool %s %w", name, cfgs[idx].Type, ErrNotRegistered)
+		}
+		fn, err := newTool(&cfgs[idx])
+		if err != nil {
+			return nil, fmt.Errorf("toolset %s: tool %s: %w", name, cfgs[idx].Type, err)
+		}
+		ret = append(ret, fn)
+	}
+	s.set.toolsets[name] = ret
+	return ret, nil
+}
+
+// agentType returns the registered agent type of the input and output schema names, schema.Input and schema.Output if empty
+func (s *state) agentType(input string, output string) (*agentType, error) {
+	in, err := s.schema(input, reflect.TypeFor[schema.Input]())
+	if err != nil {
+		return nil, err
+	}
+	out, err := s.schema(output, reflect.TypeFor[schema.Output]())
+	if err != nil {
+		return nil, err
+	}
+	typ, ok := s.registry.agent(in, out)
+	if !ok {
+		return nil, fmt.Errorf("agent of %s input and %s output %w", in.name, out.name, ErrNotRegistered)
+	}
+	return typ, nil
+}
+
+// schema returns the registered schema of the name, the schema of defaultType if empty
+func (s *state) schema(name string, defaultType reflect.Type) (*schemaType, error) {
+	if name == "" {
+		name, _ = s.registry.schemaName(defaultType)
+	}
+	v, ok := s.registry.schema(name)
+	if !ok {
+		return nil, fmt.Errorf("schema %s %w", name, ErrNotRegistered)
+	}
+	return v, nil
+}
+
+// keys returns the keys of m in sorted order, so that builds are deterministic
+func keys[T any](m map[string]T) []string {
+	ret := make([]string, 0, len(m))
+	for k := range m {
+		ret = append(ret, k)
+	}
+	slices.Sort(ret)
+	return ret
+}
@@ -0,0 +1,228 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/bububa/instructor-go"
+	"github.com/bububa/instructor-go/instructors"
+	cohereClient "github.com/cohere-ai/cohere-go/v2/client"
+	cohereOption "github.com/cohere-ai/cohere-go/v2/option"
+	anthropic "github.com/liushuangls/go-anthropic/v2"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/philippgille/chromem-go"
+	gemini "google.golang.org/genai"
+
+	"github.com/bububa/atomic-agents/components/embedder"
+	cohereEmbedder "github.com/bububa/atomic-agents/components/embedder/providers/cohere"
+	"github.com/bububa/atomic-agents/components/embedder/providers/huggingface"
+	openaiEmbedder "github.com/bububa/atomic-agents/components/embedder/providers/openai"
+	"github.com/bububa/atomic-agents/components/embedder/providers/voyageai"
+	"github.com/bububa/atomic-agents/components/vectordb"
+	chromemEngine "github.com/bububa/atomic-agents/components/vectordb/engines/chromem"
+	"github.com/bububa/atomic-agents/components/vectordb/engines/memory"
+	"github.com/bububa/atomic-agents/tools"
+	"github.com/bububa/atomic-agents/tools/calculator"
+	"github.com/bububa/atomic-agents/tools/searxng"
+)
+
+func registerBuiltins(r *Registry) {
+	r.RegisterClient(instructor.ProviderOpenAI, newOpenAIClient).
+		RegisterClient(instructor.ProviderAnthropic, newAnthropicClient).
+		RegisterClient(instructor.ProviderCohere, newCohereClient).
+		RegisterClient(instructor.ProviderGemini, newGeminiClient).
+		RegisterEmbedder(embedder.ProviderOpenAI, newOpenAIEmbedder).
+		RegisterEmbedder(embedder.ProviderCohere, newCohereEmbedder).
+		RegisterEmbedder(embedder.ProviderVoyageAI, newVoyageAIEmbedder).
+		RegisterEmbedder(embedder.ProviderHuggingFace, newHuggingFaceEmbedder).
+		RegisterVectorDB(string(vectordb.Memory), newMemoryEngine).
+		RegisterVectorDB(string(vectordb.Chromem), newChromemEngine).
+		RegisterTool("calculator", newCalculatorTool).
+		RegisterTool("searxng", newSearxngTool)
+}
+
+// apiKey returns the literal API key, or the key in the env variable, or the key in the default env variable
+func apiKey(key string, env string, defaultEnv string) string {
+	if key != "" {
+		return key
+	}
+	if env == "" {
+		env = defaultEnv
+	}
+	return os.Getenv(env)
+}
+
+// instructorOptions returns the instructor options of the client config
+func instructorOptions(cfg *ClientConfig) []instructor.Option {
+	opts := make([]instructor.Option, 0, 3)
+	if mode := cfg.Mode; mode != "" {
+		if !strings.HasSuffix(mode, "_mode") {
+			mode += "_mode"
+		}
+		opts = append(opts, instructor.WithMode(mode))
+	}
+	if cfg.MaxRetries != nil {
+		opts = append(opts, instructor.WithMaxRetries(*cfg.MaxRetries))
+	}
+	if cfg.Validation {
+		opts = append(opts, instructor.WithValidation())
+	}
+	return opts
+}
+
+func newOpenAIClient(_ context.Context, cfg *ClientConfig) (instructor.Instructor, error) {
+	opts := []option.RequestOption{option.WithAPIKey(apiKey(cfg.APIKey, cfg.APIKeyEnv, "OPENAI_API_KEY"))}
+	if cfg.BaseURL != "" {
+		opts = append(opts, option.WithBaseURL(cfg.BaseURL))
+	}
+	clt := openai.NewClient(opts...)
+	return instructors.FromOpenAI(&clt, instructorOptions(cfg)...), nil
+}
+
+func newAnthropicClient(_ context.Context, cfg *ClientConfig) (instructor.Instructor, error) {
+	var opts []anthropic.ClientOption
+	if cfg.BaseURL != "" {
+		opts = append(opts, anthropic.WithBaseURL(cfg.BaseURL))
+	}
+	clt := anthropic.NewClient(apiKey(cfg.APIKey, cfg.APIKeyEnv, "ANTHROPIC_API_KEY"), opts...)
+	return instructors.FromAnthropic(clt, instructorOptions(cfg)...), nil
+}
+
+func newCohereClient(_ context.Context, cfg *ClientConfig) (instructor.Instructor, error) {
+	opts := []cohereOption.RequestOption{cohereOption.WithToken(apiKey(cfg.APIKey, cfg.APIKeyEnv, "COHERE_API_KEY"))}
+	if cfg.BaseURL != "" {
+		opts = append(opts, cohereOption.WithBaseURL(cfg.BaseURL))
+	}
+	return instructors.FromCohere(cohereClient.NewClient(opts...), instructorOptions(cfg)...), nil
+}
+
+func newGeminiClient(ctx context.Context, cfg *ClientConfig) (instructor.Instructor, error) {
+	clt, err := gemini.NewClient(ctx, &gemini.ClientConfig{
+		APIKey:      apiKey(cfg.APIKey, cfg.APIKeyEnv, "GEMINI_API_KEY"),
+		Backend:     gemini.BackendGeminiAPI,
+		HTTPOptions: gemini.HTTPOptions{BaseURL: cfg.BaseURL},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return instructors.FromGemini(clt, instructorOptions(cfg)...), nil
+}
+
+func newOpenAIEmbedder(_ context.Context, cfg *EmbedderConfig) (embedder.Embedder, error) {
+	opts := []option.RequestOption{option.WithAPIKey(apiKey(cfg.APIKey, cfg.APIKeyEnv, "OPENAI_API_KEY"))}
+	if cfg.BaseURL != "" {
+		opts = append(opts, option.WithBaseURL(cfg.BaseURL))
+	}
+	clt := openai.NewClient(opts...)
+	return openaiEmbedder.New(&clt, embedderOptions(cfg)...), nil
+}
+
+func newCohereEmbedder(_ context.Context, cfg *EmbedderConfig) (embedder.Embedder, error) {
+	opts := []cohereOption.RequestOption{cohereOption.WithToken(apiKey(cfg.APIKey, cfg.APIKeyEnv, "COHERE_API_KEY"))}
+	if cfg.BaseURL != "" {
+		opts = append(opts, cohereOption.WithBaseURL(cfg.BaseURL))
+	}
+	return cohereEmbedder.New(cohereClient.NewClient(opts...), embedderOptions(cfg)...), nil
+}
+
+func newVoyageAIEmbedder(_ context.Context, cfg *EmbedderConfig) (embedder.Embedder, error) {
+	opts := []voyageai.Option{voyageai.WithAPIKey(apiKey(cfg.APIKey, cfg.APIKeyEnv, "VOYAGE_API_KEY"))}
+	if cfg.BaseURL != "" {
+		opts = append(opts, voyageai.WithBaseURL(cfg.BaseURL))
+	}
+	return voyageai.New(voyageai.NewClient(opts...), embedderOptions(cfg)...), nil
+}
+
+func newHuggingFaceEmbedder(_ context.Context, cfg *EmbedderConfig) (embedder.Embedder, error) {
+	opts := []huggingface.Option{huggingface.WithAPIKey(apiKey(cfg.APIKey, cfg.APIKeyEnv, "HUGGING_FACE_API_KEY"))}
+	if cfg.BaseURL != "" {
+		opts = append(opts, huggingface.WithBaseURL(cfg.BaseURL))
+	}
+	return huggingface.New(huggingface.NewClient(opts...), embedderOptions(cfg)...), nil
+}
+
+func embedderOptions(cfg *EmbedderConfig) []embedder.Option {
+	if cfg.Model == "" {
+		return nil
+	}
+	return []embedder.Option{embedder.WithModel(cfg.Model)}
+}
+
+func vectordbOptions(cfg *VectorDBConfig) []vectordb.Option {
+	opts := []vectordb.Option{
+		vectordb.WithTopK(cfg.TopK),
+		vectordb.WithMinScore(cfg.MinScore),
+		vectordb.WithHybrid(cfg.Hybrid),
+		vectordb.WithDimension(cfg.Dimension),
+	}
+	if len(cfg.Columns) > 0 {
+		opts = append(opts, vectordb.WithColumns(cfg.Columns...))
+	}
+	return opts
+}
+
+func newMemoryEngine(_ context.Context, cfg *VectorDBConfig) (vectordb.Engine, error) {
+	return memory.New(vectordbOptions(cfg)...)
+}
+
+func newChromemEngine(_ context.Context, cfg *VectorDBConfig) (vectordb.Engine, error) {
+	db := chromem.NewDB()
+	if cfg.Path != "" {
+		var err error
+		if db, err = chromem.NewPersistentDB(cfg.Path, false); err != nil {
+			return nil, err
+		}
+	}
+	return chromemEngine.New(db, vectordbOptions(cfg)...), nil
+}
+
+// toolOptions returns the title and description options of the tool config
+func toolOptions(cfg *ToolConfig) []tools.Option {
+	var opts []tools.Option
+	if cfg.Title != "" {
+		opts = append(opts, tools.WithTitle(cfg.Title))
+	}
+	if cfg.Description != "" {
+		opts = append(opts, tools.WithDescription(cfg.Description))
+	}
+	return opts
+}
+
+func newCalculatorTool(cfg *ToolConfig) (tools.Function, error) {
+	return tools.NewFunction[calculator.Input](calculator.New(toolOptions(cfg)...)), nil
+}
+
+func newSearxngTool(cfg *ToolConfig) (tools.Function, error) {
+	var params struct {
+		BaseURL    string `json:"base_url"`
+		Language   string `json:"language"`
+		MaxResults int    `json:"max_results"`
+	}
+	if err := decodeParams(cfg.Params, &params); err != nil {
+		return nil, err
+	}
+	tool := searxng.New(
+		searxng.WithBaseURL(params.BaseURL),
+		searxng.WithLanguage(params.Language),
+		searxng.WithMaxResults(params.MaxResults),
+	)
+	for _, opt := range toolOptions(cfg) {
+		opt(&tool.Config.Config)
+	}
+	return tools.NewFunction[searxng.Input](tool), nil
+}
+
+// decodeParams decodes engine or tool specific params into v
+func decodeParams(params map[string]any, v any) error {
+	if len(params) == 0 {
+		return nil
+	}
+	bs, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bs, v)
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	openaiClt "github.com/bububa/instructor-go/instructors/openai"
+
+	"github.com/bububa/atomic-agents/internal/llmtest"
+	"github.com/bububa/atomic-agents/schema"
+)
+
+const document = `
+clients:
+  openai:
+    provider: openai
+    mode: json
+    api_key: test
+    base_url: %s
+    max_retries: 2
+toolsets:
+  math:
+    - type: calculator
+      title: calc
+agents:
+  summarizer:
+    client: openai
+    model: gpt-test
+    temperature: 0.2
+    system_prompt:
+      type: cot
+      background: ["You summarize texts."]
+      steps: ["Read the text."]
+  answerer:
+    client: openai
+    model: gpt-test
+    input: schema.String
+    toolset: math
+    system_prompt:
+      type: simple
+      content: You answer questions with the context.
+chains:
+  pipeline:
+    steps: [summarizer]
+rags:
+  docs:
+    agent: answerer
+    embedder:
+      provider: openai
+      model: text-embedding-3-small
+    vectordb:
+      engine: memory
+    collection: docs
+    top_k: 3
+`
+
+type answer struct {
+	schema.Base
+	Answer string `json:"answer"`
+}
+
+func TestBuild(t *testing.T) {
+	srv := llmtest.NewServer(t, func(body map[string]any) llmtest.Reply {
+		if temperature, _ := body["temperature"].(float64); temperature != 0.2 {
+			t.Errorf("expect configured temperature, got %v", body["temperature"])
+		}
+		return llmtest.Reply{Content: `{"chat_message":"short"}`}
+	})
+	doc, err := ParseYAML([]byte(fmt.Sprintf(document, srv.URL)))
+	if err != nil {
+		t.Fatalf("parse document failed: %v", err)
+	}
+	set, err := Build(context.Background(), doc)
+	if err != nil {
+		t.Fatalf("build document failed: %v", err)
+	}
+
+	summarizer, err := Agent[schema.Input, schema.Output](set, "summarizer")
+	if err != nil {
+		t.Fatalf("lookup agent failed: %v", err)
+	}
+	if retries := summarizer.Client().MaxRetries(); retries != 2 {
+		t.Errorf("expect configured max retries 2, got %d", retries)
+	}
+	clt, err := newOpenAIClient(context.Background(), &ClientConfig{Provider: "openai", APIKey: "test"})
+	if defaultRetries := openaiClt.New(nil).MaxRetries(); err != nil || clt.MaxRetries() != defaultRetries {
+		t.Errorf("expect instructor default max retries %d if unset, got %d, %v", defaultRetries, clt.MaxRetries(), err)
+	}
+	if prompt := summarizer.SystemPrompt(); !strings.Contains(prompt, "You summarize texts.") || !strings.Contains(prompt, "Read the text.") {
+		t.Errorf("expect cot system prompt, got %q", prompt)
+	}
+	answerer, err := Agent[schema.String, schema.Output](set, "answerer")
+	if err != nil {
+		t.Fatalf("lookup agent failed: %v", err)
+	}
+	if fns := answerer.Tools(); len(fns) != 1 || fns[0].Title() != "calc" {
+		t.Errorf("expect toolset registered as native tools, got %d tools", len(fns))
+	}
+	if _, err := Agent[schema.String, schema.String](set, "answerer"); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("expect type mismatch, got %v", err)
+	}
+	if _, err := RAG[schema.Output](set, "docs"); err != nil {
+		t.Errorf("lookup rag failed: %v", err)
+	}
+
+	pipeline, err := Chain[schema.Input, schema.Output](set, "pipeline")
+	if err != nil {
+		t.Fatalf("lookup chain failed: %v", err)
+	}
+	output := new(schema.Output)
+	if _, err := pipeline.Run(context.Background(), schema.NewInput("a long text"), output); err != nil {
+		t.Fatalf("run chain failed: %v", err)
+	}
+	if output.ChatMessage != "short" {
+		t.Errorf("unexpected chain output: %+v", output)
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	registry := NewRegistry()
+	RegisterSchema[answer](registry, "Answer")
+	doc := &Document{
+		Clients: map[string]ClientConfig{"openai": {Provider: "openai", APIKey: "test"}},
+		Agents:  map[string]AgentConfig{"a": {Client: "openai", Output: "Answer"}},
+	}
+	if _, err := NewBuilder(registry).Build(context.Background(), doc); !errors.Is(err, ErrNotRegistered) {
+		t.Errorf("expect agent of unregistered schema pair, got %v", err)
+	}
+	RegisterAgent[schema.Input, answer](registry)
+	set, err := NewBuilder(registry).Build(context.Background(), doc)
+	if err != nil {
+		t.Fatalf("build document failed: %v", err)
+	}
+	if _, err := Agent[schema.Input, answer](set, "a"); err != nil {
+		t.Errorf("lookup agent of registered schema failed: %v", err)
+	}
+
+	doc.Agents["a"] = AgentConfig{Client: "openai", SystemPrompt: &PromptConfig{Type: "unknown"}}
+	if _, err := NewBuilder(registry).Build(context.Background(), doc); !errors.Is(err, ErrUnknownPrompt) {
+		t.Errorf("expect unknown prompt, got %v", err)
+	}
+	doc.Agents = nil
+	doc.Chains = map[string]ChainConfig{"loop": {Steps: []string{"loop"}}}
+	if _, err := NewBuilder(registry).Build(context.Background(), doc); !errors.Is(err, ErrCycle) {
+		t.Errorf("expect reference cycle, got %v", err)
+	}
+}
@@ -0,0 +1,5 @@
+// Package config builds agents, chains, RAG pipelines and tool sets from a declarative YAML or JSON Document.
+// The Document names clients with their provider, agents with their model, sampling params and system prompt generator
+// (cot, crispe, broke or simple), tool sets, embedders and vectordb engines. Input and output schemas are resolved from
+// a Registry of Go types, so prompts could be iterated without recompiling.
+package config
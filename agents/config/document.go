@@ -0,0 +1,170 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrUnknownFormat returns when the document file extension is neither JSON nor YAML
+var ErrUnknownFormat = errors.New("unknown document format")
+
+// Document is a declarative description of agents, chains, RAG pipelines and tool sets, all keyed by name
+type Document struct {
+	Clients  map[string]ClientConfig `json:"clients,omitempty" yaml:"clients,omitempty"`
+	Toolsets map[string][]ToolConfig `json:"toolsets,omitempty" yaml:"toolsets,omitempty"`
+	Agents   map[string]AgentConfig  `json:"agents,omitempty" yaml:"agents,omitempty"`
+	Chains   map[string]ChainConfig  `json:"chains,omitempty" yaml:"chains,omitempty"`
+	RAGs     map[string]RAGConfig    `json:"rags,omitempty" yaml:"rags,omitempty"`
+}
+
+// ClientConfig describes an instructor client, each agent gets its own client so that agents don't share memory
+type ClientConfig struct {
+	// Provider openai, anthropic, cohere, gemini or a provider registered with RegisterClient
+	Provider string `json:"provider" yaml:"provider"`
+	// Mode instructor mode like json, json_schema, tool_call or yaml, the instructor default if empty
+	Mode   string `json:"mode,omitempty" yaml:"mode,omitempty"`
+	APIKey string `json:"api_key,omitempty" yaml:"api_key,omitempty"`
+	// APIKeyEnv environment variable of the API key, the provider default like OPENAI_API_KEY if both empty
+	APIKeyEnv string `json:"api_key_env,omitempty" yaml:"api_key_env,omitempty"`
+	BaseURL   string `json:"base_url,omitempty" yaml:"base_url,omitempty"`
+	// MaxRetries the instructor default if empty, set values including 0 are applied
+	MaxRetries *int `json:"max_retries,omitempty" yaml:"max_retries,omitempty"`
+	Validation bool `json:"validation,omitempty" yaml:"validation,omitempty"`
+}
+
+// AgentConfig describes an agents.Agent
+type AgentConfig struct {
+	// Client name of the client in Document Clients
+	Client string `json:"client" yaml:"client"`
+	Model  string `json:"model,omitempty" yaml:"model,omitempty"`
+	// Temperature the provider default if empty
+	Temperature *float64 `json:"temperature,omitempty" yaml:"temperature,omitempty"`
+	TopP        float64  `json:"top_p,omitempty" yaml:"top_p,omitempty"`
+	TopK        int      `json:"top_k,omitempty" yaml:"top_k,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty" yaml:"max_tokens,omitempty"`
+	// Input registered name of the input schema, schema.Input if empty
+	Input string `json:"input,omitempty" yaml:"input,omitempty"`
+	// Output registered name of the output schema, schema.Output if empty
+	Output       string        `json:"output,omitempty" yaml:"output,omitempty"`
+	SystemPrompt *PromptConfig `json:"system_prompt,omitempty" yaml:"system_prompt,omitempty"`
+	// Toolset name of the tool set in Document Toolsets registered as LLM native tools
+	Toolset           string `json:"toolset,omitempty" yaml:"toolset,omitempty"`
+	MaxToolIterations int    `json:"max_tool_iterations,omitempty" yaml:"max_tool_iterations,omitempty"`
+}
+
+// PromptConfig describes a system prompt generator, fields are used by the generator of the Type
+type PromptConfig struct {
+	// Type cot, crispe, broke or simple
+	Type       string   `json:"type" yaml:"type"`
+	Background []string `json:"background,omitempty" yaml:"background,omitempty"`
+	// Content simple
+	Content string `json:"content,omitempty" yaml:"content,omitempty"`
+	// Steps, OutputInstructs cot
+	Steps           []string `json:"steps,omitempty" yaml:"steps,omitempty"`
+	OutputInstructs []string `json:"output_instructs,omitempty" yaml:"output_instructs,omitempty"`
+	// Capacities, Statements, Personalities, Experiments crispe
+	Capacities    []string `json:"capacities,omitempty" yaml:"capacities,omitempty"`
+	Statements    []string `json:"statements,omitempty" yaml:"statements,omitempty"`
+	Personalities []string `json:"personalities,omitempty" yaml:"personalities,omitempty"`
+	Experiments   []string `json:"experiments,omitempty" yaml:"experiments,omitempty"`
+	// Roles, Objectives, KeyResults, Evolves broke
+	Roles      []string `json:"roles,omitempty" yaml:"roles,omitempty"`
+	Objectives []string `json:"objectives,omitempty" yaml:"objectives,omitempty"`
+	KeyResults []string `json:"key_results,omitempty" yaml:"key_results,omitempty"`
+	Evolves    []string `json:"evolves,omitempty" yaml:"evolves,omitempty"`
+}
+
+// ChainConfig describes an agents.Chain
+type ChainConfig struct {
+	Input  string `json:"input,omitempty" yaml:"input,omitempty"`
+	Output string `json:"output,omitempty" yaml:"output,omitempty"`
+	// Steps names of agents, chains or RAG pipelines in order
+	Steps []string `json:"steps" yaml:"steps"`
+}
+
+// RAGConfig describes a rag.RAG, the output schema is the output of its agent
+type RAGConfig struct {
+	// Agent name of the agent answering with the retrieved context, its input must be schema.String
+	Agent string `json:"agent" yaml:"agent"`
+	// EnhanceQueryAgent name of the schema.String to schema.String agent rewriting queries, optional
+	EnhanceQueryAgent string         `json:"enhance_query_agent,omitempty" yaml:"enhance_query_agent,omitempty"`
+	Embedder          EmbedderConfig `json:"embedder" yaml:"embedder"`
+	VectorDB          VectorDBConfig `json:"vectordb" yaml:"vectordb"`
+	// Collection vectordb collection searched
+	Collection string `json:"collection,omitempty" yaml:"collection,omitempty"`
+	TopK       int    `json:"top_k,omitempty" yaml:"top_k,omitempty"`
+}
+
+// EmbedderConfig describes an embedder.Embedder
+type EmbedderConfig struct {
+	// Provider openai, cohere, voyageai, huggingface or a provider registered with RegisterEmbedder
+	Provider  string `json:"provider" yaml:"provider"`
+	Model     string `json:"model,omitempty" yaml:"model,omitempty"`
+	APIKey    string `json:"api_key,omitempty" yaml:"api_key,omitempty"`
+	APIKeyEnv string `json:"api_key_env,omitempty" yaml:"api_key_env,omitempty"`
+	BaseURL   string `json:"base_url,omitempty" yaml:"base_url,omitempty"`
+}
+
+// VectorDBConfig describes a vectordb.Engine
+type VectorDBConfig struct {
+	// Engine memory, chromem or an engine registered with RegisterVectorDB like milvus
+	Engine    string   `json:"engine" yaml:"engine"`
+	TopK      int      `json:"top_k,omitempty" yaml:"top_k,omitempty"`
+	MinScore  float64  `json:"min_score,omitempty" yaml:"min_score,omitempty"`
+	Dimension int      `json:"dimension,omitempty" yaml:"dimension,omitempty"`
+	Hybrid    bool     `json:"hybrid,omitempty" yaml:"hybrid,omitempty"`
+	Columns   []string `json:"columns,omitempty" yaml:"columns,omitempty"`
+	// Path chromem persistent db path, in memory if empty
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+	// Params engine specific params of registered engines
+	Params map[string]any `json:"params,omitempty" yaml:"params,omitempty"`
+}
+
+// ToolConfig describes a tool of a tool set
+type ToolConfig struct {
+	// Type calculator, searxng or a tool registered with RegisterTool
+	Type        string `json:"type" yaml:"type"`
+	Title       string `json:"title,omitempty" yaml:"title,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	// Params tool specific params
+	Params map[string]any `json:"params,omitempty" yaml:"params,omitempty"`
+}
+
+// LoadFile loads a Document from a .json, .yaml or .yml file
+func LoadFile(path string) (*Document, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return ParseJSON(bs)
+	case ".yaml", ".yml":
+		return ParseYAML(bs)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, path)
+}
+
+// ParseJSON parses a JSON Document
+func ParseJSON(bs []byte) (*Document, error) {
+	doc := new(Document)
+	if err := json.Unmarshal(bs, doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// ParseYAML parses a YAML Document
+func ParseYAML(bs []byte) (*Document, error) {
+	doc := new(Document)
+	if err := yaml.Unmarshal(bs, doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
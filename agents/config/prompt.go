@@ -0,0 +1,47 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bububa/atomic-agents/components/systemprompt"
+	"github.com/bububa/atomic-agents/components/systemprompt/broke"
+	"github.com/bububa/atomic-agents/components/systemprompt/cot"
+	"github.com/bububa/atomic-agents/components/systemprompt/crispe"
+	"github.com/bububa/atomic-agents/components/systemprompt/simple"
+)
+
+// ErrUnknownPrompt returns when the system prompt generator type is not one of cot, crispe, broke or simple
+var ErrUnknownPrompt = errors.New("unknown system prompt generator")
+
+// Generator returns the system prompt generator of the config
+func (c *PromptConfig) Generator() (systemprompt.Generator, error) {
+	switch strings.ToLower(c.Type) {
+	case "cot":
+		return cot.New(
+			cot.WithBackground(c.Background),
+			cot.WithSteps(c.Steps),
+			cot.WithOutputInstructs(c.OutputInstructs),
+		), nil
+	case "crispe":
+		return crispe.New(
+			crispe.WithBackground(c.Background),
+			crispe.WithCapacities(c.Capacities),
+			crispe.WithStatements(c.Statements),
+			crispe.WithPersonalities(c.Personalities),
+			crispe.WithExperiments(c.Experiments),
+		), nil
+	case "broke":
+		return broke.New(
+			broke.WithBackground(c.Background),
+			broke.WithRoles(c.Roles),
+			broke.WithObjectives(c.Objectives),
+			broke.WithKeyResults(c.KeyResults),
+			broke.WithEvolves(c.Evolves),
+		), nil
+	case "simple":
+		return simple.New(c.Content), nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownPrompt, c.Type)
+}
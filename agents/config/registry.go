@@ -0,0 +1,203 @@
+package config
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/bububa/instructor-go"
+
+	"github.com/bububa/atomic-agents/agents"
+	"github.com/bububa/atomic-agents/agents/rag"
+	"github.com/bububa/atomic-agents/components/embedder"
+	"github.com/bububa/atomic-agents/components/vectordb"
+	"github.com/bububa/atomic-agents/schema"
+	"github.com/bububa/atomic-agents/tools"
+)
+
+// ClientFactory creates an instructor client of a provider
+type ClientFactory func(ctx context.Context, cfg *ClientConfig) (instructor.Instructor, error)
+
+// EmbedderFactory creates an embedder of a provider
+type EmbedderFactory func(ctx context.Context, cfg *EmbedderConfig) (embedder.Embedder, error)
+
+// VectorDBFactory creates a vectordb engine
+type VectorDBFactory func(ctx context.Context, cfg *VectorDBConfig) (vectordb.Engine, error)
+
+// ToolFactory creates a tool registered as LLM native tool
+type ToolFactory func(cfg *ToolConfig) (tools.Function, error)
+
+// schemaType is a registered schema Go type
+type schemaType struct {
+	name string
+	typ  reflect.Type
+	// newRAG returns a rag.RAG of the schema as output
+	newRAG func(agent agents.AnonymousAgent, opts ...rag.Option) (agents.AnonymousAgent, bool)
+}
+
+// agentType builds agents and chains of a registered input and output schema pair
+type agentType struct {
+	newAgent func(opts ...agents.Option) agents.AnonymousAgent
+	newChain func(steps ...agents.AnonymousAgent) agents.AnonymousAgent
+}
+
+// Registry resolves the names of a Document into Go types and factories. Go generics are instantiated at compile time,
+// so agents and chains could only be built for input and output schema pairs registered with RegisterAgent.
+type Registry struct {
+	mu        sync.RWMutex
+	schemas   map[string]*schemaType
+	names     map[reflect.Type]string
+	agents    map[[2]reflect.Type]*agentType
+	clients   map[string]ClientFactory
+	embedders map[string]EmbedderFactory
+	vectordbs map[string]VectorDBFactory
+	tools     map[string]ToolFactory
+}
+
+// NewRegistry returns a Registry with the built-in clients, embedders, vectordb engines and tools,
+// and agents of schema.Input, schema.Output and schema.String
+func NewRegistry() *Registry {
+	r := &Registry{
+		schemas:   make(map[string]*schemaType),
+		names:     make(map[reflect.Type]string),
+		agents:    make(map[[2]reflect.Type]*agentType),
+		clients:   make(map[string]ClientFactory),
+		embedders: make(map[string]EmbedderFactory),
+		vectordbs: make(map[string]VectorDBFactory),
+		tools:     make(map[string]ToolFactory),
+	}
+	RegisterAgent[schema.Input, schema.Output](r)
+	RegisterAgent[schema.Input, schema.String](r)
+	RegisterAgent[schema.String, schema.Output](r)
+	RegisterAgent[schema.String, schema.String](r)
+	registerBuiltins(r)
+	return r
+}
+
+// DefaultRegistry is used by Builders without Registry
+var DefaultRegistry = NewRegistry()
+
+// RegisterSchema registers the schema type T by name, names default to the Go type name like schema.Input
+func RegisterSchema[T schema.Schema](r *Registry, name string) {
+	typ := reflect.TypeFor[T]()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if old, ok := r.names[typ]; ok && old != name {
+		delete(r.schemas, old)
+	}
+	r.names[typ] = name
+	r.schemas[name] = &schemaType{
+		name: name,
+		typ:  typ,
+		newRAG: func(agent agents.AnonymousAgent, opts ...rag.Option) (agents.AnonymousAgent, bool) {
+			v, ok := agent.(agents.TypeableAgent[schema.String, T])
+			if !ok {
+				return nil, false
+			}
+			return rag.NewRAG[T](v, opts...), true
+		},
+	}
+}
+
+// RegisterAgent registers the I input and O output schema pair of agents and chains,
+// the schemas not registered yet are registered by their Go type name
+func RegisterAgent[I schema.Schema, O schema.Schema](r *Registry) {
+	if _, ok := r.schemaName(reflect.TypeFor[I]()); !ok {
+		RegisterSchema[I](r, reflect.TypeFor[I]().String())
+	}
+	if _, ok := r.schemaName(reflect.TypeFor[O]()); !ok {
+		RegisterSchema[O](r, reflect.TypeFor[O]().String())
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.agents[[2]reflect.Type{reflect.TypeFor[I](), reflect.TypeFor[O]()}] = &agentType{
+		newAgent: func(opts ...agents.Option) agents.AnonymousAgent {
+			return agents.NewAgent[I, O](opts...)
+		},
+		newChain: func(steps ...agents.AnonymousAgent) agents.AnonymousAgent {
+			return agents.NewChain[I, O](steps...)
+		},
+	}
+}
+
+// RegisterClient registers the ClientFactory of a provider, provider names are case-insensitive
+func (r *Registry) RegisterClient(provider string, fn ClientFactory) *Registry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clients[strings.ToLower(provider)] = fn
+	return r
+}
+
+// RegisterEmbedder registers the EmbedderFactory of a provider, provider names are case-insensitive
+func (r *Registry) RegisterEmbedder(provider string, fn EmbedderFactory) *Registry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.embedders[strings.ToLower(provider)] = fn
+	return r
+}
+
+// RegisterVectorDB registers the VectorDBFactory of an engine, engine names are case-insensitive
+func (r *Registry) RegisterVectorDB(engine string, fn VectorDBFactory) *Registry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.vectordbs[strings.ToLower(engine)] = fn
+	return r
+}
+
+// RegisterTool registers the ToolFactory of a tool type, type names are case-insensitive
+func (r *Registry) RegisterTool(typ string, fn ToolFactory) *Registry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tools[strings.ToLower(typ)] = fn
+	return r
+}
+
+func (r *Registry) schemaName(typ reflect.Type) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	name, ok := r.names[typ]
+	return name, ok
+}
+
+func (r *Registry) schema(name string) (*schemaType, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	v, ok := r.schemas[name]
+	return v, ok
+}
+
+func (r *Registry) agent(input *schemaType, output *schemaType) (*agentType, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	v, ok := r.agents[[2]reflect.Type{input.typ, output.typ}]
+	return v, ok
+}
+
+func (r *Registry) client(provider string) (ClientFactory, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	v, ok := r.clients[strings.ToLower(provider)]
+	return v, ok
+}
+
+func (r *Registry) embedder(provider string) (EmbedderFactory, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	v, ok := r.embedders[strings.ToLower(provider)]
+	return v, ok
+}
+
+func (r *Registry) vectordb(engine string) (VectorDBFactory, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	v, ok := r.vectordbs[strings.ToLower(engine)]
+	return v, ok
+}
+
+func (r *Registry) tool(typ string) (ToolFactory, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	v, ok := r.tools[strings.ToLower(typ)]
+	return v, ok
+}
@@ -0,0 +1,24 @@
+package agents
+
+import "github.com/bububa/atomic-agents/components"
+
+// ErrorClass classifies errors returned by LLM calls, classes could be combined as flags
+type ErrorClass = components.ErrorClass
+
+const (
+	// SchemaError the response could not be decoded into the output schema or violated its constraints
+	SchemaError = components.SchemaError
+	// RateLimitError the provider rejected the request with rate limit
+	RateLimitError = components.RateLimitError
+	// TimeoutError the request timed out
+	TimeoutError = components.TimeoutError
+	// ServerError the provider failed with 5xx or overloaded
+	ServerError = components.ServerError
+	// NetworkError the request failed to reach the provider
+	NetworkError = components.NetworkError
+)
+
+// ClassifyError returns the ErrorClass of an error returned by LLM calls, returns 0 if the error is unknown
+func ClassifyError(err error) ErrorClass {
+	return components.ClassifyError(err)
+}
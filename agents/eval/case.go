@@ -0,0 +1,158 @@
+package eval
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/bububa/atomic-agents/schema"
+)
+
+// ErrUnknownFormat returns when the dataset file extension is neither JSONL nor YAML
+var ErrUnknownFormat = errors.New("unknown dataset format")
+
+// Case is an evaluation case, the expected output or the rubric is used by scorers
+type Case[I schema.Schema, O schema.Schema] struct {
+	ID       string
+	Input    *I
+	Expected *O
+	// Rubric describes what a good output is, used by judges
+	Rubric string
+	Tags   []string
+}
+
+// rawCase is a case decoded from a dataset, inputs and outputs are decoded into schemas afterwards
+type rawCase struct {
+	ID       string   `json:"id,omitempty" yaml:"id,omitempty"`
+	Input    any      `json:"input" yaml:"input"`
+	Expected any      `json:"expected,omitempty" yaml:"expected,omitempty"`
+	Rubric   string   `json:"rubric,omitempty" yaml:"rubric,omitempty"`
+	Tags     []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+}
+
+// LoadFile loads cases from a .jsonl, .json, .yaml or .yml dataset file, .json files hold a JSON array of cases
+func LoadFile[I schema.Schema, O schema.Schema](path string) ([]Case[I, O], error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl":
+		return LoadJSONL[I, O](f)
+	case ".json":
+		return LoadJSON[I, O](f)
+	case ".yaml", ".yml":
+		return LoadYAML[I, O](f)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, path)
+}
+
+// LoadJSONL loads cases from JSON lines, one case per line, blank lines are skipped
+func LoadJSONL[I schema.Schema, O schema.Schema](r io.Reader) ([]Case[I, O], error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	var (
+		ret  []Case[I, O]
+		line int
+	)
+	for scanner.Scan() {
+		line++
+		bs := bytes.TrimSpace(scanner.Bytes())
+		if len(bs) == 0 {
+			continue
+		}
+		var raw rawCase
+		if err := json.Unmarshal(bs, &raw); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		c, err := newCase[I, O](&raw, len(ret))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		ret = append(ret, c)
+	}
+	return ret, scanner.Err()
+}
+
+// LoadJSON loads cases from a JSON array
+func LoadJSON[I schema.Schema, O schema.Schema](r io.Reader) ([]Case[I, O], error) {
+	var raws []rawCase
+	if err := json.NewDecoder(r).Decode(&raws); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return newCases[I, O](raws)
+}
+
+// LoadYAML loads cases from a YAML list
+func LoadYAML[I schema.Schema, O schema.Schema](r io.Reader) ([]Case[I, O], error) {
+	var raws []rawCase
+	if err := yaml.NewDecoder(r).Decode(&raws); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return newCases[I, O](raws)
+}
+
+// newCases decodes the raw cases of a list
+func newCases[I schema.Schema, O schema.Schema](raws []rawCase) ([]Case[I, O], error) {
+	ret := make([]Case[I, O], 0, len(raws))
+	for idx := range raws {
+		c, err := newCase[I, O](&raws[idx], idx)
+		if err != nil {
+			return nil, fmt.Errorf("case %d: %w", idx+1, err)
+		}
+		ret = append(ret, c)
+	}
+	return ret, nil
+}
+
+// newCase decodes the raw case, the ID defaults to the 1-based case index
+func newCase[I schema.Schema, O schema.Schema](raw *rawCase, idx int) (Case[I, O], error) {
+	c := Case[I, O]{
+		ID:     raw.ID,
+		Input:  new(I),
+		Rubric: raw.Rubric,
+		Tags:   raw.Tags,
+	}
+	if c.ID == "" {
+		c.ID = strconv.Itoa(idx + 1)
+	}
+	if err := decodeValue(raw.Input, c.Input); err != nil {
+		return c, fmt.Errorf("decode input: %w", err)
+	}
+	if raw.Expected != nil {
+		c.Expected = new(O)
+		if err := decodeValue(raw.Expected, c.Expected); err != nil {
+			return c, fmt.Errorf("decode expected: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// unmarshaler is implemented by schemas decoded from raw text, like schema.String
+type unmarshaler interface {
+	Unmarshal([]byte) error
+}
+
+// decodeValue decodes a JSON or YAML value into target, strings are decoded as raw text by text schemas
+func decodeValue(v any, target any) error {
+	if s, ok := v.(string); ok {
+		if u, ok := target.(unmarshaler); ok {
+			return u.Unmarshal([]byte(s))
+		}
+	}
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bs, target)
+}
@@ -0,0 +1,8 @@
+// Package eval is the evaluation harness of agents.
+//
+// Cases, an input with an expected output or a rubric, are loaded from JSONL, JSON or YAML datasets and run by a Runner
+// over any TypeableAgent concurrently, every case and every judgement in a new memory.Isolation. Every output is scored
+// by Scorers: exact match, embedding similarity or a judge agent grading optimizer Metrics. A Report summarizes pass
+// rate, mean scores, usage and latency of a run, and Compare reports regressions and improvements between two runs,
+// like before and after a model upgrade.
+package eval
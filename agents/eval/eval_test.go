@@ -0,0 +1,162 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bububa/instructor-go"
+	openaiClt "github.com/bububa/instructor-go/instructors/openai"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+
+	"github.com/bububa/atomic-agents/agents"
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/optimizer"
+	"github.com/bububa/atomic-agents/internal/llmtest"
+	"github.com/bububa/atomic-agents/schema"
+)
+
+// answerAgent answers from a table
+type answerAgent struct {
+	name    string
+	answers map[string]string
+}
+
+func (a *answerAgent) Name() string { return a.name }
+
+func (a *answerAgent) Run(_ context.Context, input *schema.String, output *schema.String, apiResp *components.LLMResponse) error {
+	*output = *schema.NewString(a.answers[input.String()])
+	apiResp.Usage = &components.LLMUsage{InputTokens: 10, OutputTokens: 2, Cost: 0.01}
+	return nil
+}
+
+// lengthJudge scores outputs by their length
+type lengthJudge struct{}
+
+func (lengthJudge) Name() string { return "judge" }
+
+func (lengthJudge) Run(_ context.Context, input *JudgeInput, output *Judgement, apiResp *components.LLMResponse) error {
+	score := float64(min(len(input.Output), 20))
+	output.Score = score
+	output.Metrics = []optimizer.Metric{{Name: "Length", Value: score}}
+	apiResp.Usage = &components.LLMUsage{InputTokens: 5}
+	return nil
+}
+
+const dataset = `
+{"id":"capital","input":"capital of France","expected":"Paris"}
+
+{"id":"sum","input":"1+1","expected":"2","tags":["math"]}
+`
+
+const yamlDataset = `
+- input: capital of France
+  expected: Paris
+- input: describe Paris
+  rubric: mentions the Eiffel tower
+`
+
+func TestRunAndCompare(t *testing.T) {
+	cases, err := LoadJSONL[schema.String, schema.String](strings.NewReader(dataset))
+	if err != nil {
+		t.Fatalf("load jsonl failed: %v", err)
+	}
+	if len(cases) != 2 || cases[1].ID != "sum" || cases[1].Expected.String() != "2" || cases[1].Tags[0] != "math" {
+		t.Fatalf("unexpected cases: %+v", cases)
+	}
+	yamlCases, err := LoadYAML[schema.String, schema.String](strings.NewReader(yamlDataset))
+	if err != nil {
+		t.Fatalf("load yaml failed: %v", err)
+	}
+	if len(yamlCases) != 2 || yamlCases[0].ID != "1" || yamlCases[1].Expected != nil || yamlCases[1].Rubric == "" {
+		t.Fatalf("unexpected yaml cases: %+v", yamlCases)
+	}
+
+	run := func(name string, answers map[string]string) *Report {
+		agent := &answerAgent{name: name, answers: answers}
+		runner := NewRunner(name, agent,
+			NewExactMatch[schema.String, schema.String]().SetIgnoreCase(true),
+			NewJudge[schema.String, schema.String](lengthJudge{}, 0.05),
+		).SetConcurrency(2)
+		report, err := runner.Run(context.Background(), cases)
+		if err != nil {
+			t.Fatalf("run %s failed: %v", name, err)
+		}
+		return report
+	}
+	baseline := run("baseline", map[string]string{"capital of France": "Paris", "1+1": "3"})
+	candidate := run("candidate", map[string]string{"capital of France": "Lyon", "1+1": "2"})
+	if baseline.Summary.PassRate != 0.5 || baseline.Results[0].CaseID != "capital" || !baseline.Results[0].Passed {
+		t.Errorf("unexpected baseline summary: %+v", baseline.Summary)
+	}
+	if v := baseline.Summary.Scores["exact_match"]; v != 0.5 {
+		t.Errorf("expect mean exact match score 0.5, got %v", v)
+	}
+	if baseline.Summary.Usage.InputTokens != 30 {
+		t.Errorf("expect agent and judge usage summed to 30, got %d", baseline.Summary.Usage.InputTokens)
+	}
+	cmp := Compare(baseline, candidate)
+	if len(cmp.Regressions) != 1 || cmp.Regressions[0] != "capital" || len(cmp.Improvements) != 1 || cmp.Improvements[0] != "sum" {
+		t.Errorf("unexpected regressions %v, improvements %v", cmp.Regressions, cmp.Improvements)
+	}
+	if md := cmp.Markdown(); !strings.Contains(md, "| exact_match |") || !strings.Contains(md, "Regressions: capital") {
+		t.Errorf("unexpected markdown:\n%s", md)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cases.json")
+	if err := os.WriteFile(path, []byte(`[{"id":"capital","input":"capital of France","expected":"Paris"},{"input":"1+1"}]`), 0o644); err != nil {
+		t.Fatalf("write dataset failed: %v", err)
+	}
+	cases, err := LoadFile[schema.String, schema.String](path)
+	if err != nil {
+		t.Fatalf("load json failed: %v", err)
+	}
+	if len(cases) != 2 || cases[0].Expected.String() != "Paris" || cases[1].ID != "2" || cases[1].Expected != nil {
+		t.Errorf("unexpected cases: %+v", cases)
+	}
+}
+
+func TestRunIsolation(t *testing.T) {
+	srv := llmtest.NewServer(t, func(body map[string]any) llmtest.Reply {
+		return llmtest.Reply{Content: fmt.Sprintf(`{"chat_message":"%d"}`, len(llmtest.Messages(body)))}
+	})
+	judgeSrv := llmtest.NewServer(t, func(body map[string]any) llmtest.Reply {
+		return llmtest.Reply{Content: fmt.Sprintf(`{"metrics":[{"name":"History","value":%d}],"score":%d,"reasoning":"counted"}`, len(llmtest.Messages(body)), len(llmtest.Messages(body)))}
+	})
+	newClient := func(baseURL string) instructor.Instructor {
+		clt := openai.NewClient(option.WithBaseURL(baseURL), option.WithAPIKey("test"), option.WithMaxRetries(0))
+		return openaiClt.New(&clt, instructor.WithMode(instructor.ModeJSON), instructor.WithMaxRetries(0))
+	}
+	agent := agents.NewAgent[schema.Input, schema.Output](agents.WithClient(newClient(srv.URL)), agents.WithModel(llmtest.Model))
+	judge := NewJudgeAgent([]agents.Option{agents.WithClient(newClient(judgeSrv.URL)), agents.WithModel(llmtest.Model)}, nil)
+	cases := []Case[schema.Input, schema.Output]{
+		{ID: "1", Input: schema.NewInput("first")},
+		{ID: "2", Input: schema.NewInput("second")},
+		{ID: "3", Input: schema.NewInput("third")},
+	}
+	runner := NewRunner("isolated", agent, NewJudge[schema.Input, schema.Output](judge, 0)).SetConcurrency(1)
+	report, err := runner.Run(context.Background(), cases)
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	// every case and judgement is sent the system prompt and its own input only
+	for _, result := range report.Results {
+		if result.Error != "" || result.Output != report.Results[0].Output || result.Scores[0].Reasoning != "counted" ||
+			result.Scores[0].Metrics[0].Value != report.Results[0].Scores[0].Metrics[0].Value {
+			t.Errorf("expect isolated case %s, got output %q, scores %+v, error %s", result.CaseID, result.Output, result.Scores, result.Error)
+		}
+	}
+	if got := len(agent.Memory().List()); got != 0 {
+		t.Errorf("expect agent memory untouched, got %d messages", got)
+	}
+	if got := len(judge.Memory().List()); got != 0 {
+		t.Errorf("expect judge memory untouched, got %d messages", got)
+	}
+}
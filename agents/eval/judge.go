@@ -0,0 +1,104 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/bububa/atomic-agents/agents"
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/memory"
+	"github.com/bububa/atomic-agents/components/optimizer"
+	"github.com/bububa/atomic-agents/components/systemprompt/broke"
+	"github.com/bububa/atomic-agents/schema"
+)
+
+// JudgeInput is the input of a judge agent
+type JudgeInput struct {
+	schema.Base
+	Input    string `json:"input" jsonschema:"title=input,description=the input given to the evaluated agent"`
+	Expected string `json:"expected,omitempty" jsonschema:"title=expected,description=the reference output, could be empty"`
+	Output   string `json:"output" jsonschema:"title=output,description=the output of the evaluated agent"`
+	Rubric   string `json:"rubric,omitempty" jsonschema:"title=rubric,description=describes what a good output is, could be empty"`
+}
+
+// Judgement is the output of a judge agent
+type Judgement struct {
+	schema.Base
+	// Metrics grades the output per metric (0-20 scale)
+	Metrics []optimizer.Metric `json:"metrics" validate:"required,min=1" jsonschema:"title=metrics,description=grades of the output per metric"`
+	// Score is the overall score of the output (0-20 scale)
+	Score float64 `json:"score" validate:"min=0,max=20" jsonschema:"title=score,description=the overall score of the output (0-20 scale)"`
+	// Reasoning explains the score
+	Reasoning string `json:"reasoning" jsonschema:"title=reasoning,description=explains the score"`
+}
+
+// Judge scores outputs with a judge agent grading optimizer Metrics, the value is the overall score normalized from the 0-20 scale
+type Judge[I schema.Schema, O schema.Schema] struct {
+	agent     agents.TypeableAgent[JudgeInput, Judgement]
+	threshold float64
+}
+
+// NewJudge returns a new Judge scorer, outputs pass at or above the normalized threshold
+func NewJudge[I schema.Schema, O schema.Schema](agent agents.TypeableAgent[JudgeInput, Judgement], threshold float64) *Judge[I, O] {
+	return &Judge[I, O]{
+		agent:     agent,
+		threshold: threshold,
+	}
+}
+
+// NewJudgeAgent returns a judge agent grading outputs with the metrics
+func NewJudgeAgent(agentOpts []agents.Option, metrics []optimizer.Metric) *agents.Agent[JudgeInput, Judgement] {
+	bs, _ := json.Marshal(metrics)
+	opts := make([]agents.Option, 0, len(agentOpts)+1)
+	opts = append(opts, agentOpts...)
+	opts = append(opts, agents.WithSystemPromptGenerator(broke.New(
+		broke.WithBackground([]string{
+			"Grade the output of an AI agent for the given input.",
+			fmt.Sprintf("- Metrics: %s", string(bs)),
+		}),
+		broke.WithRoles([]string{
+			"- You are an impartial evaluator of AI agent outputs.",
+		}),
+		broke.WithObjectives([]string{
+			"- compare the output with the expected output if given.",
+			"- check the output against the rubric if given.",
+			"- grade every metric on a 0-20 scale with reasoning.",
+		}),
+		broke.WithKeyResults([]string{
+			"- metrics graded on a 0-20 scale.",
+			"- an overall score on a 0-20 scale with reasoning.",
+		}),
+	)))
+	return agents.NewAgent[JudgeInput, Judgement](opts...)
+}
+
+func (s *Judge[I, O]) Name() string {
+	return "judge"
+}
+
+func (s *Judge[I, O]) Score(ctx context.Context, c *Case[I, O], output *O) (Score, error) {
+	ret := Score{Scorer: s.Name()}
+	in := JudgeInput{
+		Input:  schema.Stringify(*c.Input),
+		Output: schema.Stringify(*output),
+		Rubric: c.Rubric,
+	}
+	if c.Expected != nil {
+		in.Expected = schema.Stringify(*c.Expected)
+	}
+	judgement := new(Judgement)
+	apiResp := new(components.LLMResponse)
+	// every judgement runs in a new memory.Isolation, so that judgements never see each other
+	err := s.agent.Run(memory.Isolate(ctx), &in, judgement, apiResp)
+	ret.Usage = apiResp.Usage
+	if err != nil {
+		return ret, err
+	}
+	ret.Value = math.Min(1, math.Max(0, judgement.Score/20))
+	ret.Passed = ret.Value >= s.threshold
+	ret.Metrics = judgement.Metrics
+	ret.Reasoning = judgement.Reasoning
+	return ret, nil
+}
@@ -0,0 +1,177 @@
+package eval
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bububa/atomic-agents/components"
+)
+
+// Result is the result of a case
+type Result struct {
+	CaseID string   `json:"case_id"`
+	Tags   []string `json:"tags,omitempty"`
+	// Output is the stringified agent output
+	Output string  `json:"output,omitempty"`
+	Scores []Score `json:"scores,omitempty"`
+	// Passed reports the agent succeeded and every scorer which scored the case passed it
+	Passed bool   `json:"passed"`
+	Error  string `json:"error,omitempty"`
+	// Usage is the agent usage, scorer usage is reported in scores
+	Usage   components.LLMUsage `json:"usage"`
+	Latency time.Duration       `json:"latency"`
+}
+
+// Summary summarizes the results of a run
+type Summary struct {
+	Cases    int     `json:"cases"`
+	Passed   int     `json:"passed"`
+	Errors   int     `json:"errors"`
+	PassRate float64 `json:"pass_rate"`
+	// Scores are the mean score values per scorer over scored cases
+	Scores map[string]float64 `json:"scores,omitempty"`
+	// Usage is the total usage of agents and scorers
+	Usage components.LLMUsage `json:"usage"`
+	// Latency is the mean agent latency
+	Latency time.Duration `json:"latency"`
+}
+
+// Report is the report of a run, it could be saved as JSON and compared with later runs
+type Report struct {
+	Name      string    `json:"name"`
+	StartedAt time.Time `json:"started_at"`
+	Results   []Result  `json:"results"`
+	Summary   Summary   `json:"summary"`
+}
+
+// Summarize computes the report summary from results
+func (r *Report) Summarize() {
+	summary := Summary{
+		Cases:  len(r.Results),
+		Scores: make(map[string]float64),
+	}
+	var (
+		latency time.Duration
+		scored  = make(map[string]int)
+	)
+	for idx := range r.Results {
+		result := &r.Results[idx]
+		if result.Passed {
+			summary.Passed++
+		}
+		if result.Error != "" {
+			summary.Errors++
+		}
+		latency += result.Latency
+		summary.Usage.Merge(&result.Usage)
+		for _, score := range result.Scores {
+			summary.Usage.Merge(score.Usage)
+			if score.Skipped {
+				continue
+			}
+			summary.Scores[score.Scorer] += score.Value
+			scored[score.Scorer]++
+		}
+	}
+	for name, n := range scored {
+		summary.Scores[name] /= float64(n)
+	}
+	if summary.Cases > 0 {
+		summary.PassRate = float64(summary.Passed) / float64(summary.Cases)
+		summary.Latency = latency / time.Duration(summary.Cases)
+	}
+	r.Summary = summary
+}
+
+// Delta compares a value of the baseline and candidate runs
+type Delta struct {
+	Baseline  float64 `json:"baseline"`
+	Candidate float64 `json:"candidate"`
+}
+
+// Diff returns the candidate value minus the baseline value
+func (d Delta) Diff() float64 {
+	return d.Candidate - d.Baseline
+}
+
+// Comparison compares a candidate run with a baseline run over their common cases
+type Comparison struct {
+	Baseline  string           `json:"baseline"`
+	Candidate string           `json:"candidate"`
+	PassRate  Delta            `json:"pass_rate"`
+	Scores    map[string]Delta `json:"scores,omitempty"`
+	Cost      Delta            `json:"cost"`
+	Latency   Delta            `json:"latency"`
+	// Regressions are the IDs of cases passed by the baseline and failed by the candidate
+	Regressions []string `json:"regressions,omitempty"`
+	// Improvements are the IDs of cases failed by the baseline and passed by the candidate
+	Improvements []string `json:"improvements,omitempty"`
+}
+
+// Compare compares the candidate report with the baseline report
+func Compare(baseline *Report, candidate *Report) *Comparison {
+	ret := &Comparison{
+		Baseline:  baseline.Name,
+		Candidate: candidate.Name,
+		PassRate:  Delta{Baseline: baseline.Summary.PassRate, Candidate: candidate.Summary.PassRate},
+		Scores:    make(map[string]Delta),
+		Cost:      Delta{Baseline: baseline.Summary.Usage.Cost, Candidate: candidate.Summary.Usage.Cost},
+		Latency:   Delta{Baseline: baseline.Summary.Latency.Seconds(), Candidate: candidate.Summary.Latency.Seconds()},
+	}
+	for name, v := range baseline.Summary.Scores {
+		d := ret.Scores[name]
+		d.Baseline = v
+		ret.Scores[name] = d
+	}
+	for name, v := range candidate.Summary.Scores {
+		d := ret.Scores[name]
+		d.Candidate = v
+		ret.Scores[name] = d
+	}
+	passed := make(map[string]bool, len(baseline.Results))
+	for _, result := range baseline.Results {
+		passed[result.CaseID] = result.Passed
+	}
+	for _, result := range candidate.Results {
+		basePassed, ok := passed[result.CaseID]
+		if !ok {
+			continue
+		}
+		if basePassed && !result.Passed {
+			ret.Regressions = append(ret.Regressions, result.CaseID)
+		} else if !basePassed && result.Passed {
+			ret.Improvements = append(ret.Improvements, result.CaseID)
+		}
+	}
+	return ret
+}
+
+// Markdown returns the comparison as a markdown table followed by regressions and improvements
+func (c *Comparison) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "| Metric | %s | %s | Delta |\n", c.Baseline, c.Candidate)
+	b.WriteString("| --- | --- | --- | --- |\n")
+	row := func(name string, d Delta) {
+		fmt.Fprintf(&b, "| %s | %.4f | %.4f | %+.4f |\n", name, d.Baseline, d.Candidate, d.Diff())
+	}
+	row("pass_rate", c.PassRate)
+	names := make([]string, 0, len(c.Scores))
+	for name := range c.Scores {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		row(name, c.Scores[name])
+	}
+	row("cost", c.Cost)
+	row("latency_seconds", c.Latency)
+	if len(c.Regressions) > 0 {
+		fmt.Fprintf(&b, "\nRegressions: %s\n", strings.Join(c.Regressions, ", "))
+	}
+	if len(c.Improvements) > 0 {
+		fmt.Fprintf(&b, "\nImprovements: %s\n", strings.Join(c.Improvements, ", "))
+	}
+	return b.String()
+}
@@ -0,0 +1,114 @@
+package eval
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bububa/atomic-agents/agents"
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/memory"
+	"github.com/bububa/atomic-agents/schema"
+)
+
+// Runner runs an agent over cases concurrently and scores the outputs
+type Runner[I schema.Schema, O schema.Schema] struct {
+	name        string
+	agent       agents.TypeableAgent[I, O]
+	scorers     []Scorer[I, O]
+	concurrency int
+	resultHook  func(context.Context, *Case[I, O], *Result)
+}
+
+// NewRunner returns a new Runner, name identifies the run in reports, like the evaluated model
+func NewRunner[I schema.Schema, O schema.Schema](name string, agent agents.TypeableAgent[I, O], scorers ...Scorer[I, O]) *Runner[I, O] {
+	return &Runner[I, O]{
+		name:    name,
+		agent:   agent,
+		scorers: scorers,
+	}
+}
+
+func (r *Runner[I, O]) Name() string {
+	return r.name
+}
+
+// SetConcurrency set the maximum number of cases running at the same time, unbounded if not positive
+func (r *Runner[I, O]) SetConcurrency(n int) *Runner[I, O] {
+	r.concurrency = n
+	return r
+}
+
+// SetResultHook set the hook called with the result of every case once scored
+func (r *Runner[I, O]) SetResultHook(fn func(context.Context, *Case[I, O], *Result)) {
+	r.resultHook = fn
+}
+
+// Run runs the cases and returns the report in cases order. Every case runs in a new memory.Isolation. Failed cases are reported in results,
+// the context error is returned with the partial report if the run is cancelled.
+func (r *Runner[I, O]) Run(ctx context.Context, cases []Case[I, O]) (*Report, error) {
+	report := &Report{
+		Name:      r.name,
+		StartedAt: time.Now(),
+		Results:   make([]Result, len(cases)),
+	}
+	limit := r.concurrency
+	if limit <= 0 || limit > len(cases) {
+		limit = len(cases)
+	}
+	var (
+		sem = make(chan struct{}, max(limit, 1))
+		wg  sync.WaitGroup
+	)
+	for idx := range cases {
+		wg.Add(1)
+		go func(c *Case[I, O], result *Result) {
+			defer wg.Done()
+			result.CaseID = c.ID
+			result.Tags = c.Tags
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				result.Error = ctx.Err().Error()
+				return
+			}
+			r.runCase(ctx, c, result)
+			if fn := r.resultHook; fn != nil {
+				fn(ctx, c, result)
+			}
+		}(&cases[idx], &report.Results[idx])
+	}
+	wg.Wait()
+	report.Summarize()
+	return report, ctx.Err()
+}
+
+// runCase runs the case in a new memory.Isolation, so that cases never see the history of each other or of the agent
+func (r *Runner[I, O]) runCase(ctx context.Context, c *Case[I, O], result *Result) {
+	output := new(O)
+	apiResp := new(components.LLMResponse)
+	start := time.Now()
+	err := r.agent.Run(memory.Isolate(ctx), c.Input, output, apiResp)
+	result.Latency = time.Since(start)
+	if apiResp.Usage != nil {
+		result.Usage = *apiResp.Usage
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return
+	}
+	result.Output = schema.Stringify(*output)
+	result.Passed = true
+	for _, scorer := range r.scorers {
+		score, err := scorer.Score(ctx, c, output)
+		if err != nil {
+			score.Scorer = scorer.Name()
+			result.Error = err.Error()
+		}
+		result.Scores = append(result.Scores, score)
+		if !score.Skipped && !score.Passed {
+			result.Passed = false
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/embedder"
+	"github.com/bububa/atomic-agents/components/optimizer"
+	"github.com/bububa/atomic-agents/schema"
+)
+
+// Score is the score of an output by a Scorer
+type Score struct {
+	Scorer string `json:"scorer"`
+	// Value is the normalized score in [0, 1]
+	Value  float64 `json:"value"`
+	Passed bool    `json:"passed"`
+	// Skipped reports the case could not be scored, like exact match without expected output
+	Skipped   bool                 `json:"skipped,omitempty"`
+	Metrics   []optimizer.Metric   `json:"metrics,omitempty"`
+	Reasoning string               `json:"reasoning,omitempty"`
+	Usage     *components.LLMUsage `json:"usage,omitempty"`
+}
+
+// Scorer scores the agent output of a case
+type Scorer[I schema.Schema, O schema.Schema] interface {
+	Name() string
+	Score(ctx context.Context, c *Case[I, O], output *O) (Score, error)
+}
+
+// ExactMatch scores 1 if the stringified output equals the expected output, ignoring surrounding spaces
+type ExactMatch[I schema.Schema, O schema.Schema] struct {
+	ignoreCase bool
+}
+
+// NewExactMatch returns a new ExactMatch scorer
+func NewExactMatch[I schema.Schema, O schema.Schema]() *ExactMatch[I, O] {
+	return new(ExactMatch[I, O])
+}
+
+// SetIgnoreCase set whether to compare case-insensitively
+func (s *ExactMatch[I, O]) SetIgnoreCase(ignoreCase bool) *ExactMatch[I, O] {
+	s.ignoreCase = ignoreCase
+	return s
+}
+
+func (s *ExactMatch[I, O]) Name() string {
+	return "exact_match"
+}
+
+func (s *ExactMatch[I, O]) Score(_ context.Context, c *Case[I, O], output *O) (Score, error) {
+	ret := Score{Scorer: s.Name()}
+	if c.Expected == nil {
+		ret.Skipped = true
+		return ret, nil
+	}
+	got := strings.TrimSpace(schema.Stringify(*output))
+	expected := strings.TrimSpace(schema.Stringify(*c.Expected))
+	if got == expected || s.ignoreCase && strings.EqualFold(got, expected) {
+		ret.Value, ret.Passed = 1, true
+	}
+	return ret, nil
+}
+
+// Similarity scores the cosine similarity of the output and expected output embeddings
+type Similarity[I schema.Schema, O schema.Schema] struct {
+	embedder  embedder.Embedder
+	threshold float64
+}
+
+// NewSimilarity returns a new Similarity scorer, outputs pass at or above the threshold
+func NewSimilarity[I schema.Schema, O schema.Schema](e embedder.Embedder, threshold float64) *Similarity[I, O] {
+	return &Similarity[I, O]{
+		embedder:  e,
+		threshold: threshold,
+	}
+}
+
+func (s *Similarity[I, O]) Name() string {
+	return "similarity"
+}
+
+func (s *Similarity[I, O]) Score(ctx context.Context, c *Case[I, O], output *O) (Score, error) {
+	ret := Score{Scorer: s.Name()}
+	if c.Expected == nil {
+		ret.Skipped = true
+		return ret, nil
+	}
+	usage := new(components.LLMUsage)
+	embeddings, err := s.embedder.BatchEmbed(ctx, []string{schema.Stringify(*output), schema.Stringify(*c.Expected)}, usage)
+	ret.Usage = usage
+	if err != nil {
+		return ret, err
+	}
+	if len(embeddings) != 2 {
+		return ret, errors.New("embedder returned unexpected number of embeddings")
+	}
+	ret.Value = cosine(embeddings[0].Embedding, embeddings[1].Embedding)
+	ret.Passed = ret.Value >= s.threshold
+	return ret, nil
+}
+
+func cosine(a []float64, b []float64) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, aNorm, bNorm float64
+	for idx, v := range a {
+		dot += v * b[idx]
+		aNorm += v * v
+		bNorm += b[idx] * b[idx]
+	}
+	if aNorm == 0 || bNorm == 0 {
+		return 0
+	}
+	return math.Max(0, dot/(math.Sqrt(aNorm)*math.Sqrt(bNorm)))
+}
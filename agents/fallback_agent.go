@@ -0,0 +1,368 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/bububa/instructor-go"
+
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/interceptor"
+	"github.com/bububa/atomic-agents/components/memory"
+	"github.com/bububa/atomic-agents/components/systemprompt"
+	"github.com/bububa/atomic-agents/schema"
+)
+
+// DefaultFallbackOn is the default error classes which make FallbackAgent fall over to the next backend
+const DefaultFallbackOn = RateLimitError | TimeoutError | ServerError | NetworkError
+
+// ErrNoBackend returns when FallbackAgent has no backend
+var ErrNoBackend = errors.New("no fallback backend")
+
+// Backend is an instructor client with the model served by it
+type Backend struct {
+	Client instructor.Instructor
+	Model  string
+}
+
+// FallbackAgent holds an ordered list of backends, and falls over to the next backend
+// when the current one fails with rate limit, timeout or server errors.
+// The backend which served the request is recorded in LLMResponse Provider and Model.
+type FallbackAgent[I schema.Schema, O schema.Schema] struct {
+	interceptor.Stack
+	name         string
+	backends     []Backend
+	agents       []*Agent[I, O]
+	fallbackOn   ErrorClass
+	fallbackHook func(context.Context, *FallbackAgent[I, O], *I, Backend, error)
+	// errs are the errors of backends which could not be added, returned by every run
+	errs []error
+}
+
+var (
+	_ TypeableAgent[schema.String, schema.String]   = (*FallbackAgent[schema.String, schema.String])(nil)
+	_ StreamableAgent[schema.String, schema.String] = (*FallbackAgent[schema.String, schema.String])(nil)
+	_ AnonymousAgent                                = (*FallbackAgent[schema.String, schema.String])(nil)
+	_ AnonymousStreamableAgent                      = (*FallbackAgent[schema.String, schema.String])(nil)
+	_ AgentSetter                                   = (*FallbackAgent[schema.String, schema.String])(nil)
+	_ MemoryManagerSetter                           = (*FallbackAgent[schema.String, schema.String])(nil)
+)
+
+// NewFallbackAgent returns a new FallbackAgent, options are applied to every backend.
+// Backends share the system prompt generator and the memory of the first backend, the turns of failed backends
+// are rolled back from the memory before falling over.
+func NewFallbackAgent[I schema.Schema, O schema.Schema](backends []Backend, options ...Option) *FallbackAgent[I, O] {
+	ret := &FallbackAgent[I, O]{
+		fallbackOn: DefaultFallbackOn,
+	}
+	for _, backend := range backends {
+		ret.AddBackend(backend, options...)
+	}
+	return ret
+}
+
+// AddBackend appends a backend to the end of the fallback list.
+// Every run fails with ErrCloneInstructor if the client of a backup could not be copied to share the memory of the primary backend.
+func (f *FallbackAgent[I, O]) AddBackend(backend Backend, options ...Option) *FallbackAgent[I, O] {
+	opts := make([]Option, 0, len(options)+2)
+	opts = append(opts, options...)
+	opts = append(opts, WithClient(backend.Client), WithModel(backend.Model))
+	agent := NewAgent[I, O](opts...)
+	if len(f.agents) > 0 {
+		primary := f.agents[0]
+		agent.SetSystemPromptGenerator(primary.systemPromptGenerator)
+		// the backup runs on a copy of the client, so that the memory of the caller's client stays untouched.
+		// Runs fail if the client could not be copied.
+		if mem := primary.Memory(); mem != nil {
+			clt, err := components.CloneInstructor(backend.Client)
+			if err != nil {
+				f.errs = append(f.errs, fmt.Errorf("fallback backend %s: %w", backend.Model, err))
+			} else {
+				clt.SetMemory(mem)
+				agent.SetClient(clt)
+			}
+		}
+	}
+	// backends share one memory in a memory.Isolation
+	agent.isolationKey = f
+	agent.SetName(f.name)
+	f.backends = append(f.backends, backend)
+	f.agents = append(f.agents, agent)
+	return f
+}
+
+// Backends returns the ordered backends
+func (f *FallbackAgent[I, O]) Backends() []Backend {
+	return f.backends
+}
+
+// SetFallbackOn set error classes which make the agent fall over to the next backend
+func (f *FallbackAgent[I, O]) SetFallbackOn(classes ErrorClass) *FallbackAgent[I, O] {
+	f.fallbackOn = classes
+	return f
+}
+
+func (f *FallbackAgent[I, O]) Name() string {
+	return f.name
+}
+
+func (f *FallbackAgent[I, O]) SetName(name string) {
+	f.name = name
+	for _, agent := range f.agents {
+		agent.SetName(name)
+	}
+}
+
+// SetClient replaces the client of the primary backend
+func (f *FallbackAgent[I, O]) SetClient(clt instructor.Instructor) {
+	if len(f.agents) == 0 {
+		return
+	}
+	f.backends[0].Client = clt
+	f.agents[0].SetClient(clt)
+}
+
+func (f *FallbackAgent[I, O]) SetMemory(m *instructor.Memory) {
+	for _, agent := range f.agents {
+		agent.SetMemory(m)
+	}
+}
+
+func (f *FallbackAgent[I, O]) SetSystemPromptGenerator(g systemprompt.Generator) {
+	for _, agent := range f.agents {
+		agent.SetSystemPromptGenerator(g)
+	}
+}
+
+// SetModel replaces the model of the primary backend
+func (f *FallbackAgent[I, O]) SetModel(model string) {
+	if len(f.agents) == 0 {
+		return
+	}
+	f.backends[0].Model = model
+	f.agents[0].SetModel(model)
+}
+
+func (f *FallbackAgent[I, O]) SetTemperature(temperature float64) {
+	for _, agent := range f.agents {
+		agent.SetTemperature(temperature)
+	}
+}
+
+func (f *FallbackAgent[I, O]) SetTopP(topP float64) {
+	for _, agent := range f.agents {
+		agent.SetTopP(topP)
+	}
+}
+
+func (f *FallbackAgent[I, O]) SetTopK(topK int) {
+	for _, agent := range f.agents {
+		agent.SetTopK(topK)
+	}
+}
+
+func (f *FallbackAgent[I, O]) SetMaxTokens(maxTokens int) {
+	for _, agent := range f.agents {
+		agent.SetMaxTokens(maxTokens)
+	}
+}
+
+func (f *FallbackAgent[I, O]) SetMemoryManager(m *memory.Manager) {
+	for _, agent := range f.agents {
+		agent.SetMemoryManager(m)
+	}
+}
+
+func (f *FallbackAgent[I, O]) SetMemoryStore(store memory.Store) {
+	for _, agent := range f.agents {
+		agent.SetMemoryStore(store)
+	}
+}
+
+// MemoryStore returns the memory Store of the primary agent, nil if none
+func (f *FallbackAgent[I, O]) MemoryStore() memory.Store {
+	if len(f.agents) == 0 {
+		return nil
+	}
+	return f.agents[0].MemoryStore()
+}
+
+// SetStartHook sets the hook calling fn before every run or stream start
+//
+// Deprecated: use Use with interceptor.Hooks or interceptor.Before, see interceptor.Stack SetHook
+func (f *FallbackAgent[I, O]) SetStartHook(fn func(context.Context, *FallbackAgent[I, O], *I)) {
+	if fn == nil {
+		f.SetHook(interceptor.StartHook, nil)
+		return
+	}
+	f.SetHook(interceptor.StartHook, interceptor.Hooks[I, O](func(ctx context.Context, input *I) {
+		fn(ctx, f, input)
+	}, nil, nil))
+}
+
+// SetEndHook sets the hook calling fn after every successful run or stream start
+//
+// Deprecated: use Use with interceptor.Hooks or interceptor.After, see interceptor.Stack SetHook
+func (f *FallbackAgent[I, O]) SetEndHook(fn func(context.Context, *FallbackAgent[I, O], *I, *O, *components.LLMResponse)) {
+	if fn == nil {
+		f.SetHook(interceptor.EndHook, nil)
+		return
+	}
+	f.SetHook(interceptor.EndHook, interceptor.Hooks[I, O](nil, func(ctx context.Context, input *I, output *O, apiResp *components.LLMResponse) {
+		fn(ctx, f, input, output, apiResp)
+	}, nil))
+}
+
+// SetErrorHook sets the hook calling fn after every failed run or stream start
+//
+// Deprecated: use Use with interceptor.Hooks or interceptor.After, see interceptor.Stack SetHook
+func (f *FallbackAgent[I, O]) SetErrorHook(fn func(context.Context, *FallbackAgent[I, O], *I, *components.LLMResponse, error)) {
+	if fn == nil {
+		f.SetHook(interceptor.ErrorHook, nil)
+		return
+	}
+	f.SetHook(interceptor.ErrorHook, interceptor.Hooks[I, O](nil, nil, func(ctx context.Context, input *I, apiResp *components.LLMResponse, err error) {
+		fn(ctx, f, input, apiResp, err)
+	}))
+}
+
+// SetFallbackHook set the hook called when a backend failed and the agent falls over to the next one
+func (f *FallbackAgent[I, O]) SetFallbackHook(fn func(context.Context, *FallbackAgent[I, O], *I, Backend, error)) {
+	f.fallbackHook = fn
+}
+
+// memory returns the memory shared by the backends in the run, the memory of the Isolation carried by the context if any
+func (f *FallbackAgent[I, O]) memory(ctx context.Context) *instructor.Memory {
+	if isolation := memory.IsolationFromContext(ctx); isolation != nil {
+		return isolation.Memory(f)
+	}
+	if len(f.agents) == 0 {
+		return nil
+	}
+	return f.agents[0].Memory()
+}
+
+// snapshot returns a func rolling the memory back to its current history
+func snapshot(mem *instructor.Memory) func() {
+	if mem == nil {
+		return func() {}
+	}
+	history := slices.Clone(mem.List())
+	return func() {
+		mem.Set(history)
+	}
+}
+
+// shouldFallback reports whether the agent should fall over to the next backend after err
+func (f *FallbackAgent[I, O]) shouldFallback(ctx context.Context, userInput *I, idx int, err error) bool {
+	if ctx.Err() != nil || idx == len(f.agents)-1 || !f.fallbackOn.Has(ClassifyError(err)) {
+		return false
+	}
+	if fn := f.fallbackHook; fn != nil {
+		fn(ctx, f, userInput, f.backends[idx], err)
+	}
+	return true
+}
+
+// Run runs the backends in order until one of them succeeds
+func (f *FallbackAgent[I, O]) Run(ctx context.Context, userInput *I, output *O, apiResp *components.LLMResponse) error {
+	call := &interceptor.Call{Kind: interceptor.AgentCall, Name: f.name, Target: f}
+	return interceptor.Typed(ctx, call, f.Interceptors(), userInput, output, apiResp, f.invoke)
+}
+
+func (f *FallbackAgent[I, O]) invoke(ctx context.Context, userInput *I, output *O, apiResp *components.LLMResponse) error {
+	if len(f.errs) > 0 {
+		return errors.Join(f.errs...)
+	}
+	if apiResp == nil {
+		apiResp = new(components.LLMResponse)
+	}
+	var (
+		usage = new(components.LLMUsage)
+		mem   = f.memory(ctx)
+		errs  []error
+	)
+	for idx, agent := range f.agents {
+		rollback := snapshot(mem)
+		resp := new(components.LLMResponse)
+		err := agent.Run(ctx, userInput, output, resp)
+		usage.Merge(resp.Usage)
+		*apiResp = *resp
+		apiResp.Usage = usage
+		if err == nil {
+			return nil
+		}
+		rollback()
+		// a failed backend could have decoded part of the output, the next backend starts on a fresh one
+		*output = *new(O)
+		errs = append(errs, err)
+		if !f.shouldFallback(ctx, userInput, idx, err) {
+			break
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	return ErrNoBackend
+}
+
+// Run runs the chat agent with the given user input for chain.
+func (f *FallbackAgent[I, O]) RunAnonymous(ctx context.Context, userInput any, apiResp *components.LLMResponse) (any, error) {
+	in, ok := userInput.(*I)
+	if !ok {
+		return nil, errors.New("invalid input schema")
+	}
+	out := new(O)
+	if err := f.Run(ctx, in, out, apiResp); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Stream streams from the backends in order until one of them starts streaming.
+// Only errors returned before streaming make the agent fall over to the next backend.
+// Interceptors run around the start of the stream, see interceptor.Call Stream.
+func (f *FallbackAgent[I, O]) Stream(ctx context.Context, userInput *I) (<-chan instructor.StreamData, MergeResponse, error) {
+	call := &interceptor.Call{Kind: interceptor.AgentCall, Name: f.name, Target: f}
+	s, err := interceptStream[I, O](ctx, call, f.Interceptors(), userInput, func(ctx context.Context, userInput *I) (*startedStream, error) {
+		ch, mergeResp, err := f.invokeStream(ctx, userInput)
+		return &startedStream{ch: ch, mergeResp: mergeResp}, err
+	})
+	return s.ch, s.mergeResp, err
+}
+
+func (f *FallbackAgent[I, O]) invokeStream(ctx context.Context, userInput *I) (<-chan instructor.StreamData, MergeResponse, error) {
+	if len(f.errs) > 0 {
+		return nil, nil, errors.Join(f.errs...)
+	}
+	var (
+		mem  = f.memory(ctx)
+		errs []error
+	)
+	for idx, agent := range f.agents {
+		rollback := snapshot(mem)
+		ch, mergeResp, err := agent.Stream(ctx, userInput)
+		if err == nil {
+			return ch, mergeResp, nil
+		}
+		rollback()
+		errs = append(errs, err)
+		if !f.shouldFallback(ctx, userInput, idx, err) {
+			break
+		}
+	}
+	if len(errs) > 0 {
+		return nil, nil, errors.Join(errs...)
+	}
+	return nil, nil, ErrNoBackend
+}
+
+func (f *FallbackAgent[I, O]) StreamAnonymous(ctx context.Context, userInput any) (<-chan instructor.StreamData, MergeResponse, error) {
+	in, ok := userInput.(*I)
+	if !ok {
+		return nil, nil, errors.New("invalid input schema")
+	}
+	return f.Stream(ctx, in)
+}
@@ -0,0 +1,116 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/bububa/instructor-go"
+	openaiClt "github.com/bububa/instructor-go/instructors/openai"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/memory"
+	"github.com/bububa/atomic-agents/internal/llmtest"
+	"github.com/bububa/atomic-agents/schema"
+)
+
+func TestFallbackAgent(t *testing.T) {
+	primary := startOpenAICompatibleServer(t, func(map[string]any) llmtest.Reply {
+		return llmtest.Reply{Status: http.StatusTooManyRequests}
+	})
+	backup := startOpenAICompatibleServer(t, replyMessageCount)
+	newClient := func(baseURL string) instructor.Instructor {
+		clt := openai.NewClient(option.WithBaseURL(baseURL), option.WithAPIKey("test"), option.WithMaxRetries(0))
+		return openaiClt.New(&clt, instructor.WithMode(instructor.ModeJSON), instructor.WithMaxRetries(0))
+	}
+	backupClient := newClient(backup.URL)
+	var fallbacks []Backend
+	agent := NewFallbackAgent[schema.Input, schema.Output]([]Backend{
+		{Client: newClient(primary.URL), Model: "primary-model"},
+		{Client: backupClient, Model: "backup-model"},
+	})
+	agent.SetFallbackHook(func(_ context.Context, _ *FallbackAgent[schema.Input, schema.Output], _ *schema.Input, backend Backend, err error) {
+		if ClassifyError(err) != RateLimitError {
+			t.Errorf("expect rate limit error, got %v", err)
+		}
+		fallbacks = append(fallbacks, backend)
+	})
+	run := func(ctx context.Context) (string, components.LLMResponse) {
+		output := new(schema.Output)
+		var apiResp components.LLMResponse
+		if err := agent.Run(ctx, schema.NewInput("hi"), output, &apiResp); err != nil {
+			t.Fatalf("run agent failed: %v", err)
+		}
+		return output.ChatMessage, apiResp
+	}
+	// the backup receives the system prompt and the user message once, the turn of the primary is rolled back
+	got, apiResp := run(context.Background())
+	if got != "2" {
+		t.Errorf("expect backup request without duplicated user message, got message count %s", got)
+	}
+	if len(primary.Requests()) != 1 || len(fallbacks) != 1 || fallbacks[0].Model != "primary-model" {
+		t.Errorf("expect fall over from primary backend once, got %d calls, fallbacks: %+v", len(primary.Requests()), fallbacks)
+	}
+	if backup.Last()["model"] != "backup-model" || apiResp.Provider != instructor.ProviderOpenAI {
+		t.Errorf("expect response served by backup backend, got %s/%v", apiResp.Provider, backup.Last()["model"])
+	}
+	if got, _ := run(context.Background()); got != "4" {
+		t.Errorf("expect backends sharing memory, got message count %s", got)
+	}
+	if history := agent.agents[0].Memory().List(); len(history) != 4 {
+		t.Errorf("expect 2 turns in memory, got %+v", history)
+	}
+	if mem := backupClient.Memory(); mem != nil && len(mem.List()) != 0 {
+		t.Errorf("expect memory of the backup client untouched, got %+v", mem.List())
+	}
+	// backends share the memory of an Isolation
+	ctx := memory.WithIsolation(context.Background(), memory.NewIsolation())
+	if got := []string{func() string { v, _ := run(ctx); return v }(), func() string { v, _ := run(ctx); return v }()}; got[0] != "2" || got[1] != "4" {
+		t.Errorf("expect backends sharing isolated memory, got message counts %v", got)
+	}
+}
+
+// pairOutput is an output which could be decoded in part before failing validation
+type pairOutput struct {
+	schema.Base
+	First  string `json:"first,omitempty"`
+	Second string `json:"second,omitempty" validate:"required"`
+}
+
+// valueInstructor is an instructor held by value, it could not be cloned
+type valueInstructor struct {
+	*openaiClt.Instructor
+}
+
+func TestFallbackAgentPartialOutput(t *testing.T) {
+	// the primary output fails validation after its first field was decoded
+	primary := startOpenAICompatibleServer(t, llmtest.Text(`{"first":"leaked"}`))
+	backup := startOpenAICompatibleServer(t, llmtest.Text(`{"second":"ok"}`))
+	newClient := func(baseURL string) *openaiClt.Instructor {
+		clt := openai.NewClient(option.WithBaseURL(baseURL), option.WithAPIKey("test"), option.WithMaxRetries(0))
+		return openaiClt.New(&clt, instructor.WithMode(instructor.ModeJSON), instructor.WithMaxRetries(0), instructor.WithValidation())
+	}
+	agent := NewFallbackAgent[schema.Input, pairOutput]([]Backend{
+		{Client: newClient(primary.URL), Model: "primary-model"},
+		{Client: newClient(backup.URL), Model: "backup-model"},
+	}).SetFallbackOn(DefaultFallbackOn | SchemaError)
+	output := new(pairOutput)
+	if err := agent.Run(context.Background(), schema.NewInput("hi"), output, nil); err != nil {
+		t.Fatalf("run agent failed: %v", err)
+	}
+	if output.First != "" || output.Second != "ok" {
+		t.Errorf("expect output of the backup only, got %+v", output)
+	}
+
+	// a backup which could not share the memory of the primary backend fails every run
+	agent = NewFallbackAgent[schema.Input, pairOutput]([]Backend{
+		{Client: newClient(primary.URL), Model: "primary-model"},
+		{Client: valueInstructor{newClient(backup.URL)}, Model: "backup-model"},
+	})
+	if err := agent.Run(context.Background(), schema.NewInput("hi"), output, nil); !errors.Is(err, ErrCloneInstructor) {
+		t.Errorf("expect clone instructor error, got %v", err)
+	}
+}
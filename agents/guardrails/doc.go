@@ -0,0 +1,8 @@
+// Package guardrails is the input and output guardrails pipeline of agents.
+//
+// A Pipeline runs input guardrails before and output guardrails after the run of an agent, chain or tool it is
+// used on as an interceptor. Built-in guardrails redact PII and regex patterns, limit the input length, detect banned
+// topics with a classifier agent and allow-list JSON fields of outputs. Guardrails calling LLMs report their usage with
+// AddUsage, merged into the usage of the run. Every guardrail blocks, rewrites or flags on violation, blocked runs fail
+// with a *ViolationError wrapping the typed error of the guardrail.
+package guardrails
@@ -0,0 +1,97 @@
+package guardrails
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// FieldAllowList allows only the listed top-level JSON fields of outputs to be set, Rewrite clears the other fields
+type FieldAllowList struct {
+	action  Action
+	allowed map[string]struct{}
+}
+
+// NewFieldAllowList returns a FieldAllowList guardrail of JSON field names
+func NewFieldAllowList(fields []string, action Action) *FieldAllowList {
+	allowed := make(map[string]struct{}, len(fields))
+	for _, field := range fields {
+		allowed[field] = struct{}{}
+	}
+	return &FieldAllowList{
+		action:  action,
+		allowed: allowed,
+	}
+}
+
+func (g *FieldAllowList) Name() string {
+	return "field_allow_list"
+}
+
+func (g *FieldAllowList) Check(_ context.Context, _ Stage, value any) (any, *Violation, error) {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return value, nil, nil
+	}
+	cp := reflect.New(v.Elem().Type())
+	cp.Elem().Set(v.Elem())
+	fields := g.clear(cp.Elem())
+	if len(fields) == 0 {
+		return value, nil, nil
+	}
+	return cp.Interface(), &Violation{
+		Action: g.action,
+		Err:    ErrFieldNotAllowed,
+		Reason: fmt.Sprintf("fields %s are set", strings.Join(fields, ", ")),
+	}, nil
+}
+
+// clear zeroes the set fields out of the allow-list, returns their JSON names
+func (g *FieldAllowList) clear(v reflect.Value) []string {
+	var ret []string
+	t := v.Type()
+	for idx := range t.NumField() {
+		field := t.Field(idx)
+		name, skip := jsonName(&field)
+		if skip {
+			continue
+		}
+		value := v.Field(idx)
+		if field.Anonymous && name == "" {
+			if value.Kind() == reflect.Pointer && !value.IsNil() && value.CanSet() {
+				// copy the embedded struct, so that the checked value is not modified
+				elem := reflect.New(value.Elem().Type())
+				elem.Elem().Set(value.Elem())
+				value.Set(elem)
+				value = elem.Elem()
+			}
+			if value.Kind() == reflect.Struct {
+				ret = append(ret, g.clear(value)...)
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if _, ok := g.allowed[name]; ok || value.IsZero() {
+			continue
+		}
+		value.SetZero()
+		ret = append(ret, name)
+	}
+	return ret
+}
+
+// jsonName returns the json tag name of the field, skip reports the field is ignored by json
+func jsonName(field *reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", true
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	return name, false
+}
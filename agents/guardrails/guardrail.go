@@ -0,0 +1,204 @@
+package guardrails
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/interceptor"
+)
+
+var (
+	// ErrBlocked is wrapped by every ViolationError
+	ErrBlocked = errors.New("blocked by guardrail")
+	// ErrPII returns when PII or a redaction pattern is found
+	ErrPII = errors.New("pii detected")
+	// ErrTooLong returns when the input exceeds the maximum length
+	ErrTooLong = errors.New("input too long")
+	// ErrBannedTopic returns when the text is classified into a banned topic
+	ErrBannedTopic = errors.New("banned topic")
+	// ErrFieldNotAllowed returns when the output sets fields out of the allow-list
+	ErrFieldNotAllowed = errors.New("field not allowed")
+)
+
+// Action is the action taken on violation
+type Action int
+
+const (
+	// Block fails the run with a *ViolationError
+	Block Action = iota
+	// Rewrite rewrites the input or output, like redacting or truncating, and continues
+	Rewrite
+	// Flag reports the violation to the flag hook and continues
+	Flag
+)
+
+func (a Action) String() string {
+	switch a {
+	case Block:
+		return "block"
+	case Rewrite:
+		return "rewrite"
+	case Flag:
+		return "flag"
+	}
+	return "unknown"
+}
+
+// Stage is the stage a guardrail runs at
+type Stage string
+
+const (
+	InputStage  Stage = "input"
+	OutputStage Stage = "output"
+)
+
+// Violation is a violated guardrail
+type Violation struct {
+	Guardrail string
+	Stage     Stage
+	Action    Action
+	// Err is the typed error of the guardrail, like ErrPII
+	Err    error
+	Reason string
+}
+
+// ViolationError returns when a blocking guardrail is violated
+type ViolationError struct {
+	Violation
+}
+
+func (e *ViolationError) Error() string {
+	return fmt.Sprintf("guardrail %s blocked %s: %v: %s", e.Guardrail, e.Stage, e.Err, e.Reason)
+}
+
+func (e *ViolationError) Unwrap() []error {
+	return []error{ErrBlocked, e.Err}
+}
+
+// Guardrail checks an input or output, a pointer of the schema
+type Guardrail interface {
+	Name() string
+	// Check returns the violation of the value or nil. With Rewrite action, the returned value replaces the checked one,
+	// the checked value must not be modified.
+	Check(ctx context.Context, stage Stage, value any) (any, *Violation, error)
+}
+
+// Pipeline runs input guardrails before and output guardrails after the intercepted run, in order
+type Pipeline struct {
+	input    []Guardrail
+	output   []Guardrail
+	flagHook func(context.Context, *interceptor.Call, *Violation)
+}
+
+var _ interceptor.Interceptor = (*Pipeline)(nil)
+
+// New returns a new Pipeline
+func New() *Pipeline {
+	return new(Pipeline)
+}
+
+// Input appends input guardrails
+func (p *Pipeline) Input(guardrails ...Guardrail) *Pipeline {
+	p.input = append(p.input, guardrails...)
+	return p
+}
+
+// Output appends output guardrails
+func (p *Pipeline) Output(guardrails ...Guardrail) *Pipeline {
+	p.output = append(p.output, guardrails...)
+	return p
+}
+
+// SetFlagHook set the hook called with flagged and rewritten violations
+func (p *Pipeline) SetFlagHook(fn func(context.Context, *interceptor.Call, *Violation)) *Pipeline {
+	p.flagHook = fn
+	return p
+}
+
+// Intercept runs the call through the guardrails, the usage reported by guardrails with AddUsage is merged into apiResp
+func (p *Pipeline) Intercept(ctx context.Context, call *interceptor.Call, input any, apiResp *components.LLMResponse, next interceptor.Handler) (any, error) {
+	usage := new(usageCollector)
+	defer usage.mergeInto(apiResp)
+	checkCtx := context.WithValue(ctx, usageKey{}, usage)
+	input, err := p.check(checkCtx, call, InputStage, p.input, input)
+	if err != nil {
+		return nil, err
+	}
+	output, err := next(ctx, input, apiResp)
+	if err != nil {
+		return output, err
+	}
+	return p.check(checkCtx, call, OutputStage, p.output, output)
+}
+
+// CheckInput runs the input guardrails on the input, returns the rewritten input
+func (p *Pipeline) CheckInput(ctx context.Context, input any) (any, error) {
+	return p.check(ctx, nil, InputStage, p.input, input)
+}
+
+// CheckOutput runs the output guardrails on the output, returns the rewritten output
+func (p *Pipeline) CheckOutput(ctx context.Context, output any) (any, error) {
+	return p.check(ctx, nil, OutputStage, p.output, output)
+}
+
+func (p *Pipeline) check(ctx context.Context, call *interceptor.Call, stage Stage, guardrails []Guardrail, value any) (any, error) {
+	for _, g := range guardrails {
+		ret, violation, err := g.Check(ctx, stage, value)
+		if err != nil {
+			return nil, err
+		}
+		if violation == nil {
+			continue
+		}
+		violation.Guardrail = g.Name()
+		violation.Stage = stage
+		if violation.Action == Block {
+			return nil, &ViolationError{Violation: *violation}
+		}
+		if violation.Action == Rewrite && ret != nil {
+			value = ret
+		}
+		if fn := p.flagHook; fn != nil {
+			fn(ctx, call, violation)
+		}
+	}
+	return value, nil
+}
+
+type usageKey struct{}
+
+// usageCollector sums the usage reported by guardrails during an intercepted run
+type usageCollector struct {
+	mu    sync.Mutex
+	usage *components.LLMUsage
+}
+
+func (c *usageCollector) mergeInto(apiResp *components.LLMResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.usage == nil || apiResp == nil {
+		return
+	}
+	if apiResp.Usage == nil {
+		apiResp.Usage = new(components.LLMUsage)
+	}
+	apiResp.Usage.Merge(c.usage)
+}
+
+// AddUsage reports the usage of LLM calls made by a guardrail, like a classification.
+// A Pipeline used as an interceptor merges the reported usage into the response of the intercepted run.
+func AddUsage(ctx context.Context, usage *components.LLMUsage) {
+	c, ok := ctx.Value(usageKey{}).(*usageCollector)
+	if !ok || usage == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.usage == nil {
+		c.usage = new(components.LLMUsage)
+	}
+	c.usage.Merge(usage)
+}
@@ -0,0 +1,148 @@
+package guardrails
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bububa/instructor-go"
+	openaiClt "github.com/bububa/instructor-go/instructors/openai"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+
+	"github.com/bububa/atomic-agents/agents"
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/interceptor"
+	"github.com/bububa/atomic-agents/internal/llmtest"
+	"github.com/bububa/atomic-agents/schema"
+)
+
+// keywordClassifier classifies texts mentioning a topic into the topic
+type keywordClassifier struct{}
+
+func (keywordClassifier) Name() string { return "classifier" }
+
+func (keywordClassifier) Run(_ context.Context, input *TopicInput, output *TopicDecision, _ *components.LLMResponse) error {
+	output.Topic, output.Confidence = NoTopic, 0.9
+	for _, topic := range input.Topics {
+		if strings.Contains(strings.ToLower(input.Text), topic) {
+			output.Topic = topic
+		}
+	}
+	return nil
+}
+
+type account struct {
+	schema.Base
+	Name    string `json:"name"`
+	Balance int    `json:"balance"`
+	Secret  string `json:"secret,omitempty"`
+}
+
+func TestPipeline(t *testing.T) {
+	var flagged []string
+	pipeline := New().
+		Input(
+			NewPII(Rewrite),
+			NewMaxLength(80, Block),
+			NewBannedTopics(keywordClassifier{}, []string{"politics"}, Block),
+		).
+		Output(NewFieldAllowList([]string{"name", "balance"}, Rewrite)).
+		SetFlagHook(func(_ context.Context, _ *interceptor.Call, v *Violation) {
+			flagged = append(flagged, v.Guardrail)
+		})
+	var received string
+	handler := func(_ context.Context, input *schema.String, output *account, _ *components.LLMResponse) error {
+		received = input.String()
+		*output = account{Name: "alice", Balance: 10, Secret: "s3cret"}
+		return nil
+	}
+	run := func(text string) (*schema.String, *account, error) {
+		input, output := schema.NewString(text), new(account)
+		err := interceptor.Typed(context.Background(), &interceptor.Call{Kind: interceptor.AgentCall}, []interceptor.Interceptor{pipeline}, input, output, nil, handler)
+		return input, output, err
+	}
+
+	input, output, err := run("mail bob@example.com or call +1 415-555-0100, card 4111 1111 1111 1111")
+	if err != nil {
+		t.Fatalf("run pipeline failed: %v", err)
+	}
+	if received != "mail [EMAIL] or call [PHONE], card [CARD]" {
+		t.Errorf("expect redacted input, got %q", received)
+	}
+	if !strings.Contains(input.String(), "bob@example.com") {
+		t.Errorf("expect caller input untouched, got %q", input.String())
+	}
+	if output.Secret != "" || output.Name != "alice" || output.Balance != 10 {
+		t.Errorf("expect not allowed output fields cleared, got %+v", output)
+	}
+	if strings.Join(flagged, ",") != "pii,field_allow_list" {
+		t.Errorf("expect rewrites reported, got %v", flagged)
+	}
+
+	_, _, err = run(strings.Repeat("a", 81))
+	var violation *ViolationError
+	if !errors.As(err, &violation) || !errors.Is(err, ErrTooLong) || !errors.Is(err, ErrBlocked) || violation.Stage != InputStage {
+		t.Errorf("expect blocked too long input, got %v", err)
+	}
+	if _, _, err = run("let's talk politics"); !errors.Is(err, ErrBannedTopic) {
+		t.Errorf("expect blocked banned topic, got %v", err)
+	}
+}
+
+func TestLuhn(t *testing.T) {
+	if !luhn("4111 1111 1111 1111") || luhn("4111 1111 1111 1112") {
+		t.Error("unexpected luhn check")
+	}
+}
+
+func TestBannedTopicsClassifier(t *testing.T) {
+	srv := llmtest.NewServer(t, func(body map[string]any) llmtest.Reply {
+		topic := NoTopic
+		if messages := llmtest.Messages(body); strings.Contains(fmt.Sprint(messages[len(messages)-1]), "talk politics") {
+			topic = "politics"
+		}
+		// every classification is sent the system prompt and its own text only
+		return llmtest.Reply{Content: fmt.Sprintf(`{"topic":%q,"confidence":0.9,"reasoning":"%d messages"}`, topic, len(llmtest.Messages(body)))}
+	})
+	clt := openai.NewClient(option.WithBaseURL(srv.URL), option.WithAPIKey("test"), option.WithMaxRetries(0))
+	classifier := NewTopicClassifier(
+		agents.WithClient(openaiClt.New(&clt, instructor.WithMode(instructor.ModeJSON), instructor.WithMaxRetries(0))),
+		agents.WithModel(llmtest.Model),
+	)
+	guardrail := NewBannedTopics(classifier, []string{"politics"}, Block)
+	pipeline := New().Input(guardrail)
+	handler := func(_ context.Context, input *schema.String, output *schema.String, apiResp *components.LLMResponse) error {
+		*output = *input
+		apiResp.Usage = &components.LLMUsage{InputTokens: 100}
+		return nil
+	}
+	for _, text := range []string{"hello", "how are you"} {
+		apiResp := new(components.LLMResponse)
+		if err := interceptor.Typed(context.Background(), &interceptor.Call{Kind: interceptor.AgentCall}, []interceptor.Interceptor{pipeline}, schema.NewString(text), new(schema.String), apiResp, handler); err != nil {
+			t.Fatalf("run pipeline failed: %v", err)
+		}
+		if apiResp.Usage == nil || apiResp.Usage.InputTokens != 112 {
+			t.Errorf("expect classification usage merged into the run usage, got %+v", apiResp.Usage)
+		}
+	}
+	apiResp := new(components.LLMResponse)
+	if err := interceptor.Typed(context.Background(), &interceptor.Call{Kind: interceptor.AgentCall}, []interceptor.Interceptor{pipeline}, schema.NewString("let's talk politics"), new(schema.String), apiResp, handler); !errors.Is(err, ErrBannedTopic) {
+		t.Fatalf("expect blocked banned topic, got %v", err)
+	}
+	if apiResp.Usage == nil || apiResp.Usage.InputTokens != 12 {
+		t.Errorf("expect classification usage of blocked run reported, got %+v", apiResp.Usage)
+	}
+	if usage := guardrail.Usage(); usage.InputTokens != 36 {
+		t.Errorf("expect classification usage summed to 36, got %d", usage.InputTokens)
+	}
+	requests := srv.Requests()
+	if first, last := len(llmtest.Messages(requests[0])), len(llmtest.Messages(requests[len(requests)-1])); first != last {
+		t.Errorf("expect isolated classifications, got %d then %d messages", first, last)
+	}
+	if got := len(classifier.Memory().List()); got != 0 {
+		t.Errorf("expect classifier memory untouched, got %d messages", got)
+	}
+}
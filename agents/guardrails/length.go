@@ -0,0 +1,47 @@
+package guardrails
+
+import (
+	"context"
+	"fmt"
+	"unicode/utf8"
+)
+
+// MaxLength limits the length in runes of the text of inputs, Rewrite truncates every text to the limit
+type MaxLength struct {
+	action Action
+	limit  int
+}
+
+// NewMaxLength returns a MaxLength guardrail
+func NewMaxLength(limit int, action Action) *MaxLength {
+	return &MaxLength{
+		action: action,
+		limit:  limit,
+	}
+}
+
+func (g *MaxLength) Name() string {
+	return "max_length"
+}
+
+func (g *MaxLength) Check(_ context.Context, _ Stage, value any) (any, *Violation, error) {
+	length := utf8.RuneCountInString(joinedText(value))
+	if length <= g.limit {
+		return value, nil, nil
+	}
+	violation := &Violation{
+		Action: g.action,
+		Err:    ErrTooLong,
+		Reason: fmt.Sprintf("length %d exceeds %d", length, g.limit),
+	}
+	if g.action != Rewrite {
+		return value, violation, nil
+	}
+	ret, _ := rewriteStrings(value, func(s string) string {
+		if utf8.RuneCountInString(s) <= g.limit {
+			return s
+		}
+		return string([]rune(s)[:g.limit])
+	})
+	return ret, violation, nil
+}
@@ -0,0 +1,140 @@
+package guardrails
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Pattern is a redaction pattern, matches not accepted by Validate are kept
+type Pattern struct {
+	Name        string
+	Regexp      *regexp.Regexp
+	Replacement string
+	Validate    func(match string) bool
+}
+
+var (
+	// EmailPattern matches email addresses
+	EmailPattern = Pattern{
+		Name:        "email",
+		Regexp:      regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+		Replacement: "[EMAIL]",
+	}
+	// CardPattern matches payment card numbers passing the Luhn check
+	CardPattern = Pattern{
+		Name:        "card",
+		Regexp:      regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`),
+		Replacement: "[CARD]",
+		Validate:    luhn,
+	}
+	// PhonePattern matches phone numbers of 8 to 15 digits, with optional country code and separators
+	PhonePattern = Pattern{
+		Name:        "phone",
+		Regexp:      regexp.MustCompile(`(?:\+\d{1,3}[ \-.]?)?(?:\(\d{1,4}\)[ \-.]?)?\d{2,4}(?:[ \-.]?\d{2,4}){1,4}`),
+		Replacement: "[PHONE]",
+		Validate: func(match string) bool {
+			n := countDigits(match)
+			return n >= 8 && n <= 15
+		},
+	}
+)
+
+// PII redacts or detects emails, card numbers, phone numbers and custom patterns, patterns are applied in order
+type PII struct {
+	action   Action
+	patterns []Pattern
+}
+
+// NewPII returns a PII guardrail with email, card and phone patterns
+func NewPII(action Action) *PII {
+	return &PII{
+		action:   action,
+		patterns: []Pattern{EmailPattern, CardPattern, PhonePattern},
+	}
+}
+
+// NewRedactor returns a PII guardrail with the patterns only
+func NewRedactor(action Action, patterns ...Pattern) *PII {
+	return &PII{
+		action:   action,
+		patterns: patterns,
+	}
+}
+
+// AddPattern appends a redaction pattern
+func (g *PII) AddPattern(p Pattern) *PII {
+	g.patterns = append(g.patterns, p)
+	return g
+}
+
+func (g *PII) Name() string {
+	return "pii"
+}
+
+func (g *PII) Check(_ context.Context, _ Stage, value any) (any, *Violation, error) {
+	found := make(map[string]struct{})
+	ret, changed := rewriteStrings(value, func(s string) string {
+		for _, p := range g.patterns {
+			s = p.Regexp.ReplaceAllStringFunc(s, func(match string) string {
+				if p.Validate != nil && !p.Validate(match) {
+					return match
+				}
+				found[p.Name] = struct{}{}
+				return p.Replacement
+			})
+		}
+		return s
+	})
+	if !changed {
+		return value, nil, nil
+	}
+	names := make([]string, 0, len(found))
+	for _, p := range g.patterns {
+		if _, ok := found[p.Name]; ok {
+			names = append(names, p.Name)
+		}
+	}
+	return ret, &Violation{
+		Action: g.action,
+		Err:    ErrPII,
+		Reason: fmt.Sprintf("found %s", strings.Join(names, ", ")),
+	}, nil
+}
+
+func countDigits(s string) int {
+	var n int
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			n++
+		}
+	}
+	return n
+}
+
+// luhn reports whether the digits of s pass the Luhn checksum
+func luhn(s string) bool {
+	var (
+		sum    int
+		n      int
+		double bool
+	)
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+		n++
+	}
+	return n >= 13 && n <= 19 && sum%10 == 0
+}
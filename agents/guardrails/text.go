@@ -0,0 +1,153 @@
+package guardrails
+
+import (
+	"reflect"
+	"strings"
+)
+
+// textSchema is implemented by schemas of raw text, like schema.String
+type textSchema interface {
+	String() string
+	Unmarshal([]byte) error
+}
+
+var textSchemaType = reflect.TypeFor[textSchema]()
+
+// texts returns the text of text schemas and the exported string fields of the value
+func texts(value any) []string {
+	var ret []string
+	walkStrings(reflect.ValueOf(value), func(s string) string {
+		ret = append(ret, s)
+		return s
+	})
+	return ret
+}
+
+// joinedText returns the texts of the value joined by new lines
+func joinedText(value any) string {
+	return strings.Join(texts(value), "\n")
+}
+
+// rewriteStrings returns a copy of the value with fn applied to the text of text schemas and exported string fields,
+// the value is not modified. changed reports whether any string was rewritten.
+func rewriteStrings(value any, fn func(string) string) (any, bool) {
+	ret, changed := walkStrings(reflect.ValueOf(value), fn)
+	if !changed {
+		return value, false
+	}
+	return ret.Interface(), true
+}
+
+// walkStrings applies fn to strings of v, containers are copied on write
+func walkStrings(v reflect.Value, fn func(string) string) (reflect.Value, bool) {
+	if !v.IsValid() {
+		return v, false
+	}
+	if v.Kind() == reflect.Struct && reflect.PointerTo(v.Type()).Implements(textSchemaType) {
+		cp := reflect.New(v.Type())
+		cp.Elem().Set(v)
+		text := cp.Interface().(textSchema)
+		s := text.String()
+		r := fn(s)
+		if r == s || text.Unmarshal([]byte(r)) != nil {
+			return v, false
+		}
+		return cp.Elem(), true
+	}
+	switch v.Kind() {
+	case reflect.String:
+		s := v.String()
+		r := fn(s)
+		if r == s {
+			return v, false
+		}
+		nv := reflect.New(v.Type()).Elem()
+		nv.SetString(r)
+		return nv, true
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v, false
+		}
+		elem, changed := walkStrings(v.Elem(), fn)
+		if !changed {
+			return v, false
+		}
+		nv := reflect.New(v.Elem().Type())
+		nv.Elem().Set(elem)
+		return nv, true
+	case reflect.Interface:
+		if v.IsNil() {
+			return v, false
+		}
+		elem, changed := walkStrings(v.Elem(), fn)
+		if !changed {
+			return v, false
+		}
+		nv := reflect.New(v.Type()).Elem()
+		nv.Set(elem)
+		return nv, true
+	case reflect.Struct:
+		var cp reflect.Value
+		for idx := range v.NumField() {
+			if !v.Type().Field(idx).IsExported() {
+				continue
+			}
+			field, changed := walkStrings(v.Field(idx), fn)
+			if !changed {
+				continue
+			}
+			if !cp.IsValid() {
+				cp = reflect.New(v.Type()).Elem()
+				cp.Set(v)
+			}
+			cp.Field(idx).Set(field)
+		}
+		if !cp.IsValid() {
+			return v, false
+		}
+		return cp, true
+	case reflect.Slice, reflect.Array:
+		var cp reflect.Value
+		for idx := range v.Len() {
+			elem, changed := walkStrings(v.Index(idx), fn)
+			if !changed {
+				continue
+			}
+			if !cp.IsValid() {
+				if v.Kind() == reflect.Slice {
+					cp = reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+					reflect.Copy(cp, v)
+				} else {
+					cp = reflect.New(v.Type()).Elem()
+					cp.Set(v)
+				}
+			}
+			cp.Index(idx).Set(elem)
+		}
+		if !cp.IsValid() {
+			return v, false
+		}
+		return cp, true
+	case reflect.Map:
+		var cp reflect.Value
+		iter := v.MapRange()
+		for iter.Next() {
+			elem, changed := walkStrings(iter.Value(), fn)
+			if !changed {
+				continue
+			}
+			if !cp.IsValid() {
+				cp = reflect.MakeMapWithSize(v.Type(), v.Len())
+				for _, key := range v.MapKeys() {
+					cp.SetMapIndex(key, v.MapIndex(key))
+				}
+			}
+			cp.SetMapIndex(iter.Key(), elem)
+		}
+		if !cp.IsValid() {
+			return v, false
+		}
+		return cp, true
+	}
+	return v, false
+}
@@ -0,0 +1,143 @@
+package guardrails
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/bububa/atomic-agents/agents"
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/memory"
+	"github.com/bububa/atomic-agents/components/systemprompt/cot"
+	"github.com/bububa/atomic-agents/schema"
+)
+
+// NoTopic is the topic of texts out of the banned topics
+const NoTopic = "none"
+
+// TopicInput is the input of a topic classifier agent
+type TopicInput struct {
+	schema.Base
+	Text   string   `json:"text" jsonschema:"title=text,description=the text to classify"`
+	Topics []string `json:"topics" jsonschema:"title=topics,description=the candidate topics"`
+}
+
+// TopicDecision is the output of a topic classifier agent
+type TopicDecision struct {
+	schema.Base
+	// Topic is one of the candidate topics, or none
+	Topic      string  `json:"topic" jsonschema:"title=topic,description=one of the candidate topics or none"`
+	Confidence float64 `json:"confidence" validate:"min=0,max=1" jsonschema:"title=confidence,description=confidence of the classification from 0 to 1"`
+	Reasoning  string  `json:"reasoning,omitempty" jsonschema:"title=reasoning,description=short explanation of the classification"`
+}
+
+// NewTopicClassifier returns a classifier agent classifying texts into candidate topics
+func NewTopicClassifier(agentOpts ...agents.Option) *agents.Agent[TopicInput, TopicDecision] {
+	opts := make([]agents.Option, 0, len(agentOpts)+1)
+	opts = append(opts, agentOpts...)
+	opts = append(opts, agents.WithSystemPromptGenerator(cot.New(
+		cot.WithBackground([]string{
+			"You are a content moderation classifier.",
+		}),
+		cot.WithSteps([]string{
+			"Read the text and the candidate topics.",
+			"Decide whether the text is about one of the candidate topics.",
+		}),
+		cot.WithOutputInstructs([]string{
+			fmt.Sprintf("Answer one of the candidate topics, or %s if the text is about none of them.", NoTopic),
+			"Give your confidence from 0 to 1.",
+		}),
+	)))
+	return agents.NewAgent[TopicInput, TopicDecision](opts...)
+}
+
+// BannedTopics detects texts about banned topics with a classifier agent, Rewrite replaces the text with the replacement.
+// Every classification runs in a new memory.Isolation, its usage is reported with AddUsage.
+type BannedTopics struct {
+	classifier  agents.TypeableAgent[TopicInput, TopicDecision]
+	topics      []string
+	action      Action
+	threshold   float64
+	replacement string
+	mu          sync.Mutex
+	usage       components.LLMUsage
+}
+
+// NewBannedTopics returns a BannedTopics guardrail
+func NewBannedTopics(classifier agents.TypeableAgent[TopicInput, TopicDecision], topics []string, action Action) *BannedTopics {
+	return &BannedTopics{
+		classifier:  classifier,
+		topics:      topics,
+		action:      action,
+		threshold:   0.5,
+		replacement: "Sorry, I can't help with that topic.",
+	}
+}
+
+// SetThreshold set the minimum confidence of a violation
+func (g *BannedTopics) SetThreshold(threshold float64) *BannedTopics {
+	g.threshold = threshold
+	return g
+}
+
+// SetReplacement set the text replacing the input or output text with Rewrite action
+func (g *BannedTopics) SetReplacement(text string) *BannedTopics {
+	g.replacement = text
+	return g
+}
+
+// Usage returns the usage of the classifications summed across checks
+func (g *BannedTopics) Usage() components.LLMUsage {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.usage
+}
+
+func (g *BannedTopics) Name() string {
+	return "banned_topics"
+}
+
+func (g *BannedTopics) Check(ctx context.Context, _ Stage, value any) (any, *Violation, error) {
+	text := joinedText(value)
+	if strings.TrimSpace(text) == "" {
+		return value, nil, nil
+	}
+	decision := new(TopicDecision)
+	apiResp := new(components.LLMResponse)
+	// classifications are independent of each other, concurrent checks never share the classifier memory
+	err := g.classifier.Run(memory.Isolate(ctx), &TopicInput{Text: text, Topics: g.topics}, decision, apiResp)
+	if apiResp.Usage != nil {
+		g.mu.Lock()
+		g.usage.Merge(apiResp.Usage)
+		g.mu.Unlock()
+		AddUsage(ctx, apiResp.Usage)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	idx := slices.IndexFunc(g.topics, func(topic string) bool {
+		return strings.EqualFold(topic, decision.Topic)
+	})
+	if idx < 0 || decision.Confidence < g.threshold {
+		return value, nil, nil
+	}
+	violation := &Violation{
+		Action: g.action,
+		Err:    ErrBannedTopic,
+		Reason: fmt.Sprintf("topic %s (confidence %.2f)", g.topics[idx], decision.Confidence),
+	}
+	if g.action != Rewrite {
+		return value, violation, nil
+	}
+	replaced := false
+	ret, _ := rewriteStrings(value, func(s string) string {
+		if replaced || s == "" {
+			return ""
+		}
+		replaced = true
+		return g.replacement
+	})
+	return ret, violation, nil
+}
@@ -3,7 +3,10 @@ package agents
 import (
 	"github.com/bububa/instructor-go"
 
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/memory"
 	"github.com/bububa/atomic-agents/components/systemprompt"
+	"github.com/bububa/atomic-agents/tools"
 )
 
 type Option func(a *Config)
@@ -28,7 +31,7 @@ func WithModel(model string) Option {
 
 func WithTemperature(temperature float64) Option {
 	return func(c *Config) {
-		c.temperature = temperature
+		c.temperature = &temperature
 	}
 }
 
@@ -55,3 +58,59 @@ func WithName(name string) Option {
 		c.name = name
 	}
 }
+
+// WithTools registers LLM native tools
+func WithTools(fns ...tools.Function) Option {
+	return func(c *Config) {
+		c.tools = fns
+	}
+}
+
+// WithMaxToolIterations set maximum number of LLM calls in a tool calling loop
+func WithMaxToolIterations(n int) Option {
+	return func(c *Config) {
+		c.maxToolIterations = n
+	}
+}
+
+// WithProvider set the Provider which drives the client instead of the registered providers
+func WithProvider(p Provider) Option {
+	return func(c *Config) {
+		c.provider = p
+	}
+}
+
+// WithOpenAICompatible drives the OpenAI client against an OpenAI-compatible endpoint, e.g. Ollama, vLLM, llama.cpp servers
+func WithOpenAICompatible(config OpenAICompatible) Option {
+	return func(c *Config) {
+		c.provider = NewOpenAICompatibleProvider(config)
+	}
+}
+
+// WithPriceTable set the PriceTable computing response cost
+func WithPriceTable(t *components.PriceTable) Option {
+	return func(c *Config) {
+		c.priceTable = t
+	}
+}
+
+// WithMemoryManager set the memory Manager which fits memory history into a token budget
+func WithMemoryManager(m *memory.Manager) Option {
+	return func(c *Config) {
+		c.memoryManager = m
+	}
+}
+
+// WithMemoryStore set the memory Store which persists session memory keyed by the context session ID
+func WithMemoryStore(store memory.Store) Option {
+	return func(c *Config) {
+		c.memoryStore = store
+	}
+}
+
+// WithRetryPolicy set the RetryPolicy of Agent.Run
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(c *Config) {
+		c.retryPolicy = &p
+	}
+}
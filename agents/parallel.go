@@ -0,0 +1,199 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/interceptor"
+	"github.com/bububa/atomic-agents/components/memory"
+	"github.com/bububa/atomic-agents/schema"
+)
+
+// ParallelResult is the result of a Parallel branch
+type ParallelResult struct {
+	Agent    AnonymousAgent
+	Output   any
+	Response components.LLMResponse
+	Err      error
+}
+
+// ParallelMerge merges the results of Parallel branches in agents order into output.
+// Results with Err are only passed in collect-all mode.
+type ParallelMerge[O schema.Schema] func(ctx context.Context, results []ParallelResult, output *O) error
+
+// Parallel runs agents concurrently on the same input and merges their outputs with the merge function.
+// By default, the first failed branch cancels the others and fails the run, in collect-all mode every branch
+// runs to the end and the run fails only if all branches failed. Usage is aggregated across branches.
+// Every branch runs in a new memory.Isolation, so that branches sharing a client never share memory.
+type Parallel[I schema.Schema, O schema.Schema] struct {
+	interceptor.Stack
+	name        string
+	agents      []AnonymousAgent
+	merge       ParallelMerge[O]
+	concurrency int
+	collectAll  bool
+}
+
+var (
+	_ TypeableAgent[schema.String, schema.String] = (*Parallel[schema.String, schema.String])(nil)
+	_ AnonymousAgent                              = (*Parallel[schema.String, schema.String])(nil)
+)
+
+// NewParallel returns a new Parallel instance
+func NewParallel[I schema.Schema, O schema.Schema](merge ParallelMerge[O], agents ...AnonymousAgent) *Parallel[I, O] {
+	return &Parallel[I, O]{
+		agents: agents,
+		merge:  merge,
+	}
+}
+
+func (p *Parallel[I, O]) Name() string {
+	return p.name
+}
+
+func (p *Parallel[I, O]) SetName(name string) {
+	p.name = name
+}
+
+// SetConcurrency set the maximum number of branches running at the same time, unbounded if not positive
+func (p *Parallel[I, O]) SetConcurrency(n int) *Parallel[I, O] {
+	p.concurrency = n
+	return p
+}
+
+// SetCollectAll set whether to run every branch to the end instead of cancelling on first failure
+func (p *Parallel[I, O]) SetCollectAll(collectAll bool) *Parallel[I, O] {
+	p.collectAll = collectAll
+	return p
+}
+
+// SetStartHook sets the hook calling fn before every run
+//
+// Deprecated: use Use with interceptor.Hooks or interceptor.Before, see interceptor.Stack SetHook
+func (p *Parallel[I, O]) SetStartHook(fn func(context.Context, *Parallel[I, O], *I)) {
+	if fn == nil {
+		p.SetHook(interceptor.StartHook, nil)
+		return
+	}
+	p.SetHook(interceptor.StartHook, interceptor.Hooks[I, O](func(ctx context.Context, input *I) {
+		fn(ctx, p, input)
+	}, nil, nil))
+}
+
+// SetEndHook sets the hook calling fn after every successful run
+//
+// Deprecated: use Use with interceptor.Hooks or interceptor.After, see interceptor.Stack SetHook
+func (p *Parallel[I, O]) SetEndHook(fn func(context.Context, *Parallel[I, O], *I, *O, *components.LLMResponse)) {
+	if fn == nil {
+		p.SetHook(interceptor.EndHook, nil)
+		return
+	}
+	p.SetHook(interceptor.EndHook, interceptor.Hooks[I, O](nil, func(ctx context.Context, input *I, output *O, apiResp *components.LLMResponse) {
+		fn(ctx, p, input, output, apiResp)
+	}, nil))
+}
+
+// SetErrorHook sets the hook calling fn after every failed run
+//
+// Deprecated: use Use with interceptor.Hooks or interceptor.After, see interceptor.Stack SetHook
+func (p *Parallel[I, O]) SetErrorHook(fn func(context.Context, *Parallel[I, O], *I, *components.LLMResponse, error)) {
+	if fn == nil {
+		p.SetHook(interceptor.ErrorHook, nil)
+		return
+	}
+	p.SetHook(interceptor.ErrorHook, interceptor.Hooks[I, O](nil, nil, func(ctx context.Context, input *I, apiResp *components.LLMResponse, err error) {
+		fn(ctx, p, input, apiResp, err)
+	}))
+}
+
+// Run runs the agents concurrently with the given user input, and merges their outputs
+func (p *Parallel[I, O]) Run(ctx context.Context, input *I, output *O, apiResp *components.LLMResponse) error {
+	call := &interceptor.Call{Kind: interceptor.AgentCall, Name: p.name, Target: p}
+	return interceptor.Typed(ctx, call, p.Interceptors(), input, output, apiResp, p.invoke)
+}
+
+func (p *Parallel[I, O]) invoke(ctx context.Context, input *I, output *O, apiResp *components.LLMResponse) error {
+	if apiResp == nil {
+		apiResp = new(components.LLMResponse)
+	}
+	results, err := p.fanOut(components.WithCostScope(ctx, p.name), input)
+	usage := new(components.LLMUsage)
+	for _, v := range results {
+		usage.Merge(v.Response.Usage)
+	}
+	apiResp.Usage = usage
+	if err == nil {
+		if p.merge == nil {
+			err = errors.New("parallel merge function is not set")
+		} else {
+			err = p.merge(ctx, results, output)
+		}
+	}
+	return err
+}
+
+// fanOut runs the branches, returns the results in agents order
+func (p *Parallel[I, O]) fanOut(ctx context.Context, input *I) ([]ParallelResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	limit := p.concurrency
+	if limit <= 0 || limit > len(p.agents) {
+		limit = len(p.agents)
+	}
+	var (
+		results  = make([]ParallelResult, len(p.agents))
+		sem      = make(chan struct{}, limit)
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for idx, agent := range p.agents {
+		wg.Add(1)
+		go func(idx int, agent AnonymousAgent) {
+			defer wg.Done()
+			result := &results[idx]
+			result.Agent = agent
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				result.Err = ctx.Err()
+				return
+			}
+			result.Output, result.Err = agent.RunAnonymous(memory.Isolate(ctx), input, &result.Response)
+			if result.Err != nil && !p.collectAll {
+				once.Do(func() {
+					firstErr = result.Err
+					cancel()
+				})
+			}
+		}(idx, agent)
+	}
+	wg.Wait()
+	if !p.collectAll {
+		return results, firstErr
+	}
+	errs := make([]error, 0, len(results))
+	for _, v := range results {
+		if v.Err == nil {
+			return results, nil
+		}
+		errs = append(errs, v.Err)
+	}
+	return results, errors.Join(errs...)
+}
+
+// RunAnonymous runs the agents concurrently with the given user input for chain.
+func (p *Parallel[I, O]) RunAnonymous(ctx context.Context, input any, apiResp *components.LLMResponse) (any, error) {
+	in, ok := input.(*I)
+	if !ok {
+		return nil, errors.New("invalid agent input schema")
+	}
+	out := new(O)
+	if err := p.Run(ctx, in, out, apiResp); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,142 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bububa/instructor-go"
+
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/schema"
+)
+
+// funcAgent is an AnonymousAgent running a function
+type funcAgent struct {
+	name string
+	fn   func(context.Context, any, *components.LLMResponse) (any, error)
+}
+
+func (a *funcAgent) Name() string {
+	return a.name
+}
+
+func (a *funcAgent) RunAnonymous(ctx context.Context, input any, apiResp *components.LLMResponse) (any, error) {
+	return a.fn(ctx, input, apiResp)
+}
+
+func critic(name string, running *int32, peak *int32) *funcAgent {
+	return &funcAgent{name: name, fn: func(_ context.Context, input any, apiResp *components.LLMResponse) (any, error) {
+		n := atomic.AddInt32(running, 1)
+		defer atomic.AddInt32(running, -1)
+		for {
+			p := atomic.LoadInt32(peak)
+			if n <= p || atomic.CompareAndSwapInt32(peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		apiResp.Usage = &components.LLMUsage{InputTokens: 10, OutputTokens: 2}
+		return schema.NewString(name + ": " + input.(*schema.String).String()), nil
+	}}
+}
+
+func joinCritiques(_ context.Context, results []ParallelResult, output *schema.String) error {
+	critiques := make([]string, 0, len(results))
+	for _, v := range results {
+		if v.Err == nil {
+			critiques = append(critiques, v.Output.(*schema.String).String())
+		}
+	}
+	*output = *schema.NewString(strings.Join(critiques, "\n"))
+	return nil
+}
+
+func TestParallel(t *testing.T) {
+	var running, peak int32
+	p := NewParallel[schema.String, schema.String](joinCritiques,
+		critic("style", &running, &peak),
+		critic("security", &running, &peak),
+		critic("performance", &running, &peak),
+	).SetConcurrency(2)
+	output := new(schema.String)
+	apiResp := new(components.LLMResponse)
+	if err := p.Run(context.Background(), schema.NewString("code"), output, apiResp); err != nil {
+		t.Fatalf("run parallel failed: %v", err)
+	}
+	if output.String() != "style: code\nsecurity: code\nperformance: code" {
+		t.Errorf("expect critiques merged in agents order, got %q", output.String())
+	}
+	if peak > 2 {
+		t.Errorf("expect at most 2 concurrent branches, got %d", peak)
+	}
+	if apiResp.Usage.InputTokens != 30 || apiResp.Usage.OutputTokens != 6 {
+		t.Errorf("expect usage aggregated across branches, got %+v", apiResp.Usage)
+	}
+}
+
+func TestParallelFailure(t *testing.T) {
+	errBoom := errors.New("boom")
+	failing := &funcAgent{name: "failing", fn: func(context.Context, any, *components.LLMResponse) (any, error) {
+		return nil, errBoom
+	}}
+	slow := &funcAgent{name: "slow", fn: func(ctx context.Context, input any, _ *components.LLMResponse) (any, error) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+			return input, nil
+		}
+	}}
+	start := time.Now()
+	err := NewParallel[schema.String, schema.String](joinCritiques, failing, slow).Run(context.Background(), schema.NewString("code"), new(schema.String), nil)
+	if !errors.Is(err, errBoom) || time.Since(start) > 500*time.Millisecond {
+		t.Errorf("expect cancel on first failure, got %v after %v", err, time.Since(start))
+	}
+	output := new(schema.String)
+	var running, peak int32
+	err = NewParallel[schema.String, schema.String](joinCritiques, failing, critic("style", &running, &peak)).
+		SetCollectAll(true).
+		Run(context.Background(), schema.NewString("code"), output, nil)
+	if err != nil || output.String() != "style: code" {
+		t.Errorf("expect failed branches collected, got %q, %v", output.String(), err)
+	}
+}
+
+func TestParallelSharedClient(t *testing.T) {
+	srv := startOpenAICompatibleServer(t, replyMessageCount)
+	clt := newOpenAICompatibleTestClient(instructor.ModeJSON)
+	branch := func(name string) *Agent[schema.Input, schema.Output] {
+		return NewAgent[schema.Input, schema.Output](
+			WithName(name),
+			WithClient(clt),
+			WithModel("llama3"),
+			WithOpenAICompatible(OpenAICompatible{BaseURL: srv.URL}),
+		)
+	}
+	merge := func(_ context.Context, results []ParallelResult, output *schema.Output) error {
+		counts := make([]string, 0, len(results))
+		for _, v := range results {
+			counts = append(counts, v.Output.(*schema.Output).ChatMessage)
+		}
+		output.ChatMessage = strings.Join(counts, ",")
+		return nil
+	}
+	p := NewParallel[schema.Input, schema.Output](merge, branch("style"), branch("security"))
+	for range 2 {
+		output := new(schema.Output)
+		if err := p.Run(context.Background(), schema.NewInput("code"), output, nil); err != nil {
+			t.Fatalf("run parallel failed: %v", err)
+		}
+		// every branch receives the system prompt and the input only
+		if output.ChatMessage != "2,2" {
+			t.Errorf("expect branches on isolated memories, got message counts %s", output.ChatMessage)
+		}
+	}
+	if mem := clt.Memory(); mem != nil && len(mem.List()) != 0 {
+		t.Errorf("expect memory of the shared client untouched, got %+v", mem.List())
+	}
+}
@@ -0,0 +1,188 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/bububa/instructor-go"
+
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/tools"
+)
+
+var (
+	// ErrUnknownProvider returns when no Provider could drive the instructor
+	ErrUnknownProvider = errors.New("unknown instructor provider")
+	// ErrToolCallingNotSupported returns when the Provider doesn't support LLM native tool calling
+	ErrToolCallingNotSupported = errors.New("native tool calling is not supported by the instructor provider")
+)
+
+// ChatRequest is the provider independent request built by agents from messages and Config
+type ChatRequest struct {
+	Model string
+	// Temperature the provider default if nil, set values including 0 are sent
+	Temperature *float64
+	TopP        float64
+	TopK        int
+	MaxTokens   int
+	// System system prompt message
+	System instructor.Message
+	// History messages loaded from memory
+	History []instructor.Message
+	// Input user input message
+	Input instructor.Message
+	// InputText stringified user input
+	InputText string
+	// ExtraBody extra request body fields provided by the input schema
+	ExtraBody map[string]any
+	// Tools LLM native tools
+	Tools []tools.Function
+	// MaxToolIterations maximum number of LLM calls in a tool calling loop
+	MaxToolIterations int
+}
+
+// Messages returns system, history and input messages in order
+func (r *ChatRequest) Messages() []instructor.Message {
+	messages := make([]instructor.Message, 0, len(r.History)+2)
+	messages = append(messages, r.System)
+	messages = append(messages, r.History...)
+	messages = append(messages, r.Input)
+	return messages
+}
+
+// Adapter converts ChatRequest into provider request and provider response into LLMResponse
+type Adapter[Req any, Resp any] interface {
+	BuildRequest(*ChatRequest) *Req
+	ConvertResponse(*Resp, *components.LLMResponse)
+}
+
+// Provider drives an instructor.Instructor on behalf of agents
+type Provider interface {
+	Name() string
+	// Match reports whether the Provider could drive the instructor
+	Match(instructor.Instructor) bool
+	Chat(ctx context.Context, clt instructor.Instructor, req *ChatRequest, response any, llmResponse *components.LLMResponse) error
+	Stream(ctx context.Context, clt instructor.Instructor, req *ChatRequest, responseType any) (<-chan instructor.StreamData, MergeResponse, error)
+	SchemaStream(ctx context.Context, clt instructor.Instructor, req *ChatRequest, responseType any) (<-chan any, <-chan instructor.StreamData, MergeResponse, error)
+}
+
+// ToolProvider is a Provider which supports LLM native tool calling loop
+type ToolProvider interface {
+	Provider
+	// ChatWithTools runs the tool calling loop, returns the final text, tool calling messages exchanged with the model
+	ChatWithTools(ctx context.Context, clt instructor.Instructor, req *ChatRequest, llmResponse *components.LLMResponse) (string, []instructor.Message, error)
+}
+
+// provider implements Provider with an Adapter for instructors with Req/Resp request/response types
+type provider[Req any, Resp any] struct {
+	name    string
+	adapter Adapter[Req, Resp]
+}
+
+// NewProvider returns a Provider drives instructors of Req/Resp request/response types with the adapter
+func NewProvider[Req any, Resp any](name string, adapter Adapter[Req, Resp]) Provider {
+	return &provider[Req, Resp]{
+		name:    name,
+		adapter: adapter,
+	}
+}
+
+func (p *provider[Req, Resp]) Name() string {
+	return p.name
+}
+
+func (p *provider[Req, Resp]) Match(clt instructor.Instructor) bool {
+	_, ok := clt.(instructor.ChatInstructor[Req, Resp])
+	return ok
+}
+
+func (p *provider[Req, Resp]) Chat(ctx context.Context, clt instructor.Instructor, req *ChatRequest, response any, llmResponse *components.LLMResponse) error {
+	c, ok := clt.(instructor.ChatInstructor[Req, Resp])
+	if !ok {
+		return ErrUnknownProvider
+	}
+	res := new(Resp)
+	err := c.Chat(ctx, p.adapter.BuildRequest(req), response, res)
+	if llmResponse == nil {
+		llmResponse = new(components.LLMResponse)
+	}
+	// instructors keep usage of failed attempts in response
+	p.adapter.ConvertResponse(res, llmResponse)
+	if err != nil && ClassifyError(err) == 0 && llmResponse.Usage != nil && llmResponse.Usage.OutputTokens > 0 {
+		// instructors only fail after the model responded when the response could not be decoded or validated
+		err = &components.DecodeError{Err: err}
+	}
+	return err
+}
+
+func (p *provider[Req, Resp]) Stream(ctx context.Context, clt instructor.Instructor, req *ChatRequest, responseType any) (<-chan instructor.StreamData, MergeResponse, error) {
+	c, ok := clt.(instructor.StreamInstructor[Req, Resp])
+	if !ok {
+		return nil, nil, ErrUnknownProvider
+	}
+	res := new(Resp)
+	mergeResp := p.mergeResponse(req, res)
+	ch, err := c.Stream(ctx, p.adapter.BuildRequest(req), responseType, res)
+	if err != nil {
+		return nil, mergeResp, err
+	}
+	return ch, mergeResp, nil
+}
+
+func (p *provider[Req, Resp]) SchemaStream(ctx context.Context, clt instructor.Instructor, req *ChatRequest, responseType any) (<-chan any, <-chan instructor.StreamData, MergeResponse, error) {
+	c, ok := clt.(instructor.SchemaStreamInstructor[Req, Resp])
+	if !ok {
+		return nil, nil, nil, ErrUnknownProvider
+	}
+	res := new(Resp)
+	mergeResp := p.mergeResponse(req, res)
+	ch, stream, err := c.SchemaStream(ctx, p.adapter.BuildRequest(req), responseType, res)
+	if err != nil {
+		return nil, nil, mergeResp, err
+	}
+	return ch, stream, mergeResp, nil
+}
+
+func (p *provider[Req, Resp]) mergeResponse(req *ChatRequest, res *Resp) MergeResponse {
+	return func(resp *components.LLMResponse) {
+		if resp == nil {
+			return
+		}
+		p.adapter.ConvertResponse(res, resp)
+		if resp.Model == "" {
+			resp.Model = req.Model
+		}
+	}
+}
+
+var providers struct {
+	sync.RWMutex
+	list []Provider
+}
+
+// RegisterProvider registers a Provider, providers registered later take precedence over earlier ones
+func RegisterProvider(p Provider) {
+	providers.Lock()
+	defer providers.Unlock()
+	providers.list = append([]Provider{p}, providers.list...)
+}
+
+// LookupProvider returns the first registered Provider which could drive the instructor
+func LookupProvider(clt instructor.Instructor) (Provider, error) {
+	providers.RLock()
+	defer providers.RUnlock()
+	for _, p := range providers.list {
+		if p.Match(clt) {
+			return p, nil
+		}
+	}
+	return nil, ErrUnknownProvider
+}
+
+func init() {
+	RegisterProvider(cohereProvider)
+	RegisterProvider(geminiProvider)
+	RegisterProvider(anthropicProvider)
+	RegisterProvider(openaiProvider)
+}
@@ -0,0 +1,144 @@
+package agents
+
+import (
+	"context"
+
+	"github.com/bububa/instructor-go"
+	anthropicClt "github.com/bububa/instructor-go/instructors/anthropic"
+	anthropic "github.com/liushuangls/go-anthropic/v2"
+
+	"github.com/bububa/atomic-agents/components"
+)
+
+// DefaultAnthropicMaxTokens is the max_tokens of Anthropic requests of agents without MaxTokens,
+// the messages api requires max_tokens
+const DefaultAnthropicMaxTokens = 4096
+
+// AnthropicAdapter adapts Anthropic messages api
+type AnthropicAdapter struct{}
+
+func (AnthropicAdapter) BuildRequest(r *ChatRequest) *anthropic.MessagesRequest {
+	chatReq := anthropic.MessagesRequest{
+		Model:    anthropic.Model(r.Model),
+		Messages: anthropicMessages(r.Messages()),
+	}
+	if r.Temperature != nil {
+		v := float32(*r.Temperature)
+		chatReq.Temperature = &v
+	}
+	if v := float32(r.TopP); v > 1e-15 {
+		chatReq.TopP = &v
+	}
+	if v := r.TopK; v > 0 {
+		chatReq.TopK = &v
+	}
+	chatReq.MaxTokens = DefaultAnthropicMaxTokens
+	if v := r.MaxTokens; v > 0 {
+		chatReq.MaxTokens = v
+	}
+	return &chatReq
+}
+
+func (AnthropicAdapter) ConvertResponse(res *anthropic.MessagesResponse, dist *components.LLMResponse) {
+	dist.FromAnthropic(res)
+}
+
+func anthropicMessages(msgs []instructor.Message) []anthropic.Message {
+	ret := make([]anthropic.Message, 0, len(msgs))
+	for _, msg := range msgs {
+		var v anthropic.Message
+		anthropicClt.ConvertMessageFrom(&msg, &v)
+		ret = append(ret, v)
+	}
+	return ret
+}
+
+type anthropicToolProvider struct {
+	Provider
+	adapter Adapter[anthropic.MessagesRequest, anthropic.MessagesResponse]
+}
+
+var anthropicProvider = &anthropicToolProvider{
+	Provider: NewProvider("anthropic", AnthropicAdapter{}),
+	adapter:  AnthropicAdapter{},
+}
+
+func (p *anthropicToolProvider) ChatWithTools(ctx context.Context, clt instructor.Instructor, req *ChatRequest, llmResponse *components.LLMResponse) (string, []instructor.Message, error) {
+	c, ok := components.Unwrap(clt).(*anthropicClt.Instructor)
+	if !ok {
+		return "", nil, ErrToolCallingNotSupported
+	}
+	chatReq := p.adapter.BuildRequest(req)
+	if thinking := c.ThinkingConfig(); thinking != nil {
+		chatReq.Thinking = &anthropic.Thinking{
+			Type:         anthropic.ThinkingTypeDisabled,
+			BudgetTokens: thinking.Budget,
+		}
+		if thinking.Enabled {
+			chatReq.Thinking.Type = anthropic.ThinkingTypeEnabled
+		}
+	}
+	// messages api accepts system prompt outside of messages
+	chatReq.System = req.System.Text
+	chatReq.Messages = chatReq.Messages[:0]
+	for _, msg := range req.Messages()[1:] {
+		var v anthropic.Message
+		if err := anthropicClt.ConvertMessageFrom(&msg, &v); err != nil {
+			continue
+		}
+		chatReq.Messages = append(chatReq.Messages, v)
+	}
+	for _, fn := range req.Tools {
+		chatReq.Tools = append(chatReq.Tools, anthropic.ToolDefinition{
+			Name:        fn.Title(),
+			Description: fn.Description(),
+			InputSchema: fn.Parameters(),
+		})
+	}
+	var (
+		usage    = new(components.LLMUsage)
+		exchange []instructor.Message
+	)
+	for range req.maxToolIterations() {
+		var res anthropic.MessagesResponse
+		if err := components.RoundTrip(ctx, clt, chatReq, &res, func(ctx context.Context) error {
+			var err error
+			res, err = c.CreateMessages(ctx, *chatReq)
+			return err
+		}); err != nil {
+			return "", exchange, err
+		}
+		resp := new(components.LLMResponse)
+		p.adapter.ConvertResponse(&res, resp)
+		mergeToolLoopResponse(resp, usage, llmResponse)
+		var (
+			calls []components.ToolCall
+			text  string
+		)
+		for _, content := range res.Content {
+			if content.Type == anthropic.MessagesContentTypeToolUse && content.MessageContentToolUse != nil {
+				calls = append(calls, components.ToolCall{
+					ID:        content.ID,
+					Name:      content.Name,
+					Arguments: string(content.Input),
+				})
+			} else if content.Text != nil {
+				text += *content.Text
+			}
+		}
+		if len(calls) == 0 {
+			return text, exchange, nil
+		}
+		// the whole assistant content is sent back, thinking blocks must precede their tool uses
+		callMsg := anthropic.Message{
+			Role:    anthropic.RoleAssistant,
+			Content: res.Content,
+		}
+		var callbackMsg anthropic.Message
+		callbacks := callTools(ctx, req.Tools, calls)
+		components.ToolCallbacksToAnthropic(callbacks, &callbackMsg)
+		chatReq.Messages = append(chatReq.Messages, callMsg, callbackMsg)
+		exchange = append(exchange, toolExchange(calls, callbacks)...)
+	}
+	return "", exchange, ErrMaxToolIterations
+}
@@ -0,0 +1,43 @@
+package agents
+
+import (
+	cohereClt "github.com/bububa/instructor-go/instructors/cohere"
+	cohere "github.com/cohere-ai/cohere-go/v2"
+
+	"github.com/bububa/atomic-agents/components"
+)
+
+// CohereAdapter adapts Cohere chat api
+type CohereAdapter struct{}
+
+func (CohereAdapter) BuildRequest(r *ChatRequest) *cohere.ChatRequest {
+	model := r.Model
+	chatReq := cohere.ChatRequest{
+		Model:   &model,
+		Message: r.InputText,
+	}
+	if r.Temperature != nil {
+		v := *r.Temperature
+		chatReq.Temperature = &v
+	}
+	if v := r.TopP; v > 1e-15 {
+		chatReq.P = &v
+	}
+	if v := r.MaxTokens; v > 0 {
+		chatReq.MaxTokens = &v
+	}
+	messages := r.Messages()
+	// the last message is the user input which sent as Message
+	for _, msg := range messages[:len(messages)-1] {
+		var v cohere.Message
+		cohereClt.ConvertMessageFrom(&msg, &v)
+		chatReq.ChatHistory = append(chatReq.ChatHistory, &v)
+	}
+	return &chatReq
+}
+
+func (CohereAdapter) ConvertResponse(res *cohere.NonStreamedChatResponse, dist *components.LLMResponse) {
+	dist.FromCohere(res)
+}
+
+var cohereProvider = NewProvider("cohere", CohereAdapter{})
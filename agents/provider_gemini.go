@@ -0,0 +1,159 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/bububa/instructor-go"
+	geminiClt "github.com/bububa/instructor-go/instructors/gemini"
+	geminiAPI "google.golang.org/genai"
+
+	"github.com/bububa/atomic-agents/components"
+)
+
+// GeminiAdapter adapts Gemini generate content api
+type GeminiAdapter struct{}
+
+func (GeminiAdapter) BuildRequest(r *ChatRequest) *geminiClt.Request {
+	chatReq := geminiClt.Request{
+		Model: r.Model,
+	}
+	{
+		var v geminiAPI.Content
+		geminiClt.ConvertMessageFrom(&r.System, &v)
+		chatReq.System = &v
+	}
+	chatReq.History = make([]*geminiAPI.Content, 0, len(r.History))
+	for _, msg := range r.History {
+		var v geminiAPI.Content
+		geminiClt.ConvertMessageFrom(&msg, &v)
+		chatReq.History = append(chatReq.History, &v)
+	}
+	{
+		var v geminiAPI.Content
+		geminiClt.ConvertMessageFrom(&r.Input, &v)
+		chatReq.Parts = append(chatReq.Parts, v.Parts...)
+	}
+	return &chatReq
+}
+
+func (GeminiAdapter) ConvertResponse(res *geminiAPI.GenerateContentResponse, dist *components.LLMResponse) {
+	dist.FromGemini(res)
+}
+
+// geminiRoundTripRequest is the request of a generate content call in tool calling loops, sent to RoundTrippers
+type geminiRoundTripRequest struct {
+	Model    string                           `json:"model"`
+	Contents []*geminiAPI.Content             `json:"contents"`
+	Config   *geminiAPI.GenerateContentConfig `json:"config,omitempty"`
+}
+
+type geminiToolProvider struct {
+	Provider
+	adapter Adapter[geminiClt.Request, geminiAPI.GenerateContentResponse]
+}
+
+var geminiProvider = &geminiToolProvider{
+	Provider: NewProvider("gemini", GeminiAdapter{}),
+	adapter:  GeminiAdapter{},
+}
+
+func (p *geminiToolProvider) ChatWithTools(ctx context.Context, clt instructor.Instructor, req *ChatRequest, llmResponse *components.LLMResponse) (string, []instructor.Message, error) {
+	c, ok := components.Unwrap(clt).(*geminiClt.Instructor)
+	if !ok {
+		return "", nil, ErrToolCallingNotSupported
+	}
+	chatReq := p.adapter.BuildRequest(req)
+	cfg := &geminiAPI.GenerateContentConfig{
+		SystemInstruction: chatReq.System,
+	}
+	if req.Temperature != nil {
+		v := float32(*req.Temperature)
+		cfg.Temperature = &v
+	}
+	if v := float32(req.TopP); v > 1e-15 {
+		cfg.TopP = &v
+	}
+	if v := float32(req.TopK); v > 0 {
+		cfg.TopK = &v
+	}
+	if v := req.MaxTokens; v > 0 {
+		cfg.MaxOutputTokens = int32(v)
+	}
+	if thinking := c.ThinkingConfig(); thinking != nil {
+		budget := int32(thinking.Budget)
+		cfg.ThinkingConfig = &geminiAPI.ThinkingConfig{
+			IncludeThoughts: thinking.Enabled,
+			ThinkingBudget:  &budget,
+		}
+	}
+	declarations := make([]*geminiAPI.FunctionDeclaration, 0, len(req.Tools))
+	for _, fn := range req.Tools {
+		declarations = append(declarations, &geminiAPI.FunctionDeclaration{
+			Name:                 fn.Title(),
+			Description:          fn.Description(),
+			ParametersJsonSchema: fn.Parameters(),
+		})
+	}
+	cfg.Tools = []*geminiAPI.Tool{{FunctionDeclarations: declarations}}
+	contents := make([]*geminiAPI.Content, 0, len(chatReq.History)+1)
+	contents = append(contents, chatReq.History...)
+	contents = append(contents, &geminiAPI.Content{
+		Role:  geminiAPI.RoleUser,
+		Parts: chatReq.Parts,
+	})
+	var (
+		usage    = new(components.LLMUsage)
+		exchange []instructor.Message
+	)
+	for range req.maxToolIterations() {
+		res := new(geminiAPI.GenerateContentResponse)
+		roundTripReq := &geminiRoundTripRequest{Model: req.Model, Contents: contents, Config: cfg}
+		if err := components.RoundTrip(ctx, clt, roundTripReq, res, func(ctx context.Context) error {
+			ret, err := c.Models.GenerateContent(ctx, req.Model, contents, cfg)
+			if ret != nil {
+				*res = *ret
+			}
+			return err
+		}); err != nil {
+			return "", exchange, err
+		}
+		resp := new(components.LLMResponse)
+		p.adapter.ConvertResponse(res, resp)
+		mergeToolLoopResponse(resp, usage, llmResponse)
+		var (
+			calls   []components.ToolCall
+			text    string
+			content *geminiAPI.Content
+		)
+		for _, cand := range res.Candidates {
+			if cand.Content == nil {
+				continue
+			}
+			content = cand.Content
+			for _, part := range cand.Content.Parts {
+				if fc := part.FunctionCall; fc != nil {
+					args, _ := json.Marshal(fc.Args)
+					calls = append(calls, components.ToolCall{
+						ID:        fc.ID,
+						Name:      fc.Name,
+						Arguments: string(args),
+					})
+				} else if part.Text != "" && !part.Thought {
+					text += part.Text
+				}
+			}
+			break
+		}
+		if len(calls) == 0 {
+			return text, exchange, nil
+		}
+		// the model content is sent back as is, thought signatures must accompany their function calls
+		var callbackContent geminiAPI.Content
+		callbacks := callTools(ctx, req.Tools, calls)
+		components.ToolCallbacksToGemini(callbacks, &callbackContent)
+		contents = append(contents, content, &callbackContent)
+		exchange = append(exchange, toolExchange(calls, callbacks)...)
+	}
+	return "", exchange, ErrMaxToolIterations
+}
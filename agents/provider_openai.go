@@ -0,0 +1,160 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"maps"
+
+	"github.com/bububa/instructor-go"
+	openaiClt "github.com/bububa/instructor-go/instructors/openai"
+	"github.com/openai/openai-go"
+
+	"github.com/bububa/atomic-agents/components"
+)
+
+// OpenAIAdapter adapts OpenAI chat completion api
+type OpenAIAdapter struct{}
+
+func (OpenAIAdapter) BuildRequest(r *ChatRequest) *openai.ChatCompletionNewParams {
+	chatReq := openai.ChatCompletionNewParams{
+		Model: r.Model,
+	}
+	if r.Temperature != nil {
+		chatReq.Temperature = openai.Float(*r.Temperature)
+	}
+	if r.TopP > 1e-15 {
+		chatReq.TopP = openai.Float(r.TopP)
+	}
+	if r.TopK > 0 {
+		chatReq.TopLogprobs = openai.Int(int64(r.TopK))
+	}
+	if r.MaxTokens > 0 {
+		chatReq.MaxCompletionTokens = openai.Int(int64(r.MaxTokens))
+	}
+	if r.ExtraBody != nil {
+		chatReq.SetExtraFields(map[string]any{
+			"extra_body": r.ExtraBody,
+		})
+	}
+	for _, msg := range r.Messages() {
+		chunks := openaiClt.ConvertMessageFrom(&msg)
+		if len(chunks) > 0 {
+			chatReq.Messages = append(chatReq.Messages, chunks...)
+		}
+	}
+	return &chatReq
+}
+
+func (OpenAIAdapter) ConvertResponse(res *openai.ChatCompletion, dist *components.LLMResponse) {
+	dist.FromOpenAI(res)
+}
+
+type openaiToolProvider struct {
+	Provider
+	adapter Adapter[openai.ChatCompletionNewParams, openai.ChatCompletion]
+}
+
+var openaiProvider = &openaiToolProvider{
+	Provider: NewProvider("openai", OpenAIAdapter{}),
+	adapter:  OpenAIAdapter{},
+}
+
+func (p *openaiToolProvider) ChatWithTools(ctx context.Context, clt instructor.Instructor, req *ChatRequest, llmResponse *components.LLMResponse) (string, []instructor.Message, error) {
+	c, ok := components.Unwrap(clt).(*openaiClt.Instructor)
+	if !ok {
+		return "", nil, ErrToolCallingNotSupported
+	}
+	chatReq := p.adapter.BuildRequest(req)
+	applyOpenAIOptions(&c.Options, chatReq)
+	for _, fn := range req.Tools {
+		params := fn.Parameters()
+		chatReq.Tools = append(chatReq.Tools, openai.ChatCompletionToolParam{
+			Function: openai.FunctionDefinitionParam{
+				Name:        fn.Title(),
+				Description: openai.String(fn.Description()),
+				Parameters: openai.FunctionParameters{
+					"type":       params.Type,
+					"required":   params.Required,
+					"properties": params.Properties,
+				},
+			},
+		})
+	}
+	var (
+		usage    = new(components.LLMUsage)
+		exchange []instructor.Message
+	)
+	for range req.maxToolIterations() {
+		res := new(openai.ChatCompletion)
+		if err := components.RoundTrip(ctx, clt, chatReq, res, func(ctx context.Context) error {
+			ret, err := c.Client.Chat.Completions.New(ctx, *chatReq)
+			if ret != nil {
+				*res = *ret
+			}
+			return err
+		}); err != nil {
+			return "", exchange, err
+		}
+		resp := new(components.LLMResponse)
+		p.adapter.ConvertResponse(res, resp)
+		mergeToolLoopResponse(resp, usage, llmResponse)
+		if len(res.Choices) == 0 {
+			return "", exchange, errors.New("empty response choices")
+		}
+		toolCalls := res.Choices[0].Message.ToolCalls
+		if len(toolCalls) == 0 {
+			return res.Choices[0].Message.Content, exchange, nil
+		}
+		calls := make([]components.ToolCall, 0, len(toolCalls))
+		for _, v := range toolCalls {
+			calls = append(calls, components.ToolCall{
+				ID:        v.ID,
+				Name:      v.Function.Name,
+				Arguments: v.Function.Arguments,
+			})
+		}
+		var callMsg openai.ChatCompletionMessageParamUnion
+		components.ToolCallsToOpenAI(calls, &callMsg)
+		callbacks := callTools(ctx, req.Tools, calls)
+		chatReq.Messages = append(chatReq.Messages, callMsg)
+		chatReq.Messages = append(chatReq.Messages, components.ToolCallbacksToOpenAI(callbacks)...)
+		exchange = append(exchange, toolExchange(calls, callbacks)...)
+	}
+	return "", exchange, ErrMaxToolIterations
+}
+
+// applyOpenAIOptions sets the extra body and thinking options of the instructor into the request,
+// like the instructor does for the requests it sends itself
+func applyOpenAIOptions(opts *instructor.Options, chatReq *openai.ChatCompletionNewParams) {
+	extraFields := chatReq.ExtraFields()
+	if extraBody := opts.ExtraBody(); extraBody != nil {
+		if extraFields == nil {
+			extraFields = make(map[string]any, 1)
+		}
+		if _, ok := extraFields["extra_body"]; !ok {
+			extraFields["extra_body"] = extraBody
+		}
+	}
+	if thinking := opts.ThinkingConfig(); thinking != nil {
+		if extraFields == nil {
+			extraFields = make(map[string]any, 3)
+		}
+		if kv := thinking.Marshaler; kv != nil {
+			maps.Copy(extraFields, kv())
+		} else {
+			typ := "disabled"
+			if thinking.Enabled {
+				typ = "enabled"
+			}
+			extraFields["enable_thinking"] = thinking.Enabled
+			extraFields["thinking"] = map[string]string{"type": typ}
+			extraFields["chat_template_kwargs"] = map[string]any{
+				"enable_thinking": thinking.Enabled,
+				"thinking_budget": thinking.Budget,
+			}
+		}
+	}
+	if extraFields != nil {
+		chatReq.SetExtraFields(extraFields)
+	}
+}
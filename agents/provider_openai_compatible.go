@@ -0,0 +1,176 @@
+package agents
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/bububa/instructor-go"
+	openaiClt "github.com/bububa/instructor-go/instructors/openai"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+
+	"github.com/bububa/atomic-agents/components"
+)
+
+// OpenAICompatible configures endpoints which speak the OpenAI chat completions dialect, e.g. Ollama, vLLM, llama.cpp servers
+type OpenAICompatible struct {
+	// BaseURL overrides the client endpoint, e.g. http://localhost:11434/v1
+	BaseURL string
+	// APIKey overrides the client api key
+	APIKey string
+	// JSONModeFallback downgrades json schema response format into json_object response format with the schema in prompt
+	JSONModeFallback bool
+	// NoStrictSchema disables strict json schema and strict tool calling
+	NoStrictSchema bool
+	// NativeTopK sends top_k instead of abusing top_logprobs
+	NativeTopK bool
+	// FlattenExtraBody merges extra body fields into the request body instead of sending an extra_body field
+	FlattenExtraBody bool
+}
+
+// Mode returns the instructor mode supported by the endpoint
+func (c *OpenAICompatible) Mode(mode instructor.Mode) instructor.Mode {
+	if c.JSONModeFallback && (mode == instructor.ModeJSONSchema || mode == instructor.ModeJSONStrict) {
+		return instructor.ModeJSON
+	}
+	if c.NoStrictSchema {
+		switch mode {
+		case instructor.ModeJSONStrict:
+			return instructor.ModeJSONSchema
+		case instructor.ModeToolCallStrict:
+			return instructor.ModeToolCall
+		}
+	}
+	return mode
+}
+
+// OpenAICompatibleAdapter adapts OpenAI-compatible chat completions api
+type OpenAICompatibleAdapter struct {
+	Config OpenAICompatible
+}
+
+func (a OpenAICompatibleAdapter) BuildRequest(r *ChatRequest) *openai.ChatCompletionNewParams {
+	req := *r
+	if a.Config.NativeTopK {
+		req.TopK = 0
+	}
+	if a.Config.FlattenExtraBody {
+		req.ExtraBody = nil
+	}
+	chatReq := OpenAIAdapter{}.BuildRequest(&req)
+	extraFields := make(map[string]any)
+	if a.Config.NativeTopK && r.TopK > 0 {
+		extraFields["top_k"] = r.TopK
+	}
+	if a.Config.FlattenExtraBody {
+		maps.Copy(extraFields, r.ExtraBody)
+	}
+	if len(extraFields) > 0 {
+		maps.Copy(extraFields, chatReq.ExtraFields())
+		chatReq.SetExtraFields(extraFields)
+	}
+	return chatReq
+}
+
+func (a OpenAICompatibleAdapter) ConvertResponse(res *openai.ChatCompletion, dist *components.LLMResponse) {
+	dist.FromOpenAI(res)
+}
+
+// openaiCompatibleProvider drives OpenAI instructors against OpenAI-compatible endpoints
+type openaiCompatibleProvider struct {
+	*openaiToolProvider
+	config OpenAICompatible
+	// clients caches the clients of the endpoint by the client of the OpenAI instructor
+	clients sync.Map
+}
+
+// NewOpenAICompatibleProvider returns a ToolProvider drives OpenAI instructors against OpenAI-compatible endpoints
+func NewOpenAICompatibleProvider(config OpenAICompatible) ToolProvider {
+	adapter := OpenAICompatibleAdapter{Config: config}
+	return &openaiCompatibleProvider{
+		openaiToolProvider: &openaiToolProvider{
+			Provider: NewProvider("openai_compatible", adapter),
+			adapter:  adapter,
+		},
+		config: config,
+	}
+}
+
+func (p *openaiCompatibleProvider) Match(clt instructor.Instructor) bool {
+	_, ok := components.Unwrap(clt).(*openaiClt.Instructor)
+	return ok
+}
+
+// instructor returns clt with the innermost OpenAI instructor replaced by a copy with the endpoint and mode of the OpenAI-compatible server,
+// wrappers like rate limiters or recorders are kept around the copy. The copy shares memory with clt.
+func (p *openaiCompatibleProvider) instructor(clt instructor.Instructor) (instructor.Instructor, error) {
+	c, ok := components.Unwrap(clt).(*openaiClt.Instructor)
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	ret := &openaiClt.Instructor{
+		Client:  p.client(c.Client),
+		Options: c.Options,
+	}
+	if mode := p.config.Mode(c.Mode()); mode != c.Mode() {
+		instructor.WithMode(mode)(&ret.Options)
+	}
+	return components.Rewrap(clt, ret)
+}
+
+// client returns the client of the endpoint built from the options of clt, built once per clt
+func (p *openaiCompatibleProvider) client(clt *openai.Client) *openai.Client {
+	if p.config.BaseURL == "" && p.config.APIKey == "" {
+		return clt
+	}
+	if v, ok := p.clients.Load(clt); ok {
+		return v.(*openai.Client)
+	}
+	var opts []option.RequestOption
+	if clt != nil {
+		opts = slices.Clone(clt.Options)
+	}
+	if p.config.BaseURL != "" {
+		opts = append(opts, option.WithBaseURL(p.config.BaseURL))
+	}
+	if p.config.APIKey != "" {
+		opts = append(opts, option.WithAPIKey(p.config.APIKey))
+	}
+	client := openai.NewClient(opts...)
+	v, _ := p.clients.LoadOrStore(clt, &client)
+	return v.(*openai.Client)
+}
+
+func (p *openaiCompatibleProvider) Chat(ctx context.Context, clt instructor.Instructor, req *ChatRequest, response any, llmResponse *components.LLMResponse) error {
+	c, err := p.instructor(clt)
+	if err != nil {
+		return err
+	}
+	return p.Provider.Chat(ctx, c, req, response, llmResponse)
+}
+
+func (p *openaiCompatibleProvider) Stream(ctx context.Context, clt instructor.Instructor, req *ChatRequest, responseType any) (<-chan instructor.StreamData, MergeResponse, error) {
+	c, err := p.instructor(clt)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p.Provider.Stream(ctx, c, req, responseType)
+}
+
+func (p *openaiCompatibleProvider) SchemaStream(ctx context.Context, clt instructor.Instructor, req *ChatRequest, responseType any) (<-chan any, <-chan instructor.StreamData, MergeResponse, error) {
+	c, err := p.instructor(clt)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return p.Provider.SchemaStream(ctx, c, req, responseType)
+}
+
+func (p *openaiCompatibleProvider) ChatWithTools(ctx context.Context, clt instructor.Instructor, req *ChatRequest, llmResponse *components.LLMResponse) (string, []instructor.Message, error) {
+	c, err := p.instructor(clt)
+	if err != nil {
+		return "", nil, err
+	}
+	return p.openaiToolProvider.ChatWithTools(ctx, c, req, llmResponse)
+}
@@ -0,0 +1,188 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/bububa/instructor-go"
+	openaiClt "github.com/bububa/instructor-go/instructors/openai"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/ratelimit"
+	"github.com/bububa/atomic-agents/internal/llmtest"
+	"github.com/bububa/atomic-agents/schema"
+	"github.com/bububa/atomic-agents/tools"
+	"github.com/bububa/atomic-agents/tools/calculator"
+)
+
+// startOpenAICompatibleServer starts a stand-in chat completions server answering the reply of every request body
+func startOpenAICompatibleServer(t *testing.T, reply func(body map[string]any) llmtest.Reply) *llmtest.Server {
+	return llmtest.NewServer(t, reply)
+}
+
+// replyMessageCount replies the number of messages received, system prompt included
+func replyMessageCount(body map[string]any) llmtest.Reply {
+	return llmtest.Reply{Content: fmt.Sprintf(`{"chat_message":"%d"}`, len(llmtest.Messages(body)))}
+}
+
+func newOpenAICompatibleTestClient(mode instructor.Mode) instructor.Instructor {
+	// the default endpoint is unreachable, requests must be sent to the OpenAICompatible BaseURL
+	clt := openai.NewClient(option.WithBaseURL("http://127.0.0.1:0"), option.WithAPIKey("test"), option.WithMaxRetries(0))
+	return openaiClt.New(&clt, instructor.WithMode(mode), instructor.WithMaxRetries(0))
+}
+
+func TestOpenAICompatibleJSONModeFallback(t *testing.T) {
+	srv := startOpenAICompatibleServer(t, llmtest.Text(`{"chat_message":"hello"}`))
+	agent := NewAgent[schema.Input, schema.Output](
+		WithClient(newOpenAICompatibleTestClient(instructor.ModeJSONSchema)),
+		WithModel("llama3"),
+		WithTopK(40),
+		WithOpenAICompatible(OpenAICompatible{
+			BaseURL:          srv.URL,
+			JSONModeFallback: true,
+			NativeTopK:       true,
+			FlattenExtraBody: true,
+		}),
+	)
+	input := schema.NewInput("hi")
+	input.SetExtraBody(map[string]any{"num_ctx": 4096})
+	output := new(schema.Output)
+	var apiResp components.LLMResponse
+	if err := agent.Run(context.Background(), input, output, &apiResp); err != nil {
+		t.Fatalf("run agent failed: %v", err)
+	}
+	body := srv.Last()
+	if output.ChatMessage != "hello" {
+		t.Errorf("expect output hello, got %s", output.ChatMessage)
+	}
+	if format, _ := body["response_format"].(map[string]any); format["type"] != "json_object" {
+		t.Errorf("expect json_object response format, got %v", body["response_format"])
+	}
+	if v, _ := body["top_k"].(float64); v != 40 {
+		t.Errorf("expect top_k 40, got %v", body["top_k"])
+	}
+	if _, ok := body["top_logprobs"]; ok {
+		t.Errorf("expect no top_logprobs, got %v", body["top_logprobs"])
+	}
+	if v, _ := body["num_ctx"].(float64); v != 4096 {
+		t.Errorf("expect flattened num_ctx 4096, got %v", body["num_ctx"])
+	}
+	if _, ok := body["extra_body"]; ok {
+		t.Errorf("expect no extra_body, got %v", body["extra_body"])
+	}
+	if apiResp.Usage == nil || apiResp.Usage.InputTokens != 12 || apiResp.Usage.OutputTokens != 5 {
+		t.Errorf("unexpected usage: %+v", apiResp.Usage)
+	}
+}
+
+func TestOpenAICompatibleNoStrictSchema(t *testing.T) {
+	srv := startOpenAICompatibleServer(t, llmtest.Text(`{"chat_message":"hello"}`))
+	agent := NewAgent[schema.Input, schema.Output](
+		WithClient(newOpenAICompatibleTestClient(instructor.ModeJSONStrict)),
+		WithModel("llama3"),
+		WithTopK(40),
+		WithOpenAICompatible(OpenAICompatible{
+			BaseURL:        srv.URL,
+			NoStrictSchema: true,
+		}),
+	)
+	output := new(schema.Output)
+	if err := agent.Run(context.Background(), schema.NewInput("hi"), output, nil); err != nil {
+		t.Fatalf("run agent failed: %v", err)
+	}
+	body := srv.Last()
+	format, _ := body["response_format"].(map[string]any)
+	if format["type"] != "json_schema" {
+		t.Fatalf("expect json_schema response format, got %v", body["response_format"])
+	}
+	if jsonSchema, _ := format["json_schema"].(map[string]any); jsonSchema["strict"] == true {
+		t.Errorf("expect non-strict json schema, got %v", jsonSchema)
+	}
+	if v, _ := body["top_logprobs"].(float64); v != 40 {
+		t.Errorf("expect top_logprobs 40 without native top_k, got %v", body["top_logprobs"])
+	}
+}
+
+func TestOpenAICompatibleToolCalling(t *testing.T) {
+	srv := startOpenAICompatibleServer(t, func(body map[string]any) llmtest.Reply {
+		// the tool is called first, then the tool result is answered
+		if messages := llmtest.Messages(body); messages[len(messages)-1].(map[string]any)["role"] != "tool" {
+			return llmtest.Reply{ToolCalls: []llmtest.ToolCall{{ID: "call_1", Name: "CalculatorTool", Arguments: `{"expression":"2 + 3"}`}}}
+		}
+		return llmtest.Reply{Content: `{"chat_message":"5"}`}
+	})
+	agent := NewAgent[schema.Input, schema.Output](
+		WithClient(newOpenAICompatibleTestClient(instructor.ModeJSON)),
+		WithModel("llama3"),
+		WithOpenAICompatible(OpenAICompatible{BaseURL: srv.URL}),
+		WithTools(tools.NewFunction[calculator.Input](calculator.New())),
+	)
+	output := new(schema.Output)
+	var apiResp components.LLMResponse
+	if err := agent.Run(context.Background(), schema.NewInput("2 + 3 = ?"), output, &apiResp); err != nil {
+		t.Fatalf("run agent failed: %v", err)
+	}
+	if output.ChatMessage != "5" {
+		t.Errorf("expect output 5, got %s", output.ChatMessage)
+	}
+	requests := srv.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("expect 2 requests, got %d", len(requests))
+	}
+	if fns, _ := requests[0]["tools"].([]any); len(fns) != 1 {
+		t.Errorf("expect 1 tool, got %v", requests[0]["tools"])
+	}
+	messages, _ := requests[1]["messages"].([]any)
+	last, _ := messages[len(messages)-1].(map[string]any)
+	if last["role"] != "tool" || last["tool_call_id"] != "call_1" || last["content"] != `{"result":5}` {
+		t.Errorf("unexpected tool callback message: %v", last)
+	}
+	if apiResp.Usage == nil || apiResp.Usage.InputTokens != 24 || apiResp.Usage.OutputTokens != 10 {
+		t.Errorf("expect usage summed across calls, got %+v", apiResp.Usage)
+	}
+}
+
+func TestOpenAICompatibleWrappedClient(t *testing.T) {
+	srv := startOpenAICompatibleServer(t, func(body map[string]any) llmtest.Reply {
+		if messages := llmtest.Messages(body); messages[len(messages)-1].(map[string]any)["role"] != "tool" && body["tools"] != nil {
+			return llmtest.Reply{ToolCalls: []llmtest.ToolCall{{ID: "call_1", Name: "CalculatorTool", Arguments: `{"expression":"2 + 3"}`}}}
+		}
+		return llmtest.Reply{Content: `{"chat_message":"5"}`}
+	})
+	registry := ratelimit.NewRegistry().Set(instructor.ProviderOpenAI, "llama3", ratelimit.Limit{RPM: 3})
+	clt := ratelimit.NewInstructor(newOpenAICompatibleTestClient(instructor.ModeJSON).(*openaiClt.Instructor), registry)
+	p := NewOpenAICompatibleProvider(OpenAICompatible{BaseURL: srv.URL}).(*openaiCompatibleProvider)
+	if !p.Match(clt) {
+		t.Fatal("expect wrapped OpenAI instructor matched")
+	}
+	ctx := ratelimit.WithFailFast(context.Background())
+	agent := NewAgent[schema.Input, schema.Output](WithClient(clt), WithModel("llama3"), WithProvider(p))
+	output := new(schema.Output)
+	if err := agent.Run(ctx, schema.NewInput("2 + 3 = ?"), output, nil); err != nil || output.ChatMessage != "5" {
+		t.Fatalf("expect chat through the wrapped client, got %q, %v", output.ChatMessage, err)
+	}
+	toolAgent := NewAgent[schema.Input, schema.Output](
+		WithClient(clt),
+		WithModel("llama3"),
+		WithProvider(p),
+		WithTools(tools.NewFunction[calculator.Input](calculator.New())),
+	)
+	if err := toolAgent.Run(ctx, schema.NewInput("2 + 3 = ?"), output, nil); err != nil || output.ChatMessage != "5" {
+		t.Fatalf("expect tool calling through the wrapped client, got %q, %v", output.ChatMessage, err)
+	}
+	// the chat and both requests of the tool calling loop were limited
+	if err := agent.Run(ctx, schema.NewInput("2 + 3 = ?"), output, nil); !errors.Is(err, ratelimit.ErrRateLimited) {
+		t.Errorf("expect requests limited by the wrapper, got %v", err)
+	}
+	if got := len(srv.Requests()); got != 3 {
+		t.Errorf("expect 3 requests sent to the endpoint, got %d", got)
+	}
+	inner := clt.Instructor().(*openaiClt.Instructor)
+	if p.client(inner.Client) != p.client(inner.Client) {
+		t.Error("expect the endpoint client built once per instructor client")
+	}
+}
@@ -0,0 +1,90 @@
+package agents
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bububa/instructor-go"
+	anthropicClt "github.com/bububa/instructor-go/instructors/anthropic"
+	openaiClt "github.com/bububa/instructor-go/instructors/openai"
+	anthropic "github.com/liushuangls/go-anthropic/v2"
+	"github.com/openai/openai-go"
+)
+
+// namedProvider overrides the name of a registered Provider
+type namedProvider struct {
+	Provider
+	name string
+}
+
+func (p namedProvider) Name() string {
+	return p.name
+}
+
+// unknownInstructor is an instructor no registered Provider could drive
+type unknownInstructor struct {
+	instructor.Instructor
+}
+
+func TestRegisterProvider(t *testing.T) {
+	list := providers.list
+	t.Cleanup(func() {
+		providers.Lock()
+		providers.list = list
+		providers.Unlock()
+	})
+	clt := openai.NewClient()
+	openaiInstructor := openaiClt.New(&clt)
+	if p, err := LookupProvider(openaiInstructor); err != nil || p.Name() != "openai" {
+		t.Fatalf("expect openai provider, got %v, %v", p, err)
+	}
+	RegisterProvider(namedProvider{Provider: openaiProvider, name: "custom"})
+	if p, err := LookupProvider(openaiInstructor); err != nil || p.Name() != "custom" {
+		t.Errorf("expect provider registered later to take precedence, got %v, %v", p, err)
+	}
+	if p, err := LookupProvider(anthropicClt.New(anthropic.NewClient("test"))); err != nil || p.Name() != "anthropic" {
+		t.Errorf("expect anthropic provider, got %v, %v", p, err)
+	}
+	if _, err := LookupProvider(unknownInstructor{}); !errors.Is(err, ErrUnknownProvider) {
+		t.Errorf("expect unknown provider, got %v", err)
+	}
+}
+
+func TestAdapterBuildRequest(t *testing.T) {
+	zero := 0.0
+	tests := []struct {
+		name        string
+		temperature *float64
+	}{
+		{name: "provider default"},
+		{name: "explicit zero", temperature: &zero},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &ChatRequest{
+				Model:       "test",
+				Temperature: tt.temperature,
+				System:      instructor.Message{Role: instructor.SystemRole, Text: "system"},
+				Input:       instructor.Message{Role: instructor.UserRole, Text: "hi"},
+			}
+			openaiReq := OpenAIAdapter{}.BuildRequest(req)
+			if got := openaiReq.Temperature.Valid(); got != (tt.temperature != nil) {
+				t.Errorf("openai temperature sent %v", got)
+			}
+			if len(openaiReq.Messages) != 2 {
+				t.Errorf("expect system and input messages, got %d", len(openaiReq.Messages))
+			}
+			anthropicReq := AnthropicAdapter{}.BuildRequest(req)
+			if got := anthropicReq.Temperature != nil; got != (tt.temperature != nil) {
+				t.Errorf("anthropic temperature sent %v", got)
+			}
+			if anthropicReq.MaxTokens != DefaultAnthropicMaxTokens {
+				t.Errorf("expect default max tokens, got %d", anthropicReq.MaxTokens)
+			}
+			cohereReq := CohereAdapter{}.BuildRequest(req)
+			if got := cohereReq.Temperature != nil; got != (tt.temperature != nil) {
+				t.Errorf("cohere temperature sent %v", got)
+			}
+		})
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"github.com/bububa/atomic-agents/components"
 	"github.com/bububa/atomic-agents/components/document"
 	"github.com/bububa/atomic-agents/components/embedder"
+	"github.com/bububa/atomic-agents/components/interceptor"
+	"github.com/bububa/atomic-agents/components/tracing"
 	"github.com/bububa/atomic-agents/components/vectordb"
 	"github.com/bububa/atomic-agents/schema"
 	"github.com/bububa/instructor-go"
@@ -26,6 +28,7 @@ type Options struct {
 }
 
 type RAG[O schema.Schema] struct {
+	interceptor.Stack
 	agent agents.TypeableAgent[schema.String, O]
 	Options
 }
@@ -112,7 +115,10 @@ func (r *RAG[O]) AddDocuments(ctx context.Context, collectionName string, docs .
 			parts = []string{content}
 		}
 		usage := new(components.LLMUsage)
-		embeddings, err := r.embedder.BatchEmbed(ctx, parts, usage)
+		spanCtx, span := r.startEmbedderSpan(ctx, len(parts))
+		embeddings, err := r.embedder.BatchEmbed(spanCtx, parts, usage)
+		span.SetUsage(usage)
+		span.EndErr(err)
 		totalUsage.Merge(usage)
 		if err != nil {
 			return totalUsage, err
@@ -132,10 +138,17 @@ func (r *RAG[O]) AddDocuments(ctx context.Context, collectionName string, docs .
 func (r *RAG[O]) Search(ctx context.Context, query string, opts ...vectordb.SearchOption) ([]vectordb.Record, *components.LLMUsage, error) {
 	embedding := new(embedder.Embedding)
 	usage := new(components.LLMUsage)
-	if err := r.embedder.Embed(ctx, query, embedding, usage); err != nil {
+	spanCtx, span := r.startEmbedderSpan(ctx, 1)
+	err := r.embedder.Embed(spanCtx, query, embedding, usage)
+	span.SetUsage(usage)
+	span.EndErr(err)
+	if err != nil {
 		return nil, nil, err
 	}
-	records, err := r.vectordb.Search(ctx, embedding.Embedding, opts...)
+	spanCtx, span = tracing.Start(ctx, tracing.VectorDBSearchSpan)
+	records, err := r.vectordb.Search(spanCtx, embedding.Embedding, opts...)
+	span.SetAttributes(tracing.RecordsKey.Int(len(records)))
+	span.EndErr(err)
 	if err != nil {
 		return nil, usage, err
 	}
@@ -143,6 +156,12 @@ func (r *RAG[O]) Search(ctx context.Context, query string, opts ...vectordb.Sear
 }
 
 func (r *RAG[O]) Run(ctx context.Context, query *schema.String, output *O, llmResp *components.LLMResponse) error {
+	call := &interceptor.Call{Kind: interceptor.RAGCall, Name: r.name, Target: r}
+	return interceptor.Typed(ctx, call, r.Interceptors(), query, output, llmResp, r.invoke)
+}
+
+func (r *RAG[O]) invoke(ctx context.Context, query *schema.String, output *O, llmResp *components.LLMResponse) error {
+	ctx = components.WithCostScope(ctx, r.name)
 	enhancedQuery, err := r.generateEnhancedQuery(ctx, query, llmResp)
 	if err != nil {
 		return err
@@ -193,6 +212,7 @@ func (r *RAG[O]) Stream(ctx context.Context, query *schema.String) (<-chan instr
 	if !ok {
 		return nil, nil, errors.New("RAG agent is not streamable")
 	}
+	ctx = components.WithCostScope(ctx, r.name)
 	llmResp := new(components.LLMResponse)
 	enhancedQuery, err := r.generateEnhancedQuery(ctx, query, llmResp)
 	if err != nil {
@@ -248,6 +268,15 @@ func (r *RAG[O]) StreamAnonymous(ctx context.Context, query any) (<-chan instruc
 	return r.Stream(ctx, input)
 }
 
+// startEmbedderSpan starts an embedder span with the embedder provider and model
+func (r *RAG[O]) startEmbedderSpan(ctx context.Context, inputs int) (context.Context, *tracing.Span) {
+	return tracing.Start(ctx, tracing.EmbedderEmbedSpan,
+		tracing.ProviderKey.String(string(r.embedder.Provider())),
+		tracing.RequestModelKey.String(r.embedder.Model()),
+		tracing.InputsKey.Int(inputs),
+	)
+}
+
 func (r *RAG[O]) generateEnhancedQuery(ctx context.Context, query *schema.String, llmResp *components.LLMResponse) (string, error) {
 	if r.enhanceQueryAgent == nil {
 		return query.String(), nil
@@ -0,0 +1,154 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/bububa/instructor-go"
+
+	"github.com/bububa/atomic-agents/components"
+)
+
+// RetryPolicy configures how Agent.Run retries failed LLM calls
+type RetryPolicy struct {
+	// MaxAttempts maximum number of attempts including the first one
+	MaxAttempts int
+	// Backoff returns the delay before the next attempt, attempt starts from 1
+	Backoff func(attempt int) time.Duration
+	// Retryable error classes which should be retried
+	Retryable ErrorClass
+	// Feedback formats the schema error fed back to the model, DefaultRetryFeedback is used if nil
+	Feedback func(err error) string
+}
+
+// DefaultRetryPolicy retries schema, rate limit, timeout, server and network errors up to 3 attempts with exponential backoff
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	Backoff:     ExponentialBackoff(500*time.Millisecond, 10*time.Second),
+	Retryable:   SchemaError | RateLimitError | TimeoutError | ServerError | NetworkError,
+}
+
+// ConstantBackoff waits the same delay before every retry
+func ConstantBackoff(d time.Duration) func(int) time.Duration {
+	return func(int) time.Duration {
+		return d
+	}
+}
+
+// ExponentialBackoff doubles the delay before every retry from base up to max
+func ExponentialBackoff(base time.Duration, max time.Duration) func(int) time.Duration {
+	return func(attempt int) time.Duration {
+		d := base
+		for i := 1; i < attempt; i++ {
+			d *= 2
+			if max > 0 && d >= max {
+				return max
+			}
+		}
+		return d
+	}
+}
+
+// DefaultRetryFeedback asks the model to correct its last response
+func DefaultRetryFeedback(err error) string {
+	return fmt.Sprintf("Your previous response could not be parsed into the output schema or violated its constraints:\n%s\n\nPlease correct it and respond again strictly following the output schema.", err.Error())
+}
+
+func (p *RetryPolicy) feedback(err error) string {
+	if p.Feedback != nil {
+		return p.Feedback(err)
+	}
+	return DefaultRetryFeedback(err)
+}
+
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	if p.Backoff != nil {
+		return p.Backoff(attempt)
+	}
+	return 0
+}
+
+// chatWithRetry runs chat following the agent RetryPolicy. Schema errors are fed back to the model,
+// failed attempts are kept out of memory and usage of every attempt is aggregated into llmResponse.
+func (a *Agent[I, O]) chatWithRetry(ctx context.Context, chat func(context.Context, *ChatRequest, *O, *components.LLMResponse) error, req *ChatRequest, response *O, llmResponse *components.LLMResponse) error {
+	policy := a.retryPolicy
+	if policy == nil || policy.MaxAttempts <= 1 {
+		return chat(ctx, req, response, llmResponse)
+	}
+	var (
+		memory  = a.Memory()
+		history []instructor.Message
+		input   = req.Input
+		usage   = new(components.LLMUsage)
+	)
+	if memory != nil {
+		history = slices.Clone(memory.List())
+	}
+	attemptReq := req
+	for attempt := 1; ; attempt++ {
+		resp := new(components.LLMResponse)
+		err := chat(ctx, attemptReq, response, resp)
+		usage.Merge(resp.Usage)
+		if llmResponse != nil {
+			*llmResponse = *resp
+			llmResponse.Usage = usage
+		}
+		var added []instructor.Message
+		if memory != nil {
+			if list := memory.List(); len(list) > len(history) {
+				added = slices.Clone(list[len(history):])
+			}
+		}
+		if err == nil {
+			if memory != nil && attemptReq != req && len(added) > 0 {
+				// the feedback message is the input of the attempt, the first message it added to memory, replace it with the user input
+				added[0] = input
+				memory.Set(append(history, added...))
+			}
+			return nil
+		}
+		if memory != nil {
+			memory.Set(history)
+		}
+		class := ClassifyError(err)
+		if attempt >= policy.MaxAttempts || !policy.Retryable.Has(class) {
+			return err
+		}
+		if class == SchemaError {
+			attemptReq = feedbackRequest(req, added, policy.feedback(err))
+		}
+		*response = *new(O)
+		if d := policy.backoff(attempt); d > 0 {
+			timer := time.NewTimer(d)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return errors.Join(err, ctx.Err())
+			case <-timer.C:
+			}
+		}
+	}
+}
+
+// feedbackRequest returns a copy of req which continues the conversation of the failed attempt with the feedback message
+func feedbackRequest(req *ChatRequest, added []instructor.Message, feedback string) *ChatRequest {
+	ret := *req
+	ret.History = make([]instructor.Message, 0, len(req.History)+2)
+	ret.History = append(ret.History, req.History...)
+	ret.History = append(ret.History, req.Input)
+	for idx := len(added) - 1; idx >= 0; idx-- {
+		if msg := added[idx]; msg.Role == instructor.AssistantRole && msg.Text != "" {
+			ret.History = append(ret.History, msg)
+			break
+		}
+	}
+	ret.Input = instructor.Message{
+		Role: instructor.UserRole,
+		Text: feedback,
+	}
+	ret.InputText = feedback
+	return &ret
+}
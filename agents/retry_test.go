@@ -0,0 +1,95 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bububa/instructor-go"
+	openaiClt "github.com/bububa/instructor-go/instructors/openai"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/internal/llmtest"
+	"github.com/bububa/atomic-agents/schema"
+)
+
+func TestRetryPolicySchemaFeedback(t *testing.T) {
+	srv := startOpenAICompatibleServer(t, func(body map[string]any) llmtest.Reply {
+		// the first reply fails to parse
+		if len(llmtest.Messages(body)) == 2 {
+			return llmtest.Reply{Content: "oops"}
+		}
+		return llmtest.Reply{Content: `{"chat_message":"hello"}`}
+	})
+	agent := NewAgent[schema.Input, schema.Output](
+		WithClient(newOpenAICompatibleTestClient(instructor.ModeJSON)),
+		WithModel("llama3"),
+		WithOpenAICompatible(OpenAICompatible{BaseURL: srv.URL}),
+		WithRetryPolicy(RetryPolicy{
+			MaxAttempts: 2,
+			Backoff:     ConstantBackoff(time.Millisecond),
+			Retryable:   SchemaError,
+		}),
+	)
+	output := new(schema.Output)
+	var apiResp components.LLMResponse
+	if err := agent.Run(context.Background(), schema.NewInput("hi"), output, &apiResp); err != nil {
+		t.Fatalf("run agent failed: %v", err)
+	}
+	if output.ChatMessage != "hello" {
+		t.Errorf("expect output hello, got %s", output.ChatMessage)
+	}
+	requests := srv.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("expect 2 requests, got %d", len(requests))
+	}
+	messages := llmtest.Messages(requests[1])
+	if len(messages) < 2 {
+		t.Fatalf("unexpected retry messages: %v", messages)
+	}
+	reply, _ := messages[len(messages)-2].(map[string]any)
+	if reply["role"] != "assistant" || reply["content"] != "oops" {
+		t.Errorf("expect failed reply in retry conversation, got %v", reply)
+	}
+	feedback, _ := messages[len(messages)-1].(map[string]any)
+	if content, _ := json.Marshal(feedback["content"]); feedback["role"] != "user" || !strings.Contains(string(content), "could not be parsed") {
+		t.Errorf("expect schema error feedback, got %v", feedback)
+	}
+	if apiResp.Usage == nil || apiResp.Usage.InputTokens != 24 || apiResp.Usage.OutputTokens != 10 {
+		t.Errorf("expect usage summed across attempts, got %+v", apiResp.Usage)
+	}
+	history := agent.Memory().List()
+	if len(history) != 2 || history[1].Text != `{"chat_message":"hello"}` || history[0].Text != schema.NewInput("hi").String() {
+		t.Errorf("expect failed attempts kept out of memory, got %+v", history)
+	}
+}
+
+func TestRetryPolicyToolLoopError(t *testing.T) {
+	// the model keeps calling the calculator, the tool loop fails after the model responded
+	srv := llmtest.NewServer(t, func(map[string]any) llmtest.Reply {
+		return llmtest.Reply{ToolCalls: []llmtest.ToolCall{{ID: "call_1", Name: "CalculatorTool", Arguments: `{"expression":"2 + 3"}`}}}
+	})
+	clt := openai.NewClient(option.WithBaseURL(srv.URL), option.WithAPIKey("test"), option.WithMaxRetries(0))
+	agent := newCalculatorAgent(openaiClt.New(&clt, instructor.WithMode(instructor.ModeJSON)))
+	agent.SetRetryPolicy(&RetryPolicy{MaxAttempts: 2, Retryable: SchemaError})
+	if err := agent.Run(context.Background(), schema.NewInput("2 + 3 = ?"), new(schema.Output), nil); !errors.Is(err, ErrMaxToolIterations) {
+		t.Fatalf("expect max tool iterations exceeded, got %v", err)
+	}
+	if got := len(srv.Requests()); got != 2 {
+		t.Errorf("expect tool loop errors not retried as schema errors, got %d requests", got)
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(time.Second, 5*time.Second)
+	for attempt, expect := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second} {
+		if d := backoff(attempt + 1); d != expect {
+			t.Errorf("attempt %d: expect %v, got %v", attempt+1, expect, d)
+		}
+	}
+}
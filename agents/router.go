@@ -0,0 +1,315 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bububa/instructor-go"
+	"github.com/bububa/instructor-go/encoding"
+	jsonenc "github.com/bububa/instructor-go/encoding/json"
+	"github.com/invopop/jsonschema"
+
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/interceptor"
+	"github.com/bububa/atomic-agents/components/memory"
+	"github.com/bububa/atomic-agents/components/systemprompt/cot"
+	"github.com/bububa/atomic-agents/schema"
+)
+
+// ErrNoRoute returns when the Router could not select a route and no default route is set
+var ErrNoRoute = errors.New("no route selected")
+
+// Route is a named agent the Router dispatches inputs to
+type Route struct {
+	Name        string
+	Description string
+	Agent       AnonymousAgent
+}
+
+// RouteDecision is the classification output of the Router classifier agent
+type RouteDecision struct {
+	schema.Base
+	Route      string  `json:"route" jsonschema:"title=route,description=name of the route selected to handle the input" validate:"required"`
+	Confidence float64 `json:"confidence" jsonschema:"title=confidence,description=confidence of the selection from 0 to 1,minimum=0,maximum=1" validate:"gte=0,lte=1"`
+	Reason     string  `json:"reason,omitempty" jsonschema:"title=reason,description=short reason of the selection"`
+}
+
+// Router classifies the input with a classifier agent, usually driven by a lightweight model,
+// and forwards the input to the selected route agent. The classifier output is a RouteDecision
+// with an enum of the registered route names.
+type Router[I schema.Schema, O schema.Schema] struct {
+	interceptor.Stack
+	name          string
+	classifier    *Agent[I, RouteDecision]
+	routes        []Route
+	defaultRoute  string
+	minConfidence float64
+	routeHook     func(context.Context, *Router[I, O], *I, *RouteDecision, Route)
+}
+
+var (
+	_ TypeableAgent[schema.String, schema.String] = (*Router[schema.String, schema.String])(nil)
+	_ AnonymousAgent                              = (*Router[schema.String, schema.String])(nil)
+)
+
+// NewRouter returns a new Router, options are applied to the classifier agent.
+// The classifier client is cloned, so that it could be shared with other agents.
+func NewRouter[I schema.Schema, O schema.Schema](routes []Route, options ...Option) *Router[I, O] {
+	ret := &Router[I, O]{
+		routes: routes,
+	}
+	opts := make([]Option, 0, len(options)+1)
+	opts = append(opts, WithSystemPromptGenerator(cot.New(
+		cot.WithBackground([]string{
+			"- You are a router which selects the most suitable route to handle the user input.",
+		}),
+		cot.WithSteps([]string{
+			"- Understand the intent of the user input.",
+			"- Compare the intent with the description of every available route.",
+			"- Select the route which matches the intent best, and estimate the confidence of the selection.",
+		}),
+		cot.WithOutputInstructs([]string{
+			"- The route must be one of the available route names.",
+			"- Use a low confidence if no route matches the intent well.",
+		}),
+	)))
+	opts = append(opts, options...)
+	classifier := NewAgent[I, RouteDecision](opts...)
+	if clt, err := components.CloneInstructor(classifier.client); err == nil {
+		clt.SetMemory(instructor.NewMemory(0))
+		clt.SetEncoder(nil)
+		classifier.client = clt
+	}
+	classifier.RegisterSystemPromptContextProvider(routesContextProvider{routes: &ret.routes})
+	ret.classifier = classifier
+	ret.refreshEncoder()
+	return ret
+}
+
+func (r *Router[I, O]) Name() string {
+	return r.name
+}
+
+func (r *Router[I, O]) SetName(name string) {
+	r.name = name
+}
+
+// Classifier returns the classifier agent
+func (r *Router[I, O]) Classifier() *Agent[I, RouteDecision] {
+	return r.classifier
+}
+
+// Routes returns the registered routes
+func (r *Router[I, O]) Routes() []Route {
+	return r.routes
+}
+
+// AddRoute registers a route
+func (r *Router[I, O]) AddRoute(route Route) *Router[I, O] {
+	r.routes = append(r.routes, route)
+	r.refreshEncoder()
+	return r
+}
+
+// SetDefaultRoute set the route used when the classifier confidence is lower than minConfidence,
+// or the classifier selects an unknown route
+func (r *Router[I, O]) SetDefaultRoute(name string, minConfidence float64) *Router[I, O] {
+	r.defaultRoute = name
+	r.minConfidence = minConfidence
+	return r
+}
+
+// SetStartHook sets the hook calling fn before every run
+//
+// Deprecated: use Use with interceptor.Hooks or interceptor.Before, see interceptor.Stack SetHook
+func (r *Router[I, O]) SetStartHook(fn func(context.Context, *Router[I, O], *I)) {
+	if fn == nil {
+		r.SetHook(interceptor.StartHook, nil)
+		return
+	}
+	r.SetHook(interceptor.StartHook, interceptor.Hooks[I, O](func(ctx context.Context, input *I) {
+		fn(ctx, r, input)
+	}, nil, nil))
+}
+
+// SetEndHook sets the hook calling fn after every successful run
+//
+// Deprecated: use Use with interceptor.Hooks or interceptor.After, see interceptor.Stack SetHook
+func (r *Router[I, O]) SetEndHook(fn func(context.Context, *Router[I, O], *I, *O, *components.LLMResponse)) {
+	if fn == nil {
+		r.SetHook(interceptor.EndHook, nil)
+		return
+	}
+	r.SetHook(interceptor.EndHook, interceptor.Hooks[I, O](nil, func(ctx context.Context, input *I, output *O, apiResp *components.LLMResponse) {
+		fn(ctx, r, input, output, apiResp)
+	}, nil))
+}
+
+// SetErrorHook sets the hook calling fn after every failed run
+//
+// Deprecated: use Use with interceptor.Hooks or interceptor.After, see interceptor.Stack SetHook
+func (r *Router[I, O]) SetErrorHook(fn func(context.Context, *Router[I, O], *I, *components.LLMResponse, error)) {
+	if fn == nil {
+		r.SetHook(interceptor.ErrorHook, nil)
+		return
+	}
+	r.SetHook(interceptor.ErrorHook, interceptor.Hooks[I, O](nil, nil, func(ctx context.Context, input *I, apiResp *components.LLMResponse, err error) {
+		fn(ctx, r, input, apiResp, err)
+	}))
+}
+
+// SetRouteHook set the hook called with the classifier decision and the route the input is forwarded to
+func (r *Router[I, O]) SetRouteHook(fn func(context.Context, *Router[I, O], *I, *RouteDecision, Route)) {
+	r.routeHook = fn
+}
+
+// refreshEncoder sets the classifier encoder with the route enum built from the registered route names
+func (r *Router[I, O]) refreshEncoder() {
+	clt := r.classifier.client
+	if clt == nil {
+		return
+	}
+	enc, err := encoding.PredefinedEncoder(clt.Mode(), new(RouteDecision), clt.SchemaNamer())
+	if err != nil {
+		return
+	}
+	if jsonEnc, ok := enc.(*jsonenc.Encoder); ok {
+		names := make([]any, 0, len(r.routes))
+		for _, route := range r.routes {
+			names = append(names, route.Name)
+		}
+		s := jsonEnc.Schema()
+		if prop := routeProperty(s.Schema); prop != nil {
+			prop.Enum = names
+			if bs, err := json.MarshalIndent(s.Schema, "", "  "); err == nil {
+				s.String = string(bs)
+			}
+		}
+	}
+	clt.SetEncoder(enc)
+}
+
+// routeProperty finds the route property schema in the RouteDecision schema or its definitions
+func routeProperty(s *jsonschema.Schema) *jsonschema.Schema {
+	if s == nil {
+		return nil
+	}
+	if s.Properties != nil {
+		if prop, ok := s.Properties.Get("route"); ok {
+			return prop
+		}
+	}
+	for _, def := range s.Definitions {
+		if prop := routeProperty(def); prop != nil {
+			return prop
+		}
+	}
+	return nil
+}
+
+// Run classifies the input and forwards it to the selected route agent
+func (r *Router[I, O]) Run(ctx context.Context, input *I, output *O, apiResp *components.LLMResponse) error {
+	call := &interceptor.Call{Kind: interceptor.AgentCall, Name: r.name, Target: r}
+	return interceptor.Typed(ctx, call, r.Interceptors(), input, output, apiResp, r.invoke)
+}
+
+func (r *Router[I, O]) invoke(ctx context.Context, input *I, output *O, apiResp *components.LLMResponse) error {
+	if apiResp == nil {
+		apiResp = new(components.LLMResponse)
+	}
+	return r.route(components.WithCostScope(ctx, r.name), input, output, apiResp)
+}
+
+func (r *Router[I, O]) route(ctx context.Context, input *I, output *O, apiResp *components.LLMResponse) error {
+	decision := new(RouteDecision)
+	classifyResp := new(components.LLMResponse)
+	// routing decisions are independent of each other, concurrent runs never share the classifier memory
+	err := r.classifier.Run(memory.Isolate(ctx), input, decision, classifyResp)
+	*apiResp = *classifyResp
+	if err != nil {
+		return err
+	}
+	route, err := r.selectRoute(decision)
+	if err != nil {
+		return err
+	}
+	if fn := r.routeHook; fn != nil {
+		fn(ctx, r, input, decision, route)
+	}
+	routeResp := new(components.LLMResponse)
+	out, err := route.Agent.RunAnonymous(ctx, input, routeResp)
+	usage := new(components.LLMUsage)
+	usage.Merge(classifyResp.Usage)
+	usage.Merge(routeResp.Usage)
+	*apiResp = *routeResp
+	apiResp.Usage = usage
+	if err != nil {
+		return err
+	}
+	outO, ok := out.(*O)
+	if !ok {
+		return errors.New("invalid agent output schema")
+	}
+	*output = *outO
+	return nil
+}
+
+// selectRoute returns the route of the decision, or the default route on unknown route or low confidence
+func (r *Router[I, O]) selectRoute(decision *RouteDecision) (Route, error) {
+	route, found := r.findRoute(decision.Route)
+	if found && decision.Confidence >= r.minConfidence {
+		return route, nil
+	}
+	if r.defaultRoute != "" {
+		if route, found := r.findRoute(r.defaultRoute); found {
+			return route, nil
+		}
+		return Route{}, fmt.Errorf("%w: default route %s not found", ErrNoRoute, r.defaultRoute)
+	}
+	if !found {
+		return Route{}, fmt.Errorf("%w: unknown route %s", ErrNoRoute, decision.Route)
+	}
+	return route, nil
+}
+
+func (r *Router[I, O]) findRoute(name string) (Route, bool) {
+	for _, route := range r.routes {
+		if route.Name == name {
+			return route, true
+		}
+	}
+	return Route{}, false
+}
+
+// RunAnonymous classifies the input and forwards it to the selected route agent for chain.
+func (r *Router[I, O]) RunAnonymous(ctx context.Context, input any, apiResp *components.LLMResponse) (any, error) {
+	in, ok := input.(*I)
+	if !ok {
+		return nil, errors.New("invalid agent input schema")
+	}
+	out := new(O)
+	if err := r.Run(ctx, in, out, apiResp); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// routesContextProvider lists the available routes in the classifier system prompt
+type routesContextProvider struct {
+	routes *[]Route
+}
+
+func (p routesContextProvider) Title() string {
+	return "Available Routes"
+}
+
+func (p routesContextProvider) Info() string {
+	sb := new(strings.Builder)
+	for _, route := range *p.routes {
+		fmt.Fprintf(sb, "- %s: %s\n", route.Name, route.Description)
+	}
+	return sb.String()
+}
@@ -0,0 +1,71 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/bububa/instructor-go"
+
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/internal/llmtest"
+	"github.com/bububa/atomic-agents/schema"
+)
+
+func TestRouter(t *testing.T) {
+	var decision string
+	srv := startOpenAICompatibleServer(t, func(map[string]any) llmtest.Reply {
+		return llmtest.Reply{Content: decision, InputTokens: 20, OutputTokens: 4}
+	})
+	echo := func(name string) *funcAgent {
+		return &funcAgent{name: name, fn: func(_ context.Context, input any, apiResp *components.LLMResponse) (any, error) {
+			apiResp.Usage = &components.LLMUsage{InputTokens: 10, OutputTokens: 1}
+			return schema.NewString(name + ": " + input.(*schema.String).String()), nil
+		}}
+	}
+	router := NewRouter[schema.String, schema.String]([]Route{
+		{Name: "billing", Description: "questions about invoices and payments", Agent: echo("billing")},
+		{Name: "support", Description: "technical problems", Agent: echo("support")},
+	},
+		WithClient(newOpenAICompatibleTestClient(instructor.ModeJSON)),
+		WithModel("router-mini"),
+		WithOpenAICompatible(OpenAICompatible{BaseURL: srv.URL}),
+	).AddRoute(Route{Name: "general", Description: "anything else", Agent: echo("general")}).
+		SetDefaultRoute("general", 0.5)
+	var decisions []RouteDecision
+	router.SetRouteHook(func(_ context.Context, _ *Router[schema.String, schema.String], _ *schema.String, d *RouteDecision, _ Route) {
+		decisions = append(decisions, *d)
+	})
+	tests := []struct {
+		decision string
+		want     string
+	}{
+		{decision: `{"route":"billing","confidence":0.9}`, want: "billing: where is my invoice"},
+		{decision: `{"route":"support","confidence":0.2}`, want: "general: where is my invoice"},
+	}
+	for _, tt := range tests {
+		decision = tt.decision
+		output := new(schema.String)
+		apiResp := new(components.LLMResponse)
+		if err := router.Run(context.Background(), schema.NewString("where is my invoice"), output, apiResp); err != nil {
+			t.Fatalf("run router failed: %v", err)
+		}
+		if output.String() != tt.want {
+			t.Errorf("expect %q, got %q", tt.want, output.String())
+		}
+		if apiResp.Usage.InputTokens != 30 || apiResp.Usage.OutputTokens != 5 {
+			t.Errorf("expect classifier and route usage summed, got %+v", apiResp.Usage)
+		}
+		if messages := llmtest.Messages(srv.Last()); len(messages) != 2 {
+			t.Errorf("expect classifier without history, got %d messages", len(messages))
+		}
+	}
+	bs, _ := json.Marshal(llmtest.Messages(srv.Last()))
+	if prompt := string(bs); !strings.Contains(prompt, `\"billing\",\n`) || !strings.Contains(prompt, "general: anything else") {
+		t.Errorf("expect route enum and descriptions in classifier prompt, got %s", prompt)
+	}
+	if len(decisions) != 2 || decisions[1].Confidence != 0.2 {
+		t.Errorf("expect decisions exposed to route hook, got %+v", decisions)
+	}
+}
@@ -0,0 +1,80 @@
+package agents
+
+import (
+	"context"
+
+	"github.com/bububa/instructor-go"
+
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/memory"
+)
+
+// ErrCloneInstructor returns when a session scoped copy of the instructor could not be made
+var ErrCloneInstructor = components.ErrCloneInstructor
+
+// noFlush is the flush func of agents without session
+func noFlush(context.Context) error {
+	return nil
+}
+
+// session returns the agent serving the session carried by the context, and the func flushing the session memory
+// into the memory store. A session agent is a copy of the agent with a client cloned onto the session memory,
+// so concurrent sessions never share history. The agent itself is returned if it has no memory store or
+// the context carries no session ID. Concurrent runs of the same session are not serialized, the last flush wins.
+// The agent memory of an Isolation carried by the context takes the place of session memory, and is never flushed.
+func (a *Agent[I, O]) session(ctx context.Context) (*Agent[I, O], func(context.Context) error, error) {
+	if isolation := memory.IsolationFromContext(ctx); isolation != nil {
+		key := a.isolationKey
+		if key == nil {
+			key = a
+		}
+		session, err := a.withMemory(isolation.Memory(key))
+		return session, noFlush, err
+	}
+	store := a.memoryStore
+	sessionID := memory.SessionID(ctx)
+	if store == nil || sessionID == "" {
+		return a, noFlush, nil
+	}
+	history, err := store.Load(ctx, sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	mem := instructor.NewMemory(len(history))
+	mem.Set(history)
+	session, err := a.withMemory(mem)
+	if err != nil {
+		return nil, nil, err
+	}
+	flush := func(ctx context.Context) error {
+		return store.Save(ctx, sessionID, mem.List())
+	}
+	return session, flush, nil
+}
+
+// withMemory returns a copy of the agent with a client cloned onto the memory
+func (a *Agent[I, O]) withMemory(mem *instructor.Memory) (*Agent[I, O], error) {
+	clt, err := components.CloneInstructor(a.client)
+	if err != nil {
+		return nil, err
+	}
+	clt.SetMemory(mem)
+	ret := &Agent[I, O]{
+		Config: a.Config,
+	}
+	ret.client = clt
+	return ret, nil
+}
+
+// flushMergeResponse wraps a stream MergeResponse to flush the session memory once the stream merged,
+// flush errors are reported to the error hook
+func (a *Agent[I, O]) flushMergeResponse(ctx context.Context, userInput *I, mergeResp MergeResponse, flush func(context.Context) error) MergeResponse {
+	return func(llmResponse *components.LLMResponse) {
+		if mergeResp != nil {
+			mergeResp(llmResponse)
+		}
+		if err := flush(ctx); err != nil && a.flushErrorHook != nil {
+			a.flushErrorHook(ctx, a, userInput, llmResponse, err)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bububa/instructor-go"
+
+	"github.com/bububa/atomic-agents/components/memory"
+	"github.com/bububa/atomic-agents/components/memory/stores/boltdb"
+	"github.com/bububa/atomic-agents/components/memory/stores/jsonl"
+	"github.com/bububa/atomic-agents/internal/llmtest"
+	"github.com/bububa/atomic-agents/schema"
+	"github.com/bububa/atomic-agents/tools"
+	"github.com/bububa/atomic-agents/tools/calculator"
+)
+
+func TestSessionMemoryStore(t *testing.T) {
+	srv := startOpenAICompatibleServer(t, replyMessageCount)
+	jsonlStore, err := jsonl.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("new jsonl store failed: %v", err)
+	}
+	boltStore, err := boltdb.Open(filepath.Join(t.TempDir(), "sessions.db"))
+	if err != nil {
+		t.Fatalf("open bolt store failed: %v", err)
+	}
+	defer boltStore.Close()
+	for name, store := range map[string]memory.Store{"jsonl": jsonlStore, "boltdb": boltStore} {
+		t.Run(name, func(t *testing.T) {
+			agent := NewAgent[schema.Input, schema.Output](
+				WithClient(newOpenAICompatibleTestClient(instructor.ModeJSON)),
+				WithModel("llama3"),
+				WithOpenAICompatible(OpenAICompatible{BaseURL: srv.URL}),
+				WithMemoryStore(store),
+			)
+			run := func(sessionID string) string {
+				output := new(schema.Output)
+				if err := agent.Run(memory.WithSessionID(context.Background(), sessionID), schema.NewInput("hi"), output, nil); err != nil {
+					t.Fatalf("run agent failed: %v", err)
+				}
+				return output.ChatMessage
+			}
+			if got := []string{run("alice"), run("bob"), run("alice")}; got[0] != "2" || got[1] != "2" || got[2] != "4" {
+				t.Errorf("expect isolated session histories, got message counts %v", got)
+			}
+			if len(agent.Memory().List()) != 0 {
+				t.Errorf("expect agent memory untouched by sessions, got %+v", agent.Memory().List())
+			}
+			history, err := store.Load(context.Background(), "alice")
+			if err != nil || len(history) != 4 || history[3].Role != instructor.AssistantRole {
+				t.Errorf("expect alice history flushed into store, got %+v, %v", history, err)
+			}
+		})
+	}
+}
+
+func TestToolAgentRejectionSession(t *testing.T) {
+	srv := startOpenAICompatibleServer(t, func(body map[string]any) llmtest.Reply {
+		// answers both the calculator params of the start agent and the output of the end agent
+		return llmtest.Reply{Content: fmt.Sprintf(`{"expression":"2 + 3","chat_message":"%d"}`, len(llmtest.Messages(body)))}
+	})
+	store, err := jsonl.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("new jsonl store failed: %v", err)
+	}
+	agent := NewToolAgent[schema.Input, calculator.Input, schema.Output](
+		WithClient(newOpenAICompatibleTestClient(instructor.ModeJSON)),
+		WithModel("llama3"),
+		WithOpenAICompatible(OpenAICompatible{BaseURL: srv.URL}),
+		WithMemoryStore(store),
+	)
+	agent.SetTool(calculator.New())
+	agent.SetApprover(tools.ApproverFunc(func(context.Context, *tools.ApprovalRequest) (*tools.Decision, error) {
+		return &tools.Decision{Verdict: tools.Reject, Reason: "no math today"}, nil
+	}))
+	ctx := memory.WithSessionID(context.Background(), "alice")
+	if err := agent.Run(ctx, schema.NewInput("2 + 3 = ?"), new(schema.Output), nil); err != nil {
+		t.Fatalf("run agent failed: %v", err)
+	}
+	rejected := func(body map[string]any) bool {
+		return strings.Contains(fmt.Sprint(llmtest.Messages(body)), "no math today")
+	}
+	requests := srv.Requests()
+	if len(requests) != 2 || rejected(requests[0]) || !rejected(requests[1]) {
+		t.Fatalf("expect the rejection sent to the end agent only, got %d requests", len(requests))
+	}
+	history, err := store.Load(context.Background(), "alice")
+	if err != nil || strings.Contains(fmt.Sprint(history), "no math today") {
+		t.Errorf("expect the rejection kept out of the session history, got %+v, %v", history, err)
+	}
+	if len(agent.EndAgent().Memory().List()) != 0 {
+		t.Errorf("expect end agent memory untouched, got %+v", agent.EndAgent().Memory().List())
+	}
+
+	agent.SetApprover(nil)
+	if err := agent.Run(ctx, schema.NewInput("2 + 3 = ?"), new(schema.Output), nil); err != nil {
+		t.Fatalf("run agent failed: %v", err)
+	}
+	if requests = srv.Requests(); rejected(requests[len(requests)-1]) {
+		t.Errorf("expect the rejection sent for the rejected run only")
+	}
+}
@@ -0,0 +1,94 @@
+package agents
+
+import (
+	"context"
+	"sync"
+
+	"github.com/bububa/instructor-go"
+
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/interceptor"
+	"github.com/bububa/atomic-agents/components/tracing"
+	"github.com/bububa/atomic-agents/schema"
+)
+
+// startedStream is the opaque output of an intercepted stream call
+type startedStream struct {
+	ch        <-chan instructor.StreamData
+	schemaCh  <-chan any
+	progress  <-chan ChainProgress
+	mergeResp MergeResponse
+}
+
+// interceptStream starts a stream through interceptors, see interceptor.Stream. An output of O short-circuited
+// by an interceptor is streamed as a whole. start returns a non nil stream, with its merge response on failure.
+func interceptStream[I any, O schema.Schema](ctx context.Context, call *interceptor.Call, interceptors []interceptor.Interceptor, input *I, start func(context.Context, *I) (*startedStream, error)) (*startedStream, error) {
+	if len(interceptors) == 0 {
+		return start(ctx, input)
+	}
+	started := new(startedStream)
+	apiResp := new(components.LLMResponse)
+	ret, err := interceptor.Stream(ctx, call, interceptors, input, apiResp, func(ctx context.Context, in *I) (any, error) {
+		s, err := start(ctx, in)
+		started = s
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	})
+	if err != nil {
+		return started, err
+	}
+	switch v := ret.(type) {
+	case *startedStream:
+		return v, nil
+	case *O:
+		return outputStream(v, apiResp), nil
+	}
+	return started, interceptor.ErrOutputType
+}
+
+// outputStream streams an output short-circuited by an interceptor as a whole, the content is the stringified output
+func outputStream[O schema.Schema](output *O, apiResp *components.LLMResponse) *startedStream {
+	ch := make(chan instructor.StreamData, 1)
+	ch <- instructor.StreamData{Type: instructor.ContentStream, Content: schema.Stringify(*output)}
+	close(ch)
+	schemaCh := make(chan any, 1)
+	schemaCh <- output
+	close(schemaCh)
+	progress := make(chan ChainProgress)
+	close(progress)
+	return &startedStream{
+		ch:       ch,
+		schemaCh: schemaCh,
+		progress: progress,
+		mergeResp: func(resp *components.LLMResponse) {
+			if resp != nil {
+				*resp = *apiResp
+			}
+		},
+	}
+}
+
+// streamSpan is the span of a stream, ended once the stream response merged or the stream failed
+type streamSpan struct {
+	span *tracing.Span
+	once sync.Once
+}
+
+func (s *streamSpan) end(resp *components.LLMResponse, err error) {
+	s.once.Do(func() {
+		s.span.SetResponse(resp)
+		s.span.EndErr(err)
+	})
+}
+
+// mergeResponse wraps a stream MergeResponse to end the span with the merged response
+func (s *streamSpan) mergeResponse(mergeResp MergeResponse) MergeResponse {
+	return func(resp *components.LLMResponse) {
+		if mergeResp != nil {
+			mergeResp(resp)
+		}
+		s.end(resp, nil)
+	}
+}
@@ -0,0 +1,72 @@
+package agents
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/bububa/instructor-go"
+
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/interceptor"
+	"github.com/bububa/atomic-agents/internal/llmtest"
+	"github.com/bububa/atomic-agents/schema"
+)
+
+func TestAgentStreamInterceptors(t *testing.T) {
+	srv := startOpenAICompatibleServer(t, func(map[string]any) llmtest.Reply {
+		return llmtest.Reply{Chunks: []string{"hel", "lo"}}
+	})
+	agent := NewAgent[schema.String, schema.String](
+		WithClient(newOpenAICompatibleTestClient(instructor.ModeJSON)),
+		WithModel("llama3"),
+		WithOpenAICompatible(OpenAICompatible{BaseURL: srv.URL}),
+	)
+	var started []string
+	agent.SetStartHook(func(_ context.Context, _ *Agent[schema.String, schema.String], input *schema.String) {
+		started = append(started, input.String())
+	})
+	var calls []interceptor.Call
+	agent.Use(interceptor.Before(func(_ context.Context, call *interceptor.Call, input any) (any, any, error) {
+		calls = append(calls, *call)
+		if in := input.(*schema.String); in.String() == "cached" {
+			return input, schema.NewString("from cache"), nil
+		}
+		return input, nil, nil
+	}))
+	collect := func(ch <-chan instructor.StreamData) string {
+		var b strings.Builder
+		for v := range ch {
+			b.WriteString(v.Content)
+		}
+		return b.String()
+	}
+	ch, mergeResp, err := agent.Stream(context.Background(), schema.NewString("hi"))
+	if err != nil {
+		t.Fatalf("stream agent failed: %v", err)
+	}
+	if got := collect(ch); got != "hello" {
+		t.Errorf("expect streamed content, got %q", got)
+	}
+	mergeResp(new(components.LLMResponse))
+	schemaCh, ch, mergeResp, err := agent.SchemaStream(context.Background(), schema.NewString("cached"))
+	if err != nil {
+		t.Fatalf("stream agent failed: %v", err)
+	}
+	if got := collect(ch); got != "from cache" {
+		t.Errorf("expect short-circuited output streamed as a whole, got %q", got)
+	}
+	if out, ok := (<-schemaCh).(*schema.String); !ok || out.String() != "from cache" {
+		t.Errorf("expect short-circuited output on the schema channel, got %v", out)
+	}
+	mergeResp(new(components.LLMResponse))
+	if n := len(srv.Requests()); n != 1 {
+		t.Errorf("expect short-circuited stream never sent, got %d requests", n)
+	}
+	if len(calls) != 2 || !calls[0].Stream || calls[0].Kind != interceptor.AgentCall {
+		t.Errorf("expect stream calls intercepted, got %+v", calls)
+	}
+	if strings.Join(started, ",") != "hi,cached" {
+		t.Errorf("expect start hook run as an interceptor of streams, got %v", started)
+	}
+}
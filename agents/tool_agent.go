@@ -7,6 +7,8 @@ import (
 	"github.com/bububa/instructor-go"
 
 	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/interceptor"
+	"github.com/bububa/atomic-agents/components/memory"
 	"github.com/bububa/atomic-agents/components/systemprompt"
 	"github.com/bububa/atomic-agents/schema"
 	"github.com/bububa/atomic-agents/tools"
@@ -14,15 +16,16 @@ import (
 
 // ToolAgent represent agent with tool callback
 type ToolAgent[I schema.Schema, T schema.Schema, O schema.Schema] struct {
-	name      string
-	start     *Agent[I, T]
-	end       *Agent[I, O]
-	tool      tools.AnonymousTool
-	startHook func(context.Context, *ToolAgent[I, T, O], *I)
-	endHook   func(context.Context, *ToolAgent[I, T, O], *I, *O, *components.LLMResponse)
-	errorHook func(context.Context, *ToolAgent[I, T, O], *I, *components.LLMResponse, error)
+	interceptor.Stack
+	name     string
+	start    *Agent[I, T]
+	end      *Agent[I, O]
+	tool     tools.AnonymousTool
+	approver tools.Approver
 }
 
+var _ MemoryManagerSetter = (*ToolAgent[schema.String, schema.String, schema.String])(nil)
+
 // NewToolAgent returns a new ToolAgent instance
 func NewToolAgent[I schema.Schema, T schema.Schema, O schema.Schema](options ...Option) *ToolAgent[I, T, O] {
 	return &ToolAgent[I, T, O]{
@@ -36,6 +39,25 @@ func (t *ToolAgent[I, T, O]) SetTool(tool tools.AnonymousTool) *ToolAgent[I, T,
 	return t
 }
 
+// SetApprover set the Approver of tool calls, the tool or native tools run only once approved.
+// A rejection reason is fed back to the model.
+func (t *ToolAgent[I, T, O]) SetApprover(approver tools.Approver) *ToolAgent[I, T, O] {
+	t.approver = approver
+	return t
+}
+
+// SetFunctions registers LLM native tools, the end agent calls them in a loop until the final output is responsed
+func (t *ToolAgent[I, T, O]) SetFunctions(fns ...tools.Function) *ToolAgent[I, T, O] {
+	t.end.SetTools(fns...)
+	return t
+}
+
+// SetMaxIterations set max LLM calls of the native tool calling loop
+func (t *ToolAgent[I, T, O]) SetMaxIterations(n int) *ToolAgent[I, T, O] {
+	t.end.SetMaxToolIterations(n)
+	return t
+}
+
 func (t *ToolAgent[I, T, O]) SetClient(clt instructor.Instructor) {
 	t.start.client = clt
 	t.end.client = clt
@@ -46,6 +68,11 @@ func (t *ToolAgent[I, T, O]) SetMemory(m *instructor.Memory) {
 	t.end.SetMemory(m)
 }
 
+func (t *ToolAgent[I, T, O]) SetMemoryManager(m *memory.Manager) {
+	t.start.SetMemoryManager(m)
+	t.end.SetMemoryManager(m)
+}
+
 func (t *ToolAgent[I, T, O]) SetSystemPromptGenerator(g systemprompt.Generator) {
 	t.start.systemPromptGenerator = g
 	t.end.systemPromptGenerator = g
@@ -57,8 +84,8 @@ func (t *ToolAgent[I, T, O]) SetModel(model string) {
 }
 
 func (t *ToolAgent[I, T, O]) SetTemperature(temperature float64) {
-	t.start.temperature = temperature
-	t.end.temperature = temperature
+	t.start.SetTemperature(temperature)
+	t.end.SetTemperature(temperature)
 }
 
 func (t *ToolAgent[I, T, O]) SetTopP(topP float64) {
@@ -84,16 +111,53 @@ func (t *ToolAgent[I, T, O]) SetName(name string) {
 	t.name = name
 }
 
+// SetStartHook sets the hook calling fn before every run
+//
+// Deprecated: use Use with interceptor.Hooks or interceptor.Before, see interceptor.Stack SetHook
 func (t *ToolAgent[I, T, O]) SetStartHook(fn func(context.Context, *ToolAgent[I, T, O], *I)) {
-	t.startHook = fn
+	if fn == nil {
+		t.SetHook(interceptor.StartHook, nil)
+		return
+	}
+	t.SetHook(interceptor.StartHook, interceptor.Hooks[I, O](func(ctx context.Context, input *I) {
+		fn(ctx, t, input)
+	}, nil, nil))
 }
 
+// SetEndHook sets the hook calling fn after every successful run
+//
+// Deprecated: use Use with interceptor.Hooks or interceptor.After, see interceptor.Stack SetHook
 func (t *ToolAgent[I, T, O]) SetEndHook(fn func(context.Context, *ToolAgent[I, T, O], *I, *O, *components.LLMResponse)) {
-	t.endHook = fn
+	if fn == nil {
+		t.SetHook(interceptor.EndHook, nil)
+		return
+	}
+	t.SetHook(interceptor.EndHook, interceptor.Hooks[I, O](nil, func(ctx context.Context, input *I, output *O, apiResp *components.LLMResponse) {
+		fn(ctx, t, input, output, apiResp)
+	}, nil))
 }
 
+// SetErrorHook sets the hook calling fn after every failed run
+//
+// Deprecated: use Use with interceptor.Hooks or interceptor.After, see interceptor.Stack SetHook
 func (t *ToolAgent[I, T, O]) SetErrorHook(fn func(context.Context, *ToolAgent[I, T, O], *I, *components.LLMResponse, error)) {
-	t.errorHook = fn
+	if fn == nil {
+		t.SetHook(interceptor.ErrorHook, nil)
+		return
+	}
+	t.SetHook(interceptor.ErrorHook, interceptor.Hooks[I, O](nil, nil, func(ctx context.Context, input *I, apiResp *components.LLMResponse, err error) {
+		fn(ctx, t, input, apiResp, err)
+	}))
+}
+
+// StartAgent returns the agent selecting the tool parameters
+func (t *ToolAgent[I, T, O]) StartAgent() *Agent[I, T] {
+	return t.start
+}
+
+// EndAgent returns the agent responding with the tool result
+func (t *ToolAgent[I, T, O]) EndAgent() *Agent[I, O] {
+	return t.end
 }
 
 func (t *ToolAgent[I, T, O]) SetStartAgentName(name string) {
@@ -104,26 +168,44 @@ func (t *ToolAgent[I, T, O]) SetEndAgentName(name string) {
 	t.end.SetName(name)
 }
 
+// SetStartAgentStartHook adds a start hook to the start agent
+//
+// Deprecated: use StartAgent().Use with interceptor.Hooks or interceptor.Before
 func (t *ToolAgent[I, T, O]) SetStartAgentStartHook(fn func(context.Context, *Agent[I, T], *I)) {
 	t.start.SetStartHook(fn)
 }
 
+// SetStartAgentEndHook adds an end hook to the start agent
+//
+// Deprecated: use StartAgent().Use with interceptor.Hooks or interceptor.After
 func (t *ToolAgent[I, T, O]) SetStartAgentEndHook(fn func(context.Context, *Agent[I, T], *I, *T, *components.LLMResponse)) {
 	t.start.SetEndHook(fn)
 }
 
+// SetStartAgentErrorHook adds an error hook to the start agent
+//
+// Deprecated: use StartAgent().Use with interceptor.Hooks or interceptor.After
 func (t *ToolAgent[I, T, O]) SetStartAgentErrorHook(fn func(context.Context, *Agent[I, T], *I, *components.LLMResponse, error)) {
 	t.start.SetErrorHook(fn)
 }
 
+// SetEndAgentStartHook adds a start hook to the end agent
+//
+// Deprecated: use EndAgent().Use with interceptor.Hooks or interceptor.Before
 func (t *ToolAgent[I, T, O]) SetEndAgentStartHook(fn func(context.Context, *Agent[I, O], *I)) {
 	t.end.SetStartHook(fn)
 }
 
+// SetEndAgentEndHook adds an end hook to the end agent
+//
+// Deprecated: use EndAgent().Use with interceptor.Hooks or interceptor.After
 func (t *ToolAgent[I, T, O]) SetEndAgentEndHook(fn func(context.Context, *Agent[I, O], *I, *O, *components.LLMResponse)) {
 	t.end.SetEndHook(fn)
 }
 
+// SetEndAgentErrorHook adds an error hook to the end agent
+//
+// Deprecated: use EndAgent().Use with interceptor.Hooks or interceptor.After
 func (t *ToolAgent[I, T, O]) SetEndAgentErrorHook(fn func(context.Context, *Agent[I, O], *I, *components.LLMResponse, error)) {
 	t.end.SetErrorHook(fn)
 }
@@ -142,38 +224,97 @@ func (t *ToolAgent[I, T, O]) SetToolErrorHook(fn func(context.Context, tools.Ano
 
 // Run runs the chat agent with the given user input synchronously.
 func (t *ToolAgent[I, T, O]) Run(ctx context.Context, userInput *I, output *O, apiResp *components.LLMResponse) error {
+	call := &interceptor.Call{Kind: interceptor.AgentCall, Name: t.name, Target: t}
+	return interceptor.Typed(ctx, call, t.Interceptors(), userInput, output, apiResp, t.invoke)
+}
+
+func (t *ToolAgent[I, T, O]) invoke(ctx context.Context, userInput *I, output *O, apiResp *components.LLMResponse) error {
 	toolOutput := new(T)
-	if fn := t.startHook; fn != nil {
-		fn(ctx, t, userInput)
+	if apiResp == nil {
+		apiResp = new(components.LLMResponse)
 	}
-	if err := t.start.Run(ctx, userInput, toolOutput, apiResp); err != nil {
-		if fn := t.errorHook; fn != nil {
-			fn(ctx, t, userInput, apiResp, err)
-		}
+	ctx = components.WithCostScope(ctx, t.name)
+	if t.approver != nil {
+		ctx = tools.WithApprover(ctx, t.approver)
+	}
+	if len(t.end.tools) > 0 {
+		// native tool calling loop runs inside the end agent
+		return t.end.Run(ctx, userInput, output, apiResp)
+	}
+	startResp := new(components.LLMResponse)
+	err := t.start.Run(ctx, userInput, toolOutput, startResp)
+	*apiResp = *startResp
+	if err != nil {
 		return err
 	}
+	endCtx := ctx
 	if t.tool != nil {
-		if toolResult, err := t.tool.RunAnonymous(ctx, toolOutput); err != nil {
-			if fn := t.errorHook; fn != nil {
-				fn(ctx, t, userInput, apiResp, err)
-			}
-			return err
-		} else if _, ok := toolResult.(schema.Schema); !ok {
-			err := errors.New("invalid agent output schema")
-			if fn := t.errorHook; fn != nil {
-				fn(ctx, t, userInput, apiResp, err)
-			}
+		rejection, err := t.runTool(ctx, toolOutput)
+		if err != nil {
 			return err
 		}
-	}
-	if err := t.end.Run(ctx, userInput, output, apiResp); err != nil {
-		if fn := t.errorHook; fn != nil {
-			fn(ctx, t, userInput, apiResp, err)
+		if rejection != nil {
+			endCtx = withRunMessages(ctx, t.end, *rejection)
 		}
-		return err
 	}
-	if fn := t.endHook; fn != nil {
-		fn(ctx, t, userInput, output, apiResp)
+	endResp := new(components.LLMResponse)
+	err = t.end.Run(endCtx, userInput, output, endResp)
+	// usage sums across start and end agents
+	usage := new(components.LLMUsage)
+	usage.Merge(startResp.Usage)
+	usage.Merge(endResp.Usage)
+	*apiResp = *endResp
+	apiResp.Usage = usage
+	return err
+}
+
+// runTool runs the tool once approved, Dispatcher tools request approvals of their selected tools.
+// A rejection is returned as a message for the end agent instead of failing the run.
+func (t *ToolAgent[I, T, O]) runTool(ctx context.Context, params *T) (*instructor.Message, error) {
+	var (
+		input any = params
+		err   error
+	)
+	if _, ok := t.tool.(tools.Dispatcher); !ok {
+		input, err = tools.RequestApproval(ctx, nil, t.tool, input)
+	}
+	var toolResult any
+	if err == nil {
+		toolResult, err = tools.Invoke(ctx, t.tool, input)
+	}
+	if rejected := new(tools.RejectedError); errors.As(err, &rejected) {
+		return &instructor.Message{
+			Role: instructor.UserRole,
+			Text: rejected.Error(),
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := toolResult.(schema.Schema); !ok {
+		return nil, errors.New("invalid agent output schema")
+	}
+	return nil, nil
+}
+
+type runMessagesKey struct{}
+
+// runMessages are messages sent to an agent for a single run
+type runMessages struct {
+	agent    any
+	messages []instructor.Message
+}
+
+// withRunMessages returns a context carrying messages sent after the history in the next run of the agent only,
+// so that they reach neither the memory nor the session store, nor the nested agents of the run
+func withRunMessages(ctx context.Context, agent any, messages ...instructor.Message) context.Context {
+	return context.WithValue(ctx, runMessagesKey{}, &runMessages{agent: agent, messages: messages})
+}
+
+// runMessagesFor returns the run messages carried by the context for the agent
+func runMessagesFor(ctx context.Context, agent any) []instructor.Message {
+	if v, ok := ctx.Value(runMessagesKey{}).(*runMessages); ok && v.agent == agent {
+		return v.messages
 	}
 	return nil
 }
@@ -0,0 +1,145 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/bububa/instructor-go"
+	"github.com/bububa/instructor-go/encoding"
+
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/tools"
+)
+
+// DefaultMaxToolIterations is the default maximum number of LLM calls in a tool calling loop
+const DefaultMaxToolIterations = 10
+
+// ErrMaxToolIterations returns when the model still calls tools after the max tool iterations
+var ErrMaxToolIterations = errors.New("max tool iterations exceeded")
+
+// chatWithTools runs a native tool calling loop. Registered tools are sent to the model as provider native tools,
+// tool results are fed back to the model until it responses the final output or max iterations hit.
+func (a *Agent[I, O]) chatWithTools(ctx context.Context, req *ChatRequest, response *O, llmResponse *components.LLMResponse) error {
+	p, err := a.lookupProvider()
+	if err != nil {
+		return err
+	}
+	toolProvider, ok := p.(ToolProvider)
+	if !ok {
+		return ErrToolCallingNotSupported
+	}
+	enc, err := encoding.PredefinedEncoder(a.client.Mode(), response, a.client.SchemaNamer())
+	if err != nil {
+		return err
+	}
+	toolReq := *req
+	if bs := enc.Context(); bs != nil {
+		toolReq.System.Text = fmt.Sprintf("%s\n\n#OUTPUT SCHEMA\n%s", req.System.Text, string(bs))
+	}
+	toolReq.Tools = a.tools
+	toolReq.MaxToolIterations = a.maxToolIterations
+	text, exchange, err := toolProvider.ChatWithTools(ctx, a.client, &toolReq, llmResponse)
+	a.completeResponse(llmResponse)
+	if err != nil {
+		return err
+	}
+	if memory := a.Memory(); memory != nil {
+		memory.Add(req.Input)
+		memory.Add(exchange...)
+		memory.Add(instructor.Message{
+			Role: instructor.AssistantRole,
+			Text: text,
+		})
+	}
+	if err := enc.Unmarshal([]byte(text), response); err != nil {
+		return &components.DecodeError{Err: err}
+	}
+	if a.client.Validate() {
+		if validator, ok := enc.(instructor.Validator); ok {
+			if err := validator.Validate(response); err != nil {
+				return &components.DecodeError{Err: err}
+			}
+		}
+	}
+	return nil
+}
+
+func (r *ChatRequest) maxToolIterations() int {
+	if r.MaxToolIterations > 0 {
+		return r.MaxToolIterations
+	}
+	return DefaultMaxToolIterations
+}
+
+// mergeToolLoopResponse sets the latest response of a tool calling loop into llmResponse with usage summed across calls
+func mergeToolLoopResponse(resp *components.LLMResponse, usage *components.LLMUsage, llmResponse *components.LLMResponse) {
+	usage.Merge(resp.Usage)
+	if llmResponse != nil {
+		*llmResponse = *resp
+		llmResponse.Usage = usage
+	}
+}
+
+// callTools runs the tools called by the model, tool errors are returned to the model as error callbacks
+func callTools(ctx context.Context, fns []tools.Function, calls []components.ToolCall) []components.ToolCallback {
+	callbacks := make([]components.ToolCallback, 0, len(calls))
+	for _, call := range calls {
+		callback := components.ToolCallback{
+			ID:   call.ID,
+			Name: call.Name,
+		}
+		if fn := findFunction(fns, call.Name); fn == nil {
+			callback.Content = toolErrorContent(fmt.Errorf("tool %s not found", call.Name))
+			callback.IsError = true
+		} else if content, err := tools.CallFunction(ctx, fn, call.Arguments); err != nil {
+			callback.Content = toolErrorContent(err)
+			callback.IsError = true
+		} else {
+			callback.Content = content
+		}
+		callbacks = append(callbacks, callback)
+	}
+	return callbacks
+}
+
+func findFunction(fns []tools.Function, name string) tools.Function {
+	for _, fn := range fns {
+		if fn.Title() == name {
+			return fn
+		}
+	}
+	return nil
+}
+
+// toolErrorContent encodes tool error as json object, gemini only accepts object function response
+func toolErrorContent(err error) string {
+	bs, _ := json.Marshal(map[string]string{"error": err.Error()})
+	return string(bs)
+}
+
+// toolExchange converts tool calls and callbacks into memory messages
+func toolExchange(calls []components.ToolCall, callbacks []components.ToolCallback) []instructor.Message {
+	uses := make([]instructor.ToolUse, 0, len(calls))
+	for _, v := range calls {
+		uses = append(uses, instructor.ToolUse{
+			ID:        v.ID,
+			Name:      v.Name,
+			Arguments: v.Arguments,
+		})
+	}
+	results := make([]instructor.ToolResult, 0, len(callbacks))
+	for _, v := range callbacks {
+		results = append(results, instructor.ToolResult{
+			ID:      v.ID,
+			Name:    v.Name,
+			Content: v.Content,
+			IsError: v.IsError,
+		})
+	}
+	return []instructor.Message{
+		{Role: instructor.AssistantRole, ToolUses: uses},
+		{Role: instructor.ToolRole, ToolResults: results},
+	}
+}
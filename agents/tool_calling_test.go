@@ -0,0 +1,147 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bububa/instructor-go"
+	anthropicClt "github.com/bububa/instructor-go/instructors/anthropic"
+	openaiClt "github.com/bububa/instructor-go/instructors/openai"
+	anthropic "github.com/liushuangls/go-anthropic/v2"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/internal/llmtest"
+	"github.com/bububa/atomic-agents/schema"
+	"github.com/bububa/atomic-agents/tools"
+	"github.com/bububa/atomic-agents/tools/calculator"
+)
+
+func newCalculatorAgent(clt instructor.Instructor) *Agent[schema.Input, schema.Output] {
+	return NewAgent[schema.Input, schema.Output](
+		WithClient(clt),
+		WithModel("gpt-test"),
+		WithTools(tools.NewFunction[calculator.Input](calculator.New())),
+		WithMaxToolIterations(2),
+	)
+}
+
+func TestOpenAIToolCalling(t *testing.T) {
+	// the calculator is called until the tool result is sent, always called if loop is set
+	loop := false
+	srv := llmtest.NewServer(t, func(body map[string]any) llmtest.Reply {
+		messages := llmtest.Messages(body)
+		if loop || messages[len(messages)-1].(map[string]any)["role"] != "tool" {
+			return llmtest.Reply{ToolCalls: []llmtest.ToolCall{{ID: "call_1", Name: "CalculatorTool", Arguments: `{"expression":"2 + 3"}`}}}
+		}
+		return llmtest.Reply{Content: `{"chat_message":"5"}`}
+	})
+	clt := openai.NewClient(option.WithBaseURL(srv.URL), option.WithAPIKey("test"), option.WithMaxRetries(0))
+	agent := newCalculatorAgent(openaiClt.New(&clt, instructor.WithMode(instructor.ModeJSON), instructor.WithExtraBody(map[string]any{"num_ctx": 4096})))
+
+	output := new(schema.Output)
+	var apiResp components.LLMResponse
+	if err := agent.Run(context.Background(), schema.NewInput("2 + 3 = ?"), output, &apiResp); err != nil {
+		t.Fatalf("run agent failed: %v", err)
+	}
+	requests := srv.Requests()
+	if output.ChatMessage != "5" || len(requests) != 2 {
+		t.Fatalf("expect answer after 2 requests, got %s after %d", output.ChatMessage, len(requests))
+	}
+	if extraBody, _ := requests[0]["extra_body"].(map[string]any); extraBody["num_ctx"] != float64(4096) {
+		t.Errorf("expect instructor extra body sent, got %v", requests[0]["extra_body"])
+	}
+	messages := llmtest.Messages(requests[1])
+	if last, _ := messages[len(messages)-1].(map[string]any); last["tool_call_id"] != "call_1" || last["content"] != `{"result":5}` {
+		t.Errorf("unexpected tool result message: %v", last)
+	}
+	if apiResp.Usage == nil || apiResp.Usage.InputTokens != 24 {
+		t.Errorf("expect usage summed across calls, got %+v", apiResp.Usage)
+	}
+
+	loop = true
+	if err := agent.Run(context.Background(), schema.NewInput("2 + 3 = ?"), output, nil); !errors.Is(err, ErrMaxToolIterations) {
+		t.Errorf("expect max tool iterations exceeded, got %v", err)
+	}
+	if got := len(srv.Requests()); got != 4 {
+		t.Errorf("expect loop stopped after 2 iterations, got %d requests", got-2)
+	}
+}
+
+func TestAnthropicToolCalling(t *testing.T) {
+	var (
+		requests []map[string]any
+		loop     bool
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := make(map[string]any)
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request body failed: %v", err)
+			return
+		}
+		requests = append(requests, body)
+		messages := llmtest.Messages(body)
+		last, _ := messages[len(messages)-1].(map[string]any)
+		content := []map[string]any{{"type": "text", "text": `{"chat_message":"5"}`}}
+		stopReason := "end_turn"
+		if blocks, _ := last["content"].([]any); loop || len(blocks) == 0 || blocks[0].(map[string]any)["type"] != "tool_result" {
+			content = []map[string]any{
+				{"type": "thinking", "thinking": "2 + 3 needs the calculator", "signature": "sig"},
+				{"type": "tool_use", "id": "toolu_1", "name": "CalculatorTool", "input": map[string]any{"expression": "2 + 3"}},
+			}
+			stopReason = "tool_use"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":          "msg_1",
+			"type":        "message",
+			"role":        "assistant",
+			"model":       "claude-test",
+			"content":     content,
+			"stop_reason": stopReason,
+			"usage":       map[string]any{"input_tokens": 12, "output_tokens": 5},
+		})
+	}))
+	defer srv.Close()
+	clt := anthropic.NewClient("test", anthropic.WithBaseURL(srv.URL))
+	agent := newCalculatorAgent(anthropicClt.New(clt, instructor.WithMode(instructor.ModeJSON), instructor.WithThinking(1024)))
+
+	output := new(schema.Output)
+	if err := agent.Run(context.Background(), schema.NewInput("2 + 3 = ?"), output, nil); err != nil {
+		t.Fatalf("run agent failed: %v", err)
+	}
+	if output.ChatMessage != "5" || len(requests) != 2 {
+		t.Fatalf("expect answer after 2 requests, got %s after %d", output.ChatMessage, len(requests))
+	}
+	if v, _ := requests[0]["max_tokens"].(float64); v != DefaultAnthropicMaxTokens {
+		t.Errorf("expect default max_tokens, got %v", requests[0]["max_tokens"])
+	}
+	if thinking, _ := requests[0]["thinking"].(map[string]any); thinking["type"] != "enabled" {
+		t.Errorf("expect instructor thinking sent, got %v", requests[0]["thinking"])
+	}
+	if fns, _ := requests[0]["tools"].([]any); len(fns) != 1 {
+		t.Errorf("expect 1 tool, got %v", requests[0]["tools"])
+	}
+	messages := llmtest.Messages(requests[1])
+	call, _ := messages[len(messages)-2].(map[string]any)
+	if blocks, _ := call["content"].([]any); len(blocks) != 2 || blocks[0].(map[string]any)["type"] != "thinking" {
+		t.Errorf("expect thinking block sent back before the tool use, got %v", call)
+	}
+	callback, _ := messages[len(messages)-1].(map[string]any)
+	if blocks, _ := callback["content"].([]any); len(blocks) != 1 || blocks[0].(map[string]any)["tool_use_id"] != "toolu_1" {
+		t.Errorf("unexpected tool result message: %v", callback)
+	}
+
+	loop = true
+	if err := agent.Run(context.Background(), schema.NewInput("2 + 3 = ?"), output, nil); !errors.Is(err, ErrMaxToolIterations) {
+		t.Errorf("expect max tool iterations exceeded, got %v", err)
+	}
+	if len(requests) != 4 {
+		t.Errorf("expect loop stopped after 2 iterations, got %d requests", len(requests)-2)
+	}
+}
@@ -0,0 +1,62 @@
+package agents
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bububa/instructor-go"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/tracing"
+	"github.com/bububa/atomic-agents/components/tracing/otel"
+	"github.com/bububa/atomic-agents/internal/llmtest"
+	"github.com/bububa/atomic-agents/schema"
+)
+
+func TestTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tracing.SetTracer(otel.New(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))))
+	defer tracing.SetTracer(nil)
+	srv := llmtest.NewServer(t, func(map[string]any) llmtest.Reply {
+		return llmtest.Reply{Content: `{"chat_message":"hi"}`, InputTokens: 12, OutputTokens: 3}
+	})
+	agent := NewAgent[schema.Input, schema.Output](
+		WithName("greeter"),
+		WithClient(newOpenAICompatibleTestClient(instructor.ModeJSON)),
+		WithModel("llama3"),
+		WithOpenAICompatible(OpenAICompatible{BaseURL: srv.URL}),
+	)
+	echo := &funcAgent{name: "echo", fn: func(_ context.Context, input any, _ *components.LLMResponse) (any, error) {
+		return input, nil
+	}}
+	chain := NewChain[schema.Input, schema.Output](agent, echo)
+	chain.SetName("greeting")
+	if _, err := chain.Run(context.Background(), schema.NewInput("hello"), new(schema.Output)); err != nil {
+		t.Fatalf("run chain failed: %v", err)
+	}
+	spans := make(map[string]tracetest.SpanStub)
+	for _, v := range exporter.GetSpans() {
+		spans[v.Name] = v
+	}
+	chainSpan, stepSpan, agentSpan := spans[tracing.ChainRunSpan], spans[tracing.ChainStepSpan], spans[tracing.AgentRunSpan]
+	if len(exporter.GetSpans()) != 4 || agentSpan.Name == "" || chainSpan.Name == "" {
+		t.Fatalf("expect chain, 2 steps and agent spans, got %+v", exporter.GetSpans())
+	}
+	// spans are exported on end: agent, first step, second step, chain
+	firstStep := exporter.GetSpans()[1]
+	if agentSpan.Parent.SpanID() != firstStep.SpanContext.SpanID() || stepSpan.Parent.SpanID() != chainSpan.SpanContext.SpanID() {
+		t.Errorf("expect agent span parented by step span parented by chain span")
+	}
+	attrs := make(map[string]any)
+	for _, v := range agentSpan.Attributes {
+		attrs[string(v.Key)] = v.Value.AsInterface()
+	}
+	if attrs[string(tracing.RequestModelKey)] != "llama3" || attrs[string(tracing.InputTokensKey)] != int64(12) || attrs[string(tracing.OutputTokensKey)] != int64(3) || attrs[string(tracing.AgentNameKey)] != "greeter" {
+		t.Errorf("expect model and usage attributes on agent span, got %+v", attrs)
+	}
+	if _, ok := attrs[string(tracing.LatencyKey)]; !ok {
+		t.Errorf("expect latency attribute on agent span, got %+v", attrs)
+	}
+}
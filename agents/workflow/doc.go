@@ -0,0 +1,8 @@
+// Package workflow is a graph workflow engine over agents and tools.
+//
+// Nodes run an AnonymousAgent, an AnonymousTool or a join function, edges carry optional
+// conditions on the output of their source node. A node with several activated incoming edges
+// is a join, it runs once all its upstream nodes are resolved. Edges pointing back to an upstream
+// node close a loop and must be bounded with WithMaxLoops. Ready nodes run concurrently in waves,
+// agent nodes run on isolated memories, so agents sharing a client never share a conversation.
+package workflow
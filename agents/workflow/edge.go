@@ -0,0 +1,68 @@
+package workflow
+
+import "context"
+
+// Condition decides whether an edge is taken from the output of its source node
+type Condition func(ctx context.Context, output any) bool
+
+// Match returns a Condition on typed output, false if the output is not a *O
+func Match[O any](fn func(context.Context, *O) bool) Condition {
+	return func(ctx context.Context, output any) bool {
+		out, ok := output.(*O)
+		return ok && fn(ctx, out)
+	}
+}
+
+// Mapper converts the output of the edge source node into the input of its target node
+type Mapper func(ctx context.Context, output any) (any, error)
+
+// Edge connects two nodes of the Graph
+type Edge struct {
+	from      string
+	to        string
+	condition Condition
+	mapper    Mapper
+	maxLoops  int
+}
+
+type EdgeOption func(e *Edge)
+
+// When set the condition of the edge, edges without condition are always taken
+func When(cond Condition) EdgeOption {
+	return func(e *Edge) {
+		e.condition = cond
+	}
+}
+
+// WithMapper set the function converting the source output into the target input
+func WithMapper(fn Mapper) EdgeOption {
+	return func(e *Edge) {
+		e.mapper = fn
+	}
+}
+
+// WithMaxLoops set the maximum times a loop edge could be taken in a run
+func WithMaxLoops(n int) EdgeOption {
+	return func(e *Edge) {
+		e.maxLoops = n
+	}
+}
+
+func (e *Edge) From() string {
+	return e.from
+}
+
+func (e *Edge) To() string {
+	return e.to
+}
+
+func (e *Edge) match(ctx context.Context, output any) bool {
+	return e.condition == nil || e.condition(ctx, output)
+}
+
+func (e *Edge) deliver(ctx context.Context, output any) (any, error) {
+	if e.mapper == nil {
+		return output, nil
+	}
+	return e.mapper(ctx, output)
+}
@@ -0,0 +1,560 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/bububa/atomic-agents/agents"
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/interceptor"
+	"github.com/bububa/atomic-agents/schema"
+)
+
+var (
+	// ErrInvalidGraph returns when the graph validation failed
+	ErrInvalidGraph = errors.New("invalid workflow graph")
+	// ErrLoopLimit returns when a loop edge is matched more times than its max loops
+	ErrLoopLimit = errors.New("workflow loop limit exceeded")
+	// ErrFinishNotReached returns when the run ends without running the finish node
+	ErrFinishNotReached = errors.New("workflow finish node not reached")
+)
+
+// Graph is a workflow of agents and tools nodes connected by conditional edges.
+// Nodes whose upstream nodes are resolved run concurrently, a node without any activated
+// incoming edge is skipped. A run ends when every node is resolved, the output is the finish node output.
+type Graph[I schema.Schema, O schema.Schema] struct {
+	interceptor.Stack
+	name          string
+	nodes         map[string]*Node
+	edges         []*Edge
+	entry         string
+	finish        string
+	concurrency   int
+	errs          []error
+	nodeStartHook func(context.Context, *Graph[I, O], *Node, any)
+	nodeEndHook   func(context.Context, *Graph[I, O], *Node, any, any, *components.LLMResponse)
+	nodeErrorHook func(context.Context, *Graph[I, O], *Node, any, error)
+}
+
+var (
+	_ agents.TypeableAgent[schema.String, schema.String] = (*Graph[schema.String, schema.String])(nil)
+	_ agents.AnonymousAgent                              = (*Graph[schema.String, schema.String])(nil)
+)
+
+// New returns a new Graph instance
+func New[I schema.Schema, O schema.Schema]() *Graph[I, O] {
+	return &Graph[I, O]{
+		nodes: make(map[string]*Node),
+	}
+}
+
+func (g *Graph[I, O]) Name() string {
+	return g.name
+}
+
+func (g *Graph[I, O]) SetName(name string) {
+	g.name = name
+}
+
+// AddNode adds nodes to the graph, node names must be unique
+func (g *Graph[I, O]) AddNode(nodes ...*Node) *Graph[I, O] {
+	for _, n := range nodes {
+		if _, found := g.nodes[n.name]; found {
+			g.errs = append(g.errs, fmt.Errorf("duplicated node %s", n.name))
+			continue
+		}
+		g.nodes[n.name] = n
+	}
+	return g
+}
+
+// AddEdge adds an edge from one node to another
+func (g *Graph[I, O]) AddEdge(from string, to string, opts ...EdgeOption) *Graph[I, O] {
+	e := &Edge{from: from, to: to}
+	for _, opt := range opts {
+		opt(e)
+	}
+	g.edges = append(g.edges, e)
+	return g
+}
+
+// Node returns the node of the name
+func (g *Graph[I, O]) Node(name string) (*Node, bool) {
+	n, found := g.nodes[name]
+	return n, found
+}
+
+// Edges returns the edges in adding order
+func (g *Graph[I, O]) Edges() []*Edge {
+	return g.edges
+}
+
+// SetEntry set the node receiving the graph input
+func (g *Graph[I, O]) SetEntry(name string) *Graph[I, O] {
+	g.entry = name
+	return g
+}
+
+// SetFinish set the node whose output is the graph output
+func (g *Graph[I, O]) SetFinish(name string) *Graph[I, O] {
+	g.finish = name
+	return g
+}
+
+// SetConcurrency set the maximum number of nodes running at the same time, unbounded if not positive
+func (g *Graph[I, O]) SetConcurrency(n int) *Graph[I, O] {
+	g.concurrency = n
+	return g
+}
+
+// SetStartHook sets the hook calling fn before every run
+//
+// Deprecated: use Use with interceptor.Hooks or interceptor.Before, see interceptor.Stack SetHook
+func (g *Graph[I, O]) SetStartHook(fn func(context.Context, *Graph[I, O], *I)) {
+	if fn == nil {
+		g.SetHook(interceptor.StartHook, nil)
+		return
+	}
+	g.SetHook(interceptor.StartHook, interceptor.Hooks[I, O](func(ctx context.Context, input *I) {
+		fn(ctx, g, input)
+	}, nil, nil))
+}
+
+// SetEndHook sets the hook calling fn after every successful run
+//
+// Deprecated: use Use with interceptor.Hooks or interceptor.After, see interceptor.Stack SetHook
+func (g *Graph[I, O]) SetEndHook(fn func(context.Context, *Graph[I, O], *I, *O, *components.LLMResponse)) {
+	if fn == nil {
+		g.SetHook(interceptor.EndHook, nil)
+		return
+	}
+	g.SetHook(interceptor.EndHook, interceptor.Hooks[I, O](nil, func(ctx context.Context, input *I, output *O, apiResp *components.LLMResponse) {
+		fn(ctx, g, input, output, apiResp)
+	}, nil))
+}
+
+// SetErrorHook sets the hook calling fn after every failed run
+//
+// Deprecated: use Use with interceptor.Hooks or interceptor.After, see interceptor.Stack SetHook
+func (g *Graph[I, O]) SetErrorHook(fn func(context.Context, *Graph[I, O], *I, *components.LLMResponse, error)) {
+	if fn == nil {
+		g.SetHook(interceptor.ErrorHook, nil)
+		return
+	}
+	g.SetHook(interceptor.ErrorHook, interceptor.Hooks[I, O](nil, nil, func(ctx context.Context, input *I, apiResp *components.LLMResponse, err error) {
+		fn(ctx, g, input, apiResp, err)
+	}))
+}
+
+// SetNodeStartHook set the hook called with the node input before a node runs, could be called concurrently
+func (g *Graph[I, O]) SetNodeStartHook(fn func(context.Context, *Graph[I, O], *Node, any)) {
+	g.nodeStartHook = fn
+}
+
+// SetNodeEndHook set the hook called with the node input, output and usage after a node runs, could be called concurrently
+func (g *Graph[I, O]) SetNodeEndHook(fn func(context.Context, *Graph[I, O], *Node, any, any, *components.LLMResponse)) {
+	g.nodeEndHook = fn
+}
+
+// SetNodeErrorHook set the hook called when a node failed, could be called concurrently
+func (g *Graph[I, O]) SetNodeErrorHook(fn func(context.Context, *Graph[I, O], *Node, any, error)) {
+	g.nodeErrorHook = fn
+}
+
+// Validate checks the graph is runnable: entry and finish nodes exist, edges connect known nodes,
+// every node is reachable from the entry node and every loop is bounded
+func (g *Graph[I, O]) Validate() error {
+	_, err := g.compile()
+	return err
+}
+
+// plan is the validated graph, with loop edges split from the forward edges
+type plan struct {
+	// order is the topological order of nodes on forward edges
+	order []string
+	// preds are the incoming forward edges of nodes
+	preds map[string][]*Edge
+	// outs are the outgoing forward edges of nodes
+	outs map[string][]*Edge
+	// loops are the outgoing loop edges of nodes
+	loops map[string][]*Edge
+	// slots are the indexes of forward edges in their target node preds
+	slots map[*Edge]int
+}
+
+func (g *Graph[I, O]) compile() (*plan, error) {
+	errs := append([]error(nil), g.errs...)
+	for _, v := range []struct{ kind, name string }{{"entry", g.entry}, {"finish", g.finish}} {
+		if v.name == "" {
+			errs = append(errs, fmt.Errorf("%s node is not set", v.kind))
+		} else if _, found := g.nodes[v.name]; !found {
+			errs = append(errs, fmt.Errorf("%s node %s not found", v.kind, v.name))
+		}
+	}
+	for name, n := range g.nodes {
+		if n.agent == nil && n.tool == nil && n.join == nil {
+			errs = append(errs, fmt.Errorf("node %s has neither agent, tool nor join function", name))
+		}
+	}
+	adjacency := make(map[string][]*Edge, len(g.nodes))
+	for _, e := range g.edges {
+		_, fromFound := g.nodes[e.from]
+		_, toFound := g.nodes[e.to]
+		if !fromFound || !toFound {
+			errs = append(errs, fmt.Errorf("edge from %s to %s connects unknown node", e.from, e.to))
+			continue
+		}
+		adjacency[e.from] = append(adjacency[e.from], e)
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidGraph, errors.Join(errs...))
+	}
+	p := &plan{
+		preds: make(map[string][]*Edge, len(g.nodes)),
+		outs:  make(map[string][]*Edge, len(g.nodes)),
+		loops: make(map[string][]*Edge),
+		slots: make(map[*Edge]int, len(g.edges)),
+	}
+	var (
+		visited   = make(map[string]bool, len(g.nodes))
+		onStack   = make(map[string]bool, len(g.nodes))
+		postOrder = make([]string, 0, len(g.nodes))
+		forward   = make(map[*Edge]bool, len(g.edges))
+		visit     func(string)
+	)
+	visit = func(name string) {
+		visited[name] = true
+		onStack[name] = true
+		for _, e := range adjacency[name] {
+			if onStack[e.to] {
+				p.loops[name] = append(p.loops[name], e)
+				if e.maxLoops <= 0 {
+					errs = append(errs, fmt.Errorf("loop edge from %s to %s is unbounded", e.from, e.to))
+				}
+				continue
+			}
+			forward[e] = true
+			p.outs[name] = append(p.outs[name], e)
+			if !visited[e.to] {
+				visit(e.to)
+			}
+		}
+		onStack[name] = false
+		postOrder = append(postOrder, name)
+	}
+	visit(g.entry)
+	for name := range g.nodes {
+		if !visited[name] {
+			errs = append(errs, fmt.Errorf("node %s is not reachable from entry node", name))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidGraph, errors.Join(errs...))
+	}
+	p.order = make([]string, 0, len(postOrder))
+	for idx := len(postOrder) - 1; idx >= 0; idx-- {
+		p.order = append(p.order, postOrder[idx])
+	}
+	for _, e := range g.edges {
+		if forward[e] {
+			p.slots[e] = len(p.preds[e.to])
+			p.preds[e.to] = append(p.preds[e.to], e)
+		}
+	}
+	return p, nil
+}
+
+// reach returns the nodes reachable from the node on forward edges, the node included
+func (p *plan) reach(name string) map[string]struct{} {
+	ret := map[string]struct{}{name: {}}
+	queue := []string{name}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, e := range p.outs[cur] {
+			if _, found := ret[e.to]; !found {
+				ret[e.to] = struct{}{}
+				queue = append(queue, e.to)
+			}
+		}
+	}
+	return ret
+}
+
+// Run validates the graph and runs the nodes from the entry node with the given input
+func (g *Graph[I, O]) Run(ctx context.Context, input *I, output *O, apiResp *components.LLMResponse) error {
+	call := &interceptor.Call{Kind: interceptor.AgentCall, Name: g.name, Target: g}
+	return interceptor.Typed(ctx, call, g.Interceptors(), input, output, apiResp, g.invoke)
+}
+
+func (g *Graph[I, O]) invoke(ctx context.Context, input *I, output *O, apiResp *components.LLMResponse) error {
+	if apiResp == nil {
+		apiResp = new(components.LLMResponse)
+	}
+	usage := new(components.LLMUsage)
+	out, err := g.execute(components.WithCostScope(ctx, g.name), input, usage)
+	apiResp.Usage = usage
+	if err == nil {
+		if outO, ok := out.(*O); ok {
+			*output = *outO
+		} else {
+			err = errors.New("invalid agent output schema")
+		}
+	}
+	return err
+}
+
+// RunAnonymous runs the graph with the given input for chain.
+func (g *Graph[I, O]) RunAnonymous(ctx context.Context, input any, apiResp *components.LLMResponse) (any, error) {
+	in, ok := input.(*I)
+	if !ok {
+		return nil, errors.New("invalid agent input schema")
+	}
+	out := new(O)
+	if err := g.Run(ctx, in, out, apiResp); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+type nodeState int
+
+const (
+	pendingNode nodeState = iota
+	runningNode
+	doneNode
+	skippedNode
+)
+
+// delivery is an input delivered by an edge
+type delivery struct {
+	value     any
+	delivered bool
+}
+
+// nodeResult is the result of a node run in a wave
+type nodeResult struct {
+	name   string
+	output any
+	resp   components.LLMResponse
+	err    error
+}
+
+// execution is the state of a graph run
+type execution struct {
+	plan    *plan
+	states  map[string]nodeState
+	slots   map[string][]delivery
+	direct  map[string]any
+	outputs map[string]any
+	loops   map[*Edge]int
+}
+
+func (g *Graph[I, O]) execute(ctx context.Context, input *I, usage *components.LLMUsage) (any, error) {
+	p, err := g.compile()
+	if err != nil {
+		return nil, err
+	}
+	ex := &execution{
+		plan:    p,
+		states:  make(map[string]nodeState, len(p.order)),
+		slots:   make(map[string][]delivery, len(p.order)),
+		direct:  map[string]any{g.entry: input},
+		outputs: make(map[string]any, len(p.order)),
+		loops:   make(map[*Edge]int),
+	}
+	for _, name := range p.order {
+		ex.slots[name] = make([]delivery, len(p.preds[name]))
+	}
+	for {
+		ready, inputs := ex.ready()
+		if len(ready) == 0 {
+			break
+		}
+		results := g.runWave(ctx, ready, inputs)
+		for _, res := range results {
+			usage.Merge(res.resp.Usage)
+		}
+		if res := firstFailure(results); res != nil {
+			return nil, fmt.Errorf("workflow node %s: %w", res.name, res.err)
+		}
+		for _, res := range results {
+			if err := ex.complete(ctx, res); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if ex.states[g.finish] != doneNode {
+		return nil, fmt.Errorf("%w: %s", ErrFinishNotReached, g.finish)
+	}
+	return ex.outputs[g.finish], nil
+}
+
+// ready returns the nodes whose upstream nodes are resolved with their inputs, skips nodes without any input
+func (ex *execution) ready() ([]string, [][]any) {
+	var (
+		names  []string
+		inputs [][]any
+	)
+	for _, name := range ex.plan.order {
+		if ex.states[name] != pendingNode {
+			continue
+		}
+		resolved := true
+		for _, e := range ex.plan.preds[name] {
+			if st := ex.states[e.from]; st != doneNode && st != skippedNode {
+				resolved = false
+				break
+			}
+		}
+		if !resolved {
+			continue
+		}
+		var in []any
+		if v, found := ex.direct[name]; found {
+			in = []any{v}
+		} else {
+			for _, v := range ex.slots[name] {
+				if v.delivered {
+					in = append(in, v.value)
+				}
+			}
+		}
+		if len(in) == 0 {
+			ex.states[name] = skippedNode
+			continue
+		}
+		ex.states[name] = runningNode
+		names = append(names, name)
+		inputs = append(inputs, in)
+	}
+	return names, inputs
+}
+
+// complete delivers the node output on its matched edges, a matched loop edge resets the looped nodes
+func (ex *execution) complete(ctx context.Context, res nodeResult) error {
+	if ex.states[res.name] != runningNode {
+		// reset by a loop edge in the same wave
+		return nil
+	}
+	ex.states[res.name] = doneNode
+	ex.outputs[res.name] = res.output
+	for _, e := range ex.plan.loops[res.name] {
+		if !e.match(ctx, res.output) {
+			continue
+		}
+		if ex.loops[e] >= e.maxLoops {
+			return fmt.Errorf("%w: from %s to %s %d times", ErrLoopLimit, e.from, e.to, e.maxLoops)
+		}
+		ex.loops[e]++
+		v, err := e.deliver(ctx, res.output)
+		if err != nil {
+			return fmt.Errorf("workflow edge from %s to %s: %w", e.from, e.to, err)
+		}
+		ex.reset(e.to)
+		ex.direct[e.to] = v
+		return nil
+	}
+	for _, e := range ex.plan.outs[res.name] {
+		if !e.match(ctx, res.output) {
+			continue
+		}
+		v, err := e.deliver(ctx, res.output)
+		if err != nil {
+			return fmt.Errorf("workflow edge from %s to %s: %w", e.from, e.to, err)
+		}
+		ex.slots[e.to][ex.plan.slots[e]] = delivery{value: v, delivered: true}
+	}
+	return nil
+}
+
+// reset marks the nodes reachable from the loop target pending, and drops the inputs delivered among them
+func (ex *execution) reset(name string) {
+	nodes := ex.plan.reach(name)
+	for n := range nodes {
+		ex.states[n] = pendingNode
+		delete(ex.outputs, n)
+		delete(ex.direct, n)
+		for idx, e := range ex.plan.preds[n] {
+			if _, found := nodes[e.from]; found {
+				ex.slots[n][idx] = delivery{}
+			}
+		}
+	}
+}
+
+// runWave runs the ready nodes concurrently, returns the results in nodes order
+func (g *Graph[I, O]) runWave(ctx context.Context, names []string, inputs [][]any) []nodeResult {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	limit := g.concurrency
+	if limit <= 0 || limit > len(names) {
+		limit = len(names)
+	}
+	var (
+		results = make([]nodeResult, len(names))
+		sem     = make(chan struct{}, limit)
+		wg      sync.WaitGroup
+	)
+	for idx, name := range names {
+		wg.Add(1)
+		go func(idx int, node *Node) {
+			defer wg.Done()
+			result := &results[idx]
+			result.name = node.name
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				result.err = ctx.Err()
+				return
+			}
+			result.output, result.err = g.runNode(ctx, node, inputs[idx], &result.resp)
+			if result.err != nil {
+				cancel()
+			}
+		}(idx, g.nodes[name])
+	}
+	wg.Wait()
+	return results
+}
+
+// firstFailure returns the first failed result, prefers the failure over the cancellation it caused
+func firstFailure(results []nodeResult) *nodeResult {
+	var ret *nodeResult
+	for idx := range results {
+		res := &results[idx]
+		if res.err == nil {
+			continue
+		}
+		if !errors.Is(res.err, context.Canceled) {
+			return res
+		}
+		if ret == nil {
+			ret = res
+		}
+	}
+	return ret
+}
+
+func (g *Graph[I, O]) runNode(ctx context.Context, node *Node, inputs []any, apiResp *components.LLMResponse) (any, error) {
+	input, err := node.input(ctx, inputs)
+	if err == nil {
+		if fn := g.nodeStartHook; fn != nil {
+			fn(ctx, g, node, input)
+		}
+		var output any
+		if output, err = node.run(ctx, input, apiResp); err == nil {
+			if fn := g.nodeEndHook; fn != nil {
+				fn(ctx, g, node, input, output, apiResp)
+			}
+			return output, nil
+		}
+	}
+	if fn := g.nodeErrorHook; fn != nil {
+		fn(ctx, g, node, input, err)
+	}
+	return nil, err
+}
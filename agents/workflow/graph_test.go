@@ -0,0 +1,186 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bububa/instructor-go"
+	openaiClt "github.com/bububa/instructor-go/instructors/openai"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+
+	"github.com/bububa/atomic-agents/agents"
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/internal/llmtest"
+	"github.com/bububa/atomic-agents/schema"
+)
+
+// funcAgent is an AnonymousAgent running a function on string input
+type funcAgent struct {
+	name string
+	fn   func(string) string
+}
+
+func (a *funcAgent) Name() string {
+	return a.name
+}
+
+func (a *funcAgent) RunAnonymous(_ context.Context, input any, apiResp *components.LLMResponse) (any, error) {
+	in, ok := input.(*schema.String)
+	if !ok {
+		return nil, errors.New("invalid agent input schema")
+	}
+	apiResp.Usage = &components.LLMUsage{InputTokens: 10, OutputTokens: 1}
+	return schema.NewString(a.fn(in.String())), nil
+}
+
+func prefix(name string) *Node {
+	return NewAgentNode(name, &funcAgent{name: name, fn: func(s string) string { return name + "(" + s + ")" }})
+}
+
+func contains(sub string) Condition {
+	return Match(func(_ context.Context, s *schema.String) bool {
+		return strings.Contains(s.String(), sub)
+	})
+}
+
+func joinStrings(_ context.Context, inputs []any) (any, error) {
+	list := make([]string, 0, len(inputs))
+	for _, v := range inputs {
+		list = append(list, v.(*schema.String).String())
+	}
+	return schema.NewString(strings.Join(list, " + ")), nil
+}
+
+func TestGraphBranchJoin(t *testing.T) {
+	g := New[schema.String, schema.String]().
+		AddNode(prefix("lint"), prefix("security"), prefix("style"), prefix("docs"), NewJoinNode("review", joinStrings)).
+		AddEdge("lint", "security").
+		AddEdge("lint", "style").
+		AddEdge("lint", "docs", When(contains("README"))).
+		AddEdge("security", "review").
+		AddEdge("style", "review").
+		AddEdge("docs", "review").
+		SetEntry("lint").
+		SetFinish("review")
+	var visited []string
+	g.SetNodeEndHook(func(_ context.Context, _ *Graph[schema.String, schema.String], n *Node, _ any, _ any, resp *components.LLMResponse) {
+		if n.Agent() != nil && resp.Usage.InputTokens != 10 {
+			t.Errorf("expect node usage exposed to hook, got %+v", resp.Usage)
+		}
+	})
+	g.SetNodeStartHook(func(_ context.Context, _ *Graph[schema.String, schema.String], n *Node, _ any) {
+		if n.Name() == "docs" {
+			visited = append(visited, n.Name())
+		}
+	})
+	output := new(schema.String)
+	apiResp := new(components.LLMResponse)
+	if err := g.Run(context.Background(), schema.NewString("main.go"), output, apiResp); err != nil {
+		t.Fatalf("run graph failed: %v", err)
+	}
+	if want := "security(lint(main.go)) + style(lint(main.go))"; output.String() != want {
+		t.Errorf("expect %q, got %q", want, output.String())
+	}
+	if len(visited) != 0 {
+		t.Errorf("expect docs branch skipped, got %v", visited)
+	}
+	if apiResp.Usage.InputTokens != 30 {
+		t.Errorf("expect usage aggregated across nodes, got %+v", apiResp.Usage)
+	}
+}
+
+func TestGraphLoop(t *testing.T) {
+	var drafts int
+	drafter := NewAgentNode("draft", &funcAgent{name: "draft", fn: func(s string) string {
+		drafts++
+		return strings.Repeat("!", drafts) + s
+	}})
+	critic := NewAgentNode("critic", &funcAgent{name: "critic", fn: func(s string) string {
+		if strings.HasPrefix(s, "!!!") {
+			return "approved: " + s
+		}
+		return "rejected: " + s
+	}})
+	build := func(maxLoops int) *Graph[schema.String, schema.String] {
+		drafts = 0
+		return New[schema.String, schema.String]().
+			AddNode(drafter, critic, prefix("publish")).
+			AddEdge("draft", "critic").
+			AddEdge("critic", "draft", When(contains("rejected")), WithMaxLoops(maxLoops), WithMapper(func(_ context.Context, output any) (any, error) {
+				return schema.NewString("topic"), nil
+			})).
+			AddEdge("critic", "publish", When(contains("approved"))).
+			SetEntry("draft").
+			SetFinish("publish")
+	}
+	output := new(schema.String)
+	if err := build(3).Run(context.Background(), schema.NewString("topic"), output, nil); err != nil {
+		t.Fatalf("run graph failed: %v", err)
+	}
+	if want := "publish(approved: !!!topic)"; output.String() != want {
+		t.Errorf("expect %q, got %q", want, output.String())
+	}
+	if err := build(1).Run(context.Background(), schema.NewString("topic"), output, nil); !errors.Is(err, ErrLoopLimit) {
+		t.Errorf("expect loop limit error, got %v", err)
+	}
+}
+
+func TestGraphValidate(t *testing.T) {
+	err := New[schema.String, schema.String]().
+		AddNode(prefix("draft"), prefix("critic"), prefix("orphan")).
+		AddEdge("draft", "critic").
+		AddEdge("critic", "draft").
+		SetEntry("draft").
+		SetFinish("critic").
+		Validate()
+	if !errors.Is(err, ErrInvalidGraph) || !strings.Contains(err.Error(), "unbounded") || !strings.Contains(err.Error(), "orphan") {
+		t.Errorf("expect unbounded loop and unreachable node reported, got %v", err)
+	}
+}
+
+func TestGraphSharedClient(t *testing.T) {
+	// replies the number of messages received, system prompt included
+	srv := llmtest.NewServer(t, func(body map[string]any) llmtest.Reply {
+		return llmtest.Reply{Content: fmt.Sprintf(`{"chat_message":"%d"}`, len(llmtest.Messages(body)))}
+	})
+	openaiClient := openai.NewClient(option.WithBaseURL(srv.URL), option.WithAPIKey("test"), option.WithMaxRetries(0))
+	clt := openaiClt.New(&openaiClient, instructor.WithMode(instructor.ModeJSON), instructor.WithMaxRetries(0))
+	clt.SetMemory(instructor.NewMemory(10))
+	newNode := func(name string) *Node {
+		return NewAgentNode(name, agents.NewAgent[schema.Input, schema.Output](agents.WithClient(clt), agents.WithModel(llmtest.Model)))
+	}
+	g := New[schema.Input, schema.Output]().
+		AddNode(
+			NewJoinNode("start", func(_ context.Context, inputs []any) (any, error) { return inputs[0], nil }),
+			newNode("a"),
+			newNode("b"),
+			NewJoinNode("end", func(_ context.Context, inputs []any) (any, error) {
+				list := make([]string, 0, len(inputs))
+				for _, v := range inputs {
+					list = append(list, v.(*schema.Output).ChatMessage)
+				}
+				return schema.NewOutput(strings.Join(list, " + ")), nil
+			}),
+		).
+		AddEdge("start", "a").
+		AddEdge("start", "b").
+		AddEdge("a", "end").
+		AddEdge("b", "end").
+		SetEntry("start").
+		SetFinish("end")
+	output := new(schema.Output)
+	if err := g.Run(context.Background(), schema.NewInput("hi"), output, nil); err != nil {
+		t.Fatalf("run graph failed: %v", err)
+	}
+	// every node sees the system prompt and its own input only
+	if len(srv.Requests()) != 2 || output.ChatMessage != "2 + 2" {
+		t.Errorf("expect nodes on isolated memories, got %q", output.ChatMessage)
+	}
+	if n := len(clt.Memory().List()); n != 0 {
+		t.Errorf("expect shared client memory untouched, got %d messages", n)
+	}
+}
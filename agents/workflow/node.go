@@ -0,0 +1,79 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bububa/atomic-agents/agents"
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/memory"
+	"github.com/bububa/atomic-agents/tools"
+)
+
+// JoinFunc merges the inputs delivered by activated incoming edges, in edges order, into the node input
+type JoinFunc func(ctx context.Context, inputs []any) (any, error)
+
+// Node is a step of the Graph
+type Node struct {
+	name  string
+	agent agents.AnonymousAgent
+	tool  tools.AnonymousTool
+	join  JoinFunc
+}
+
+// NewAgentNode returns a Node running an agent
+func NewAgentNode(name string, agent agents.AnonymousAgent) *Node {
+	return &Node{name: name, agent: agent}
+}
+
+// NewToolNode returns a Node running a tool
+func NewToolNode(name string, tool tools.AnonymousTool) *Node {
+	return &Node{name: name, tool: tool}
+}
+
+// NewJoinNode returns a Node which outputs the merged inputs
+func NewJoinNode(name string, join JoinFunc) *Node {
+	return &Node{name: name, join: join}
+}
+
+func (n *Node) Name() string {
+	return n.name
+}
+
+// Agent returns the agent of the node, nil for tool or join nodes
+func (n *Node) Agent() agents.AnonymousAgent {
+	return n.agent
+}
+
+// Tool returns the tool of the node, nil for agent or join nodes
+func (n *Node) Tool() tools.AnonymousTool {
+	return n.tool
+}
+
+// SetJoin set the function merging inputs when the node has several activated incoming edges
+func (n *Node) SetJoin(fn JoinFunc) *Node {
+	n.join = fn
+	return n
+}
+
+// input returns the node input from the delivered inputs
+func (n *Node) input(ctx context.Context, inputs []any) (any, error) {
+	if n.join != nil {
+		return n.join(ctx, inputs)
+	}
+	if len(inputs) != 1 {
+		return nil, fmt.Errorf("node %s received %d inputs without join function", n.name, len(inputs))
+	}
+	return inputs[0], nil
+}
+
+func (n *Node) run(ctx context.Context, input any, apiResp *components.LLMResponse) (any, error) {
+	switch {
+	case n.agent != nil:
+		// nodes of a wave run concurrently, every agent run gets its own memory so that agents sharing a client don't race
+		return n.agent.RunAnonymous(memory.Isolate(ctx), input, apiResp)
+	case n.tool != nil:
+		return tools.Invoke(ctx, n.tool, input)
+	}
+	return input, nil
+}
@@ -0,0 +1,138 @@
+package components
+
+import (
+	"context"
+	"sync"
+)
+
+// CostKind kind of the call which costs
+type CostKind string
+
+const (
+	// ChatCost LLM chat call
+	ChatCost CostKind = "chat"
+	// EmbeddingCost embedder call
+	EmbeddingCost CostKind = "embedding"
+)
+
+// CostEntry is the usage of a LLM or embedder call attributed to the component made it
+type CostEntry struct {
+	// Scope slash separated names of the enclosing components, e.g. feature/chain/rag
+	Scope string `json:"scope,omitempty"`
+	// Name component name
+	Name     string   `json:"name,omitempty"`
+	Kind     CostKind `json:"kind,omitempty"`
+	Provider string   `json:"provider,omitempty"`
+	Model    string   `json:"model,omitempty"`
+	Usage    LLMUsage `json:"usage"`
+}
+
+// CostReport collects usage and cost of the calls made during a run. Components record into the CostReport
+// carried by the context, attach one with WithCostReport.
+type CostReport struct {
+	mu      sync.Mutex
+	entries []CostEntry
+}
+
+// NewCostReport returns a new CostReport
+func NewCostReport() *CostReport {
+	return new(CostReport)
+}
+
+// Add adds a cost entry
+func (r *CostReport) Add(entry CostEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, entry)
+}
+
+// Entries returns the recorded cost entries in order
+func (r *CostReport) Entries() []CostEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]CostEntry(nil), r.entries...)
+}
+
+// Total returns the usage summed across entries
+func (r *CostReport) Total() LLMUsage {
+	var total LLMUsage
+	for _, v := range r.Entries() {
+		total.Merge(&v.Usage)
+	}
+	return total
+}
+
+// GroupBy returns usage summed by the key of entries
+func (r *CostReport) GroupBy(key func(CostEntry) string) map[string]LLMUsage {
+	ret := make(map[string]LLMUsage)
+	for _, v := range r.Entries() {
+		k := key(v)
+		usage := ret[k]
+		usage.Merge(&v.Usage)
+		ret[k] = usage
+	}
+	return ret
+}
+
+// ByScope returns usage summed by entry scope
+func (r *CostReport) ByScope() map[string]LLMUsage {
+	return r.GroupBy(func(v CostEntry) string {
+		return v.Scope
+	})
+}
+
+// ByModel returns usage summed by entry provider/model
+func (r *CostReport) ByModel() map[string]LLMUsage {
+	return r.GroupBy(func(v CostEntry) string {
+		return v.Provider + "/" + v.Model
+	})
+}
+
+type costReportKey struct{}
+
+type costScopeKey struct{}
+
+// WithCostReport returns a context which carries the CostReport
+func WithCostReport(ctx context.Context, report *CostReport) context.Context {
+	return context.WithValue(ctx, costReportKey{}, report)
+}
+
+// CostReportFromContext returns the CostReport carried by the context, nil if none
+func CostReportFromContext(ctx context.Context) *CostReport {
+	report, _ := ctx.Value(costReportKey{}).(*CostReport)
+	return report
+}
+
+// WithCostScope returns a context which attributes the costs recorded in it to the named scope,
+// scopes nest, so a feature name set by callers prefixes the names of chains, RAG and agents.
+func WithCostScope(ctx context.Context, name string) context.Context {
+	if name == "" || CostReportFromContext(ctx) == nil {
+		return ctx
+	}
+	if scope := CostScope(ctx); scope != "" {
+		name = scope + "/" + name
+	}
+	return context.WithValue(ctx, costScopeKey{}, name)
+}
+
+// CostScope returns the cost scope of the context
+func CostScope(ctx context.Context) string {
+	scope, _ := ctx.Value(costScopeKey{}).(string)
+	return scope
+}
+
+// RecordCost records usage into the CostReport carried by the context, does nothing if none
+func RecordCost(ctx context.Context, kind CostKind, name string, provider string, model string, usage *LLMUsage) {
+	report := CostReportFromContext(ctx)
+	if report == nil || usage == nil {
+		return
+	}
+	report.Add(CostEntry{
+		Scope:    CostScope(ctx),
+		Name:     name,
+		Kind:     kind,
+		Provider: provider,
+		Model:    model,
+		Usage:    *usage,
+	})
+}
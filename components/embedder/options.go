@@ -1,5 +1,11 @@
 package embedder
 
+import (
+	"context"
+
+	"github.com/bububa/atomic-agents/components"
+)
+
 // Options holds the configuration for creating an Embedder instance.
 // It supports multiple embedding providers and their specific options.
 type Options struct {
@@ -7,6 +13,8 @@ type Options struct {
 	provider Provider
 	// model specifies the model to use
 	model string
+	// priceTable computes the embedding cost, components.DefaultPriceTable is used if nil
+	priceTable *components.PriceTable
 }
 
 // Option is a function type for configuring the EmbedderConfig.
@@ -25,6 +33,13 @@ func WithModel(model string) Option {
 	}
 }
 
+// WithPriceTable set the PriceTable computing the embedding cost
+func WithPriceTable(t *components.PriceTable) Option {
+	return func(o *Options) {
+		o.priceTable = t
+	}
+}
+
 func (i Options) Provider() Provider {
 	return i.provider
 }
@@ -32,3 +47,12 @@ func (i Options) Provider() Provider {
 func (i Options) Model() string {
 	return i.model
 }
+
+// RecordCost computes the usage cost with the price table, and records the usage into the CostReport carried by the context.
+// Embedders call it once the usage of an embedding request is known.
+func (i Options) RecordCost(ctx context.Context, usage *components.LLMUsage) {
+	if cost, ok := i.priceTable.Cost(i.provider, i.model, usage); ok {
+		usage.Cost = cost
+	}
+	components.RecordCost(ctx, components.EmbeddingCost, i.provider, i.provider, i.model, usage)
+}
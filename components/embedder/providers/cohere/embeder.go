@@ -26,7 +26,7 @@ func New(client *cohereClient.Client, opts ...embedder.Option) *Embedder {
 	i := &Embedder{
 		Client: client,
 	}
-	embedder.WithProvider(embedder.ProviderCohere)
+	embedder.WithProvider(embedder.ProviderCohere)(&i.Options)
 	for _, opt := range opts {
 		opt(&i.Options)
 	}
@@ -45,7 +45,10 @@ func (p *Embedder) Embed(ctx context.Context, text string, embedding *embedder.E
 		return err
 	}
 	respV := resp.GetEmbeddingsFloats()
-	if usage != nil && respV.Meta != nil && respV.Meta.Tokens != nil {
+	if usage == nil {
+		usage = new(components.LLMUsage)
+	}
+	if respV.Meta != nil && respV.Meta.Tokens != nil {
 		if v := respV.Meta.Tokens.InputTokens; v != nil {
 			usage.InputTokens = int64(*v)
 		}
@@ -53,6 +56,7 @@ func (p *Embedder) Embed(ctx context.Context, text string, embedding *embedder.E
 			usage.OutputTokens = int64(*v)
 		}
 	}
+	p.RecordCost(ctx, usage)
 	if len(respV.Embeddings) == 0 {
 		return nil
 	}
@@ -75,7 +79,10 @@ func (p *Embedder) BatchEmbed(ctx context.Context, parts []string, usage *compon
 		return nil, err
 	}
 	respV := resp.GetEmbeddingsFloats()
-	if usage != nil && respV.Meta != nil && respV.Meta.Tokens != nil {
+	if usage == nil {
+		usage = new(components.LLMUsage)
+	}
+	if respV.Meta != nil && respV.Meta.Tokens != nil {
 		if v := respV.Meta.Tokens.InputTokens; v != nil {
 			usage.InputTokens = int64(*v)
 		}
@@ -83,6 +90,7 @@ func (p *Embedder) BatchEmbed(ctx context.Context, parts []string, usage *compon
 			usage.OutputTokens = int64(*v)
 		}
 	}
+	p.RecordCost(ctx, usage)
 	ret := make([]embedder.Embedding, 0, len(respV.Embeddings))
 	for idx, v := range respV.Embeddings {
 		ret = append(ret, embedder.Embedding{
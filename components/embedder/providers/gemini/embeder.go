@@ -24,7 +24,7 @@ func New(client *gemini.Client, opts ...embedder.Option) *Embedder {
 	i := &Embedder{
 		Client: client,
 	}
-	embedder.WithProvider(embedder.ProviderGemini)
+	embedder.WithProvider(embedder.ProviderGemini)(&i.Options)
 	for _, opt := range opts {
 		opt(&i.Options)
 	}
@@ -27,7 +27,7 @@ func New(client *Client, opts ...embedder.Option) *Embedder {
 	i := &Embedder{
 		Client: client,
 	}
-	embedder.WithProvider(embedder.ProviderHuggingFace)
+	embedder.WithProvider(embedder.ProviderHuggingFace)(&i.Options)
 	embedder.WithModel(DefaultEmbedderModel)
 	for _, opt := range opts {
 		opt(&i.Options)
@@ -26,7 +26,7 @@ func New(client *openai.Client, opts ...embedder.Option) *Embedder {
 	i := &Embedder{
 		Client: client,
 	}
-	embedder.WithProvider(embedder.ProviderOpenAI)
+	embedder.WithProvider(embedder.ProviderOpenAI)(&i.Options)
 	for _, opt := range opts {
 		opt(&i.Options)
 	}
@@ -45,9 +45,11 @@ func (p *Embedder) Embed(ctx context.Context, text string, embedding *embedder.E
 	if err != nil {
 		return err
 	}
-	if usage != nil {
-		usage.InputTokens = resp.Usage.TotalTokens
+	if usage == nil {
+		usage = new(components.LLMUsage)
 	}
+	usage.InputTokens = resp.Usage.TotalTokens
+	p.RecordCost(ctx, usage)
 	if len(resp.Data) == 0 {
 		return nil
 	}
@@ -73,9 +75,11 @@ func (p *Embedder) BatchEmbed(ctx context.Context, parts []string, usage *compon
 	if err != nil {
 		return nil, err
 	}
-	if usage != nil {
-		usage.InputTokens = resp.Usage.TotalTokens
+	if usage == nil {
+		usage = new(components.LLMUsage)
 	}
+	usage.InputTokens = resp.Usage.TotalTokens
+	p.RecordCost(ctx, usage)
 	ret := make([]embedder.Embedding, 0, len(resp.Data))
 	for _, v := range resp.Data {
 		embeddings := make([]float64, 0, len(v.Embedding))
@@ -0,0 +1,50 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/embedder"
+)
+
+func TestEmbedderRecordCost(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"object": "list",
+			"model":  "text-embedding-3-small",
+			"data": []map[string]any{
+				{"object": "embedding", "index": 0, "embedding": []float64{0.1, 0.2}},
+			},
+			"usage": map[string]any{"prompt_tokens": 1000, "total_tokens": 1000},
+		})
+	}))
+	defer srv.Close()
+	clt := openai.NewClient(option.WithBaseURL(srv.URL), option.WithAPIKey("test"), option.WithMaxRetries(0))
+	e := New(&clt, embedder.WithModel("text-embedding-3-small"))
+	report := components.NewCostReport()
+	ctx := components.WithCostScope(components.WithCostReport(context.Background(), report), "search")
+	embedding := new(embedder.Embedding)
+	if err := e.Embed(ctx, "hello", embedding, nil); err != nil {
+		t.Fatalf("embed failed: %v", err)
+	}
+	if len(embedding.Embedding) != 2 {
+		t.Errorf("unexpected embedding: %+v", embedding)
+	}
+	entries := report.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("expect 1 cost entry, got %+v", entries)
+	}
+	if entry := entries[0]; entry.Kind != components.EmbeddingCost || entry.Scope != "search" || entry.Provider != embedder.ProviderOpenAI ||
+		entry.Usage.InputTokens != 1000 || math.Abs(entry.Usage.Cost-0.00002) > 1e-12 {
+		t.Errorf("unexpected cost entry: %+v", entry)
+	}
+}
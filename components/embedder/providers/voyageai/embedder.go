@@ -23,7 +23,7 @@ func New(client *Client, opts ...embedder.Option) *Embedder {
 	i := &Embedder{
 		Client: client,
 	}
-	embedder.WithProvider(embedder.ProviderVoyageAI)
+	embedder.WithProvider(embedder.ProviderVoyageAI)(&i.Options)
 	for _, opt := range opts {
 		opt(&i.Options)
 	}
@@ -40,9 +40,11 @@ func (p *Embedder) Embed(ctx context.Context, text string, embedding *embedder.E
 	if err != nil {
 		return err
 	}
-	if usage != nil {
-		usage.InputTokens = int64(resp.Usage.TotalTokens)
+	if usage == nil {
+		usage = new(components.LLMUsage)
 	}
+	usage.InputTokens = int64(resp.Usage.TotalTokens)
+	p.RecordCost(ctx, usage)
 	if len(resp.Data) == 0 {
 		return nil
 	}
@@ -63,9 +65,11 @@ func (p *Embedder) BatchEmbed(ctx context.Context, parts []string, usage *compon
 	if err != nil {
 		return nil, err
 	}
-	if usage != nil {
-		usage.InputTokens = int64(resp.Usage.TotalTokens)
+	if usage == nil {
+		usage = new(components.LLMUsage)
 	}
+	usage.InputTokens = int64(resp.Usage.TotalTokens)
+	p.RecordCost(ctx, usage)
 	ret := make([]embedder.Embedding, 0, len(resp.Data))
 	for _, v := range resp.Data {
 		ret = append(ret, embedder.Embedding{
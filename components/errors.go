@@ -0,0 +1,119 @@
+package components
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/cohere-ai/cohere-go/v2/core"
+	"github.com/go-playground/validator/v10"
+	anthropic "github.com/liushuangls/go-anthropic/v2"
+	"github.com/openai/openai-go"
+	geminiAPI "google.golang.org/genai"
+)
+
+// ErrorClass classifies errors returned by LLM calls, classes could be combined as flags
+type ErrorClass uint
+
+const (
+	// SchemaError the response could not be decoded into the output schema or violated its constraints
+	SchemaError ErrorClass = 1 << iota
+	// RateLimitError the provider rejected the request with rate limit
+	RateLimitError
+	// TimeoutError the request timed out
+	TimeoutError
+	// ServerError the provider failed with 5xx or overloaded
+	ServerError
+	// NetworkError the request failed to reach the provider
+	NetworkError
+)
+
+// Has reports whether c contains any class of v
+func (c ErrorClass) Has(v ErrorClass) bool {
+	return c&v != 0
+}
+
+// DecodeError wraps the error of a response which could not be decoded into the output schema or violated its constraints,
+// decoders like the lenient JSON decoder of instructors return untyped errors
+type DecodeError struct {
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// ClassifyError returns the ErrorClass of an error returned by LLM calls, returns 0 if the error is unknown
+func ClassifyError(err error) ErrorClass {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return 0
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return TimeoutError
+	}
+	if code := ErrorStatusCode(err); code > 0 {
+		switch {
+		case code == http.StatusTooManyRequests:
+			return RateLimitError
+		case code == http.StatusRequestTimeout || code == http.StatusGatewayTimeout:
+			return TimeoutError
+		case code >= http.StatusInternalServerError:
+			return ServerError
+		}
+		return 0
+	}
+	var (
+		decodeErr      *DecodeError
+		validationErrs validator.ValidationErrors
+		syntaxErr      *json.SyntaxError
+		typeErr        *json.UnmarshalTypeError
+	)
+	if errors.As(err, &decodeErr) || errors.As(err, &validationErrs) || errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		return SchemaError
+	}
+	if netErr := net.Error(nil); errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return TimeoutError
+		}
+		return NetworkError
+	}
+	return 0
+}
+
+// ErrorStatusCode returns the HTTP status code of provider api errors, 0 if unknown.
+// Anthropic errors reported in response bodies get the status code of their type.
+func ErrorStatusCode(err error) int {
+	if v := (interface{ HTTPStatusCode() int })(nil); errors.As(err, &v) && v.HTTPStatusCode() > 0 {
+		return v.HTTPStatusCode()
+	}
+	if openaiErr := new(openai.Error); errors.As(err, &openaiErr) {
+		return openaiErr.StatusCode
+	}
+	if reqErr := new(anthropic.RequestError); errors.As(err, &reqErr) {
+		return reqErr.StatusCode
+	}
+	if apiErr := new(anthropic.APIError); errors.As(err, &apiErr) {
+		switch {
+		case apiErr.IsRateLimitErr():
+			return http.StatusTooManyRequests
+		case apiErr.IsOverloadedErr():
+			return 529
+		case apiErr.IsApiErr():
+			return http.StatusInternalServerError
+		}
+		return 0
+	}
+	if cohereErr := new(core.APIError); errors.As(err, &cohereErr) {
+		return cohereErr.StatusCode
+	}
+	if geminiErr := new(geminiAPI.APIError); errors.As(err, geminiErr) {
+		return geminiErr.Code
+	}
+	return 0
+}
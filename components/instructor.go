@@ -0,0 +1,115 @@
+package components
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+
+	"github.com/bububa/instructor-go"
+)
+
+// Unwrapper is an instructor wrapping another instructor, like a recorder or a rate limiter
+type Unwrapper interface {
+	Unwrap() instructor.Instructor
+}
+
+// ErrCloneInstructor returns when a copy of the instructor could not be made
+var ErrCloneInstructor = errors.New("instructor could not be cloned")
+
+// ErrRewrap returns when an instructor wrapper could not wrap another instructor
+var ErrRewrap = errors.New("instructor wrapper could not rewrap instructor")
+
+// Rewrapper is an Unwrapper which could wrap another instructor the same way as the one it wraps
+type Rewrapper interface {
+	Unwrapper
+	// Rewrap returns a copy of the wrapper wrapping clt, false if clt is not of the type it wraps
+	Rewrap(clt instructor.Instructor) (instructor.Instructor, bool)
+}
+
+// RoundTripper is an instructor wrapper intercepting the provider requests sent outside of the instructor methods,
+// like the requests of native tool calling loops, which are sent with the client of the innermost instructor.
+// request and response point to the provider request and response, call sends the request and decodes the response.
+// A RoundTripper could serve the response without calling call.
+type RoundTripper interface {
+	RoundTrip(ctx context.Context, request any, response any, call func(context.Context) error) error
+}
+
+// CloneInstructor returns a shallow copy of the instructor, so that memory could be set on the copy without touching clt.
+// clt must be a non nil pointer to a struct, ErrCloneInstructor returns otherwise.
+func CloneInstructor[T instructor.Instructor](clt T) (T, error) {
+	var zero T
+	v := reflect.ValueOf(clt)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return zero, ErrCloneInstructor
+	}
+	cp := reflect.New(v.Elem().Type())
+	cp.Elem().Set(v.Elem())
+	ret, ok := cp.Interface().(T)
+	if !ok {
+		return zero, ErrCloneInstructor
+	}
+	return ret, nil
+}
+
+// WithMemory returns a shallow copy of the instructor with memory, so that wrappers copied per session don't share
+// the memory of the instructor they wrap. The memory is set on clt itself if it could not be copied.
+func WithMemory[T instructor.Instructor](clt T, memory *instructor.Memory) T {
+	if cp, err := CloneInstructor(clt); err == nil {
+		clt = cp
+	}
+	clt.SetMemory(memory)
+	return clt
+}
+
+// Unwrap returns the innermost instructor wrapped by clt, clt itself if it doesn't implement Unwrapper
+func Unwrap(clt instructor.Instructor) instructor.Instructor {
+	chain := wrappers(clt)
+	return chain[len(chain)-1]
+}
+
+// Rewrap returns clt with the innermost instructor replaced by inner, so that callers driving a copy of the innermost instructor
+// keep the wrappers around it. It returns ErrRewrap if a wrapper of clt is not a Rewrapper or could not wrap the replacement.
+func Rewrap(clt instructor.Instructor, inner instructor.Instructor) (instructor.Instructor, error) {
+	chain := wrappers(clt)
+	for _, v := range slices.Backward(chain[:len(chain)-1]) {
+		w, ok := v.(Rewrapper)
+		if !ok {
+			return nil, ErrRewrap
+		}
+		if inner, ok = w.Rewrap(inner); !ok {
+			return nil, ErrRewrap
+		}
+	}
+	return inner, nil
+}
+
+// RoundTrip sends a provider request with call through the RoundTrippers of clt and the instructors it wraps, outermost first
+func RoundTrip(ctx context.Context, clt instructor.Instructor, request any, response any, call func(context.Context) error) error {
+	for _, v := range slices.Backward(wrappers(clt)) {
+		if rt, ok := v.(RoundTripper); ok {
+			next := call
+			call = func(ctx context.Context) error {
+				return rt.RoundTrip(ctx, request, response, next)
+			}
+		}
+	}
+	return call(ctx)
+}
+
+// wrappers returns clt and the instructors it wraps, the innermost last
+func wrappers(clt instructor.Instructor) []instructor.Instructor {
+	chain := []instructor.Instructor{clt}
+	for {
+		u, ok := clt.(Unwrapper)
+		if !ok {
+			return chain
+		}
+		inner := u.Unwrap()
+		if inner == nil || slices.Contains(chain, inner) {
+			return chain
+		}
+		chain = append(chain, inner)
+		clt = inner
+	}
+}
@@ -0,0 +1,7 @@
+// Package interceptor is the middleware of agents, chains, tools and RAG.
+//
+// An Interceptor wraps a run with Around semantics, Before and After adapt simpler functions.
+// Interceptors could mutate the input, short-circuit with an output without running the target,
+// or rewrite the output and error, so that logging, auth, caching and guardrails are written once
+// and composed with Use on any agent or tool.
+package interceptor
@@ -0,0 +1,228 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/bububa/atomic-agents/components"
+)
+
+var (
+	// ErrInputType returns when an interceptor replaced the input with a value of another type
+	ErrInputType = errors.New("invalid intercepted input type")
+	// ErrOutputType returns when an interceptor returned an output of another type
+	ErrOutputType = errors.New("invalid intercepted output type")
+)
+
+// Kind is the kind of the intercepted target
+type Kind string
+
+const (
+	AgentCall Kind = "agent"
+	ChainCall Kind = "chain"
+	ToolCall  Kind = "tool"
+	RAGCall   Kind = "rag"
+)
+
+// Call describes an intercepted run
+type Call struct {
+	Kind Kind
+	// Name is the name of the agent, chain or RAG, or the title of the tool
+	Name string
+	// Target is the intercepted agent, chain, tool or RAG
+	Target any
+	// Stream is true when the call starts a stream. next returns the started stream as an opaque output,
+	// interceptors could mutate the input, fail the call or short-circuit it with an output of the target schema,
+	// which is streamed as a whole. Usage is only known once the stream is consumed, apiResp is left empty.
+	Stream bool
+}
+
+// Handler runs the call, input and output are pointers of the target schemas.
+// apiResp is never nil, tools leave it empty.
+type Handler func(ctx context.Context, input any, apiResp *components.LLMResponse) (any, error)
+
+// Interceptor wraps a run, it could call next with a mutated input, return an output without calling next,
+// or rewrite the output and error returned by next
+type Interceptor interface {
+	Intercept(ctx context.Context, call *Call, input any, apiResp *components.LLMResponse, next Handler) (any, error)
+}
+
+// Func is an Interceptor function with Around semantics
+type Func func(ctx context.Context, call *Call, input any, apiResp *components.LLMResponse, next Handler) (any, error)
+
+func (fn Func) Intercept(ctx context.Context, call *Call, input any, apiResp *components.LLMResponse, next Handler) (any, error) {
+	return fn(ctx, call, input, apiResp, next)
+}
+
+// Around returns an Interceptor from function
+func Around(fn func(ctx context.Context, call *Call, input any, apiResp *components.LLMResponse, next Handler) (any, error)) Interceptor {
+	return Func(fn)
+}
+
+// Before returns an Interceptor running fn before the call. fn returns the input passed on,
+// a non nil output short-circuits the call, an error fails it.
+func Before(fn func(ctx context.Context, call *Call, input any) (any, any, error)) Interceptor {
+	return Func(func(ctx context.Context, call *Call, input any, apiResp *components.LLMResponse, next Handler) (any, error) {
+		in, out, err := fn(ctx, call, input)
+		if err != nil {
+			return nil, err
+		}
+		if out != nil {
+			return out, nil
+		}
+		return next(ctx, in, apiResp)
+	})
+}
+
+// After returns an Interceptor running fn after the call with its output and error, fn returns the output and error passed on
+func After(fn func(ctx context.Context, call *Call, input any, output any, apiResp *components.LLMResponse, err error) (any, error)) Interceptor {
+	return Func(func(ctx context.Context, call *Call, input any, apiResp *components.LLMResponse, next Handler) (any, error) {
+		out, err := next(ctx, input, apiResp)
+		return fn(ctx, call, input, out, apiResp, err)
+	})
+}
+
+// Hooks returns an Interceptor calling start before the call, end after a successful call and fail after a failed one,
+// nil hooks are skipped. It adapts the start, end and error hooks of agents, end gets a nil output on stream calls.
+func Hooks[I any, O any](start func(context.Context, *I), end func(context.Context, *I, *O, *components.LLMResponse), fail func(context.Context, *I, *components.LLMResponse, error)) Interceptor {
+	return Func(func(ctx context.Context, call *Call, input any, apiResp *components.LLMResponse, next Handler) (any, error) {
+		in, _ := input.(*I)
+		if start != nil {
+			start(ctx, in)
+		}
+		out, err := next(ctx, input, apiResp)
+		if err != nil {
+			if fail != nil {
+				fail(ctx, in, apiResp, err)
+			}
+			return out, err
+		}
+		if end != nil {
+			typedOut, _ := out.(*O)
+			end(ctx, in, typedOut, apiResp)
+		}
+		return out, nil
+	})
+}
+
+// Compose returns an Interceptor running interceptors in order, the first one is the outermost
+func Compose(interceptors ...Interceptor) Interceptor {
+	return Func(func(ctx context.Context, call *Call, input any, apiResp *components.LLMResponse, next Handler) (any, error) {
+		return Run(ctx, call, interceptors, input, apiResp, next)
+	})
+}
+
+// Run runs handler through interceptors, the first interceptor is the outermost
+func Run(ctx context.Context, call *Call, interceptors []Interceptor, input any, apiResp *components.LLMResponse, handler Handler) (any, error) {
+	if apiResp == nil {
+		apiResp = new(components.LLMResponse)
+	}
+	next := handler
+	for idx := len(interceptors) - 1; idx >= 0; idx-- {
+		ic, inner := interceptors[idx], next
+		next = func(ctx context.Context, input any, apiResp *components.LLMResponse) (any, error) {
+			return ic.Intercept(ctx, call, input, apiResp, inner)
+		}
+	}
+	return next(ctx, input, apiResp)
+}
+
+// Typed runs a typed run through interceptors. Intercepted inputs must be *I and outputs *O,
+// a short-circuited output is copied into output.
+func Typed[I any, O any](ctx context.Context, call *Call, interceptors []Interceptor, input *I, output *O, apiResp *components.LLMResponse, fn func(context.Context, *I, *O, *components.LLMResponse) error) error {
+	if len(interceptors) == 0 {
+		return fn(ctx, input, output, apiResp)
+	}
+	ret, err := Run(ctx, call, interceptors, input, apiResp, func(ctx context.Context, in any, apiResp *components.LLMResponse) (any, error) {
+		typedIn, ok := in.(*I)
+		if !ok {
+			return nil, ErrInputType
+		}
+		if err := fn(ctx, typedIn, output, apiResp); err != nil {
+			return nil, err
+		}
+		return output, nil
+	})
+	if err != nil {
+		return err
+	}
+	out, ok := ret.(*O)
+	if !ok {
+		return ErrOutputType
+	}
+	if out != output {
+		*output = *out
+	}
+	return nil
+}
+
+// Stream runs the start of a stream through interceptors, call.Stream is set. fn starts the stream and returns it as an opaque output,
+// the returned output is either the started stream or an output short-circuited by an interceptor.
+func Stream[I any](ctx context.Context, call *Call, interceptors []Interceptor, input *I, apiResp *components.LLMResponse, fn func(context.Context, *I) (any, error)) (any, error) {
+	call.Stream = true
+	return Run(ctx, call, interceptors, input, apiResp, func(ctx context.Context, in any, _ *components.LLMResponse) (any, error) {
+		typedIn, ok := in.(*I)
+		if !ok {
+			return nil, ErrInputType
+		}
+		return fn(ctx, typedIn)
+	})
+}
+
+// Hook is the kind of a start, end or error hook adapted to an interceptor
+type Hook int
+
+const (
+	// StartHook is called before the call
+	StartHook Hook = iota
+	// EndHook is called after a successful call
+	EndHook
+	// ErrorHook is called after a failed call
+	ErrorHook
+	hookCount
+)
+
+// Stack holds the interceptors of agents, chains, tools and RAG
+type Stack struct {
+	interceptors []Interceptor
+	// hooks are the positions plus one of the hook interceptors in interceptors, 0 if the hook is not set
+	hooks [hookCount]int
+}
+
+// Use appends interceptors, the first used interceptor is the outermost
+func (s *Stack) Use(interceptors ...Interceptor) {
+	s.interceptors = append(s.interceptors, interceptors...)
+}
+
+// SetHook sets the interceptor of hook, replacing the interceptor set before for the same hook, a nil interceptor removes it.
+// The hook keeps the position of the first interceptor set for it.
+//
+// SetStartHook, SetEndHook and SetErrorHook of agents, chains and workflows set their hook with SetHook,
+// so that setting a hook again replaces it as it did before interceptors. They are deprecated, use Use with Hooks, Before or After,
+// which compose any number of interceptors.
+func (s *Stack) SetHook(hook Hook, ic Interceptor) {
+	// interceptors are copied before being changed, copies of the stack share their backing array
+	pos := s.hooks[hook]
+	switch {
+	case pos > 0 && ic == nil:
+		s.interceptors = slices.Delete(slices.Clone(s.interceptors), pos-1, pos)
+		for idx, v := range s.hooks {
+			if v > pos {
+				s.hooks[idx] = v - 1
+			}
+		}
+		s.hooks[hook] = 0
+	case pos > 0:
+		s.interceptors = slices.Clone(s.interceptors)
+		s.interceptors[pos-1] = ic
+	case ic != nil:
+		s.interceptors = append(slices.Clip(s.interceptors), ic)
+		s.hooks[hook] = len(s.interceptors)
+	}
+}
+
+// Interceptors returns the used interceptors
+func (s *Stack) Interceptors() []Interceptor {
+	return s.interceptors
+}
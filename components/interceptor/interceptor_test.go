@@ -0,0 +1,151 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bububa/atomic-agents/components"
+)
+
+func TestRun(t *testing.T) {
+	var trace []string
+	logging := Around(func(ctx context.Context, call *Call, input any, apiResp *components.LLMResponse, next Handler) (any, error) {
+		trace = append(trace, "before "+call.Name)
+		out, err := next(ctx, input, apiResp)
+		trace = append(trace, "after "+call.Name)
+		return out, err
+	})
+	upper := Before(func(_ context.Context, _ *Call, input any) (any, any, error) {
+		s := strings.ToUpper(*input.(*string))
+		return &s, nil, nil
+	})
+	cache := Before(func(_ context.Context, _ *Call, input any) (any, any, error) {
+		if s := *input.(*string); s == "CACHED" {
+			out := "from cache"
+			return input, &out, nil
+		}
+		return input, nil, nil
+	})
+	suffix := After(func(_ context.Context, _ *Call, _ any, output any, _ *components.LLMResponse, err error) (any, error) {
+		if err != nil {
+			return nil, err
+		}
+		s := *output.(*string) + "!"
+		return &s, nil
+	})
+	var calls int
+	handler := func(_ context.Context, input *string, output *string, apiResp *components.LLMResponse) error {
+		calls++
+		*output = "echo " + *input
+		apiResp.Usage = &components.LLMUsage{InputTokens: 1}
+		return nil
+	}
+	call := &Call{Kind: AgentCall, Name: "echo"}
+	interceptors := []Interceptor{logging, Compose(upper, suffix), cache}
+
+	input, output := "hello", ""
+	apiResp := new(components.LLMResponse)
+	if err := Typed(context.Background(), call, interceptors, &input, &output, apiResp, handler); err != nil {
+		t.Fatalf("run interceptors failed: %v", err)
+	}
+	if output != "echo HELLO!" || input != "hello" || apiResp.Usage.InputTokens != 1 {
+		t.Errorf("expect mutated input and rewritten output, got %q, %q, %+v", output, input, apiResp.Usage)
+	}
+	if strings.Join(trace, ",") != "before echo,after echo" {
+		t.Errorf("expect around interceptor wraps the run, got %v", trace)
+	}
+
+	input, apiResp = "cached", new(components.LLMResponse)
+	if err := Typed(context.Background(), call, interceptors, &input, &output, apiResp, handler); err != nil {
+		t.Fatalf("run interceptors failed: %v", err)
+	}
+	if output != "from cache!" || calls != 1 || apiResp.Usage != nil {
+		t.Errorf("expect short-circuited output without running handler, got %q after %d calls", output, calls)
+	}
+
+	wrongType := Before(func(_ context.Context, _ *Call, input any) (any, any, error) {
+		return 1, nil, nil
+	})
+	if err := Typed(context.Background(), call, []Interceptor{wrongType}, &input, &output, nil, handler); !errors.Is(err, ErrInputType) {
+		t.Errorf("expect input type error, got %v", err)
+	}
+}
+
+func TestHooks(t *testing.T) {
+	var trace []string
+	hooks := Hooks[string, string](
+		func(_ context.Context, input *string) {
+			trace = append(trace, "start "+*input)
+		},
+		func(_ context.Context, _ *string, output *string, _ *components.LLMResponse) {
+			trace = append(trace, "end "+*output)
+		},
+		func(_ context.Context, _ *string, _ *components.LLMResponse, err error) {
+			trace = append(trace, "error "+err.Error())
+		},
+	)
+	call := &Call{Kind: AgentCall, Name: "echo"}
+	input, output := "hello", ""
+	echo := func(_ context.Context, input *string, output *string, _ *components.LLMResponse) error {
+		if *input == "fail" {
+			return errors.New("failed")
+		}
+		*output = "echo " + *input
+		return nil
+	}
+	if err := Typed(context.Background(), call, []Interceptor{hooks}, &input, &output, nil, echo); err != nil {
+		t.Fatalf("run interceptors failed: %v", err)
+	}
+	input = "fail"
+	if err := Typed(context.Background(), call, []Interceptor{hooks}, &input, &output, nil, echo); err == nil {
+		t.Fatal("expect error")
+	}
+	if got := strings.Join(trace, ","); got != "start hello,end echo hello,start fail,error failed" {
+		t.Errorf("expect hooks around the calls, got %s", got)
+	}
+}
+
+func TestStackSetHook(t *testing.T) {
+	var trace []string
+	tracer := func(name string) Interceptor {
+		return Before(func(_ context.Context, _ *Call, input any) (any, any, error) {
+			trace = append(trace, name)
+			return input, nil, nil
+		})
+	}
+	run := func(s *Stack) string {
+		trace = nil
+		input, output := "hello", ""
+		echo := func(_ context.Context, input *string, output *string, _ *components.LLMResponse) error {
+			*output = *input
+			return nil
+		}
+		if err := Typed(context.Background(), &Call{Kind: AgentCall}, s.Interceptors(), &input, &output, nil, echo); err != nil {
+			t.Fatalf("run interceptors failed: %v", err)
+		}
+		return strings.Join(trace, ",")
+	}
+	var s Stack
+	s.SetHook(StartHook, tracer("start 1"))
+	s.Use(tracer("used"))
+	s.SetHook(EndHook, tracer("end"))
+	copied := s
+	s.SetHook(StartHook, tracer("start 2"))
+	if got := run(&s); got != "start 2,used,end" {
+		t.Errorf("expect start hook replaced in place, got %s", got)
+	}
+	if got := run(&copied); got != "start 1,used,end" {
+		t.Errorf("expect copied stack untouched, got %s", got)
+	}
+	s.SetHook(StartHook, nil)
+	s.SetHook(EndHook, tracer("end 2"))
+	if got := run(&s); got != "used,end 2" {
+		t.Errorf("expect start hook removed, got %s", got)
+	}
+	s.SetHook(StartHook, tracer("start 3"))
+	if got := run(&s); got != "used,end 2,start 3" {
+		t.Errorf("expect start hook set again last, got %s", got)
+	}
+}
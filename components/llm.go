@@ -10,12 +10,13 @@ import (
 
 // LLMResponse instructor provider chat response
 type LLMResponse struct {
-	ID        string          `json:"id,omitempty"`
-	Role      instructor.Role `json:"role,omitempty"`
-	Model     string          `json:"model,omitempty"`
-	Usage     *LLMUsage       `json:"usage,omitempty"`
-	Timestamp int64           `json:"ts,omitempty"`
-	Details   any             `json:"content,omitempty"`
+	ID        string              `json:"id,omitempty"`
+	Role      instructor.Role     `json:"role,omitempty"`
+	Model     string              `json:"model,omitempty"`
+	Provider  instructor.Provider `json:"provider,omitempty"`
+	Usage     *LLMUsage           `json:"usage,omitempty"`
+	Timestamp int64               `json:"ts,omitempty"`
+	Details   any                 `json:"content,omitempty"`
 }
 
 // FromOpenAI convnert response from openai
@@ -24,8 +25,10 @@ func (r *LLMResponse) FromOpenAI(v *openai.ChatCompletion) {
 	r.Role = instructor.AssistantRole
 	r.Model = v.Model
 	r.Usage = &LLMUsage{
-		InputTokens:  v.Usage.PromptTokens,
-		OutputTokens: v.Usage.CompletionTokens,
+		InputTokens:     v.Usage.PromptTokens,
+		OutputTokens:    v.Usage.CompletionTokens,
+		CachedTokens:    v.Usage.PromptTokensDetails.CachedTokens,
+		ReasoningTokens: v.Usage.CompletionTokensDetails.ReasoningTokens,
 	}
 	r.Details = v.Choices
 }
@@ -35,9 +38,12 @@ func (r *LLMResponse) FromAnthropic(v *anthropic.MessagesResponse) {
 	r.ID = v.ID
 	r.Role = instructor.AssistantRole
 	r.Model = string(v.Model)
+	// anthropic input tokens exclude cache reads and writes, they are kept as reported
 	r.Usage = &LLMUsage{
-		InputTokens:  int64(v.Usage.InputTokens),
-		OutputTokens: int64(v.Usage.OutputTokens),
+		InputTokens:      int64(v.Usage.InputTokens),
+		OutputTokens:     int64(v.Usage.OutputTokens),
+		CachedTokens:     int64(v.Usage.CacheReadInputTokens),
+		CacheWriteTokens: int64(v.Usage.CacheCreationInputTokens),
 	}
 	r.Details = v.Content
 }
@@ -70,14 +76,35 @@ func (r *LLMResponse) FromGemini(v *gemini.GenerateContentResponse) {
 	if v.UsageMetadata != nil && (v.UsageMetadata.PromptTokenCount > 0 || v.UsageMetadata.CandidatesTokenCount > 0) {
 		r.Usage = new(LLMUsage)
 		r.Usage.InputTokens = int64(v.UsageMetadata.PromptTokenCount)
-		r.Usage.OutputTokens = int64(v.UsageMetadata.CachedContentTokenCount)
+		// gemini candidates tokens exclude thoughts tokens
+		r.Usage.OutputTokens = int64(v.UsageMetadata.CandidatesTokenCount + v.UsageMetadata.ThoughtsTokenCount)
+		r.Usage.CachedTokens = int64(v.UsageMetadata.CachedContentTokenCount)
+		r.Usage.ReasoningTokens = int64(v.UsageMetadata.ThoughtsTokenCount)
 	}
 	r.Details = v.Candidates
 }
 
+// ComputeCost computes usage cost with the price of response provider and model, usage cost is left unchanged if the price is unknown
+func (r *LLMResponse) ComputeCost(table *PriceTable) {
+	if r.Usage == nil {
+		return
+	}
+	if cost, ok := table.Cost(r.Provider, r.Model, r.Usage); ok {
+		r.Usage.Cost = cost
+	}
+}
+
 type LLMUsage struct {
 	InputTokens  int64 `json:"input_tokens,omitempty"`
 	OutputTokens int64 `json:"output_tokens,omitempty"`
+	// CachedTokens cached input tokens, part of InputTokens except for Anthropic which reports them apart
+	CachedTokens int64 `json:"cached_tokens,omitempty"`
+	// CacheWriteTokens input tokens written into the cache, part of InputTokens except for Anthropic which reports them apart
+	CacheWriteTokens int64 `json:"cache_write_tokens,omitempty"`
+	// ReasoningTokens reasoning output tokens, part of OutputTokens
+	ReasoningTokens int64 `json:"reasoning_tokens,omitempty"`
+	// Cost computed cost in price table currency
+	Cost float64 `json:"cost,omitempty"`
 }
 
 func (u *LLMUsage) Merge(v *LLMUsage) {
@@ -86,4 +113,8 @@ func (u *LLMUsage) Merge(v *LLMUsage) {
 	}
 	u.InputTokens += v.InputTokens
 	u.OutputTokens += v.OutputTokens
+	u.CachedTokens += v.CachedTokens
+	u.CacheWriteTokens += v.CacheWriteTokens
+	u.ReasoningTokens += v.ReasoningTokens
+	u.Cost += v.Cost
 }
@@ -0,0 +1,18 @@
+package components
+
+import (
+	"testing"
+
+	anthropic "github.com/liushuangls/go-anthropic/v2"
+)
+
+func TestFromAnthropic(t *testing.T) {
+	var resp LLMResponse
+	resp.FromAnthropic(&anthropic.MessagesResponse{
+		Usage: anthropic.MessagesUsage{InputTokens: 10, OutputTokens: 5, CacheCreationInputTokens: 20, CacheReadInputTokens: 30},
+	})
+	want := LLMUsage{InputTokens: 10, OutputTokens: 5, CachedTokens: 30, CacheWriteTokens: 20}
+	if *resp.Usage != want {
+		t.Errorf("expect input tokens as reported and cache tokens apart, got %+v", resp.Usage)
+	}
+}
@@ -0,0 +1,3 @@
+// Package memory fits agent memory history into a token budget, persists session memory into stores,
+// and isolates the memories of agents running in an Isolation scope
+package memory
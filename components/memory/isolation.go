@@ -0,0 +1,56 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/bububa/instructor-go"
+)
+
+// Isolation is a memory scope, every agent running in it gets its own memory instead of the memory of its client
+// and session memory, so that runs like tool delegations, eval cases or parallel branches never share history.
+// Nested agents, like chain steps or classifiers, get separate memories in the same scope.
+type Isolation struct {
+	mu       sync.Mutex
+	history  []instructor.Message
+	memories map[any]*instructor.Memory
+}
+
+// NewIsolation returns a new Isolation, the memory of every agent starts with a copy of history
+func NewIsolation(history ...instructor.Message) *Isolation {
+	return &Isolation{
+		history:  history,
+		memories: make(map[any]*instructor.Memory),
+	}
+}
+
+// Memory returns the memory of the agent identified by key, created on first use
+func (i *Isolation) Memory(key any) *instructor.Memory {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	mem, ok := i.memories[key]
+	if !ok {
+		mem = instructor.NewMemory(len(i.history))
+		mem.Set(i.history)
+		i.memories[key] = mem
+	}
+	return mem
+}
+
+type isolationKey struct{}
+
+// WithIsolation returns a context which carries the Isolation, agents run on their memories of the Isolation
+func WithIsolation(ctx context.Context, isolation *Isolation) context.Context {
+	return context.WithValue(ctx, isolationKey{}, isolation)
+}
+
+// Isolate returns a context which carries a new Isolation starting with history
+func Isolate(ctx context.Context, history ...instructor.Message) context.Context {
+	return WithIsolation(ctx, NewIsolation(history...))
+}
+
+// IsolationFromContext returns the Isolation carried by the context, nil if none
+func IsolationFromContext(ctx context.Context) *Isolation {
+	isolation, _ := ctx.Value(isolationKey{}).(*Isolation)
+	return isolation
+}
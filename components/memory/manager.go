@@ -0,0 +1,99 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/bububa/instructor-go"
+
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/embedder/splitter"
+)
+
+// messageOverhead approximate tokens of role and formatting per message
+const messageOverhead = 4
+
+// Options holds the configuration of Manager
+type Options struct {
+	// counter counts message tokens
+	counter splitter.TokenCounter
+	// strategy fits history into budget
+	strategy Strategy
+}
+
+// Option is a function type for configuring Manager Options.
+type Option func(*Options)
+
+// WithTokenCounter set the TokenCounter counting message tokens, splitter.WordsTokenCounter is used by default
+func WithTokenCounter(counter splitter.TokenCounter) Option {
+	return func(o *Options) {
+		o.counter = counter
+	}
+}
+
+// WithStrategy set the Strategy fitting history into budget, DropOldest is used by default
+func WithStrategy(strategy Strategy) Option {
+	return func(o *Options) {
+		o.strategy = strategy
+	}
+}
+
+// Manager enforces a token budget on memory history sent to the LLM,
+// the budget covers system prompt, history and user input.
+type Manager struct {
+	Options
+	budget int
+}
+
+// NewManager returns a new Manager with budget tokens
+func NewManager(budget int, opts ...Option) *Manager {
+	ret := &Manager{
+		budget: budget,
+	}
+	for _, opt := range opts {
+		opt(&ret.Options)
+	}
+	if ret.counter == nil {
+		ret.counter = splitter.WordsTokenCounter{}
+	}
+	if ret.strategy == nil {
+		ret.strategy = DropOldest{}
+	}
+	return ret
+}
+
+// Budget returns the token budget
+func (m *Manager) Budget() int {
+	return m.budget
+}
+
+// Count returns the approximate tokens of messages
+func (m *Manager) Count(msgs ...instructor.Message) int {
+	return countTokens(m.counter, msgs...)
+}
+
+// Fit fits history into the budget left after reserved tokens of system prompt and user input.
+// Usage of LLM calls made by the strategy is merged into usage.
+func (m *Manager) Fit(ctx context.Context, history []instructor.Message, reserved int, usage *components.LLMUsage) ([]instructor.Message, error) {
+	budget := m.budget - reserved
+	if budget < 0 {
+		budget = 0
+	}
+	if countTokens(m.counter, history...) <= budget {
+		return history, nil
+	}
+	return m.strategy.Fit(ctx, m.counter, history, budget, usage)
+}
+
+func countTokens(counter splitter.TokenCounter, msgs ...instructor.Message) int {
+	var count int
+	for _, msg := range msgs {
+		count += messageOverhead + counter.Count([]byte(msg.Text))
+		for _, v := range msg.ToolUses {
+			count += counter.Count([]byte(v.Name)) + counter.Count([]byte(v.Arguments))
+		}
+		for _, v := range msg.ToolResults {
+			count += counter.Count([]byte(v.Content))
+		}
+	}
+	return count
+}
@@ -0,0 +1,112 @@
+package memory
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/bububa/instructor-go"
+
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/schema"
+)
+
+type summarizerFunc func(context.Context, *schema.String, *schema.String, *components.LLMResponse) error
+
+func (fn summarizerFunc) Run(ctx context.Context, in *schema.String, out *schema.String, resp *components.LLMResponse) error {
+	return fn(ctx, in, out, resp)
+}
+
+// fieldsCounter counts whitespace separated fields
+type fieldsCounter struct{}
+
+func (fieldsCounter) Count(p []byte) int {
+	return len(strings.Fields(string(p)))
+}
+
+func testHistory() []instructor.Message {
+	return []instructor.Message{
+		{Role: instructor.SystemRole, Text: "be nice"},
+		{Role: instructor.UserRole, Text: "one two three four"},
+		{Role: instructor.AssistantRole, Text: "five six seven eight"},
+		{Role: instructor.UserRole, Text: "nine ten"},
+		{Role: instructor.AssistantRole, ToolUses: []instructor.ToolUse{{ID: "1", Name: "calc", Arguments: "{}"}}},
+		{Role: instructor.ToolRole, ToolResults: []instructor.ToolResult{{ID: "1", Name: "calc", Content: "42"}}},
+		{Role: instructor.AssistantRole, Text: "eleven"},
+	}
+}
+
+func TestManagerFit(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy Strategy
+		budget   int
+		want     []string
+	}{
+		{
+			name:     "fits budget",
+			strategy: DropOldest{},
+			budget:   1000,
+			want:     []string{"be nice", "one two three four", "five six seven eight", "nine ten", "", "", "eleven"},
+		},
+		{
+			name:     "drop oldest keeps tool exchange",
+			strategy: DropOldest{},
+			budget:   30,
+			want:     []string{"be nice", "nine ten", "", "", "eleven"},
+		},
+		{
+			name:     "keep system and last n",
+			strategy: KeepLastN{N: 3},
+			budget:   40,
+			want:     []string{"be nice"},
+		},
+		{
+			name:     "keep system and last n turns",
+			strategy: KeepLastN{N: 4},
+			budget:   40,
+			want:     []string{"be nice", "nine ten", "", "", "eleven"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManager(tt.budget, WithStrategy(tt.strategy), WithTokenCounter(fieldsCounter{}))
+			got, err := m.Fit(context.Background(), testHistory(), 0, nil)
+			if err != nil {
+				t.Fatalf("Fit() error = %v", err)
+			}
+			texts := make([]string, 0, len(got))
+			for _, msg := range got {
+				texts = append(texts, msg.Text)
+			}
+			if strings.Join(texts, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("Fit() = %q, want %q", texts, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarizeStrategy(t *testing.T) {
+	var transcript string
+	agent := summarizerFunc(func(_ context.Context, in *schema.String, out *schema.String, resp *components.LLMResponse) error {
+		transcript = in.String()
+		*out = *schema.NewString("user counted to eight")
+		resp.Usage = &components.LLMUsage{InputTokens: 10, OutputTokens: 3}
+		return nil
+	})
+	m := NewManager(43, WithStrategy(Summarize{Agent: agent, Recent: 0.75}), WithTokenCounter(fieldsCounter{}))
+	usage := new(components.LLMUsage)
+	got, err := m.Fit(context.Background(), testHistory(), 0, usage)
+	if err != nil {
+		t.Fatalf("Fit() error = %v", err)
+	}
+	if !strings.Contains(transcript, "user: one two three four") || strings.Contains(transcript, "eleven") {
+		t.Errorf("unexpected transcript: %s", transcript)
+	}
+	if len(got) != 6 || got[1].Role != instructor.SystemRole || got[1].Text != SummaryPrefix+"user counted to eight" || got[2].Text != "nine ten" {
+		t.Errorf("unexpected fitted history: %+v", got)
+	}
+	if usage.InputTokens != 10 || usage.OutputTokens != 3 {
+		t.Errorf("expect summarizer usage merged, got %+v", usage)
+	}
+}
@@ -0,0 +1,28 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/bububa/instructor-go"
+)
+
+// Store persists memory history keyed by session ID
+type Store interface {
+	// Load returns the session history, empty if the session is not found
+	Load(ctx context.Context, sessionID string) ([]instructor.Message, error)
+	// Save replaces the session history
+	Save(ctx context.Context, sessionID string, history []instructor.Message) error
+}
+
+type sessionKey struct{}
+
+// WithSessionID returns a context which carries the session ID, agents with a Store load and flush the session memory
+func WithSessionID(ctx context.Context, sessionID string) context.Context {
+	return context.WithValue(ctx, sessionKey{}, sessionID)
+}
+
+// SessionID returns the session ID carried by the context
+func SessionID(ctx context.Context) string {
+	sessionID, _ := ctx.Value(sessionKey{}).(string)
+	return sessionID
+}
@@ -0,0 +1,99 @@
+package boltdb
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/bububa/instructor-go"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/bububa/atomic-agents/components/memory"
+)
+
+// DefaultBucket is the default bucket holding session histories
+const DefaultBucket = "sessions"
+
+// Store persists session histories into a bbolt database, keyed by session ID
+type Store struct {
+	db     *bolt.DB
+	bucket []byte
+}
+
+var _ memory.Store = (*Store)(nil)
+
+// Option is a function type for configuring Store
+type Option func(*Store)
+
+// WithBucket set the bucket holding session histories
+func WithBucket(bucket string) Option {
+	return func(s *Store) {
+		s.bucket = []byte(bucket)
+	}
+}
+
+// New returns a new Store on an opened bbolt database
+func New(db *bolt.DB, opts ...Option) *Store {
+	ret := &Store{
+		db:     db,
+		bucket: []byte(DefaultBucket),
+	}
+	for _, opt := range opts {
+		opt(ret)
+	}
+	return ret
+}
+
+// Open opens the bbolt database file and returns a new Store on it
+func Open(path string, opts ...Option) (*Store, error) {
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		return nil, err
+	}
+	return New(db, opts...), nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) Load(_ context.Context, sessionID string) ([]instructor.Message, error) {
+	var list []instructor.Message
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(s.bucket)
+		if bucket == nil {
+			return nil
+		}
+		bs := bucket.Get([]byte(sessionID))
+		if bs == nil {
+			return nil
+		}
+		return json.Unmarshal(bs, &list)
+	})
+	return list, err
+}
+
+func (s *Store) Save(_ context.Context, sessionID string, history []instructor.Message) error {
+	bs, err := json.Marshal(history)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(s.bucket)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(sessionID), bs)
+	})
+}
+
+// Delete removes the session history
+func (s *Store) Delete(_ context.Context, sessionID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(s.bucket)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.Delete([]byte(sessionID))
+	})
+}
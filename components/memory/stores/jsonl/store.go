@@ -0,0 +1,94 @@
+package jsonl
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/bububa/instructor-go"
+
+	"github.com/bububa/atomic-agents/components/memory"
+)
+
+// Store persists each session history into a JSON lines file under dir, one message per line
+type Store struct {
+	dir string
+	mu  sync.RWMutex
+}
+
+var _ memory.Store = (*Store)(nil)
+
+// New returns a new Store, dir is created if not exists
+func New(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Store{
+		dir: dir,
+	}, nil
+}
+
+// Path returns the file path of the session
+func (s *Store) Path(sessionID string) string {
+	return filepath.Join(s.dir, url.PathEscape(sessionID)+".jsonl")
+}
+
+func (s *Store) Load(_ context.Context, sessionID string) ([]instructor.Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	f, err := os.Open(s.Path(sessionID))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	var list []instructor.Message
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var msg instructor.Message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			return nil, err
+		}
+		list = append(list, msg)
+	}
+	return list, scanner.Err()
+}
+
+// Save rewrites the session file atomically
+func (s *Store) Save(_ context.Context, sessionID string, history []instructor.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path := s.Path(sessionID)
+	f, err := os.CreateTemp(s.dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, msg := range history {
+		if err := enc.Encode(msg); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
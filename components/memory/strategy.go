@@ -0,0 +1,167 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bububa/instructor-go"
+
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/embedder/splitter"
+	"github.com/bububa/atomic-agents/schema"
+)
+
+// SummaryPrefix prefixes the system message holding the summary of older turns
+const SummaryPrefix = "Summary of the earlier conversation:\n"
+
+// DefaultSummarizeInstruction is the default instruction sent to the summarizer agent with the older turns
+const DefaultSummarizeInstruction = "Summarize the following conversation concisely. Keep facts, decisions, user preferences and open questions, drop greetings and repetitions."
+
+// Strategy fits history into the token budget. System messages are kept by built-in strategies,
+// kept history starts with a user message so tool exchanges are never split.
+type Strategy interface {
+	Fit(ctx context.Context, counter splitter.TokenCounter, history []instructor.Message, budget int, usage *components.LLMUsage) ([]instructor.Message, error)
+}
+
+// Summarizer is an agent summarizing conversations, agents.TypeableAgent[schema.String, schema.String] satisfies it
+type Summarizer interface {
+	Run(context.Context, *schema.String, *schema.String, *components.LLMResponse) error
+}
+
+// DropOldest drops the oldest turns until history fits the budget
+type DropOldest struct{}
+
+func (DropOldest) Fit(_ context.Context, counter splitter.TokenCounter, history []instructor.Message, budget int, _ *components.LLMUsage) ([]instructor.Message, error) {
+	system, rest := splitSystem(history)
+	start := fitTail(counter, rest, budget-countTokens(counter, system...))
+	return append(system, rest[start:]...), nil
+}
+
+// KeepLastN keeps system messages and the last N messages, then drops the oldest turns if still over budget
+type KeepLastN struct {
+	N int
+}
+
+func (s KeepLastN) Fit(ctx context.Context, counter splitter.TokenCounter, history []instructor.Message, budget int, usage *components.LLMUsage) ([]instructor.Message, error) {
+	system, rest := splitSystem(history)
+	if s.N >= 0 && len(rest) > s.N {
+		rest = rest[alignTurn(rest, len(rest)-s.N):]
+	}
+	return DropOldest{}.Fit(ctx, counter, append(system, rest...), budget, usage)
+}
+
+// Summarize replaces older turns with a summary generated by the summarizer agent,
+// recent turns fitting the Recent share of the budget are kept verbatim. The summary is kept as a system message
+// and summarized again with older turns on next overflow.
+type Summarize struct {
+	Agent Summarizer
+	// Instruction is sent to the agent before the older turns, DefaultSummarizeInstruction is used if empty
+	Instruction string
+	// Recent share of the budget for recent turns, 0.5 if zero
+	Recent float64
+}
+
+func (s Summarize) Fit(ctx context.Context, counter splitter.TokenCounter, history []instructor.Message, budget int, usage *components.LLMUsage) ([]instructor.Message, error) {
+	if s.Agent == nil {
+		return nil, errors.New("summarize strategy requires a summarizer agent")
+	}
+	system, rest := splitSystem(history)
+	var (
+		kept      = make([]instructor.Message, 0, len(system)+1)
+		summaries []instructor.Message
+	)
+	for _, msg := range system {
+		if strings.HasPrefix(msg.Text, SummaryPrefix) {
+			summaries = append(summaries, msg)
+		} else {
+			kept = append(kept, msg)
+		}
+	}
+	recent := s.Recent
+	if recent <= 0 || recent > 1 {
+		recent = 0.5
+	}
+	start := fitTail(counter, rest, int(float64(budget-countTokens(counter, kept...))*recent))
+	older := append(summaries, rest[:start]...)
+	if len(older) == 0 {
+		return DropOldest{}.Fit(ctx, counter, history, budget, usage)
+	}
+	instruction := s.Instruction
+	if instruction == "" {
+		instruction = DefaultSummarizeInstruction
+	}
+	var (
+		out  schema.String
+		resp components.LLMResponse
+	)
+	err := s.Agent.Run(ctx, schema.NewString(instruction+"\n\n"+transcript(older)), &out, &resp)
+	if usage != nil {
+		usage.Merge(resp.Usage)
+	}
+	if err != nil {
+		return nil, err
+	}
+	kept = append(kept, instructor.Message{
+		Role: instructor.SystemRole,
+		Text: SummaryPrefix + out.String(),
+	})
+	kept = append(kept, rest[start:]...)
+	if countTokens(counter, kept...) <= budget {
+		return kept, nil
+	}
+	return DropOldest{}.Fit(ctx, counter, kept, budget, usage)
+}
+
+// splitSystem splits system messages from the other messages
+func splitSystem(history []instructor.Message) ([]instructor.Message, []instructor.Message) {
+	var system, rest []instructor.Message
+	for _, msg := range history {
+		if msg.Role == instructor.SystemRole {
+			system = append(system, msg)
+		} else {
+			rest = append(rest, msg)
+		}
+	}
+	return system, rest
+}
+
+// fitTail returns the start index of the longest tail of msgs fitting budget, aligned to a user turn
+func fitTail(counter splitter.TokenCounter, msgs []instructor.Message, budget int) int {
+	start := len(msgs)
+	var count int
+	for start > 0 {
+		count += countTokens(counter, msgs[start-1])
+		if count > budget {
+			break
+		}
+		start--
+	}
+	return alignTurn(msgs, start)
+}
+
+// alignTurn moves start forward to the next user message, so assistant replies and tool results are never orphaned
+func alignTurn(msgs []instructor.Message, start int) int {
+	for start < len(msgs) && msgs[start].Role != instructor.UserRole {
+		start++
+	}
+	return start
+}
+
+// transcript renders messages as plain text for the summarizer
+func transcript(msgs []instructor.Message) string {
+	sb := new(strings.Builder)
+	for _, msg := range msgs {
+		if msg.Text != "" {
+			fmt.Fprintf(sb, "%s: %s\n", msg.Role, msg.Text)
+		}
+		for _, v := range msg.ToolUses {
+			fmt.Fprintf(sb, "%s: call tool %s(%s)\n", msg.Role, v.Name, v.Arguments)
+		}
+		for _, v := range msg.ToolResults {
+			fmt.Fprintf(sb, "tool %s: %s\n", v.Name, v.Content)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,144 @@
+package components
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/bububa/instructor-go"
+)
+
+// Price is the model price in currency per million tokens
+type Price struct {
+	// Input price of input tokens
+	Input float64 `json:"input,omitempty"`
+	// Output price of output tokens
+	Output float64 `json:"output,omitempty"`
+	// CachedInput price of cached input tokens, Input price is used if zero
+	CachedInput float64 `json:"cached_input,omitempty"`
+	// CacheWrite price of input tokens written into the cache, Input price is used if zero
+	CacheWrite float64 `json:"cache_write,omitempty"`
+	// Reasoning price of reasoning tokens, Output price is used if zero
+	Reasoning float64 `json:"reasoning,omitempty"`
+}
+
+// Cost returns the cost of usage, cached and cache write tokens are part of input tokens and reasoning tokens are part of output tokens
+func (p Price) Cost(usage *LLMUsage) float64 {
+	if usage == nil {
+		return 0
+	}
+	cachedPrice := p.CachedInput
+	if cachedPrice == 0 {
+		cachedPrice = p.Input
+	}
+	cacheWritePrice := p.CacheWrite
+	if cacheWritePrice == 0 {
+		cacheWritePrice = p.Input
+	}
+	reasoningPrice := p.Reasoning
+	if reasoningPrice == 0 {
+		reasoningPrice = p.Output
+	}
+	cost := float64(usage.InputTokens-usage.CachedTokens-usage.CacheWriteTokens)*p.Input +
+		float64(usage.CachedTokens)*cachedPrice +
+		float64(usage.CacheWriteTokens)*cacheWritePrice +
+		float64(usage.OutputTokens-usage.ReasoningTokens)*p.Output +
+		float64(usage.ReasoningTokens)*reasoningPrice
+	return cost / 1_000_000
+}
+
+// PriceTable holds model prices per provider and model
+type PriceTable struct {
+	mu     sync.RWMutex
+	prices map[string]map[string]Price
+}
+
+// NewPriceTable returns an empty PriceTable
+func NewPriceTable() *PriceTable {
+	return &PriceTable{
+		prices: make(map[string]map[string]Price),
+	}
+}
+
+// DefaultPriceTable is used by agents and RAG if no PriceTable provided, prices are in USD and indicative only,
+// override them with Set to match your contracts.
+var DefaultPriceTable = NewPriceTable()
+
+// Set sets the price of the provider model
+func (t *PriceTable) Set(provider string, model string, price Price) *PriceTable {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	models, ok := t.prices[provider]
+	if !ok {
+		models = make(map[string]Price)
+		t.prices[provider] = models
+	}
+	models[model] = price
+	return t
+}
+
+// Lookup returns the price of the provider model. Model name is matched exactly first, then by the longest
+// registered model name prefix, so dated model snapshots share the price of their base model.
+// A nil PriceTable looks up DefaultPriceTable.
+func (t *PriceTable) Lookup(provider string, model string) (Price, bool) {
+	if t == nil {
+		t = DefaultPriceTable
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	models, ok := t.prices[provider]
+	if !ok {
+		return Price{}, false
+	}
+	if price, ok := models[model]; ok {
+		return price, true
+	}
+	var (
+		matched string
+		price   Price
+	)
+	for name, v := range models {
+		if len(name) > len(matched) && strings.HasPrefix(model, name) {
+			matched = name
+			price = v
+		}
+	}
+	return price, matched != ""
+}
+
+// Cost returns the cost of usage of the provider model, false if the model price is unknown
+func (t *PriceTable) Cost(provider string, model string, usage *LLMUsage) (float64, bool) {
+	price, ok := t.Lookup(provider, model)
+	if !ok {
+		return 0, false
+	}
+	if provider == instructor.ProviderAnthropic && usage != nil {
+		// anthropic input tokens exclude cache reads and writes
+		total := *usage
+		total.InputTokens += usage.CachedTokens + usage.CacheWriteTokens
+		usage = &total
+	}
+	return price.Cost(usage), true
+}
+
+func init() {
+	DefaultPriceTable.
+		Set(instructor.ProviderOpenAI, "gpt-4o", Price{Input: 2.5, CachedInput: 1.25, Output: 10}).
+		Set(instructor.ProviderOpenAI, "gpt-4o-mini", Price{Input: 0.15, CachedInput: 0.075, Output: 0.6}).
+		Set(instructor.ProviderOpenAI, "gpt-4.1", Price{Input: 2, CachedInput: 0.5, Output: 8}).
+		Set(instructor.ProviderOpenAI, "gpt-4.1-mini", Price{Input: 0.4, CachedInput: 0.1, Output: 1.6}).
+		Set(instructor.ProviderOpenAI, "gpt-4.1-nano", Price{Input: 0.1, CachedInput: 0.025, Output: 0.4}).
+		Set(instructor.ProviderOpenAI, "o3-mini", Price{Input: 1.1, CachedInput: 0.55, Output: 4.4}).
+		Set(instructor.ProviderOpenAI, "o4-mini", Price{Input: 1.1, CachedInput: 0.275, Output: 4.4}).
+		Set(instructor.ProviderOpenAI, "text-embedding-3-small", Price{Input: 0.02}).
+		Set(instructor.ProviderOpenAI, "text-embedding-3-large", Price{Input: 0.13}).
+		Set(instructor.ProviderAnthropic, "claude-3-5-haiku", Price{Input: 0.8, CachedInput: 0.08, CacheWrite: 1, Output: 4}).
+		Set(instructor.ProviderAnthropic, "claude-3-5-sonnet", Price{Input: 3, CachedInput: 0.3, CacheWrite: 3.75, Output: 15}).
+		Set(instructor.ProviderAnthropic, "claude-3-7-sonnet", Price{Input: 3, CachedInput: 0.3, CacheWrite: 3.75, Output: 15}).
+		Set(instructor.ProviderAnthropic, "claude-sonnet-4", Price{Input: 3, CachedInput: 0.3, CacheWrite: 3.75, Output: 15}).
+		Set(instructor.ProviderAnthropic, "claude-opus-4", Price{Input: 15, CachedInput: 1.5, CacheWrite: 18.75, Output: 75}).
+		Set(instructor.ProviderGemini, "gemini-2.0-flash", Price{Input: 0.1, CachedInput: 0.025, Output: 0.4}).
+		Set(instructor.ProviderGemini, "gemini-2.5-flash", Price{Input: 0.3, CachedInput: 0.075, Output: 2.5}).
+		Set(instructor.ProviderGemini, "gemini-2.5-pro", Price{Input: 1.25, CachedInput: 0.31, Output: 10}).
+		Set(instructor.ProviderCohere, "command-r", Price{Input: 0.15, Output: 0.6}).
+		Set(instructor.ProviderCohere, "command-r-plus", Price{Input: 2.5, Output: 10})
+}
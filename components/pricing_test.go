@@ -0,0 +1,84 @@
+package components
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestPriceTableCost(t *testing.T) {
+	table := NewPriceTable().
+		Set("OpenAI", "gpt-4o", Price{Input: 2, CachedInput: 1, Output: 10}).
+		Set("OpenAI", "gpt-4o-mini", Price{Input: 0.2, Output: 1}).
+		Set("OpenAI", "cache-write", Price{Input: 2, CachedInput: 0.2, CacheWrite: 2.5, Output: 10})
+	tests := []struct {
+		name  string
+		model string
+		usage LLMUsage
+		want  float64
+		found bool
+	}{
+		{
+			name:  "exact model",
+			model: "gpt-4o",
+			usage: LLMUsage{InputTokens: 1_000_000, CachedTokens: 500_000, OutputTokens: 100_000},
+			want:  2.5,
+			found: true,
+		},
+		{
+			name:  "longest prefix",
+			model: "gpt-4o-mini-2024-07-18",
+			usage: LLMUsage{InputTokens: 1_000_000, OutputTokens: 1_000_000, ReasoningTokens: 500_000},
+			want:  1.2,
+			found: true,
+		},
+		{
+			name:  "cache write",
+			model: "cache-write",
+			usage: LLMUsage{InputTokens: 1_000_000, CachedTokens: 200_000, CacheWriteTokens: 400_000},
+			want:  0.8 + 0.04 + 1,
+			found: true,
+		},
+		{
+			name:  "unknown model",
+			model: "llama3",
+			usage: LLMUsage{InputTokens: 1_000_000},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := table.Cost("OpenAI", tt.model, &tt.usage)
+			if found != tt.found || math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Cost() = %v, %v, want %v, %v", got, found, tt.want, tt.found)
+			}
+		})
+	}
+}
+
+func TestPriceTableCostAnthropic(t *testing.T) {
+	table := NewPriceTable().Set("Anthropic", "claude-sonnet-4", Price{Input: 3, CachedInput: 0.3, CacheWrite: 3.75, Output: 15})
+	// anthropic reports input tokens without cache reads and writes
+	usage := &LLMUsage{InputTokens: 400_000, CachedTokens: 200_000, CacheWriteTokens: 400_000, OutputTokens: 100_000}
+	got, found := table.Cost("Anthropic", "claude-sonnet-4-20250514", usage)
+	if want := 1.2 + 0.06 + 1.5 + 1.5; !found || math.Abs(got-want) > 1e-9 {
+		t.Errorf("Cost() = %v, %v, want %v", got, found, want)
+	}
+	if usage.InputTokens != 400_000 {
+		t.Errorf("expect usage untouched, got %+v", usage)
+	}
+}
+
+func TestCostReport(t *testing.T) {
+	report := NewCostReport()
+	ctx := WithCostScope(WithCostReport(context.Background(), report), "feature")
+	RecordCost(WithCostScope(ctx, "rag"), EmbeddingCost, "rag", "OpenAI", "text-embedding-3-small", &LLMUsage{InputTokens: 10, Cost: 0.1})
+	RecordCost(ctx, ChatCost, "agent", "OpenAI", "gpt-4o", &LLMUsage{InputTokens: 20, OutputTokens: 5, Cost: 0.2})
+	RecordCost(context.Background(), ChatCost, "agent", "OpenAI", "gpt-4o", &LLMUsage{InputTokens: 20})
+	if total := report.Total(); total.InputTokens != 30 || total.OutputTokens != 5 || math.Abs(total.Cost-0.3) > 1e-9 {
+		t.Errorf("unexpected total usage: %+v", total)
+	}
+	byScope := report.ByScope()
+	if len(byScope) != 2 || byScope["feature/rag"].InputTokens != 10 || byScope["feature"].InputTokens != 20 {
+		t.Errorf("unexpected usage by scope: %+v", byScope)
+	}
+}
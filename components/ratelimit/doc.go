@@ -0,0 +1,6 @@
+// Package ratelimit limits requests per minute, tokens per minute and in-flight requests of chat instructors and embedders
+// per provider and model. Token usage is estimated with a splitter.TokenCounter before each call and corrected by the actual
+// usage once the call ends. Callers wait for capacity until their context is done, or fail fast with ErrRateLimited if the
+// context is marked by WithFailFast or its deadline comes before the capacity. The requests of agents native tool calling
+// loops are sent with the client of the wrapped instructor and limited by the Instructor as a components.RoundTripper.
+package ratelimit
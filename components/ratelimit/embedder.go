@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"context"
+
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/embedder"
+)
+
+// Embedder wraps an embedder.Embedder, its calls are limited by the Limiter of its provider model.
+// The Limiter is resolved on every call, so that limits set in the registry afterwards apply.
+type Embedder struct {
+	embedder.Embedder
+	registry *Registry
+}
+
+var _ embedder.Embedder = (*Embedder)(nil)
+
+// NewEmbedder returns an Embedder limited by the Limiter of the embedder provider model in registry
+func NewEmbedder(e embedder.Embedder, registry *Registry) *Embedder {
+	return &Embedder{
+		Embedder: e,
+		registry: registry,
+	}
+}
+
+// Unwrap returns the wrapped embedder
+func (e *Embedder) Unwrap() embedder.Embedder {
+	return e.Embedder
+}
+
+// limiter returns the Limiter of the embedder provider model, nil if no limit matched
+func (e *Embedder) limiter() *Limiter {
+	return e.registry.Limiter(e.Provider(), e.Model())
+}
+
+func (e *Embedder) Embed(ctx context.Context, text string, embedding *embedder.Embedding, usage *components.LLMUsage) error {
+	limiter := e.limiter()
+	reservation, err := limiter.Acquire(ctx, limiter.Count([]byte(text)))
+	if err != nil {
+		return err
+	}
+	if usage == nil {
+		usage = new(components.LLMUsage)
+	}
+	before := usage.InputTokens
+	err = e.Embedder.Embed(ctx, text, embedding, usage)
+	reservation.Done(usage.InputTokens - before)
+	return err
+}
+
+func (e *Embedder) BatchEmbed(ctx context.Context, parts []string, usage *components.LLMUsage) ([]embedder.Embedding, error) {
+	var (
+		limiter = e.limiter()
+		tokens  int
+	)
+	for _, part := range parts {
+		tokens += limiter.Count([]byte(part))
+	}
+	reservation, err := limiter.Acquire(ctx, tokens)
+	if err != nil {
+		return nil, err
+	}
+	if usage == nil {
+		usage = new(components.LLMUsage)
+	}
+	before := usage.InputTokens
+	ret, err := e.Embedder.BatchEmbed(ctx, parts, usage)
+	reservation.Done(usage.InputTokens - before)
+	return ret, err
+}
@@ -0,0 +1,200 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/bububa/instructor-go"
+	cohere "github.com/cohere-ai/cohere-go/v2"
+	anthropic "github.com/liushuangls/go-anthropic/v2"
+	"github.com/openai/openai-go"
+	gemini "google.golang.org/genai"
+
+	"github.com/bububa/atomic-agents/components"
+)
+
+// ErrStreamNotSupported returns when the wrapped instructor doesn't support the requested stream
+var ErrStreamNotSupported = errors.New("stream is not supported by the wrapped instructor")
+
+// Instructor wraps a chat instructor, its calls are limited by the Limiter of the instructor provider and the request model.
+// Request tokens are estimated from the encoded request, agents put memory history into requests, then corrected by the response usage.
+type Instructor[Req any, Resp any] struct {
+	instructor.ChatInstructor[Req, Resp]
+	registry *Registry
+}
+
+var (
+	_ instructor.ChatInstructor[openai.ChatCompletionNewParams, openai.ChatCompletion]         = (*Instructor[openai.ChatCompletionNewParams, openai.ChatCompletion])(nil)
+	_ instructor.StreamInstructor[openai.ChatCompletionNewParams, openai.ChatCompletion]       = (*Instructor[openai.ChatCompletionNewParams, openai.ChatCompletion])(nil)
+	_ instructor.SchemaStreamInstructor[openai.ChatCompletionNewParams, openai.ChatCompletion] = (*Instructor[openai.ChatCompletionNewParams, openai.ChatCompletion])(nil)
+	_ components.Rewrapper                                                                     = (*Instructor[openai.ChatCompletionNewParams, openai.ChatCompletion])(nil)
+	_ components.RoundTripper                                                                  = (*Instructor[openai.ChatCompletionNewParams, openai.ChatCompletion])(nil)
+)
+
+// NewInstructor returns an Instructor of clt limited by the limits in registry
+func NewInstructor[Req any, Resp any](clt instructor.ChatInstructor[Req, Resp], registry *Registry) *Instructor[Req, Resp] {
+	return &Instructor[Req, Resp]{
+		ChatInstructor: clt,
+		registry:       registry,
+	}
+}
+
+// Instructor returns the wrapped instructor
+func (i *Instructor[Req, Resp]) Instructor() instructor.ChatInstructor[Req, Resp] {
+	return i.ChatInstructor
+}
+
+// Unwrap returns the wrapped instructor, native tool calling loops of agents send their requests with its client through RoundTrip
+func (i *Instructor[Req, Resp]) Unwrap() instructor.Instructor {
+	return i.ChatInstructor
+}
+
+// Rewrap returns a copy of the Instructor wrapping clt, false if clt is not a chat instructor of the same request/response types
+func (i *Instructor[Req, Resp]) Rewrap(clt instructor.Instructor) (instructor.Instructor, bool) {
+	c, ok := clt.(instructor.ChatInstructor[Req, Resp])
+	if !ok {
+		return nil, false
+	}
+	return NewInstructor(c, i.registry), true
+}
+
+// RoundTrip limits a provider request sent outside of the instructor methods, like the requests of agents native tool calling loops
+func (i *Instructor[Req, Resp]) RoundTrip(ctx context.Context, request any, response any, call func(context.Context) error) error {
+	reservation, err := i.acquire(ctx, request)
+	if err != nil {
+		return err
+	}
+	err = call(ctx)
+	reservation.Done(usageTokens(response))
+	return err
+}
+
+// SetMemory set memory on a copy of the wrapped instructor, so that session copies of the Instructor don't share memory
+func (i *Instructor[Req, Resp]) SetMemory(memory *instructor.Memory) {
+	i.ChatInstructor = components.WithMemory(i.ChatInstructor, memory)
+}
+
+func (i *Instructor[Req, Resp]) Chat(ctx context.Context, request *Req, responseType any, response *Resp) error {
+	reservation, err := i.acquire(ctx, request)
+	if err != nil {
+		return err
+	}
+	err = i.ChatInstructor.Chat(ctx, request, responseType, response)
+	reservation.Done(usageTokens(response))
+	return err
+}
+
+func (i *Instructor[Req, Resp]) Handler(ctx context.Context, request *Req, response *Resp) (string, error) {
+	reservation, err := i.acquire(ctx, request)
+	if err != nil {
+		return "", err
+	}
+	ret, err := i.ChatInstructor.Handler(ctx, request, response)
+	reservation.Done(usageTokens(response))
+	return ret, err
+}
+
+// Stream runs the wrapped instructor Stream, the request is in flight until the stream ends
+func (i *Instructor[Req, Resp]) Stream(ctx context.Context, request *Req, responseType any, response *Resp) (<-chan instructor.StreamData, error) {
+	c, ok := i.ChatInstructor.(instructor.StreamInstructor[Req, Resp])
+	if !ok {
+		return nil, ErrStreamNotSupported
+	}
+	reservation, err := i.acquire(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	ch, err := c.Stream(ctx, request, responseType, response)
+	if err != nil {
+		reservation.Done(usageTokens(response))
+		return nil, err
+	}
+	return forward(ch, func() { reservation.Done(usageTokens(response)) }), nil
+}
+
+// SchemaStream runs the wrapped instructor SchemaStream, the request is in flight until the stream ends
+func (i *Instructor[Req, Resp]) SchemaStream(ctx context.Context, request *Req, responseType any, response *Resp) (<-chan any, <-chan instructor.StreamData, error) {
+	c, ok := i.ChatInstructor.(instructor.SchemaStreamInstructor[Req, Resp])
+	if !ok {
+		return nil, nil, ErrStreamNotSupported
+	}
+	reservation, err := i.acquire(ctx, request)
+	if err != nil {
+		return nil, nil, err
+	}
+	itemCh, ch, err := c.SchemaStream(ctx, request, responseType, response)
+	if err != nil {
+		reservation.Done(usageTokens(response))
+		return nil, nil, err
+	}
+	return itemCh, forward(ch, func() { reservation.Done(usageTokens(response)) }), nil
+}
+
+// SchemaStreamHandler runs the wrapped instructor SchemaStreamHandler, the request is in flight until the stream ends
+func (i *Instructor[Req, Resp]) SchemaStreamHandler(ctx context.Context, request *Req, response *Resp) (<-chan instructor.StreamData, error) {
+	c, ok := i.ChatInstructor.(instructor.SchemaStreamInstructor[Req, Resp])
+	if !ok {
+		return nil, ErrStreamNotSupported
+	}
+	reservation, err := i.acquire(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	ch, err := c.SchemaStreamHandler(ctx, request, response)
+	if err != nil {
+		reservation.Done(usageTokens(response))
+		return nil, err
+	}
+	return forward(ch, func() { reservation.Done(usageTokens(response)) }), nil
+}
+
+// acquire reserves the request with the Limiter of the request model, tokens are estimated from the encoded request
+func (i *Instructor[Req, Resp]) acquire(ctx context.Context, request any) (*Reservation, error) {
+	bs, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	var req struct {
+		Model string `json:"model"`
+	}
+	// model field names differ in case between providers, json matches them case-insensitively
+	_ = json.Unmarshal(bs, &req)
+	limiter := i.registry.Limiter(i.Provider(), req.Model)
+	if limiter == nil {
+		return nil, nil
+	}
+	return limiter.Acquire(ctx, limiter.Count(bs))
+}
+
+// forward forwards stream data from ch, done is called once ch ends
+func forward(ch <-chan instructor.StreamData, done func()) <-chan instructor.StreamData {
+	out := make(chan instructor.StreamData)
+	go func() {
+		defer close(out)
+		defer done()
+		for data := range ch {
+			out <- data
+		}
+	}()
+	return out
+}
+
+// usageTokens returns the input and output tokens of a provider response, zero if unknown
+func usageTokens(response any) int64 {
+	resp := new(components.LLMResponse)
+	switch v := response.(type) {
+	case *openai.ChatCompletion:
+		resp.FromOpenAI(v)
+	case *anthropic.MessagesResponse:
+		resp.FromAnthropic(v)
+	case *cohere.NonStreamedChatResponse:
+		resp.FromCohere(v)
+	case *gemini.GenerateContentResponse:
+		resp.FromGemini(v)
+	}
+	if resp.Usage == nil {
+		return 0
+	}
+	return resp.Usage.InputTokens + resp.Usage.OutputTokens
+}
@@ -0,0 +1,242 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bububa/atomic-agents/components/embedder/splitter"
+)
+
+// ErrRateLimited returns when a fail fast caller would have to wait for capacity
+var ErrRateLimited = errors.New("rate limited")
+
+// Limit is the rate limit of a provider model, zero values are unlimited
+type Limit struct {
+	// RPM requests per minute
+	RPM int `json:"rpm,omitempty" yaml:"rpm,omitempty"`
+	// TPM tokens per minute
+	TPM int `json:"tpm,omitempty" yaml:"tpm,omitempty"`
+	// MaxInFlight maximum number of concurrent requests
+	MaxInFlight int `json:"max_in_flight,omitempty" yaml:"max_in_flight,omitempty"`
+}
+
+type failFastKey struct{}
+
+// WithFailFast returns a context whose callers fail with ErrRateLimited instead of waiting for capacity
+func WithFailFast(ctx context.Context) context.Context {
+	return context.WithValue(ctx, failFastKey{}, true)
+}
+
+func isFailFast(ctx context.Context) bool {
+	v, _ := ctx.Value(failFastKey{}).(bool)
+	return v
+}
+
+// Limiter limits requests with requests and tokens per minute buckets and a maximum of in-flight requests.
+// A nil Limiter is unlimited.
+type Limiter struct {
+	mu       sync.Mutex
+	limit    Limit
+	counter  splitter.TokenCounter
+	requests *bucket
+	tokens   *bucket
+	inFlight int
+	released chan struct{}
+}
+
+// NewLimiter returns a new Limiter of the limit
+func NewLimiter(limit Limit) *Limiter {
+	now := time.Now()
+	return &Limiter{
+		limit:    limit,
+		counter:  approxCounter{},
+		requests: newBucket(limit.RPM, now),
+		tokens:   newBucket(limit.TPM, now),
+		released: make(chan struct{}),
+	}
+}
+
+// SetTokenCounter set the TokenCounter estimating request tokens, about 4 bytes per token by default
+func (l *Limiter) SetTokenCounter(counter splitter.TokenCounter) *Limiter {
+	l.counter = counter
+	return l
+}
+
+// Limit returns the limit of the Limiter
+func (l *Limiter) Limit() Limit {
+	if l == nil {
+		return Limit{}
+	}
+	return l.limit
+}
+
+// Count estimates the number of tokens of the text
+func (l *Limiter) Count(p []byte) int {
+	if l == nil || l.counter == nil {
+		return approxCounter{}.Count(p)
+	}
+	return l.counter.Count(p)
+}
+
+// Acquire reserves a request of the estimated tokens, it waits for capacity until ctx is done.
+// It returns ErrRateLimited without waiting if ctx is marked by WithFailFast or the capacity comes after ctx deadline.
+// The returned Reservation must be done once the request ends.
+func (l *Limiter) Acquire(ctx context.Context, tokens int) (*Reservation, error) {
+	if l == nil {
+		return nil, nil
+	}
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.requests.refill(now)
+		l.tokens.refill(now)
+		wait := max(l.requests.wait(1), l.tokens.wait(float64(tokens)))
+		full := l.limit.MaxInFlight > 0 && l.inFlight >= l.limit.MaxInFlight
+		if wait == 0 && !full {
+			l.requests.take(1)
+			l.tokens.take(float64(tokens))
+			l.inFlight++
+			l.mu.Unlock()
+			return &Reservation{limiter: l, tokens: tokens}, nil
+		}
+		released := l.released
+		l.mu.Unlock()
+		if isFailFast(ctx) {
+			return nil, rateLimited(wait, full)
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return nil, rateLimited(wait, full)
+		}
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			timeout = timer.C
+		}
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return nil, ctx.Err()
+		case <-released:
+		case <-timeout:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// release ends an in-flight request, the reserved tokens are corrected by the actual tokens if known
+func (l *Limiter) release(reserved int, actual int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if actual > 0 {
+		l.tokens.refill(time.Now())
+		l.tokens.put(float64(int64(reserved) - actual))
+	}
+	l.inFlight--
+	close(l.released)
+	l.released = make(chan struct{})
+}
+
+func rateLimited(wait time.Duration, full bool) error {
+	if full {
+		return fmt.Errorf("%w: too many requests in flight", ErrRateLimited)
+	}
+	return fmt.Errorf("%w: retry after %s", ErrRateLimited, wait.Round(time.Millisecond))
+}
+
+// Reservation is an in-flight request reserved by a Limiter
+type Reservation struct {
+	limiter *Limiter
+	tokens  int
+	once    sync.Once
+}
+
+// Tokens returns the estimated tokens reserved
+func (r *Reservation) Tokens() int {
+	if r == nil {
+		return 0
+	}
+	return r.tokens
+}
+
+// Done ends the request, the reserved tokens are corrected by the actual tokens used, zero keeps the estimate.
+// Calling Done more than once has no effect.
+func (r *Reservation) Done(actual int64) {
+	if r == nil {
+		return
+	}
+	r.once.Do(func() {
+		r.limiter.release(r.tokens, actual)
+	})
+}
+
+// bucket is a token bucket refilled per minute, a nil bucket is unlimited
+type bucket struct {
+	capacity  float64
+	available float64
+	// rate refill per second
+	rate float64
+	last time.Time
+}
+
+func newBucket(perMinute int, now time.Time) *bucket {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &bucket{
+		capacity:  float64(perMinute),
+		available: float64(perMinute),
+		rate:      float64(perMinute) / 60,
+		last:      now,
+	}
+}
+
+func (b *bucket) refill(now time.Time) {
+	if b == nil {
+		return
+	}
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.available = min(b.capacity, b.available+elapsed*b.rate)
+		b.last = now
+	}
+}
+
+// wait returns the duration until n is available, n is capped by the capacity so that large requests pass once the bucket is full
+func (b *bucket) wait(n float64) time.Duration {
+	if b == nil {
+		return 0
+	}
+	n = min(n, b.capacity)
+	if b.available >= n {
+		return 0
+	}
+	return time.Duration((n - b.available) / b.rate * float64(time.Second))
+}
+
+// take takes n, the bucket goes into debt if n exceeds the available
+func (b *bucket) take(n float64) {
+	if b != nil {
+		b.available -= n
+	}
+}
+
+// put puts back n, a negative n takes more
+func (b *bucket) put(n float64) {
+	if b != nil {
+		b.available = min(b.capacity, b.available+n)
+	}
+}
+
+// approxCounter estimates about 4 bytes per token
+type approxCounter struct{}
+
+func (approxCounter) Count(p []byte) int {
+	return (len(p) + 3) / 4
+}
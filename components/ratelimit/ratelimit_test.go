@@ -0,0 +1,186 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bububa/instructor-go"
+	openaiClt "github.com/bububa/instructor-go/instructors/openai"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+
+	"github.com/bububa/atomic-agents/agents"
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/embedder"
+	"github.com/bububa/atomic-agents/internal/llmtest"
+	"github.com/bububa/atomic-agents/schema"
+	"github.com/bububa/atomic-agents/tools"
+	"github.com/bububa/atomic-agents/tools/calculator"
+)
+
+func TestLimiter(t *testing.T) {
+	ctx := context.Background()
+	limiter := NewLimiter(Limit{RPM: 2, TPM: 100, MaxInFlight: 1})
+	r1, err := limiter.Acquire(ctx, 60)
+	if err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+	if _, err := limiter.Acquire(WithFailFast(ctx), 10); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expect fail fast on max in flight, got %v", err)
+	}
+	acquired := make(chan error)
+	go func() {
+		r, err := limiter.Acquire(ctx, 10)
+		r.Done(10)
+		acquired <- err
+	}()
+	// the actual usage gives back 50 of the 60 estimated tokens
+	r1.Done(10)
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Errorf("expect blocked caller acquired once released, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expect blocked caller acquired once released")
+	}
+	if _, err := limiter.Acquire(WithFailFast(ctx), 1); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expect fail fast on requests per minute, got %v", err)
+	}
+	timeout, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := limiter.Acquire(timeout, 1); !errors.Is(err, ErrRateLimited) || time.Since(start) > 50*time.Millisecond {
+		t.Errorf("expect fail fast if capacity comes after deadline, got %v", err)
+	}
+	if available := limiter.tokens.available; available < 79 || available > 81 {
+		t.Errorf("expect tokens corrected by actual usage, got %v available", available)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry().
+		Set(instructor.ProviderOpenAI, "", Limit{RPM: 10}).
+		Set(instructor.ProviderOpenAI, "gpt-4o", Limit{RPM: 20})
+	if l := registry.Limiter(instructor.ProviderOpenAI, "gpt-4o-2024-08-06"); l.Limit().RPM != 20 || l != registry.Limiter(instructor.ProviderOpenAI, "gpt-4o") {
+		t.Errorf("expect shared prefix matched limiter, got %+v", l.Limit())
+	}
+	if l := registry.Limiter(instructor.ProviderOpenAI, "o3-mini"); l.Limit().RPM != 10 {
+		t.Errorf("expect provider limiter, got %+v", l.Limit())
+	}
+	if l := registry.Limiter(instructor.ProviderAnthropic, "claude-sonnet-4"); l != nil {
+		t.Errorf("expect unlimited provider, got %+v", l.Limit())
+	}
+}
+
+type countEmbedder struct{}
+
+func (countEmbedder) Provider() embedder.Provider { return embedder.ProviderOpenAI }
+
+func (countEmbedder) Model() string { return "text-embedding-3-small" }
+
+func (e countEmbedder) Embed(_ context.Context, text string, embedding *embedder.Embedding, usage *components.LLMUsage) error {
+	embedding.Object = text
+	usage.InputTokens += 1
+	return nil
+}
+
+func (e countEmbedder) BatchEmbed(ctx context.Context, parts []string, usage *components.LLMUsage) ([]embedder.Embedding, error) {
+	ret := make([]embedder.Embedding, len(parts))
+	for idx, part := range parts {
+		if err := e.Embed(ctx, part, &ret[idx], usage); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+func (countEmbedder) DotProduct(_ context.Context, a *embedder.Embedding, b *embedder.Embedding) (float64, error) {
+	return a.DotProduct(b)
+}
+
+func TestEmbedder(t *testing.T) {
+	registry := NewRegistry()
+	e := NewEmbedder(countEmbedder{}, registry)
+	if err := e.Embed(WithFailFast(context.Background()), "0123456789abcdef01234567", new(embedder.Embedding), nil); err != nil {
+		t.Fatalf("expect unlimited embedder, got %v", err)
+	}
+	// limits set after the embedder is wrapped apply to its next calls
+	registry.Set(embedder.ProviderOpenAI, "text-embedding-3", Limit{TPM: 10})
+	usage := new(components.LLMUsage)
+	// estimated 10 tokens corrected to 3 actual tokens
+	if _, err := e.BatchEmbed(context.Background(), []string{"0123456789ab", "0123456789ab", "0123456789ab", "0123"}, usage); err != nil {
+		t.Fatalf("batch embed failed: %v", err)
+	}
+	if usage.InputTokens != 4 {
+		t.Errorf("expect usage of wrapped embedder, got %d", usage.InputTokens)
+	}
+	if err := e.Embed(WithFailFast(context.Background()), "0123456789abcdef01234567", new(embedder.Embedding), usage); err != nil {
+		t.Errorf("expect tokens given back by actual usage, got %v", err)
+	}
+	if err := e.Embed(WithFailFast(context.Background()), "0123456789abcdef01234567", new(embedder.Embedding), usage); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expect fail fast on tokens per minute, got %v", err)
+	}
+}
+
+func TestInstructor(t *testing.T) {
+	srv := llmtest.NewServer(t, llmtest.Text(`{"chat_message":"hello"}`))
+	openaiClient := openai.NewClient(option.WithBaseURL(srv.URL), option.WithAPIKey("test"), option.WithMaxRetries(0))
+	registry := NewRegistry().Set(instructor.ProviderOpenAI, llmtest.Model, Limit{RPM: 1, TPM: 1000})
+	clt := NewInstructor(openaiClt.New(&openaiClient, instructor.WithMode(instructor.ModeJSON), instructor.WithMaxRetries(0)), registry)
+
+	ctx := WithFailFast(context.Background())
+	agent := agents.NewAgent[schema.Input, schema.Output](agents.WithClient(clt), agents.WithModel(llmtest.Model))
+	if err := agent.Run(ctx, schema.NewInput("hi"), new(schema.Output), new(components.LLMResponse)); err != nil {
+		t.Fatalf("run agent failed: %v", err)
+	}
+	limiter := registry.Limiter(instructor.ProviderOpenAI, llmtest.Model)
+	if available := limiter.tokens.available; available < 983 || available > 984 {
+		t.Errorf("expect tokens corrected by response usage, got %v available", available)
+	}
+	if err := agent.Run(ctx, schema.NewInput("hi again"), new(schema.Output), new(components.LLMResponse)); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expect fail fast on requests per minute, got %v", err)
+	}
+	other := agents.NewAgent[schema.Input, schema.Output](agents.WithClient(clt), agents.WithModel("gpt-other"))
+	if err := other.Run(ctx, schema.NewInput("hi"), new(schema.Output), new(components.LLMResponse)); err != nil {
+		t.Errorf("expect unlimited model, got %v", err)
+	}
+	if got := len(srv.Requests()); got != 2 {
+		t.Errorf("expect 2 requests sent, got %d", got)
+	}
+}
+
+func TestInstructorToolCalling(t *testing.T) {
+	srv := llmtest.NewServer(t, func(body map[string]any) llmtest.Reply {
+		messages := llmtest.Messages(body)
+		if messages[len(messages)-1].(map[string]any)["role"] != "tool" {
+			return llmtest.Reply{ToolCalls: []llmtest.ToolCall{{ID: "call_1", Name: "CalculatorTool", Arguments: `{"expression":"2 + 3"}`}}}
+		}
+		return llmtest.Reply{Content: `{"chat_message":"5"}`}
+	})
+	openaiClient := openai.NewClient(option.WithBaseURL(srv.URL), option.WithAPIKey("test"), option.WithMaxRetries(0))
+	registry := NewRegistry().Set(instructor.ProviderOpenAI, llmtest.Model, Limit{RPM: 2})
+	clt := NewInstructor(openaiClt.New(&openaiClient, instructor.WithMode(instructor.ModeJSON), instructor.WithMaxRetries(0)), registry)
+	agent := agents.NewAgent[schema.Input, schema.Output](
+		agents.WithClient(clt),
+		agents.WithModel(llmtest.Model),
+		agents.WithTools(tools.NewFunction[calculator.Input](calculator.New())),
+	)
+	ctx := WithFailFast(context.Background())
+	output := new(schema.Output)
+	if err := agent.Run(ctx, schema.NewInput("2 + 3 = ?"), output, nil); err != nil {
+		t.Fatalf("run agent failed: %v", err)
+	}
+	if output.ChatMessage != "5" || len(srv.Requests()) != 2 {
+		t.Errorf("expect tool calling loop through the rate limited instructor, got %q after %d requests", output.ChatMessage, len(srv.Requests()))
+	}
+	// both requests of the loop were limited, the next one fails fast
+	if err := agent.Run(ctx, schema.NewInput("2 + 3 = ?"), output, nil); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expect tool calling requests counted by the limiter, got %v", err)
+	}
+	if got := len(srv.Requests()); got != 2 {
+		t.Errorf("expect no request sent once limited, got %d", got)
+	}
+}
@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/bububa/atomic-agents/components/embedder/splitter"
+)
+
+// Registry holds the limits per provider and model, Limiters of a matched limit are shared by its callers
+type Registry struct {
+	mu       sync.Mutex
+	limits   map[string]map[string]Limit
+	limiters map[string]map[string]*Limiter
+	counter  splitter.TokenCounter
+}
+
+// NewRegistry returns an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{
+		limits:   make(map[string]map[string]Limit),
+		limiters: make(map[string]map[string]*Limiter),
+	}
+}
+
+// Set sets the limit of the provider model, an empty model sets the limit of the provider models without their own limit
+func (r *Registry) Set(provider string, model string, limit Limit) *Registry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	models, ok := r.limits[provider]
+	if !ok {
+		models = make(map[string]Limit)
+		r.limits[provider] = models
+	}
+	models[model] = limit
+	if limiters, ok := r.limiters[provider]; ok {
+		delete(limiters, model)
+	}
+	return r
+}
+
+// SetTokenCounter set the TokenCounter of the Limiters created afterwards
+func (r *Registry) SetTokenCounter(counter splitter.TokenCounter) *Registry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counter = counter
+	return r
+}
+
+// Limiter returns the Limiter of the provider model, nil if no limit matched. Model name is matched exactly first,
+// then by the longest registered model name prefix, then the provider limit set with an empty model.
+func (r *Registry) Limiter(provider string, model string) *Limiter {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	models, ok := r.limits[provider]
+	if !ok {
+		return nil
+	}
+	matched, limit, ok := model, Limit{}, false
+	if limit, ok = models[model]; !ok {
+		matched = ""
+		for name, v := range models {
+			if len(name) > len(matched) && strings.HasPrefix(model, name) {
+				matched, limit = name, v
+			}
+		}
+		if limit, ok = models[matched]; !ok {
+			return nil
+		}
+	}
+	limiters, ok := r.limiters[provider]
+	if !ok {
+		limiters = make(map[string]*Limiter)
+		r.limiters[provider] = limiters
+	}
+	limiter, ok := limiters[matched]
+	if !ok {
+		limiter = NewLimiter(limit)
+		if r.counter != nil {
+			limiter.SetTokenCounter(r.counter)
+		}
+		limiters[matched] = limiter
+	}
+	return limiter
+}
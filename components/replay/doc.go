@@ -0,0 +1,14 @@
+// Package replay records chat instructor exchanges to golden files and serves them back, so that agents could be tested offline.
+//
+// A Recorder wraps a real instructor and writes every Chat, Stream and SchemaStream exchange into a golden file named
+// by the hash of the request. A Replayer wraps an instructor built with an unreachable client and serves the golden
+// files back by the same hash without any network call. Both implement the ChatInstructor, StreamInstructor and
+// SchemaStreamInstructor interfaces of their request/response types, so the OpenAI, Anthropic, Cohere and Gemini
+// providers of agents drive them like the wrapped instructor.
+//
+// Native tool calling loops of agents send their requests with the client of the unwrapped instructor, both wrappers
+// intercept them as a components.RoundTripper, so the Recorder records every request of the loop and the Replayer
+// serves them back, tools still run for real. Recorded errors are replayed as *Error,
+// which keeps the kind and HTTP status code of the original error, so components.ClassifyError and errors.Is classify
+// them like the live ones.
+package replay
@@ -0,0 +1,84 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"reflect"
+
+	"github.com/bububa/atomic-agents/components"
+)
+
+// ErrorKind is the kind of a recorded error, so that replayed errors are classified like the live ones
+type ErrorKind string
+
+const (
+	CanceledError ErrorKind = "canceled"
+	TimeoutError  ErrorKind = "timeout"
+	NetworkError  ErrorKind = "network"
+	SchemaError   ErrorKind = "schema"
+	// APIError is a provider API error with an HTTP status code
+	APIError ErrorKind = "api"
+)
+
+// Error is a replayed error with the message of the recorded one. It unwraps to context.Canceled, context.DeadlineExceeded,
+// a *net.OpError or a *json.UnmarshalTypeError by kind, and reports the HTTP status code of API errors.
+type Error struct {
+	Message    string
+	Kind       ErrorKind
+	StatusCode int
+	err        error
+}
+
+func newError(message string, kind ErrorKind, statusCode int) *Error {
+	ret := &Error{
+		Message:    message,
+		Kind:       kind,
+		StatusCode: statusCode,
+	}
+	switch kind {
+	case CanceledError:
+		ret.err = context.Canceled
+	case TimeoutError:
+		ret.err = context.DeadlineExceeded
+	case NetworkError:
+		ret.err = &net.OpError{Op: "replay", Err: errors.New(message)}
+	case SchemaError:
+		ret.err = &json.UnmarshalTypeError{Value: "replayed output", Type: reflect.TypeFor[string]()}
+	}
+	return ret
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// HTTPStatusCode returns the HTTP status code of a replayed API error, 0 for other kinds
+func (e *Error) HTTPStatusCode() int {
+	return e.StatusCode
+}
+
+// classifyError returns the kind and the HTTP status code of an error returned by the wrapped instructor,
+// API errors keep any status code, other errors are classified like components.ClassifyError does
+func classifyError(err error) (ErrorKind, int) {
+	if errors.Is(err, context.Canceled) {
+		return CanceledError, 0
+	}
+	if code := components.ErrorStatusCode(err); code > 0 {
+		return APIError, code
+	}
+	switch class := components.ClassifyError(err); {
+	case class.Has(components.TimeoutError):
+		return TimeoutError, 0
+	case class.Has(components.SchemaError):
+		return SchemaError, 0
+	case class.Has(components.NetworkError):
+		return NetworkError, 0
+	}
+	return "", 0
+}
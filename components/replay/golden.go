@@ -0,0 +1,235 @@
+package replay
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/bububa/instructor-go"
+)
+
+// ErrNotRecorded returns when no golden file was recorded for the request
+var ErrNotRecorded = errors.New("request not recorded")
+
+// Kind is the kind of recorded exchange
+type Kind string
+
+const (
+	ChatKind         Kind = "chat"
+	StreamKind       Kind = "stream"
+	SchemaStreamKind Kind = "schema_stream"
+	// RoundTripKind is a provider request sent outside of the instructor methods, like the requests of agents native tool calling loops
+	RoundTripKind Kind = "round_trip"
+)
+
+// Chunk is a recorded instructor.StreamData
+type Chunk struct {
+	Type     instructor.StreamDataType `json:"type"`
+	Content  string                    `json:"content,omitempty"`
+	ToolCall *instructor.ToolCall      `json:"tool_call,omitempty"`
+	Error    string                    `json:"error,omitempty"`
+	// ErrorKind and StatusCode classify the error
+	ErrorKind  ErrorKind `json:"error_kind,omitempty"`
+	StatusCode int       `json:"status_code,omitempty"`
+}
+
+func newChunk(data *instructor.StreamData) Chunk {
+	chunk := Chunk{
+		Type:     data.Type,
+		Content:  data.Content,
+		ToolCall: data.ToolCall,
+	}
+	if data.Err != nil {
+		chunk.Error = data.Err.Error()
+		chunk.ErrorKind, chunk.StatusCode = classifyError(data.Err)
+	}
+	return chunk
+}
+
+func (c *Chunk) StreamData() instructor.StreamData {
+	data := instructor.StreamData{
+		Type:     c.Type,
+		Content:  c.Content,
+		ToolCall: c.ToolCall,
+	}
+	if c.Error != "" {
+		data.Err = newError(c.Error, c.ErrorKind, c.StatusCode)
+	}
+	return data
+}
+
+// Item is a recorded object parsed by a schema stream, Text for plain text streams, JSON otherwise
+type Item struct {
+	Text *string         `json:"text,omitempty"`
+	JSON json.RawMessage `json:"json,omitempty"`
+}
+
+func newItem(v any) (Item, error) {
+	if s, ok := v.(string); ok {
+		return Item{Text: &s}, nil
+	}
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return Item{}, err
+	}
+	return Item{JSON: bs}, nil
+}
+
+// value decodes the item into a new instance of the response type, like the instructor stream encoders do
+func (i *Item) value(responseType any) (any, error) {
+	if i.Text != nil {
+		return *i.Text, nil
+	}
+	t := reflect.TypeOf(responseType)
+	if t == nil {
+		var v any
+		err := json.Unmarshal(i.JSON, &v)
+		return v, err
+	}
+	v := reflect.New(t).Interface()
+	if err := json.Unmarshal(i.JSON, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Golden is a recorded request/response exchange
+type Golden struct {
+	Kind     Kind                `json:"kind"`
+	Provider instructor.Provider `json:"provider,omitempty"`
+	Request  json.RawMessage     `json:"request"`
+	// Response is the provider response, carries usage
+	Response json.RawMessage `json:"response,omitempty"`
+	// Output is the encoded chat output
+	Output string `json:"output,omitempty"`
+	// Items are the objects parsed by a schema stream
+	Items []Item `json:"items,omitempty"`
+	// Stream is the stream data in order
+	Stream []Chunk `json:"stream,omitempty"`
+	// Memory are the messages added to the instructor memory during the exchange
+	Memory []instructor.Message `json:"memory,omitempty"`
+	Error  string               `json:"error,omitempty"`
+	// ErrorKind and StatusCode classify the error
+	ErrorKind  ErrorKind `json:"error_kind,omitempty"`
+	StatusCode int       `json:"status_code,omitempty"`
+}
+
+// setErr records the error with its kind and HTTP status code
+func (g *Golden) setErr(err error) {
+	g.Error = err.Error()
+	g.ErrorKind, g.StatusCode = classifyError(err)
+}
+
+// Err returns the recorded error as an *Error, nil if none
+func (g *Golden) Err() error {
+	if g.Error == "" {
+		return nil
+	}
+	return newError(g.Error, g.ErrorKind, g.StatusCode)
+}
+
+// newGolden returns a Golden of the request with its hash
+func newGolden(kind Kind, provider instructor.Provider, mode instructor.Mode, request any) (*Golden, string, error) {
+	bs, err := json.Marshal(request)
+	if err != nil {
+		return nil, "", err
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n", kind, provider, mode)
+	h.Write(bs)
+	return &Golden{
+		Kind:     kind,
+		Provider: provider,
+		Request:  bs,
+	}, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Path returns the golden file path of the request hash in dir
+func Path(dir string, hash string) string {
+	return filepath.Join(dir, hash+".json")
+}
+
+func loadGolden(dir string, hash string) (*Golden, error) {
+	path := Path(dir, hash)
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrNotRecorded, path)
+		}
+		return nil, err
+	}
+	golden := new(Golden)
+	if err := json.Unmarshal(bs, golden); err != nil {
+		return nil, err
+	}
+	return golden, nil
+}
+
+func saveGolden(dir string, hash string, golden *Golden) error {
+	bs, err := json.MarshalIndent(golden, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(Path(dir, hash), bs, 0o644)
+}
+
+// unmarshaler is implemented by schemas decoded from raw text, like schema.String
+type unmarshaler interface {
+	Unmarshal([]byte) error
+}
+
+type stringer interface {
+	String() string
+}
+
+func encodeOutput(output any) (string, error) {
+	if _, ok := output.(unmarshaler); ok {
+		if v, ok := output.(stringer); ok {
+			return v.String(), nil
+		}
+	}
+	bs, err := json.Marshal(output)
+	if err != nil {
+		return "", err
+	}
+	return string(bs), nil
+}
+
+func decodeOutput(s string, output any) error {
+	if s == "" || output == nil {
+		return nil
+	}
+	if v, ok := output.(unmarshaler); ok {
+		return v.Unmarshal([]byte(s))
+	}
+	return json.Unmarshal([]byte(s), output)
+}
+
+// memoryLen returns the number of messages in the instructor memory
+func memoryLen(clt instructor.Instructor) int {
+	if memory := clt.Memory(); memory != nil {
+		return len(memory.List())
+	}
+	return 0
+}
+
+// addedMessages returns the messages added to the instructor memory since it had n messages
+func addedMessages(clt instructor.Instructor, n int) []instructor.Message {
+	memory := clt.Memory()
+	if memory == nil {
+		return nil
+	}
+	list := memory.List()
+	if len(list) <= n {
+		return nil
+	}
+	return append([]instructor.Message(nil), list[n:]...)
+}
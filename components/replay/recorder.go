@@ -0,0 +1,222 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/bububa/instructor-go"
+	geminiClt "github.com/bububa/instructor-go/instructors/gemini"
+	anthropic "github.com/liushuangls/go-anthropic/v2"
+	"github.com/openai/openai-go"
+	geminiAPI "google.golang.org/genai"
+
+	"github.com/bububa/atomic-agents/components"
+)
+
+// ErrStreamNotSupported returns when the wrapped instructor doesn't support the requested stream
+var ErrStreamNotSupported = errors.New("stream is not supported by the wrapped instructor")
+
+// Recorder wraps a chat instructor, records its exchanges into golden files in dir
+type Recorder[Req any, Resp any] struct {
+	instructor.ChatInstructor[Req, Resp]
+	dir string
+}
+
+var (
+	_ instructor.ChatInstructor[openai.ChatCompletionNewParams, openai.ChatCompletion]         = (*Recorder[openai.ChatCompletionNewParams, openai.ChatCompletion])(nil)
+	_ instructor.StreamInstructor[openai.ChatCompletionNewParams, openai.ChatCompletion]       = (*Recorder[openai.ChatCompletionNewParams, openai.ChatCompletion])(nil)
+	_ instructor.SchemaStreamInstructor[openai.ChatCompletionNewParams, openai.ChatCompletion] = (*Recorder[openai.ChatCompletionNewParams, openai.ChatCompletion])(nil)
+	_ instructor.SchemaStreamInstructor[anthropic.MessagesRequest, anthropic.MessagesResponse] = (*Recorder[anthropic.MessagesRequest, anthropic.MessagesResponse])(nil)
+	_ instructor.SchemaStreamInstructor[geminiClt.Request, geminiAPI.GenerateContentResponse]  = (*Recorder[geminiClt.Request, geminiAPI.GenerateContentResponse])(nil)
+	_ components.Rewrapper                                                                     = (*Recorder[openai.ChatCompletionNewParams, openai.ChatCompletion])(nil)
+	_ components.RoundTripper                                                                  = (*Recorder[openai.ChatCompletionNewParams, openai.ChatCompletion])(nil)
+)
+
+// NewRecorder returns a Recorder of the instructor writing golden files into dir
+func NewRecorder[Req any, Resp any](clt instructor.ChatInstructor[Req, Resp], dir string) *Recorder[Req, Resp] {
+	return &Recorder[Req, Resp]{
+		ChatInstructor: clt,
+		dir:            dir,
+	}
+}
+
+// Dir returns the golden files directory
+func (r *Recorder[Req, Resp]) Dir() string {
+	return r.dir
+}
+
+// Instructor returns the wrapped instructor
+func (r *Recorder[Req, Resp]) Instructor() instructor.ChatInstructor[Req, Resp] {
+	return r.ChatInstructor
+}
+
+// Unwrap returns the wrapped instructor, native tool calling loops of agents send their requests with its client through RoundTrip
+func (r *Recorder[Req, Resp]) Unwrap() instructor.Instructor {
+	return r.ChatInstructor
+}
+
+// Rewrap returns a copy of the Recorder wrapping clt, false if clt is not a chat instructor of the same request/response types
+func (r *Recorder[Req, Resp]) Rewrap(clt instructor.Instructor) (instructor.Instructor, bool) {
+	c, ok := clt.(instructor.ChatInstructor[Req, Resp])
+	if !ok {
+		return nil, false
+	}
+	return NewRecorder(c, r.dir), true
+}
+
+// RoundTrip sends a provider request sent outside of the instructor methods, like the requests of agents native tool calling loops,
+// and records the exchange, failed exchanges are recorded unless ctx is done
+func (r *Recorder[Req, Resp]) RoundTrip(ctx context.Context, request any, response any, call func(context.Context) error) error {
+	golden, hash, err := newGolden(RoundTripKind, r.Provider(), r.Mode(), request)
+	if err != nil {
+		return err
+	}
+	n := memoryLen(r)
+	callErr := call(ctx)
+	if callErr != nil {
+		if ctx.Err() != nil {
+			return callErr
+		}
+		golden.setErr(callErr)
+	}
+	if err := r.save(hash, golden, response, n); err != nil {
+		return errors.Join(callErr, err)
+	}
+	return callErr
+}
+
+// SetMemory set memory on a copy of the wrapped instructor, so that session copies of the Recorder don't share memory
+func (r *Recorder[Req, Resp]) SetMemory(memory *instructor.Memory) {
+	r.ChatInstructor = components.WithMemory(r.ChatInstructor, memory)
+}
+
+// Chat runs the wrapped instructor Chat and records the exchange, failed exchanges are recorded unless ctx is done
+func (r *Recorder[Req, Resp]) Chat(ctx context.Context, request *Req, responseType any, response *Resp) error {
+	golden, hash, err := newGolden(ChatKind, r.Provider(), r.Mode(), request)
+	if err != nil {
+		return err
+	}
+	n := memoryLen(r)
+	chatErr := r.ChatInstructor.Chat(ctx, request, responseType, response)
+	if chatErr != nil {
+		if ctx.Err() != nil {
+			return chatErr
+		}
+		golden.setErr(chatErr)
+	} else if golden.Output, err = encodeOutput(responseType); err != nil {
+		return err
+	}
+	if err := r.save(hash, golden, response, n); err != nil {
+		return errors.Join(chatErr, err)
+	}
+	return chatErr
+}
+
+// Stream runs the wrapped instructor Stream and records the stream data, the golden file is written once the stream ends,
+// a write failure is sent as the last ErrorStream data
+func (r *Recorder[Req, Resp]) Stream(ctx context.Context, request *Req, responseType any, response *Resp) (<-chan instructor.StreamData, error) {
+	c, ok := r.ChatInstructor.(instructor.StreamInstructor[Req, Resp])
+	if !ok {
+		return nil, ErrStreamNotSupported
+	}
+	golden, hash, err := newGolden(StreamKind, r.Provider(), r.Mode(), request)
+	if err != nil {
+		return nil, err
+	}
+	n := memoryLen(r)
+	ch, err := c.Stream(ctx, request, responseType, response)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan instructor.StreamData)
+	go func() {
+		defer close(out)
+		r.forward(ch, out, golden)
+		if err := r.save(hash, golden, response, n); err != nil {
+			out <- instructor.StreamData{Type: instructor.ErrorStream, Err: err}
+		}
+	}()
+	return out, nil
+}
+
+// SchemaStream runs the wrapped instructor SchemaStream and records the parsed objects and stream data,
+// the golden file is written once both channels end, a write failure is sent as the last ErrorStream data
+func (r *Recorder[Req, Resp]) SchemaStream(ctx context.Context, request *Req, responseType any, response *Resp) (<-chan any, <-chan instructor.StreamData, error) {
+	c, ok := r.ChatInstructor.(instructor.SchemaStreamInstructor[Req, Resp])
+	if !ok {
+		return nil, nil, ErrStreamNotSupported
+	}
+	golden, hash, err := newGolden(SchemaStreamKind, r.Provider(), r.Mode(), request)
+	if err != nil {
+		return nil, nil, err
+	}
+	n := memoryLen(r)
+	itemCh, ch, err := c.SchemaStream(ctx, request, responseType, response)
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		wg      sync.WaitGroup
+		items   []Item
+		itemErr error
+	)
+	itemOut := make(chan any)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(itemOut)
+		for v := range itemCh {
+			if item, err := newItem(v); err != nil {
+				itemErr = errors.Join(itemErr, err)
+			} else {
+				items = append(items, item)
+			}
+			itemOut <- v
+		}
+	}()
+	out := make(chan instructor.StreamData)
+	go func() {
+		defer close(out)
+		r.forward(ch, out, golden)
+		wg.Wait()
+		golden.Items = items
+		err := itemErr
+		if err == nil {
+			err = r.save(hash, golden, response, n)
+		}
+		if err != nil {
+			out <- instructor.StreamData{Type: instructor.ErrorStream, Err: err}
+		}
+	}()
+	return itemOut, out, nil
+}
+
+// SchemaStreamHandler runs the wrapped instructor SchemaStreamHandler without recording
+func (r *Recorder[Req, Resp]) SchemaStreamHandler(ctx context.Context, request *Req, response *Resp) (<-chan instructor.StreamData, error) {
+	c, ok := r.ChatInstructor.(instructor.SchemaStreamInstructor[Req, Resp])
+	if !ok {
+		return nil, ErrStreamNotSupported
+	}
+	return c.SchemaStreamHandler(ctx, request, response)
+}
+
+// forward forwards stream data from ch to out and records it into golden
+func (r *Recorder[Req, Resp]) forward(ch <-chan instructor.StreamData, out chan<- instructor.StreamData, golden *Golden) {
+	for data := range ch {
+		golden.Stream = append(golden.Stream, newChunk(&data))
+		out <- data
+	}
+}
+
+// save records the response and the messages added to memory since it had n messages, then writes the golden file
+func (r *Recorder[Req, Resp]) save(hash string, golden *Golden, response any, n int) error {
+	bs, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	golden.Response = bs
+	golden.Memory = addedMessages(r, n)
+	return saveGolden(r.dir, hash, golden)
+}
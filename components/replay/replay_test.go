@@ -0,0 +1,233 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/bububa/instructor-go"
+	anthropicClt "github.com/bububa/instructor-go/instructors/anthropic"
+	geminiClt "github.com/bububa/instructor-go/instructors/gemini"
+	openaiClt "github.com/bububa/instructor-go/instructors/openai"
+	anthropic "github.com/liushuangls/go-anthropic/v2"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	geminiAPI "google.golang.org/genai"
+
+	"github.com/bububa/atomic-agents/agents"
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/internal/llmtest"
+	"github.com/bububa/atomic-agents/schema"
+	"github.com/bububa/atomic-agents/tools"
+	"github.com/bububa/atomic-agents/tools/calculator"
+)
+
+// startServer starts a stand-in chat completions server answering chat and stream requests
+func startServer(t *testing.T) *llmtest.Server {
+	return llmtest.NewServer(t, func(map[string]any) llmtest.Reply {
+		return llmtest.Reply{Content: `{"chat_message":"hello"}`, Chunks: []string{"Hel", "lo"}}
+	})
+}
+
+func newClient(baseURL string) *openaiClt.Instructor {
+	clt := openai.NewClient(option.WithBaseURL(baseURL), option.WithAPIKey("test"), option.WithMaxRetries(0))
+	return openaiClt.New(&clt, instructor.WithMode(instructor.ModeJSON), instructor.WithMaxRetries(0))
+}
+
+// run runs a chat with a new agent of the client
+func run(t *testing.T, clt instructor.Instructor, model string) (string, *components.LLMResponse) {
+	agent := agents.NewAgent[schema.Input, schema.Output](agents.WithClient(clt), agents.WithModel(model))
+	output := new(schema.Output)
+	apiResp := new(components.LLMResponse)
+	if err := agent.Run(context.Background(), schema.NewInput("hi"), output, apiResp); err != nil {
+		t.Fatalf("run agent failed: %v", err)
+	}
+	return output.ChatMessage, apiResp
+}
+
+// exchange runs a chat and a stream with a new agent of the client
+func exchange(t *testing.T, clt instructor.Instructor) (string, *components.LLMResponse, string, *components.LLMResponse) {
+	out, apiResp := run(t, clt, llmtest.Model)
+	agent := agents.NewAgent[schema.Input, schema.Output](agents.WithClient(clt), agents.WithModel(llmtest.Model))
+	ch, merge, err := agent.Stream(context.Background(), schema.NewInput("stream please"))
+	if err != nil {
+		t.Fatalf("stream agent failed: %v", err)
+	}
+	var sb strings.Builder
+	for data := range ch {
+		if data.Err != nil {
+			t.Fatalf("stream agent failed: %v", data.Err)
+		}
+		sb.WriteString(data.Content)
+	}
+	streamResp := new(components.LLMResponse)
+	merge(streamResp)
+	return out, apiResp, sb.String(), streamResp
+}
+
+func TestRecordReplay(t *testing.T) {
+	srv := startServer(t)
+	dir := t.TempDir()
+	recorder := NewRecorder(newClient(srv.URL), dir)
+	out, resp, streamed, streamResp := exchange(t, recorder)
+	srv.Close()
+	if hits := len(srv.Requests()); out != "hello" || streamed != "Hello" || hits != 2 {
+		t.Fatalf("expect recorded answers from server, got %q, %q after %d hits", out, streamed, hits)
+	}
+
+	// the replayer client points to a closed server, every answer must come from golden files
+	replayer := NewReplayer(newClient(srv.URL), dir)
+	replayOut, replayResp, replayStreamed, replayStreamResp := exchange(t, replayer)
+	if hits := len(srv.Requests()); replayOut != out || replayStreamed != streamed || hits != 2 {
+		t.Errorf("expect replayed answers %q, %q, got %q, %q", out, streamed, replayOut, replayStreamed)
+	}
+	if replayResp.Usage.InputTokens != resp.Usage.InputTokens || replayResp.Usage.InputTokens != 12 ||
+		replayStreamResp.Usage.OutputTokens != streamResp.Usage.OutputTokens || replayStreamResp.Usage.OutputTokens != 5 {
+		t.Errorf("expect replayed usage %+v, %+v, got %+v, %+v", resp.Usage, streamResp.Usage, replayResp.Usage, replayStreamResp.Usage)
+	}
+
+	agent := agents.NewAgent[schema.Input, schema.Output](agents.WithClient(replayer), agents.WithModel(llmtest.Model))
+	if err := agent.Run(context.Background(), schema.NewInput("never recorded"), new(schema.Output), nil); !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("expect not recorded error, got %v", err)
+	}
+}
+
+// recordReplay records a chat of the instructor built by newClient on a server with handler, then replays it
+// with an instructor pointing to the closed server
+func recordReplay[Req any, Resp any](t *testing.T, handler http.HandlerFunc, model string, newClient func(baseURL string) instructor.ChatInstructor[Req, Resp]) {
+	srv := httptest.NewServer(handler)
+	dir := t.TempDir()
+	out, resp := run(t, NewRecorder(newClient(srv.URL), dir), model)
+	srv.Close()
+	if out != "hello" || resp.Usage == nil || resp.Usage.InputTokens != 12 {
+		t.Fatalf("expect recorded answer from server, got %q, %+v", out, resp.Usage)
+	}
+	replayOut, replayResp := run(t, NewReplayer(newClient(srv.URL), dir), model)
+	if replayOut != out || replayResp.Usage == nil || replayResp.Usage.InputTokens != resp.Usage.InputTokens {
+		t.Errorf("expect replayed answer %q, %+v, got %q, %+v", out, resp.Usage, replayOut, replayResp.Usage)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func TestRecordReplayAnthropic(t *testing.T) {
+	recordReplay(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{
+			"id":          "msg_1",
+			"type":        "message",
+			"role":        "assistant",
+			"model":       "claude-test",
+			"content":     []map[string]any{{"type": "text", "text": `{"chat_message":"hello"}`}},
+			"stop_reason": "end_turn",
+			"usage":       map[string]any{"input_tokens": 12, "output_tokens": 5},
+		})
+	}, "claude-test", func(baseURL string) instructor.ChatInstructor[anthropic.MessagesRequest, anthropic.MessagesResponse] {
+		return anthropicClt.New(anthropic.NewClient("test", anthropic.WithBaseURL(baseURL)), instructor.WithMode(instructor.ModeJSON), instructor.WithMaxRetries(0))
+	})
+}
+
+func TestRecordReplayGemini(t *testing.T) {
+	recordReplay(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{
+			"candidates": []map[string]any{{
+				"content":      map[string]any{"role": "model", "parts": []map[string]any{{"text": `{"chat_message":"hello"}`}}},
+				"finishReason": "STOP",
+			}},
+			"usageMetadata": map[string]any{"promptTokenCount": 12, "candidatesTokenCount": 5, "totalTokenCount": 17},
+		})
+	}, "gemini-test", func(baseURL string) instructor.ChatInstructor[geminiClt.Request, geminiAPI.GenerateContentResponse] {
+		clt, err := geminiAPI.NewClient(context.Background(), &geminiAPI.ClientConfig{
+			APIKey:      "test",
+			Backend:     geminiAPI.BackendGeminiAPI,
+			HTTPOptions: geminiAPI.HTTPOptions{BaseURL: baseURL},
+		})
+		if err != nil {
+			t.Fatalf("new gemini client failed: %v", err)
+		}
+		return geminiClt.New(clt, instructor.WithMode(instructor.ModeJSON), instructor.WithMaxRetries(0))
+	})
+}
+
+func TestReplayErrorClass(t *testing.T) {
+	srv := llmtest.NewServer(t, func(map[string]any) llmtest.Reply {
+		return llmtest.Reply{Status: http.StatusTooManyRequests}
+	})
+	dir := t.TempDir()
+	agent := agents.NewAgent[schema.Input, schema.Output](agents.WithClient(NewRecorder(newClient(srv.URL), dir)), agents.WithModel(llmtest.Model))
+	recordErr := agent.Run(context.Background(), schema.NewInput("hi"), new(schema.Output), nil)
+	if agents.ClassifyError(recordErr) != agents.RateLimitError {
+		t.Fatalf("expect rate limit error from server, got %v", recordErr)
+	}
+	srv.Close()
+
+	agent = agents.NewAgent[schema.Input, schema.Output](agents.WithClient(NewReplayer(newClient(srv.URL), dir)), agents.WithModel(llmtest.Model))
+	replayErr := agent.Run(context.Background(), schema.NewInput("hi"), new(schema.Output), nil)
+	var replayed *Error
+	if !errors.As(replayErr, &replayed) || replayed.StatusCode != http.StatusTooManyRequests || agents.ClassifyError(replayErr) != agents.RateLimitError {
+		t.Errorf("expect replayed rate limit error, got %v", replayErr)
+	}
+	if replayErr.Error() != recordErr.Error() {
+		t.Errorf("expect replayed error %q, got %q", recordErr, replayErr)
+	}
+
+	for kind, target := range map[ErrorKind]error{CanceledError: context.Canceled, TimeoutError: context.DeadlineExceeded} {
+		if err := newError("failed", kind, 0); !errors.Is(err, target) {
+			t.Errorf("expect %s error is %v", kind, target)
+		}
+	}
+	if err := newError("refused", NetworkError, 0); agents.ClassifyError(err) != agents.NetworkError {
+		t.Errorf("expect replayed network error classified, got %v", agents.ClassifyError(err))
+	}
+}
+
+func TestRecordReplayToolCalling(t *testing.T) {
+	srv := llmtest.NewServer(t, func(body map[string]any) llmtest.Reply {
+		messages := llmtest.Messages(body)
+		if messages[len(messages)-1].(map[string]any)["role"] != "tool" {
+			return llmtest.Reply{ToolCalls: []llmtest.ToolCall{{ID: "call_1", Name: "CalculatorTool", Arguments: `{"expression":"2 + 3"}`}}}
+		}
+		return llmtest.Reply{Content: `{"chat_message":"5"}`}
+	})
+	dir := t.TempDir()
+	// runs an agent and a tool agent with native tools, their loops send 2 requests each
+	runAll := func(clt instructor.Instructor) []string {
+		fn := tools.NewFunction[calculator.Input](calculator.New())
+		agent := agents.NewAgent[schema.Input, schema.Output](agents.WithClient(clt), agents.WithModel(llmtest.Model), agents.WithTools(fn))
+		toolAgent := agents.NewToolAgent[schema.Input, schema.Output, schema.Output](agents.WithClient(clt), agents.WithModel(llmtest.Model)).SetFunctions(fn)
+		var outputs []string
+		for _, a := range []agents.TypeableAgent[schema.Input, schema.Output]{agent, toolAgent} {
+			output := new(schema.Output)
+			apiResp := new(components.LLMResponse)
+			if err := a.Run(context.Background(), schema.NewInput("2 + 3 = ?"), output, apiResp); err != nil {
+				t.Fatalf("run agent failed: %v", err)
+			}
+			if apiResp.Usage == nil || apiResp.Usage.InputTokens != 24 {
+				t.Errorf("expect usage summed across the loop, got %+v", apiResp.Usage)
+			}
+			outputs = append(outputs, output.ChatMessage)
+		}
+		return outputs
+	}
+	recorded := runAll(NewRecorder(newClient(srv.URL), dir))
+	srv.Close()
+	if hits := len(srv.Requests()); recorded[0] != "5" || recorded[1] != "5" || hits != 4 {
+		t.Fatalf("expect tool calling loops through the recorder, got %v after %d requests", recorded, hits)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 4 {
+		t.Errorf("expect every tool calling request recorded, got %d golden files", len(entries))
+	}
+
+	// the replayer client points to a closed server, every request of the loops must come from golden files
+	replayed := runAll(NewReplayer(newClient(srv.URL), dir))
+	if hits := len(srv.Requests()); replayed[0] != "5" || replayed[1] != "5" || hits != 4 {
+		t.Errorf("expect replayed tool calling loops, got %v after %d requests", replayed, hits)
+	}
+}
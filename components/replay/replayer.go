@@ -0,0 +1,181 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	"github.com/bububa/instructor-go"
+	geminiClt "github.com/bububa/instructor-go/instructors/gemini"
+	anthropic "github.com/liushuangls/go-anthropic/v2"
+	"github.com/openai/openai-go"
+	geminiAPI "google.golang.org/genai"
+
+	"github.com/bububa/atomic-agents/components"
+)
+
+// Replayer serves exchanges recorded by a Recorder from golden files in dir by request hash.
+// The wrapped instructor only provides provider, mode, encoders and memory, it is never called,
+// so it could be built with an unreachable client.
+type Replayer[Req any, Resp any] struct {
+	instructor.ChatInstructor[Req, Resp]
+	dir string
+}
+
+var (
+	_ instructor.ChatInstructor[openai.ChatCompletionNewParams, openai.ChatCompletion]         = (*Replayer[openai.ChatCompletionNewParams, openai.ChatCompletion])(nil)
+	_ instructor.StreamInstructor[openai.ChatCompletionNewParams, openai.ChatCompletion]       = (*Replayer[openai.ChatCompletionNewParams, openai.ChatCompletion])(nil)
+	_ instructor.SchemaStreamInstructor[openai.ChatCompletionNewParams, openai.ChatCompletion] = (*Replayer[openai.ChatCompletionNewParams, openai.ChatCompletion])(nil)
+	_ instructor.SchemaStreamInstructor[anthropic.MessagesRequest, anthropic.MessagesResponse] = (*Replayer[anthropic.MessagesRequest, anthropic.MessagesResponse])(nil)
+	_ instructor.SchemaStreamInstructor[geminiClt.Request, geminiAPI.GenerateContentResponse]  = (*Replayer[geminiClt.Request, geminiAPI.GenerateContentResponse])(nil)
+	_ components.Rewrapper                                                                     = (*Replayer[openai.ChatCompletionNewParams, openai.ChatCompletion])(nil)
+	_ components.RoundTripper                                                                  = (*Replayer[openai.ChatCompletionNewParams, openai.ChatCompletion])(nil)
+)
+
+// NewReplayer returns a Replayer reading golden files from dir, clt must be configured with the provider and mode
+// of the recorded instructor so that request hashes match
+func NewReplayer[Req any, Resp any](clt instructor.ChatInstructor[Req, Resp], dir string) *Replayer[Req, Resp] {
+	return &Replayer[Req, Resp]{
+		ChatInstructor: clt,
+		dir:            dir,
+	}
+}
+
+// Dir returns the golden files directory
+func (r *Replayer[Req, Resp]) Dir() string {
+	return r.dir
+}
+
+// Unwrap returns the wrapped instructor, native tool calling loops of agents build their requests with it,
+// the requests are served by RoundTrip
+func (r *Replayer[Req, Resp]) Unwrap() instructor.Instructor {
+	return r.ChatInstructor
+}
+
+// Rewrap returns a copy of the Replayer wrapping clt, false if clt is not a chat instructor of the same request/response types
+func (r *Replayer[Req, Resp]) Rewrap(clt instructor.Instructor) (instructor.Instructor, bool) {
+	c, ok := clt.(instructor.ChatInstructor[Req, Resp])
+	if !ok {
+		return nil, false
+	}
+	return NewReplayer(c, r.dir), true
+}
+
+// RoundTrip serves a provider request sent outside of the instructor methods, like the requests of agents native tool calling loops,
+// from its golden file without calling call, returns the recorded error or ErrNotRecorded
+func (r *Replayer[Req, Resp]) RoundTrip(_ context.Context, request any, response any, _ func(context.Context) error) error {
+	golden, err := r.load(RoundTripKind, request, response)
+	if err != nil {
+		return err
+	}
+	return golden.Err()
+}
+
+// SetMemory set memory on a copy of the wrapped instructor, so that session copies of the Replayer don't share memory
+func (r *Replayer[Req, Resp]) SetMemory(memory *instructor.Memory) {
+	r.ChatInstructor = components.WithMemory(r.ChatInstructor, memory)
+}
+
+// Chat decodes the recorded output and response, returns the recorded error or ErrNotRecorded
+func (r *Replayer[Req, Resp]) Chat(ctx context.Context, request *Req, responseType any, response *Resp) error {
+	golden, err := r.load(ChatKind, request, response)
+	if err != nil {
+		return err
+	}
+	if err := golden.Err(); err != nil {
+		return err
+	}
+	return decodeOutput(golden.Output, responseType)
+}
+
+// Stream sends the recorded stream data
+func (r *Replayer[Req, Resp]) Stream(ctx context.Context, request *Req, responseType any, response *Resp) (<-chan instructor.StreamData, error) {
+	golden, err := r.load(StreamKind, request, response)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan instructor.StreamData)
+	go func() {
+		defer close(out)
+		replayStream(ctx, golden.Stream, out)
+	}()
+	return out, nil
+}
+
+// SchemaStream sends the recorded parsed objects, decoded into new instances of responseType, and stream data
+func (r *Replayer[Req, Resp]) SchemaStream(ctx context.Context, request *Req, responseType any, response *Resp) (<-chan any, <-chan instructor.StreamData, error) {
+	golden, err := r.load(SchemaStreamKind, request, response)
+	if err != nil {
+		return nil, nil, err
+	}
+	items := make([]any, 0, len(golden.Items))
+	for idx := range golden.Items {
+		v, err := golden.Items[idx].value(responseType)
+		if err != nil {
+			return nil, nil, err
+		}
+		items = append(items, v)
+	}
+	itemOut := make(chan any)
+	go func() {
+		defer close(itemOut)
+		for _, v := range items {
+			select {
+			case <-ctx.Done():
+				return
+			case itemOut <- v:
+			}
+		}
+	}()
+	out := make(chan instructor.StreamData)
+	go func() {
+		defer close(out)
+		replayStream(ctx, golden.Stream, out)
+	}()
+	return itemOut, out, nil
+}
+
+// SchemaStreamHandler sends the stream data recorded by SchemaStream
+func (r *Replayer[Req, Resp]) SchemaStreamHandler(ctx context.Context, request *Req, response *Resp) (<-chan instructor.StreamData, error) {
+	golden, err := r.load(SchemaStreamKind, request, response)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan instructor.StreamData)
+	go func() {
+		defer close(out)
+		replayStream(ctx, golden.Stream, out)
+	}()
+	return out, nil
+}
+
+// load loads the golden file of the request, decodes the recorded response and adds the recorded messages to memory
+func (r *Replayer[Req, Resp]) load(kind Kind, request any, response any) (*Golden, error) {
+	_, hash, err := newGolden(kind, r.Provider(), r.Mode(), request)
+	if err != nil {
+		return nil, err
+	}
+	golden, err := loadGolden(r.dir, hash)
+	if err != nil {
+		return nil, err
+	}
+	if v := reflect.ValueOf(response); len(golden.Response) > 0 && v.Kind() == reflect.Pointer && !v.IsNil() {
+		if err := json.Unmarshal(golden.Response, response); err != nil {
+			return nil, err
+		}
+	}
+	if memory := r.Memory(); memory != nil && len(golden.Memory) > 0 {
+		memory.Add(golden.Memory...)
+	}
+	return golden, nil
+}
+
+func replayStream(ctx context.Context, chunks []Chunk, out chan<- instructor.StreamData) {
+	for idx := range chunks {
+		select {
+		case <-ctx.Done():
+			return
+		case out <- chunks[idx].StreamData():
+		}
+	}
+}
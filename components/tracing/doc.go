@@ -0,0 +1,4 @@
+// Package tracing is the optional tracing of agents, chains, tools, embedders and vectordb searches.
+// Spans are started by the Tracer set by SetTracer, which is a no-op unless configured, so that agents and tools
+// never depend on a tracing SDK. The otel subpackage implements Tracer with OpenTelemetry.
+package tracing
@@ -0,0 +1,74 @@
+// Package otel implements the tracing.Tracer of atomic agents with OpenTelemetry, install it with
+//
+//	tracing.SetTracer(otel.New(nil))
+package otel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/bububa/atomic-agents/components/tracing"
+)
+
+// Tracer is a tracing.Tracer creating OpenTelemetry spans
+type Tracer struct {
+	provider trace.TracerProvider
+}
+
+var _ tracing.Tracer = (*Tracer)(nil)
+
+// New returns a new Tracer creating spans with the TracerProvider, the otel global TracerProvider is used if nil
+func New(tp trace.TracerProvider) *Tracer {
+	return &Tracer{provider: tp}
+}
+
+// Start starts an OpenTelemetry span as a child of the span in context
+func (t *Tracer) Start(ctx context.Context, name string, attrs ...tracing.Attribute) (context.Context, tracing.Recorder) {
+	tp := t.provider
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	ctx, span := tp.Tracer(tracing.InstrumentationName).Start(ctx, name, trace.WithAttributes(convert(attrs)...))
+	return ctx, &recorder{span: span}
+}
+
+type recorder struct {
+	span trace.Span
+}
+
+func (r *recorder) IsRecording() bool {
+	return r.span.IsRecording()
+}
+
+func (r *recorder) SetAttributes(attrs ...tracing.Attribute) {
+	r.span.SetAttributes(convert(attrs)...)
+}
+
+func (r *recorder) SetError(err error) {
+	r.span.RecordError(err)
+	r.span.SetStatus(codes.Error, err.Error())
+}
+
+func (r *recorder) End() {
+	r.span.End()
+}
+
+func convert(attrs []tracing.Attribute) []attribute.KeyValue {
+	ret := make([]attribute.KeyValue, 0, len(attrs))
+	for _, v := range attrs {
+		key := attribute.Key(v.Key)
+		switch val := v.Value.(type) {
+		case string:
+			ret = append(ret, key.String(val))
+		case int64:
+			ret = append(ret, key.Int64(val))
+		case float64:
+			ret = append(ret, key.Float64(val))
+		}
+	}
+	return ret
+}
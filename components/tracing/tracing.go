@@ -0,0 +1,173 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bububa/atomic-agents/components"
+)
+
+// InstrumentationName is the name of the atomic agents tracer
+const InstrumentationName = "github.com/bububa/atomic-agents"
+
+// Span names
+const (
+	AgentRunSpan       = "agent.run"
+	ChainRunSpan       = "chain.run"
+	ChainStreamSpan    = "chain.stream"
+	ChainStepSpan      = "chain.step"
+	ToolCallSpan       = "tool.call"
+	EmbedderEmbedSpan  = "embedder.embed"
+	VectorDBSearchSpan = "vectordb.search"
+)
+
+// Attribute keys, gen_ai keys follow the OpenTelemetry GenAI semantic conventions
+const (
+	ProviderKey     = Key("gen_ai.system")
+	RequestModelKey = Key("gen_ai.request.model")
+	ModelKey        = Key("gen_ai.response.model")
+	InputTokensKey  = Key("gen_ai.usage.input_tokens")
+	OutputTokensKey = Key("gen_ai.usage.output_tokens")
+	CachedTokensKey = Key("gen_ai.usage.cached_tokens")
+	CostKey         = Key("atomic_agents.usage.cost")
+	AgentNameKey    = Key("atomic_agents.agent.name")
+	ChainNameKey    = Key("atomic_agents.chain.name")
+	StepKey         = Key("atomic_agents.chain.step")
+	ToolNameKey     = Key("atomic_agents.tool.name")
+	InputsKey       = Key("atomic_agents.embedder.inputs")
+	RecordsKey      = Key("atomic_agents.vectordb.records")
+	LatencyKey      = Key("atomic_agents.latency_ms")
+)
+
+// Key is a span attribute key
+type Key string
+
+// Attribute is a span attribute, the value is a string, int64 or float64
+type Attribute struct {
+	Key   Key
+	Value any
+}
+
+// String returns a string attribute of the key
+func (k Key) String(v string) Attribute {
+	return Attribute{Key: k, Value: v}
+}
+
+// Int returns an integer attribute of the key
+func (k Key) Int(v int) Attribute {
+	return Attribute{Key: k, Value: int64(v)}
+}
+
+// Int64 returns an integer attribute of the key
+func (k Key) Int64(v int64) Attribute {
+	return Attribute{Key: k, Value: v}
+}
+
+// Float64 returns a float attribute of the key
+func (k Key) Float64(v float64) Attribute {
+	return Attribute{Key: k, Value: v}
+}
+
+// Tracer starts spans as children of the span in context
+type Tracer interface {
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Recorder)
+}
+
+// Recorder is a started span of a Tracer
+type Recorder interface {
+	// IsRecording returns false if attributes are discarded
+	IsRecording() bool
+	SetAttributes(attrs ...Attribute)
+	// SetError records the error and marks the span failed
+	SetError(err error)
+	End()
+}
+
+var (
+	mu     sync.RWMutex
+	tracer Tracer = noopTracer{}
+)
+
+// SetTracer set the Tracer starting atomic agents spans, spans are no-op if nil
+func SetTracer(t Tracer) {
+	if t == nil {
+		t = noopTracer{}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	tracer = t
+}
+
+func currentTracer() Tracer {
+	mu.RLock()
+	defer mu.RUnlock()
+	return tracer
+}
+
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, _ string, _ ...Attribute) (context.Context, Recorder) {
+	return ctx, noopRecorder{}
+}
+
+type noopRecorder struct{}
+
+func (noopRecorder) IsRecording() bool          { return false }
+func (noopRecorder) SetAttributes(...Attribute) {}
+func (noopRecorder) SetError(error)             {}
+func (noopRecorder) End()                       {}
+
+// Span is a trace span recording latency, usage and error on end
+type Span struct {
+	Recorder
+	start time.Time
+}
+
+// Start starts a span as a child of the span in context
+func Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	ctx, recorder := currentTracer().Start(ctx, name, attrs...)
+	return ctx, &Span{Recorder: recorder, start: time.Now()}
+}
+
+// SetUsage records token usage and cost
+func (s *Span) SetUsage(usage *components.LLMUsage) {
+	if usage == nil || !s.IsRecording() {
+		return
+	}
+	s.SetAttributes(
+		InputTokensKey.Int64(usage.InputTokens),
+		OutputTokensKey.Int64(usage.OutputTokens),
+	)
+	if usage.CachedTokens > 0 {
+		s.SetAttributes(CachedTokensKey.Int64(usage.CachedTokens))
+	}
+	if usage.Cost > 0 {
+		s.SetAttributes(CostKey.Float64(usage.Cost))
+	}
+}
+
+// SetResponse records the provider, model and usage of the response
+func (s *Span) SetResponse(resp *components.LLMResponse) {
+	if resp == nil || !s.IsRecording() {
+		return
+	}
+	if resp.Provider != "" {
+		s.SetAttributes(ProviderKey.String(string(resp.Provider)))
+	}
+	if resp.Model != "" {
+		s.SetAttributes(ModelKey.String(resp.Model))
+	}
+	s.SetUsage(resp.Usage)
+}
+
+// EndErr records the latency and the error if not nil, and ends the span
+func (s *Span) EndErr(err error) {
+	if s.IsRecording() {
+		s.SetAttributes(LatencyKey.Int64(time.Since(s.start).Milliseconds()))
+	}
+	if err != nil {
+		s.SetError(err)
+	}
+	s.End()
+}
@@ -16,3 +16,8 @@ type Engine interface {
 	Insert(context.Context, string, ...Record) error
 	Search(context.Context, []float64, ...SearchOption) ([]Record, error)
 }
+
+// Deleter is implemented by engines deleting records of a collection by ID
+type Deleter interface {
+	Delete(ctx context.Context, collection string, ids ...string) error
+}
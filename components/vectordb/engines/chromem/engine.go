@@ -13,7 +13,10 @@ type Engine struct {
 	vectordb.Options
 }
 
-var _ vectordb.Engine = (*Engine)(nil)
+var (
+	_ vectordb.Engine  = (*Engine)(nil)
+	_ vectordb.Deleter = (*Engine)(nil)
+)
 
 func New(db *chromem.DB, opts ...vectordb.Option) *Engine {
 	ret := &Engine{
@@ -58,6 +61,18 @@ func (e *Engine) Insert(ctx context.Context, collectionName string, records ...v
 	return nil
 }
 
+// Delete removes the documents with the ids from the collection
+func (e *Engine) Delete(ctx context.Context, collectionName string, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	col, err := e.Collection(ctx, collectionName)
+	if err != nil {
+		return err
+	}
+	return col.Delete(ctx, nil, nil, ids...)
+}
+
 // Search performs vector similarity search on a collection.
 func (e *Engine) Search(ctx context.Context, vectors []float64, opts ...vectordb.SearchOption) ([]vectordb.Record, error) {
 	var option vectordb.SearchOptions
@@ -86,7 +101,7 @@ func (e *Engine) Search(ctx context.Context, vectors []float64, opts ...vectordb
 		return nil, err
 	}
 	// Convert results
-	searchResults := make([]vectordb.Record, len(results))
+	searchResults := make([]vectordb.Record, 0, len(results))
 	for _, result := range results {
 		var rec vectordb.Record
 		resultToRecord(&result, &rec)
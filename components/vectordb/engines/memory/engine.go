@@ -4,6 +4,7 @@ import (
 	"context"
 	"math"
 	"runtime"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -20,7 +21,10 @@ type Engine struct {
 	vectordb.Options
 }
 
-var _ vectordb.Engine = (*Engine)(nil)
+var (
+	_ vectordb.Engine  = (*Engine)(nil)
+	_ vectordb.Deleter = (*Engine)(nil)
+)
 
 // Collection represents a named set of records with a defined schema.
 // It's the basic unit of organization in the memory database.
@@ -37,6 +41,19 @@ func (c *Collection) AddRecords(records ...vectordb.Record) {
 	c.mu.Unlock()
 }
 
+// DeleteRecords removes the records with the ids
+func (c *Collection) DeleteRecords(ids ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	records := make([]vectordb.Record, 0, len(c.records))
+	for _, record := range c.records {
+		if !slices.Contains(ids, record.ID) {
+			records = append(records, record)
+		}
+	}
+	c.records = records
+}
+
 func (c *Collection) Records() []vectordb.Record {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -94,6 +111,16 @@ func (e *Engine) Insert(ctx context.Context, collectionName string, records ...v
 	return nil
 }
 
+// Delete removes the records with the ids from the collection
+func (e *Engine) Delete(ctx context.Context, collectionName string, ids ...string) error {
+	col, err := e.Collection(ctx, collectionName)
+	if err != nil {
+		return err
+	}
+	col.DeleteRecords(ids...)
+	return nil
+}
+
 func (e *Engine) Search(ctx context.Context, vectors []float64, opts ...vectordb.SearchOption) ([]vectordb.Record, error) {
 	var option vectordb.SearchOptions
 	for _, opt := range opts {
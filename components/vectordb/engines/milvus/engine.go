@@ -15,7 +15,10 @@ type Engine struct {
 	vectordb.Options
 }
 
-var _ vectordb.Engine = (*Engine)(nil)
+var (
+	_ vectordb.Engine  = (*Engine)(nil)
+	_ vectordb.Deleter = (*Engine)(nil)
+)
 
 func New(db milvusClient.Client, opts ...vectordb.Option) *Engine {
 	ret := &Engine{
@@ -94,6 +97,18 @@ func (e *Engine) Insert(ctx context.Context, collectionName string, records ...v
 	return err
 }
 
+// Delete removes the records with the ids from the collection
+func (e *Engine) Delete(ctx context.Context, collectionName string, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	bs, err := json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+	return e.db.Delete(ctx, collectionName, "", "id in "+string(bs))
+}
+
 // Search performs vector similarity search on a collection.
 func (e *Engine) Search(ctx context.Context, vectors []float64, opts ...vectordb.SearchOption) ([]vectordb.Record, error) {
 	var option vectordb.SearchOptions
@@ -116,7 +131,7 @@ func (e *Engine) Search(ctx context.Context, vectors []float64, opts ...vectordb
 	if err != nil {
 		return nil, err
 	}
-	searchResults := make([]vectordb.Record, len(results))
+	searchResults := make([]vectordb.Record, 0, len(results))
 	for _, result := range results {
 		var record vectordb.Record
 		searchResultToRecord(&result, &record)
@@ -0,0 +1,163 @@
+// Package llmtest provides a stand-in OpenAI compatible chat completions server for tests
+package llmtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// Model is the model of every completion
+const Model = "gpt-test"
+
+// ToolCall is a tool call of an assistant message
+type ToolCall struct {
+	ID        string
+	Name      string
+	Arguments string
+}
+
+// Reply is the reply of the Server to a request
+type Reply struct {
+	// Content is the assistant message content, stream requests get it in Chunks if set
+	Content string
+	// Chunks are the content deltas of stream replies
+	Chunks []string
+	// ToolCalls are the tool calls of the assistant message
+	ToolCalls []ToolCall
+	// InputTokens and OutputTokens are the usage, 12 and 5 if zero
+	InputTokens  int
+	OutputTokens int
+	// Status replies an OpenAI error with the HTTP status if not zero
+	Status int
+}
+
+// Text returns a reply func answering content to every request
+func Text(content string) func(map[string]any) Reply {
+	return func(map[string]any) Reply {
+		return Reply{Content: content}
+	}
+}
+
+// Server is a stand-in chat completions server recording the request bodies, it serves every path
+type Server struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []map[string]any
+}
+
+// NewServer starts a Server answering the reply of every request body, it is closed with the test
+func NewServer(t testing.TB, reply func(body map[string]any) Reply) *Server {
+	s := new(Server)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := make(map[string]any)
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request body failed: %v", err)
+			return
+		}
+		s.mu.Lock()
+		s.requests = append(s.requests, body)
+		s.mu.Unlock()
+		ret := reply(body)
+		if ret.InputTokens == 0 && ret.OutputTokens == 0 {
+			ret.InputTokens, ret.OutputTokens = 12, 5
+		}
+		usage := map[string]any{
+			"prompt_tokens":     ret.InputTokens,
+			"completion_tokens": ret.OutputTokens,
+			"total_tokens":      ret.InputTokens + ret.OutputTokens,
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if ret.Status != 0 {
+			w.WriteHeader(ret.Status)
+			json.NewEncoder(w).Encode(map[string]any{
+				"error": map[string]any{"message": http.StatusText(ret.Status), "type": "server_error"},
+			})
+			return
+		}
+		if stream, _ := body["stream"].(bool); stream {
+			writeStream(w, ret, usage)
+			return
+		}
+		message := map[string]any{"role": "assistant", "content": ret.Content}
+		finishReason := "stop"
+		if len(ret.ToolCalls) > 0 {
+			calls := make([]map[string]any, 0, len(ret.ToolCalls))
+			for _, call := range ret.ToolCalls {
+				calls = append(calls, map[string]any{
+					"id":       call.ID,
+					"type":     "function",
+					"function": map[string]any{"name": call.Name, "arguments": call.Arguments},
+				})
+			}
+			message["tool_calls"] = calls
+			finishReason = "tool_calls"
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":      "chatcmpl-1",
+			"object":  "chat.completion",
+			"created": 1700000000,
+			"model":   Model,
+			"choices": []map[string]any{
+				{"index": 0, "finish_reason": finishReason, "message": message},
+			},
+			"usage": usage,
+		})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// writeStream writes the reply as completion chunks followed by a usage chunk
+func writeStream(w http.ResponseWriter, ret Reply, usage map[string]any) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	chunks := ret.Chunks
+	if chunks == nil {
+		chunks = []string{ret.Content}
+	}
+	write := func(choices []map[string]any, usage map[string]any) {
+		chunk := map[string]any{
+			"id":      "chatcmpl-2",
+			"object":  "chat.completion.chunk",
+			"created": 1700000000,
+			"model":   Model,
+			"choices": choices,
+		}
+		if usage != nil {
+			chunk["usage"] = usage
+		}
+		bs, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\n\n", bs)
+	}
+	for _, content := range chunks {
+		write([]map[string]any{{"index": 0, "delta": map[string]any{"content": content}}}, nil)
+	}
+	write([]map[string]any{}, usage)
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+// Requests returns the recorded request bodies
+func (s *Server) Requests() []map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]map[string]any(nil), s.requests...)
+}
+
+// Last returns the last recorded request body, nil if none
+func (s *Server) Last() map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		return nil
+	}
+	return s.requests[len(s.requests)-1]
+}
+
+// Messages returns the messages of a request body
+func Messages(body map[string]any) []any {
+	messages, _ := body["messages"].([]any)
+	return messages
+}
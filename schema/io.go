@@ -1,7 +1,5 @@
 package schema
 
-import "github.com/bububa/mdencoder"
-
 // Input implements BaseAgentInputSchema
 // This schema represents the input from the user to the AI agent.
 type Input struct {
@@ -18,8 +16,7 @@ func NewInput(chatMessage string) *Input {
 }
 
 func (i Input) String() string {
-	bs, _ := mdencoder.Marshal(i)
-	return string(bs)
+	return Markdown(i)
 }
 
 // CreateeInput returns a BaseAgentInput
@@ -0,0 +1,164 @@
+package schema
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/bububa/mdencoder"
+)
+
+var (
+	markdownMarshalerType = reflect.TypeFor[mdencoder.Marshaler]()
+	stringerType          = reflect.TypeFor[fmt.Stringer]()
+	anyType               = reflect.TypeFor[any]()
+)
+
+// Markdown encodes the exported fields of v to markdown.
+// Unexported fields, like the attachement and extra body of Base, are skipped, mdencoder can't read them.
+func Markdown(v any) string {
+	val := reflect.ValueOf(v)
+	if !val.IsValid() {
+		return ""
+	}
+	bs, _ := mdencoder.Marshal(exportedValue(val).Interface())
+	return string(bs)
+}
+
+// exportedType returns the type holding the exported fields of t, embedded structs are flattened as mdencoder renders them.
+// visiting guards recursive types, which are left to interfaces and converted value by value.
+func exportedType(t reflect.Type, visiting map[reflect.Type]bool) reflect.Type {
+	if t.Implements(markdownMarshalerType) {
+		return t
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		if t.Elem().Kind() == reflect.Struct && t.Implements(stringerType) && !hasExportedFields(t.Elem()) {
+			return reflect.TypeFor[string]()
+		}
+		return reflect.PointerTo(exportedType(t.Elem(), visiting))
+	case reflect.Slice, reflect.Array:
+		return reflect.SliceOf(exportedType(t.Elem(), visiting))
+	case reflect.Map:
+		return reflect.MapOf(t.Key(), exportedType(t.Elem(), visiting))
+	case reflect.Struct:
+		if !hasExportedFields(t) && t.Implements(stringerType) {
+			return reflect.TypeFor[string]()
+		}
+		if visiting[t] {
+			return anyType
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+		var (
+			fields []reflect.StructField
+			names  = make(map[string]struct{})
+		)
+		for _, field := range exportedFields(t) {
+			if _, ok := names[field.Name]; ok {
+				continue
+			}
+			names[field.Name] = struct{}{}
+			fields = append(fields, reflect.StructField{
+				Name: field.Name,
+				Type: exportedType(field.Type, visiting),
+				Tag:  field.Tag,
+			})
+		}
+		return reflect.StructOf(fields)
+	}
+	return t
+}
+
+// exportedValue copies the exported fields of val into a value of its exportedType
+func exportedValue(val reflect.Value) reflect.Value {
+	typ := exportedType(val.Type(), make(map[reflect.Type]bool))
+	dst := reflect.New(typ).Elem()
+	copyExported(dst, val)
+	return dst
+}
+
+func copyExported(dst reflect.Value, src reflect.Value) {
+	if !src.IsValid() || (src.Kind() == reflect.Pointer || src.Kind() == reflect.Interface) && src.IsNil() {
+		return
+	}
+	switch {
+	case dst.Type() == src.Type():
+		dst.Set(src)
+		return
+	case dst.Kind() == reflect.Interface:
+		dst.Set(exportedValue(src))
+		return
+	case dst.Kind() == reflect.String && src.Kind() != reflect.String:
+		// structs without exported fields like time.Time are rendered by their String method
+		dst.SetString(fmt.Sprint(src.Interface()))
+		return
+	}
+	switch src.Kind() {
+	case reflect.Interface:
+		copyExported(dst, src.Elem())
+	case reflect.Pointer:
+		elem := reflect.New(dst.Type().Elem())
+		copyExported(elem.Elem(), src.Elem())
+		dst.Set(elem)
+	case reflect.Slice, reflect.Array:
+		slice := reflect.MakeSlice(dst.Type(), src.Len(), src.Len())
+		for i := range src.Len() {
+			copyExported(slice.Index(i), src.Index(i))
+		}
+		dst.Set(slice)
+	case reflect.Map:
+		m := reflect.MakeMapWithSize(dst.Type(), src.Len())
+		iter := src.MapRange()
+		for iter.Next() {
+			v := reflect.New(dst.Type().Elem()).Elem()
+			copyExported(v, iter.Value())
+			m.SetMapIndex(iter.Key(), v)
+		}
+		dst.Set(m)
+	case reflect.Struct:
+		for i := range dst.NumField() {
+			copyExported(dst.Field(i), fieldByName(src, dst.Type().Field(i).Name))
+		}
+	default:
+		dst.Set(src.Convert(dst.Type()))
+	}
+}
+
+// fieldByName returns the exported field of the struct, promoted fields of nil embedded pointers are zero
+func fieldByName(val reflect.Value, name string) reflect.Value {
+	field, ok := val.Type().FieldByName(name)
+	if !ok {
+		return reflect.Value{}
+	}
+	v, err := val.FieldByIndexErr(field.Index)
+	if err != nil {
+		return reflect.Zero(field.Type)
+	}
+	return v
+}
+
+// exportedFields returns the exported fields of t, promoting the fields of embedded structs in declaration order
+func exportedFields(t reflect.Type) []reflect.StructField {
+	var fields []reflect.StructField
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if field.Anonymous {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct && !embedded.Implements(markdownMarshalerType) {
+				fields = append(fields, exportedFields(embedded)...)
+				continue
+			}
+		}
+		if field.IsExported() {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+func hasExportedFields(t reflect.Type) bool {
+	return len(exportedFields(t)) > 0
+}
@@ -0,0 +1,51 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type markdownNode struct {
+	Name     string         `json:"name"`
+	Children []markdownNode `json:"children,omitempty"`
+}
+
+type markdownSchema struct {
+	Base
+	Title   string         `json:"title" jsonschema:"title=title"`
+	Created time.Time      `json:"created"`
+	Tree    *markdownNode  `json:"tree"`
+	Extra   map[string]any `json:"extra"`
+	private string
+}
+
+func TestStringify(t *testing.T) {
+	input := NewInput("hi")
+	input.SetExtraBody(map[string]any{"num_ctx": 4096})
+	input.SetAttachement(&Attachement{ImageURLs: []string{"https://example.com/a.png"}})
+	input.chunks = []Schema{NewInput("chunk")}
+	if got := Stringify(input); !strings.Contains(got, "hi") || strings.Contains(got, "num_ctx") || strings.Contains(got, "example.com") || strings.Contains(got, "chunk") {
+		t.Errorf("expect the chat message without unexported fields, got %q", got)
+	}
+
+	s := markdownSchema{
+		Title:   "report",
+		Created: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC),
+		Tree:    &markdownNode{Name: "root", Children: []markdownNode{{Name: "leaf"}}},
+		Extra:   map[string]any{"nested": markdownNode{Name: "inner"}},
+		private: "secret",
+	}
+	s.SetExtraBody(map[string]any{"hidden": true})
+	got := Markdown(s)
+	for _, want := range []string{"report", "2025-01-02", "root", "leaf", "inner"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expect %q in markdown, got %q", want, got)
+		}
+	}
+	for _, unwanted := range []string{"secret", "hidden"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("expect no %q in markdown, got %q", unwanted, got)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+// Package server exposes registered agents behind OpenAI compatible /v1/chat/completions endpoints, so that frontends
+// and OpenAI SDK clients talk to atomic-agents pipelines directly. The model of a request is the registered agent name,
+// the last user message is decoded into the agent input. Streamable agents stream server-sent events, session IDs of
+// requests are mapped to the session memory of agents with a memory store with memory.WithSessionID, requests without
+// session ID run on a memory.Isolation starting with their prior messages, and the input and output JSON schemas of each
+// agent are served for discovery.
+package server
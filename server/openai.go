@@ -0,0 +1,125 @@
+package server
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/bububa/atomic-agents/components"
+)
+
+// ChatCompletionRequest is the subset of the OpenAI chat completion request used by the server
+type ChatCompletionRequest struct {
+	// Model is the registered agent name
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages"`
+	Stream   bool      `json:"stream,omitempty"`
+	// StreamOptions include usage in the last chunk of streams
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+	// SessionID maps the request to agent session memory, the session header takes precedence
+	SessionID string `json:"session_id,omitempty"`
+}
+
+// StreamOptions is the stream options of a ChatCompletionRequest
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage,omitempty"`
+}
+
+// Message is a chat message, content is a string or a list of content parts
+type Message struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content,omitempty"`
+}
+
+// contentPart is a chat message content part, only text parts are used
+type contentPart struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+}
+
+// Text returns the text of the message content, text parts are joined by new lines
+func (m *Message) Text() string {
+	var text string
+	if err := json.Unmarshal(m.Content, &text); err == nil {
+		return text
+	}
+	var parts []contentPart
+	if err := json.Unmarshal(m.Content, &parts); err != nil {
+		return ""
+	}
+	texts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part.Type == "text" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// ChatCompletion is an OpenAI chat completion response
+type ChatCompletion struct {
+	ID      string   `json:"id"`
+	Object  string   `json:"object"`
+	Created int64    `json:"created"`
+	Model   string   `json:"model"`
+	Choices []Choice `json:"choices"`
+	Usage   *Usage   `json:"usage,omitempty"`
+}
+
+// Choice is a chat completion choice, Delta is set in stream chunks
+type Choice struct {
+	Index        int     `json:"index"`
+	Message      *Delta  `json:"message,omitempty"`
+	Delta        *Delta  `json:"delta,omitempty"`
+	FinishReason *string `json:"finish_reason"`
+}
+
+// Delta is a chat completion message or stream delta
+type Delta struct {
+	Role             string `json:"role,omitempty"`
+	Content          string `json:"content,omitempty"`
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+}
+
+// Usage is the token usage of a chat completion
+type Usage struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
+}
+
+func newUsage(usage *components.LLMUsage) *Usage {
+	if usage == nil {
+		return &Usage{}
+	}
+	return &Usage{
+		PromptTokens:     usage.InputTokens,
+		CompletionTokens: usage.OutputTokens,
+		TotalTokens:      usage.InputTokens + usage.OutputTokens,
+	}
+}
+
+// Model is an entry of the models list, one per registered agent
+type Model struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// ModelList is the models list response
+type ModelList struct {
+	Object string  `json:"object"`
+	Data   []Model `json:"data"`
+}
+
+// Error is an OpenAI error response
+type Error struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// ErrorDetail is the detail of an Error
+type ErrorDetail struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	Code    string `json:"code,omitempty"`
+}
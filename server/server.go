@@ -0,0 +1,308 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bububa/instructor-go"
+	"github.com/google/uuid"
+	"github.com/invopop/jsonschema"
+
+	"github.com/bububa/atomic-agents/agents"
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/memory"
+	"github.com/bububa/atomic-agents/schema"
+)
+
+var (
+	// ErrNoUserMessage returns when a request has no user message to decode into the agent input
+	ErrNoUserMessage = errors.New("no user message")
+	// ErrStreamNotSupported returns when a stream is requested from an agent which is not streamable
+	ErrStreamNotSupported = errors.New("agent doesn't support stream")
+	// ErrNoMemoryStore returns when a session ID is sent to an agent without memory store
+	ErrNoMemoryStore = errors.New("agent has no memory store for sessions")
+)
+
+// DefaultSessionHeader is the request header carrying the session ID
+const DefaultSessionHeader = "X-Session-Id"
+
+// AgentSchema is the schema discovery response of an agent
+type AgentSchema struct {
+	Name   string             `json:"name"`
+	Stream bool               `json:"stream"`
+	Input  *jsonschema.Schema `json:"input"`
+	Output *jsonschema.Schema `json:"output"`
+}
+
+// route is a registered agent with its input decoder and output encoder
+type route struct {
+	schema *AgentSchema
+	// run runs the agent with the decoded input
+	run func(ctx context.Context, input any, apiResp *components.LLMResponse) (any, error)
+	// stream streams the agent with the decoded input, nil if the agent is not streamable
+	stream func(ctx context.Context, input any) (<-chan instructor.StreamData, agents.MergeResponse, error)
+	// sessions reports whether the agent has a memory store
+	sessions bool
+	// newInput decodes the user message text into the agent input
+	newInput func(text string) (any, error)
+	// outputText encodes the agent output into the assistant message text
+	outputText func(output any) (string, error)
+}
+
+// memoryStorer is implemented by agents which persist session memory, like agents.Agent
+type memoryStorer interface {
+	MemoryStore() memory.Store
+}
+
+// Server serves registered agents behind OpenAI compatible endpoints
+//
+//	POST /v1/chat/completions    runs or streams the agent named by the request model
+//	GET  /v1/models              lists the registered agents
+//	GET  /v1/agents/{name}/schema returns the input and output JSON schemas of the agent
+type Server struct {
+	mux           *http.ServeMux
+	mu            sync.RWMutex
+	routes        map[string]*route
+	sessionHeader string
+}
+
+// New returns a new Server
+func New() *Server {
+	s := &Server{
+		mux:           http.NewServeMux(),
+		routes:        make(map[string]*route),
+		sessionHeader: DefaultSessionHeader,
+	}
+	s.mux.HandleFunc("POST /v1/chat/completions", s.chatCompletions)
+	s.mux.HandleFunc("GET /v1/models", s.models)
+	s.mux.HandleFunc("GET /v1/agents/{name}/schema", s.agentSchema)
+	return s
+}
+
+// SetSessionHeader set the request header carrying the session ID
+func (s *Server) SetSessionHeader(header string) *Server {
+	s.sessionHeader = header
+	return s
+}
+
+// Handle registers the agent with I input and O output schemas by name, the request model selects the agent.
+// The user message is decoded as the chat message of schema.Input, the raw text of text schemas like schema.String,
+// or JSON of other schemas. The output is encoded as the chat message of schema.Output, the raw text of text schemas,
+// or JSON of other schemas. Agents implementing StreamableAgent serve stream requests.
+// Requests with a session ID run on the session memory of agents with a memory store, and are rejected by agents
+// without one. Requests without session ID run on a new memory.Isolation starting with the prior request messages.
+func Handle[I schema.Schema, O schema.Schema](s *Server, name string, agent agents.TypeableAgent[I, O]) *Server {
+	r := &route{
+		schema: &AgentSchema{
+			Name:   name,
+			Input:  instructor.JSONSchema(reflect.TypeFor[I](), true, nil),
+			Output: instructor.JSONSchema(reflect.TypeFor[O](), true, nil),
+		},
+		run: func(ctx context.Context, input any, apiResp *components.LLMResponse) (any, error) {
+			out := new(O)
+			if err := agent.Run(ctx, input.(*I), out, apiResp); err != nil {
+				return nil, err
+			}
+			return out, nil
+		},
+		newInput: func(text string) (any, error) {
+			in := new(I)
+			return in, decodeInput(text, in)
+		},
+		outputText: encodeOutput,
+	}
+	if streamer, ok := agent.(agents.StreamableAgent[I, O]); ok {
+		r.schema.Stream = true
+		r.stream = func(ctx context.Context, input any) (<-chan instructor.StreamData, agents.MergeResponse, error) {
+			return streamer.Stream(ctx, input.(*I))
+		}
+	}
+	if storer, ok := agent.(memoryStorer); ok {
+		r.sessions = storer.MemoryStore() != nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routes[name] = r
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) route(name string) (*route, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.routes[name]
+	return r, ok
+}
+
+func (s *Server) chatCompletions(w http.ResponseWriter, r *http.Request) {
+	var req ChatCompletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", err)
+		return
+	}
+	rt, ok := s.route(req.Model)
+	if !ok {
+		writeError(w, http.StatusNotFound, "model_not_found", fmt.Errorf("agent %s not found", req.Model))
+		return
+	}
+	idx := lastUserMessage(req.Messages)
+	if idx < 0 {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", ErrNoUserMessage)
+		return
+	}
+	input, err := rt.newInput(req.Messages[idx].Text())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", fmt.Errorf("decode agent input: %w", err))
+		return
+	}
+	ctx := r.Context()
+	sessionID := r.Header.Get(s.sessionHeader)
+	if sessionID == "" {
+		sessionID = req.SessionID
+	}
+	if sessionID != "" {
+		if !rt.sessions {
+			writeError(w, http.StatusBadRequest, "invalid_request_error", ErrNoMemoryStore)
+			return
+		}
+		ctx = memory.WithSessionID(ctx, sessionID)
+		w.Header().Set(s.sessionHeader, sessionID)
+	} else {
+		// stateless requests carry their history, it never leaks into the agent memory
+		ctx = memory.Isolate(ctx, history(req.Messages[:idx])...)
+	}
+	completion := &ChatCompletion{
+		ID:      "chatcmpl-" + uuid.NewString(),
+		Created: time.Now().Unix(),
+		Model:   req.Model,
+	}
+	if req.Stream {
+		if rt.stream == nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_error", ErrStreamNotSupported)
+			return
+		}
+		ch, mergeResp, err := rt.stream(ctx, input)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "server_error", err)
+			return
+		}
+		includeUsage := req.StreamOptions != nil && req.StreamOptions.IncludeUsage
+		stream(w, completion, ch, mergeResp, includeUsage)
+		return
+	}
+	llmResp := new(components.LLMResponse)
+	output, err := rt.run(ctx, input, llmResp)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "server_error", err)
+		return
+	}
+	content, err := rt.outputText(output)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "server_error", err)
+		return
+	}
+	stop := "stop"
+	completion.Object = "chat.completion"
+	completion.Choices = []Choice{{Message: &Delta{Role: string(instructor.AssistantRole), Content: content}, FinishReason: &stop}}
+	completion.Usage = newUsage(llmResp.Usage)
+	writeJSON(w, http.StatusOK, completion)
+}
+
+func (s *Server) models(w http.ResponseWriter, _ *http.Request) {
+	s.mu.RLock()
+	names := make([]string, 0, len(s.routes))
+	for name := range s.routes {
+		names = append(names, name)
+	}
+	s.mu.RUnlock()
+	slices.Sort(names)
+	list := ModelList{Object: "list", Data: make([]Model, 0, len(names))}
+	for _, name := range names {
+		list.Data = append(list.Data, Model{ID: name, Object: "model", OwnedBy: "atomic-agents"})
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) agentSchema(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	rt, ok := s.route(name)
+	if !ok {
+		writeError(w, http.StatusNotFound, "model_not_found", fmt.Errorf("agent %s not found", name))
+		return
+	}
+	writeJSON(w, http.StatusOK, rt.schema)
+}
+
+// lastUserMessage returns the index of the last user message, -1 if none
+func lastUserMessage(messages []Message) int {
+	for idx := len(messages) - 1; idx >= 0; idx-- {
+		if messages[idx].Role == string(instructor.UserRole) {
+			return idx
+		}
+	}
+	return -1
+}
+
+// history returns the user and assistant messages as memory history, system prompts are generated by agents
+func history(messages []Message) []instructor.Message {
+	ret := make([]instructor.Message, 0, len(messages))
+	for idx := range messages {
+		switch role := instructor.Role(messages[idx].Role); role {
+		case instructor.UserRole, instructor.AssistantRole:
+			ret = append(ret, instructor.Message{Role: role, Text: messages[idx].Text()})
+		}
+	}
+	return ret
+}
+
+// unmarshaler is implemented by schemas decoded from raw text, like schema.String
+type unmarshaler interface {
+	Unmarshal([]byte) error
+}
+
+// decodeInput decodes the user message text into the agent input
+func decodeInput(text string, in any) error {
+	switch v := in.(type) {
+	case *schema.Input:
+		v.ChatMessage = text
+		return nil
+	case unmarshaler:
+		return v.Unmarshal([]byte(text))
+	}
+	return json.Unmarshal([]byte(text), in)
+}
+
+// encodeOutput encodes the agent output into the assistant message text
+func encodeOutput(output any) (string, error) {
+	switch v := output.(type) {
+	case *schema.Output:
+		return v.ChatMessage, nil
+	case *schema.String:
+		return v.String(), nil
+	}
+	bs, err := json.Marshal(output)
+	if err != nil {
+		return "", err
+	}
+	return string(bs), nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, typ string, err error) {
+	writeJSON(w, status, Error{Error: ErrorDetail{Message: err.Error(), Type: typ}})
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bububa/instructor-go"
+	openaiClt "github.com/bububa/instructor-go/instructors/openai"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+
+	"github.com/bububa/atomic-agents/agents"
+	"github.com/bububa/atomic-agents/internal/llmtest"
+	"github.com/bububa/atomic-agents/schema"
+)
+
+// mapStore is an in-memory memory.Store
+type mapStore struct {
+	mu       sync.Mutex
+	sessions map[string][]instructor.Message
+}
+
+func (s *mapStore) Load(_ context.Context, sessionID string) ([]instructor.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions[sessionID], nil
+}
+
+func (s *mapStore) Save(_ context.Context, sessionID string, history []instructor.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[sessionID] = history
+	return nil
+}
+
+func TestServer(t *testing.T) {
+	upstream := llmtest.NewServer(t, func(map[string]any) llmtest.Reply {
+		return llmtest.Reply{Content: `{"chat_message":"hello"}`, Chunks: []string{"Hel", "lo"}}
+	})
+	newAgent := func(opts ...agents.Option) *agents.Agent[schema.Input, schema.Output] {
+		clt := openai.NewClient(option.WithBaseURL(upstream.URL), option.WithAPIKey("test"), option.WithMaxRetries(0))
+		opts = append(opts,
+			agents.WithClient(openaiClt.New(&clt, instructor.WithMode(instructor.ModeJSON), instructor.WithMaxRetries(0))),
+			agents.WithModel(llmtest.Model),
+		)
+		return agents.NewAgent[schema.Input, schema.Output](opts...)
+	}
+	agent := newAgent(agents.WithMemoryStore(&mapStore{sessions: make(map[string][]instructor.Message)}))
+	stateless := newAgent()
+	server := New()
+	Handle(server, "chat", agent)
+	Handle(server, "stateless", stateless)
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+	messageCount := func(idx int) int {
+		return len(llmtest.Messages(upstream.Requests()[idx]))
+	}
+
+	post := func(body string, sessionID string) *http.Response {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/v1/chat/completions", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if sessionID != "" {
+			req.Header.Set(DefaultSessionHeader, sessionID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("post chat completions failed: %v", err)
+		}
+		return resp
+	}
+
+	resp := post(`{"model":"chat","messages":[{"role":"system","content":"ignored"},{"role":"user","content":"hi"}]}`, "s1")
+	var completion ChatCompletion
+	json.NewDecoder(resp.Body).Decode(&completion)
+	resp.Body.Close()
+	if len(completion.Choices) != 1 || completion.Choices[0].Message.Content != "hello" || completion.Usage.TotalTokens != 17 {
+		t.Errorf("unexpected chat completion: %+v", completion)
+	}
+	if resp.Header.Get(DefaultSessionHeader) != "s1" {
+		t.Errorf("expect session ID echoed, got %q", resp.Header.Get(DefaultSessionHeader))
+	}
+
+	resp = post(`{"model":"chat","messages":[{"role":"user","content":[{"type":"text","text":"again"}]}],"stream":true,"stream_options":{"include_usage":true}}`, "s1")
+	var (
+		content strings.Builder
+		usage   *Usage
+		done    bool
+	)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		if data == "[DONE]" {
+			done = true
+			break
+		}
+		var chunk ChatCompletion
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatalf("decode chunk failed: %v", err)
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			content.WriteString(choice.Delta.Content)
+		}
+	}
+	resp.Body.Close()
+	if content.String() != "Hello" || !done || usage == nil || usage.TotalTokens != 17 {
+		t.Errorf("unexpected stream %q, done %v, usage %+v", content.String(), done, usage)
+	}
+	// the stream request carries the first exchange loaded from the session memory
+	if len(upstream.Requests()) != 2 || messageCount(1) != messageCount(0)+2 {
+		t.Errorf("expect session history sent upstream, got %d then %d messages", messageCount(0), messageCount(1))
+	}
+
+	// stateless requests carry their own history, which never reaches the agent memory
+	resp = post(`{"model":"stateless","messages":[{"role":"system","content":"ignored"},{"role":"user","content":"hi"},{"role":"assistant","content":"hello"},{"role":"user","content":"again"}]}`, "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || messageCount(2) != messageCount(0)+2 {
+		t.Errorf("expect request history sent upstream, got status %d and %d messages", resp.StatusCode, messageCount(2))
+	}
+	if got := len(stateless.Memory().List()); got != 0 {
+		t.Errorf("expect agent memory untouched by stateless requests, got %d messages", got)
+	}
+	resp = post(`{"model":"stateless","messages":[{"role":"user","content":"hi"}]}`, "s1")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest || len(upstream.Requests()) != 3 {
+		t.Errorf("expect session rejected by agent without memory store, got %d", resp.StatusCode)
+	}
+
+	resp = post(`{"model":"unknown","messages":[{"role":"user","content":"hi"}]}`, "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expect unknown agent not found, got %d", resp.StatusCode)
+	}
+
+	resp, err := http.Get(srv.URL + "/v1/agents/chat/schema")
+	if err != nil {
+		t.Fatalf("get agent schema failed: %v", err)
+	}
+	var agentSchema AgentSchema
+	json.NewDecoder(resp.Body).Decode(&agentSchema)
+	resp.Body.Close()
+	if !agentSchema.Stream || agentSchema.Input == nil || agentSchema.Input.Properties.Len() == 0 {
+		t.Errorf("unexpected agent schema: %+v", agentSchema)
+	}
+	resp, err = http.Get(srv.URL + "/v1/models")
+	if err != nil {
+		t.Fatalf("list models failed: %v", err)
+	}
+	var list ModelList
+	json.NewDecoder(resp.Body).Decode(&list)
+	resp.Body.Close()
+	if len(list.Data) != 2 || list.Data[0].ID != "chat" {
+		t.Errorf("unexpected models: %+v", list)
+	}
+}
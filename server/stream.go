@@ -0,0 +1,75 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/bububa/instructor-go"
+
+	"github.com/bububa/atomic-agents/agents"
+	"github.com/bububa/atomic-agents/components"
+)
+
+// stream writes the agent stream as server-sent chat completion chunks, ended by a finish chunk and [DONE].
+// The stream response is merged once the stream ends, so that agents flush their session memory.
+// A stream error is sent as an error event and ends the stream.
+func stream(w http.ResponseWriter, completion *ChatCompletion, ch <-chan instructor.StreamData, mergeResp agents.MergeResponse, includeUsage bool) {
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	completion.Object = "chat.completion.chunk"
+	send := func(v any) {
+		bs, _ := json.Marshal(v)
+		fmt.Fprintf(w, "data: %s\n\n", bs)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	chunk := func(delta *Delta, finishReason *string) *ChatCompletion {
+		ret := *completion
+		ret.Choices = []Choice{{Delta: delta, FinishReason: finishReason}}
+		return &ret
+	}
+	send(chunk(&Delta{Role: string(instructor.AssistantRole)}, nil))
+	var streamErr error
+	for data := range ch {
+		if streamErr != nil {
+			// drain the stream so that the agent goroutine ends
+			continue
+		}
+		switch data.Type {
+		case instructor.ContentStream:
+			send(chunk(&Delta{Content: data.Content}, nil))
+		case instructor.ThinkingStream:
+			send(chunk(&Delta{ReasoningContent: data.Content}, nil))
+		case instructor.ErrorStream:
+			if streamErr = data.Err; streamErr == nil {
+				streamErr = errors.New(data.Content)
+			}
+		}
+	}
+	llmResp := new(components.LLMResponse)
+	if mergeResp != nil {
+		mergeResp(llmResp)
+	}
+	if streamErr != nil {
+		send(Error{Error: ErrorDetail{Message: streamErr.Error(), Type: "server_error"}})
+	} else {
+		stop := "stop"
+		send(chunk(&Delta{}, &stop))
+		if includeUsage {
+			ret := *completion
+			ret.Choices = []Choice{}
+			ret.Usage = newUsage(llmResp.Usage)
+			send(&ret)
+		}
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,182 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var (
+	// ErrRejected is wrapped by every RejectedError
+	ErrRejected = errors.New("tool call rejected")
+	// ErrEditedParams returns when edited params are not of the proposed params type
+	ErrEditedParams = errors.New("edited params type mismatch")
+)
+
+// Verdict is the verdict of an approval
+type Verdict int
+
+const (
+	// Approve runs the tool with the proposed params
+	Approve Verdict = iota
+	// Reject skips the tool, the reason is fed back to the model
+	Reject
+	// Edit runs the tool with the edited params
+	Edit
+)
+
+// ApprovalRequest is a tool call waiting for approval
+type ApprovalRequest struct {
+	// Tool is the title of the tool
+	Tool        string
+	Description string
+	// Params are the proposed params, a pointer of the tool input schema
+	Params any
+}
+
+// Decision is the decision of an Approver
+type Decision struct {
+	Verdict Verdict
+	// Reason is the reason of a rejection
+	Reason string
+	// Params are the edited params, of the same type as the proposed params
+	Params any
+}
+
+// Approver approves tool calls before they run
+type Approver interface {
+	Approve(ctx context.Context, req *ApprovalRequest) (*Decision, error)
+}
+
+// ApproverFunc is an Approver function
+type ApproverFunc func(ctx context.Context, req *ApprovalRequest) (*Decision, error)
+
+func (fn ApproverFunc) Approve(ctx context.Context, req *ApprovalRequest) (*Decision, error) {
+	return fn(ctx, req)
+}
+
+// RejectedError returns when an Approver rejected a tool call
+type RejectedError struct {
+	Tool   string
+	Reason string
+}
+
+func (e *RejectedError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("tool %s call rejected", e.Tool)
+	}
+	return fmt.Sprintf("tool %s call rejected: %s", e.Tool, e.Reason)
+}
+
+func (e *RejectedError) Unwrap() error {
+	return ErrRejected
+}
+
+// Dispatcher is implemented by tools which select and run another tool, like orchestration.Tool,
+// approvals are requested for the selected tool instead of the Dispatcher
+type Dispatcher interface {
+	AnonymousTool
+	// Select returns the tool and its params selected for the input
+	Select(ctx context.Context, input any) (AnonymousTool, any, error)
+}
+
+type approverKey struct{}
+
+// WithApprover returns a context carrying the Approver of tool calls
+func WithApprover(ctx context.Context, approver Approver) context.Context {
+	return context.WithValue(ctx, approverKey{}, approver)
+}
+
+// ApproverFromContext returns the Approver carried by the context, nil if none
+func ApproverFromContext(ctx context.Context) Approver {
+	v, _ := ctx.Value(approverKey{}).(Approver)
+	return v
+}
+
+// RequestApproval asks the approver, or the Approver carried by the context if nil, to approve the tool call.
+// It returns the params to run the tool with, or a *RejectedError. Tool calls are approved if there is no Approver.
+func RequestApproval(ctx context.Context, approver Approver, tool AnonymousTool, params any) (any, error) {
+	if approver == nil {
+		if approver = ApproverFromContext(ctx); approver == nil {
+			return params, nil
+		}
+	}
+	decision, err := approver.Approve(ctx, &ApprovalRequest{
+		Tool:        tool.Title(),
+		Description: tool.Description(),
+		Params:      params,
+	})
+	if err != nil {
+		return nil, err
+	}
+	switch decision.Verdict {
+	case Reject:
+		return nil, &RejectedError{Tool: tool.Title(), Reason: decision.Reason}
+	case Edit:
+		if reflect.TypeOf(decision.Params) != reflect.TypeOf(params) {
+			return nil, fmt.Errorf("%w: expect %T, got %T", ErrEditedParams, params, decision.Params)
+		}
+		return decision.Params, nil
+	}
+	return params, nil
+}
+
+// PendingApproval is an approval request sent by a ChanApprover, it must be decided once
+type PendingApproval struct {
+	ApprovalRequest
+	decision chan *Decision
+}
+
+// Approve approves the tool call
+func (p *PendingApproval) Approve() {
+	p.decision <- &Decision{Verdict: Approve}
+}
+
+// Reject rejects the tool call with the reason fed back to the model
+func (p *PendingApproval) Reject(reason string) {
+	p.decision <- &Decision{Verdict: Reject, Reason: reason}
+}
+
+// Edit approves the tool call with edited params
+func (p *PendingApproval) Edit(params any) {
+	p.decision <- &Decision{Verdict: Edit, Params: params}
+}
+
+// ChanApprover sends approval requests to a channel and waits for their decisions, like prompts of interactive CLIs
+type ChanApprover struct {
+	requests chan *PendingApproval
+}
+
+var _ Approver = (*ChanApprover)(nil)
+
+// NewChanApprover returns a new ChanApprover with the requests channel buffer size
+func NewChanApprover(buffer int) *ChanApprover {
+	return &ChanApprover{
+		requests: make(chan *PendingApproval, buffer),
+	}
+}
+
+// Requests returns the channel of pending approvals
+func (a *ChanApprover) Requests() <-chan *PendingApproval {
+	return a.requests
+}
+
+// Approve sends the request and waits for its decision or the context done
+func (a *ChanApprover) Approve(ctx context.Context, req *ApprovalRequest) (*Decision, error) {
+	pending := &PendingApproval{
+		ApprovalRequest: *req,
+		decision:        make(chan *Decision, 1),
+	}
+	select {
+	case a.requests <- pending:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	select {
+	case decision := <-pending.decision:
+		return decision, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,62 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bububa/atomic-agents/schema"
+)
+
+type echoTool struct {
+	Config
+}
+
+func (t *echoTool) RunAnonymous(_ context.Context, input any) (any, error) {
+	return input, nil
+}
+
+func TestChanApprover(t *testing.T) {
+	approver := NewChanApprover(0)
+	tool := new(echoTool)
+	tool.SetTitle("echo")
+	go func() {
+		for pending := range approver.Requests() {
+			switch pending.Params.(*schema.String).String() {
+			case "rm -rf":
+				pending.Reject("destructive command")
+			case "typo":
+				pending.Edit(schema.NewString("fixed"))
+			case "mismatch":
+				pending.Edit("fixed")
+			default:
+				pending.Approve()
+			}
+		}
+	}()
+	ctx := WithApprover(context.Background(), approver)
+
+	if params, err := RequestApproval(ctx, nil, tool, schema.NewString("ls")); err != nil || params.(*schema.String).String() != "ls" {
+		t.Errorf("expect approved params, got %v, %v", params, err)
+	}
+	if params, err := RequestApproval(ctx, nil, tool, schema.NewString("typo")); err != nil || params.(*schema.String).String() != "fixed" {
+		t.Errorf("expect edited params, got %v, %v", params, err)
+	}
+	if _, err := RequestApproval(ctx, nil, tool, schema.NewString("mismatch")); !errors.Is(err, ErrEditedParams) {
+		t.Errorf("expect edited params type mismatch, got %v", err)
+	}
+	_, err := RequestApproval(ctx, nil, tool, schema.NewString("rm -rf"))
+	var rejected *RejectedError
+	if !errors.As(err, &rejected) || !errors.Is(err, ErrRejected) || rejected.Reason != "destructive command" || rejected.Tool != "echo" {
+		t.Errorf("expect rejected tool call, got %v", err)
+	}
+	if params, err := RequestApproval(context.Background(), nil, tool, schema.NewString("rm -rf")); err != nil || params == nil {
+		t.Errorf("expect approved without approver, got %v", err)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewChanApprover(0).Approve(cancelled, &ApprovalRequest{Tool: "echo"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expect context canceled, got %v", err)
+	}
+}
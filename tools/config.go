@@ -1,9 +1,14 @@
 package tools
 
-import "context"
+import (
+	"context"
+
+	"github.com/bububa/atomic-agents/components/interceptor"
+)
 
 // Config class for tools within the Atomic Agents framework
 type Config struct {
+	interceptor.Stack
 	// title the default title of the tool
 	title string
 	// description the default description of the tool
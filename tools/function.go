@@ -0,0 +1,71 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+
+	"github.com/bububa/instructor-go"
+	"github.com/invopop/jsonschema"
+
+	"github.com/bububa/atomic-agents/schema"
+)
+
+// Function is an AnonymousTool which could be registered as a LLM provider native tool
+type Function interface {
+	AnonymousTool
+	// Parameters returns the json schema of the tool input
+	Parameters() *jsonschema.Schema
+	// NewInput returns a new empty tool input which arguments generated by LLM will be decoded into
+	NewInput() any
+}
+
+// function wraps a typed tool into Function
+type function[I schema.Schema] struct {
+	AnonymousTool
+	parameters *jsonschema.Schema
+}
+
+// NewFunction wraps an AnonymousTool which accepts *I as input into a Function
+func NewFunction[I schema.Schema](tool AnonymousTool) Function {
+	return &function[I]{
+		AnonymousTool: tool,
+		parameters:    instructor.JSONSchema(reflect.TypeFor[I](), true, nil),
+	}
+}
+
+func (f *function[I]) Parameters() *jsonschema.Schema {
+	return f.parameters
+}
+
+func (f *function[I]) NewInput() any {
+	return new(I)
+}
+
+// CallFunction decodes LLM generated arguments into Function input, runs the Function and returns the json encoded output.
+// The call is approved by the Approver carried by the context if any.
+func CallFunction(ctx context.Context, fn Function, arguments string) (string, error) {
+	input := fn.NewInput()
+	if arguments != "" {
+		if err := json.Unmarshal([]byte(arguments), input); err != nil {
+			return "", err
+		}
+	}
+	input, err := RequestApproval(ctx, nil, fn, input)
+	if err != nil {
+		return "", err
+	}
+	output, err := Invoke(ctx, fn, input)
+	if err != nil {
+		return "", err
+	}
+	if output == nil {
+		return "", errors.New("empty tool output")
+	}
+	bs, err := json.Marshal(output)
+	if err != nil {
+		return "", err
+	}
+	return string(bs), nil
+}
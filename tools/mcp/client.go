@@ -0,0 +1,99 @@
+package mcp
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	mcpgo "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ClientName is the client name sent to MCP servers on initialize
+const ClientName = "atomic-agents"
+
+// Client is an initialized MCP client importing the tools of a server
+type Client struct {
+	client.MCPClient
+	info *mcpgo.InitializeResult
+}
+
+// NewClient initializes a started mcp-go client
+func NewClient(ctx context.Context, clt client.MCPClient) (*Client, error) {
+	req := mcpgo.InitializeRequest{}
+	req.Params.ProtocolVersion = mcpgo.LATEST_PROTOCOL_VERSION
+	req.Params.ClientInfo = mcpgo.Implementation{Name: ClientName}
+	info, err := clt.Initialize(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		MCPClient: clt,
+		info:      info,
+	}, nil
+}
+
+// NewStdioClient starts the MCP server command with env and args, and talks with it over stdio
+func NewStdioClient(ctx context.Context, command string, env []string, args ...string) (*Client, error) {
+	clt, err := client.NewStdioMCPClient(command, env, args...)
+	if err != nil {
+		return nil, err
+	}
+	return initialize(ctx, clt)
+}
+
+// NewHTTPClient talks with the MCP server at url over streamable HTTP
+func NewHTTPClient(ctx context.Context, url string, opts ...transport.StreamableHTTPCOption) (*Client, error) {
+	clt, err := client.NewStreamableHttpClient(url, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := clt.Start(ctx); err != nil {
+		return nil, err
+	}
+	return initialize(ctx, clt)
+}
+
+// NewInProcessClient talks with the MCP server in the same process
+func NewInProcessClient(ctx context.Context, srv *server.MCPServer) (*Client, error) {
+	clt, err := client.NewInProcessClient(srv)
+	if err != nil {
+		return nil, err
+	}
+	if err := clt.Start(ctx); err != nil {
+		return nil, err
+	}
+	return initialize(ctx, clt)
+}
+
+// initialize initializes the started client, the client is closed if failed
+func initialize(ctx context.Context, clt *client.Client) (*Client, error) {
+	ret, err := NewClient(ctx, clt)
+	if err != nil {
+		clt.Close()
+		return nil, err
+	}
+	return ret, nil
+}
+
+// ServerInfo returns the server name and version
+func (c *Client) ServerInfo() mcpgo.Implementation {
+	return c.info.ServerInfo
+}
+
+// Tools lists the tools of the server wrapped as tools.Function
+func (c *Client) Tools(ctx context.Context) ([]*Tool, error) {
+	res, err := c.ListTools(ctx, mcpgo.ListToolsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*Tool, 0, len(res.Tools))
+	for _, v := range res.Tools {
+		tool, err := NewTool(c, v)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, tool)
+	}
+	return ret, nil
+}
@@ -0,0 +1,7 @@
+// Package mcp connects agents with Model Context Protocol servers.
+// Client lists the tools of a server over stdio, streamable HTTP or in process, and wraps each of them as a tools.Function
+// with a dynamic Input validated against the tool input JSON Schema, so that MCP tools could be used by ToolAgent,
+// orchestration.Tool or native tool calling loops.
+// Server publishes typed tools and agents as MCP tools with input schemas generated from their jsonschema tags,
+// so that desktop assistants and other frameworks could call them.
+package mcp
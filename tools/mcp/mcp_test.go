@@ -0,0 +1,166 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/invopop/jsonschema"
+	mcpgo "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/memory"
+	"github.com/bububa/atomic-agents/schema"
+	"github.com/bububa/atomic-agents/tools"
+	"github.com/bububa/atomic-agents/tools/calculator"
+)
+
+// newServer returns a stand-in MCP server with an add tool
+func newServer() *server.MCPServer {
+	srv := server.NewMCPServer("stand-in", "1.0.0", server.WithToolCapabilities(true))
+	srv.AddTool(mcpgo.NewTool("add",
+		mcpgo.WithDescription("add two numbers"),
+		mcpgo.WithNumber("a", mcpgo.Required()),
+		mcpgo.WithNumber("b", mcpgo.Required()),
+	), func(_ context.Context, req mcpgo.CallToolRequest) (*mcpgo.CallToolResult, error) {
+		args := req.GetArguments()
+		a, _ := args["a"].(float64)
+		b, _ := args["b"].(float64)
+		if a < 0 || b < 0 {
+			return mcpgo.NewToolResultError("negative numbers are not supported"), nil
+		}
+		return mcpgo.NewToolResultText(fmt.Sprintf("%g", a+b)), nil
+	})
+	return srv
+}
+
+type addParams struct {
+	schema.Base
+	A int `json:"a"`
+	B int `json:"b"`
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	clt, err := NewInProcessClient(ctx, newServer())
+	if err != nil {
+		t.Fatalf("connect server failed: %v", err)
+	}
+	defer clt.Close()
+	if clt.ServerInfo().Name != "stand-in" {
+		t.Errorf("unexpected server info: %+v", clt.ServerInfo())
+	}
+	list, err := clt.Tools(ctx)
+	if err != nil {
+		t.Fatalf("list tools failed: %v", err)
+	}
+	if len(list) != 1 || list[0].Title() != "add" || list[0].Description() != "add two numbers" {
+		t.Fatalf("unexpected tools: %+v", list)
+	}
+	tool := list[0]
+	if params := tool.Parameters(); params.Properties.Len() != 2 || len(params.Required) != 2 {
+		t.Errorf("expect input schema mapped, got %+v", params)
+	}
+
+	// native tool calling loops decode arguments into the dynamic input
+	if out, err := tools.CallFunction(ctx, tool, `{"a":1,"b":2}`); err != nil || !strings.Contains(out, `"content":"3"`) {
+		t.Errorf("expect tool called, got %s, %v", out, err)
+	}
+	if _, err := tools.CallFunction(ctx, tool, `{"a":1}`); !errors.Is(err, ErrInvalidArguments) {
+		t.Errorf("expect missing argument, got %v", err)
+	}
+	if _, err := tools.CallFunction(ctx, tool, `{"a":1,"b":"2"}`); !errors.Is(err, ErrInvalidArguments) {
+		t.Errorf("expect argument type mismatch, got %v", err)
+	}
+	if _, err := tools.CallFunction(ctx, tool, `{"a":-1,"b":2}`); !errors.Is(err, ErrToolResult) || !strings.Contains(err.Error(), "negative") {
+		t.Errorf("expect tool error result, got %v", err)
+	}
+
+	// in process arguments keep their Go number types
+	for _, args := range []map[string]any{{"a": 2, "b": int64(3)}, {"a": float32(2), "b": json.Number("3")}, {"a": uint8(2), "b": 3.0}} {
+		if out, err := tools.Invoke(ctx, tool, NewInput(args)); err != nil || out.(*Output).String() != "5" {
+			t.Errorf("expect number arguments %v accepted, got %v", args, err)
+		}
+	}
+	integer := &jsonschema.Schema{Properties: jsonschema.NewProperties()}
+	integer.Properties.Set("n", &jsonschema.Schema{Type: "integer"})
+	if err := validate(integer, map[string]any{"n": json.Number("2.5")}); !errors.Is(err, ErrInvalidArguments) {
+		t.Errorf("expect fractional json.Number rejected as integer, got %v", err)
+	}
+
+	// typed inputs like ToolAgent start agent outputs are encoded into arguments
+	out, err := tools.Invoke(ctx, tool, &addParams{A: 2, B: 3})
+	if err != nil {
+		t.Fatalf("invoke tool failed: %v", err)
+	}
+	if output, ok := out.(*Output); !ok || output.String() != "5" {
+		t.Errorf("unexpected tool output: %+v", out)
+	}
+}
+
+// echoAgent answers the chat message in upper case
+type echoAgent struct{}
+
+func (echoAgent) Name() string { return "echo" }
+
+func (echoAgent) Run(ctx context.Context, in *schema.Input, out *schema.Output, _ *components.LLMResponse) error {
+	if memory.IsolationFromContext(ctx) == nil {
+		return errors.New("expect isolated memory")
+	}
+	out.ChatMessage = strings.ToUpper(in.ChatMessage)
+	return nil
+}
+
+func TestServer(t *testing.T) {
+	ctx := context.Background()
+	var started, ended int
+	calc := calculator.New(tools.WithDescription("evaluate math expressions"))
+	calc.SetStartHook(func(context.Context, tools.AnonymousTool, any) { started++ })
+	calc.SetEndHook(func(context.Context, tools.AnonymousTool, any, any) { ended++ })
+	srv := NewServer("atomic", "1.0.0")
+	AddTool[calculator.Input, calculator.Output](srv, calc)
+	AddAgent[schema.Input, schema.Output](srv, "echo", "echo the message", echoAgent{})
+
+	clt, err := NewInProcessClient(ctx, srv.MCPServer)
+	if err != nil {
+		t.Fatalf("connect server failed: %v", err)
+	}
+	defer clt.Close()
+	list, err := clt.Tools(ctx)
+	if err != nil {
+		t.Fatalf("list tools failed: %v", err)
+	}
+	published := make(map[string]*Tool, len(list))
+	for _, tool := range list {
+		published[tool.Name()] = tool
+	}
+	calcTool, echoTool := published["CalculatorTool"], published["echo"]
+	if len(list) != 2 || calcTool == nil || echoTool == nil {
+		t.Fatalf("unexpected tools: %+v", list)
+	}
+	if prop, ok := calcTool.Parameters().Properties.Get("expression"); !ok || prop.Type != "string" || calcTool.Description() != "evaluate math expressions" {
+		t.Errorf("expect input schema from jsonschema tags, got %+v", calcTool.Parameters())
+	}
+
+	out := new(Output)
+	if err := calcTool.Run(ctx, NewInput(map[string]any{"expression": "2+2"}), out); err != nil {
+		t.Fatalf("call calculator failed: %v", err)
+	}
+	if result, ok := out.Structured.(map[string]any); !ok || result["result"] != float64(4) {
+		t.Errorf("expect structured output, got %+v", out)
+	}
+	if started != 1 || ended != 1 {
+		t.Errorf("expect tool hooks fired, got %d start, %d end", started, ended)
+	}
+	if err := calcTool.Run(ctx, NewInput(map[string]any{"expression": "2+"}), out); !errors.Is(err, ErrToolResult) {
+		t.Errorf("expect tool error result, got %v", err)
+	}
+
+	if err := echoTool.Run(ctx, NewInput(map[string]any{"chat_message": "hi"}), out); err != nil || out.Content != "HI" {
+		t.Errorf("expect agent answered, got %+v, %v", out, err)
+	}
+}
@@ -0,0 +1,125 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/bububa/instructor-go"
+	mcpgo "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/bububa/atomic-agents/agents"
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/memory"
+	"github.com/bububa/atomic-agents/schema"
+	"github.com/bububa/atomic-agents/tools"
+)
+
+// TypedTool is a tools.Tool which runs its hooks in RunAnonymous, like the tools of this repo
+type TypedTool[I schema.Schema, O schema.Schema] interface {
+	tools.Tool[I, O]
+	tools.AnonymousTool
+}
+
+// Server publishes tools and agents as the tools of an MCP server
+type Server struct {
+	*server.MCPServer
+}
+
+// NewServer returns a new Server with name and version
+func NewServer(name string, version string, opts ...server.ServerOption) *Server {
+	opts = append([]server.ServerOption{server.WithToolCapabilities(true)}, opts...)
+	return &Server{
+		MCPServer: server.NewMCPServer(name, version, opts...),
+	}
+}
+
+// AddTool publishes the tool named by its title, the input schema is generated from the jsonschema tags of I.
+// Every call runs through tools.Invoke, so that the hooks and interceptors of the tool fire.
+func AddTool[I schema.Schema, O schema.Schema](s *Server, tool TypedTool[I, O]) *Server {
+	s.MCPServer.AddTool(newTool[I, O](tool.Title(), tool.Description()), func(ctx context.Context, req mcpgo.CallToolRequest) (*mcpgo.CallToolResult, error) {
+		in := new(I)
+		if err := decodeArguments(req.Params.Arguments, in); err != nil {
+			return mcpgo.NewToolResultError(err.Error()), nil
+		}
+		out, err := tools.Invoke(ctx, tool, in)
+		if err != nil {
+			return mcpgo.NewToolResultError(err.Error()), nil
+		}
+		return newToolResult(out)
+	})
+	return s
+}
+
+// AddAgent publishes the agent with I input and O output schemas as a tool named name,
+// every call runs on an isolated memory so concurrent clients don't share the conversation
+func AddAgent[I schema.Schema, O schema.Schema](s *Server, name string, description string, agent agents.TypeableAgent[I, O]) *Server {
+	s.MCPServer.AddTool(newTool[I, O](name, description), func(ctx context.Context, req mcpgo.CallToolRequest) (*mcpgo.CallToolResult, error) {
+		in := new(I)
+		if err := decodeArguments(req.Params.Arguments, in); err != nil {
+			return mcpgo.NewToolResultError(err.Error()), nil
+		}
+		out := new(O)
+		if err := agent.Run(memory.Isolate(ctx), in, out, new(components.LLMResponse)); err != nil {
+			return mcpgo.NewToolResultError(err.Error()), nil
+		}
+		return newToolResult(out)
+	})
+	return s
+}
+
+// ServeStdio serves the MCP server over stdio until stdin is closed
+func (s *Server) ServeStdio(opts ...server.StdioOption) error {
+	return server.ServeStdio(s.MCPServer, opts...)
+}
+
+// HTTPHandler returns the streamable HTTP handler of the MCP server
+func (s *Server) HTTPHandler(opts ...server.StreamableHTTPOption) http.Handler {
+	return server.NewStreamableHTTPServer(s.MCPServer, opts...)
+}
+
+// newTool returns the MCP tool definition, text outputs like schema.Output have no output schema
+func newTool[I schema.Schema, O schema.Schema](name string, description string) mcpgo.Tool {
+	input, _ := json.Marshal(instructor.JSONSchema(reflect.TypeFor[I](), true, nil))
+	tool := mcpgo.NewToolWithRawSchema(name, description, input)
+	if !isText(reflect.TypeFor[O]()) {
+		tool.RawOutputSchema, _ = json.Marshal(instructor.JSONSchema(reflect.TypeFor[O](), true, nil))
+	}
+	return tool
+}
+
+func decodeArguments(args any, in any) error {
+	if args == nil {
+		return nil
+	}
+	bs, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(bs, in); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidArguments, err)
+	}
+	return nil
+}
+
+// newToolResult returns the text of text outputs, or the output as structured content with its JSON as the text
+func newToolResult(out any) (*mcpgo.CallToolResult, error) {
+	switch v := out.(type) {
+	case *schema.Output:
+		return mcpgo.NewToolResultText(v.ChatMessage), nil
+	case *schema.String:
+		return mcpgo.NewToolResultText(v.String()), nil
+	}
+	bs, err := json.Marshal(out)
+	if err != nil {
+		return nil, err
+	}
+	return mcpgo.NewToolResultStructured(out, string(bs)), nil
+}
+
+func isText(t reflect.Type) bool {
+	return t == reflect.TypeFor[schema.Output]() || t == reflect.TypeFor[schema.String]()
+}
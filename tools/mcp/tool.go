@@ -0,0 +1,261 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+
+	"github.com/invopop/jsonschema"
+	"github.com/mark3labs/mcp-go/client"
+	mcpgo "github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/bububa/atomic-agents/schema"
+	"github.com/bububa/atomic-agents/tools"
+)
+
+var (
+	// ErrInvalidArguments returns when tool arguments don't match the tool input JSON Schema
+	ErrInvalidArguments = errors.New("invalid tool arguments")
+	// ErrToolResult returns when the MCP server reports the tool call failed
+	ErrToolResult = errors.New("tool call failed")
+)
+
+// Input is the dynamic input of an MCP tool, its arguments are encoded as a flat JSON object
+type Input struct {
+	schema.Base
+	Arguments map[string]any
+}
+
+// NewInput returns an Input of the arguments
+func NewInput(arguments map[string]any) *Input {
+	return &Input{
+		Arguments: arguments,
+	}
+}
+
+func (i Input) MarshalJSON() ([]byte, error) {
+	if i.Arguments == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(i.Arguments)
+}
+
+func (i *Input) UnmarshalJSON(bs []byte) error {
+	return json.Unmarshal(bs, &i.Arguments)
+}
+
+// Output is the result of an MCP tool call
+type Output struct {
+	schema.Base
+	// Content text contents of the result joined by new lines
+	Content string `json:"content"`
+	// Structured structured content of the result, if any
+	Structured any `json:"structured,omitempty"`
+}
+
+func (o Output) String() string {
+	return o.Content
+}
+
+// Tool wraps a tool of an MCP server as a tools.Function
+type Tool struct {
+	tools.Config
+	client     client.MCPClient
+	name       string
+	parameters *jsonschema.Schema
+}
+
+var _ tools.Function = (*Tool)(nil)
+
+// NewTool wraps the MCP tool served by clt, the tool name is its title
+func NewTool(clt client.MCPClient, tool mcpgo.Tool, opts ...tools.Option) (*Tool, error) {
+	bs, err := json.Marshal(tool)
+	if err != nil {
+		return nil, err
+	}
+	var raw struct {
+		InputSchema *jsonschema.Schema `json:"inputSchema"`
+	}
+	if err := json.Unmarshal(bs, &raw); err != nil {
+		return nil, fmt.Errorf("tool %s input schema: %w", tool.Name, err)
+	}
+	if raw.InputSchema == nil {
+		raw.InputSchema = &jsonschema.Schema{Type: "object"}
+	}
+	ret := &Tool{
+		client:     clt,
+		name:       tool.Name,
+		parameters: raw.InputSchema,
+	}
+	ret.SetTitle(tool.Name)
+	ret.SetDescription(tool.Description)
+	for _, opt := range opts {
+		opt(&ret.Config)
+	}
+	return ret, nil
+}
+
+// Name returns the MCP tool name
+func (t *Tool) Name() string {
+	return t.name
+}
+
+func (t *Tool) Parameters() *jsonschema.Schema {
+	return t.parameters
+}
+
+func (t *Tool) NewInput() any {
+	return new(Input)
+}
+
+// Run validates the input arguments against the tool input JSON Schema, then calls the tool
+func (t *Tool) Run(ctx context.Context, input *Input, output *Output) error {
+	if err := validate(t.parameters, input.Arguments); err != nil {
+		return err
+	}
+	req := mcpgo.CallToolRequest{}
+	req.Params.Name = t.name
+	req.Params.Arguments = input.Arguments
+	res, err := t.client.CallTool(ctx, req)
+	if err != nil {
+		return err
+	}
+	texts := make([]string, 0, len(res.Content))
+	for _, content := range res.Content {
+		if text, ok := mcpgo.AsTextContent(content); ok {
+			texts = append(texts, text.Text)
+		}
+	}
+	if res.IsError {
+		return fmt.Errorf("%w: %s", ErrToolResult, strings.Join(texts, "\n"))
+	}
+	*output = Output{
+		Content:    strings.Join(texts, "\n"),
+		Structured: res.StructuredContent,
+	}
+	return nil
+}
+
+// RunAnonymous runs the tool with an *Input, or any input encoded into a JSON object like the outputs of ToolAgent start agents
+func (t *Tool) RunAnonymous(ctx context.Context, input any) (any, error) {
+	if fn := t.StartHook(); fn != nil {
+		fn(ctx, t, input)
+	}
+	in, err := toInput(input)
+	if err != nil {
+		if fn := t.ErrorHook(); fn != nil {
+			fn(ctx, t, input, err)
+		}
+		return nil, err
+	}
+	out := new(Output)
+	if err := t.Run(ctx, in, out); err != nil {
+		if fn := t.ErrorHook(); fn != nil {
+			fn(ctx, t, input, err)
+		}
+		return nil, err
+	}
+	if fn := t.EndHook(); fn != nil {
+		fn(ctx, t, input, out)
+	}
+	return out, nil
+}
+
+func toInput(input any) (*Input, error) {
+	if in, ok := input.(*Input); ok {
+		return in, nil
+	}
+	bs, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+	in := new(Input)
+	if err := json.Unmarshal(bs, in); err != nil {
+		return nil, fmt.Errorf("%w: input is not a JSON object", ErrInvalidArguments)
+	}
+	return in, nil
+}
+
+// validate checks the required properties and the types of the top-level properties
+func validate(s *jsonschema.Schema, args map[string]any) error {
+	for _, name := range s.Required {
+		if _, ok := args[name]; !ok {
+			return fmt.Errorf("%w: %s is required", ErrInvalidArguments, name)
+		}
+	}
+	if s.Properties == nil {
+		return nil
+	}
+	for name, value := range args {
+		prop, ok := s.Properties.Get(name)
+		if !ok {
+			if isFalse(s.AdditionalProperties) {
+				return fmt.Errorf("%w: %s is not allowed", ErrInvalidArguments, name)
+			}
+			continue
+		}
+		if prop != nil && prop.Type != "" && !isType(prop.Type, value) {
+			return fmt.Errorf("%w: %s must be %s", ErrInvalidArguments, name, prop.Type)
+		}
+	}
+	return nil
+}
+
+// isType reports whether the value is of the JSON Schema type, numbers could be JSON decoded float64s, json.Numbers
+// or Go integers and floats of arguments built in process
+func isType(typ string, value any) bool {
+	switch typ {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := toFloat(value)
+		return ok
+	case "integer":
+		v, ok := toFloat(value)
+		return ok && v == math.Trunc(v) && !math.IsInf(v, 0)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "null":
+		return value == nil
+	}
+	return true
+}
+
+// toFloat returns the value of a number, false if the value is not a number
+func toFloat(value any) (float64, bool) {
+	if n, ok := value.(json.Number); ok {
+		v, err := n.Float64()
+		return v, err == nil
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// isFalse reports whether the schema is the false schema, like additionalProperties false
+func isFalse(s *jsonschema.Schema) bool {
+	if s == nil {
+		return false
+	}
+	bs, err := json.Marshal(s)
+	return err == nil && string(bs) == "false"
+}
@@ -15,8 +15,11 @@ type ToolSelector[I schema.Schema] func(req *I) (tools.AnonymousTool, any, error
 type Tool[I schema.Schema] struct {
 	tools.Config
 	selector ToolSelector[I]
+	approver tools.Approver
 }
 
+var _ tools.Dispatcher = (*Tool[schema.String])(nil)
+
 func New[I schema.Schema](selector ToolSelector[I], opts ...tools.Option) *Tool[I] {
 	ret := new(Tool[I])
 	for _, opt := range opts {
@@ -29,35 +32,46 @@ func New[I schema.Schema](selector ToolSelector[I], opts ...tools.Option) *Tool[
 	return ret
 }
 
-// RunAnonymous returns a tool results based on input for orchestration
+// SetApprover set the Approver of selected tool calls, the Approver carried by the context is used if not set
+func (t *Tool[I]) SetApprover(approver tools.Approver) *Tool[I] {
+	t.approver = approver
+	return t
+}
+
+// Select returns the tool and its params selected for the input
+func (t *Tool[I]) Select(_ context.Context, input any) (tools.AnonymousTool, any, error) {
+	in, ok := input.(*I)
+	if !ok {
+		return nil, nil, errors.New("invalid tool input schema")
+	}
+	return t.selector(in)
+}
+
+// RunAnonymous returns a tool results based on input for orchestration.
+// The selected tool call is approved before running, a rejection returns *tools.RejectedError.
 func (t *Tool[I]) RunAnonymous(ctx context.Context, input any) (any, error) {
 	if fn := t.StartHook(); fn != nil {
 		fn(ctx, t, input)
 	}
-	in, ok := input.(*I)
-	if !ok {
-    err := errors.New("invalid tool input schema")
+	tool, params, err := t.Select(ctx, input)
+	if err == nil {
+		params, err = tools.RequestApproval(ctx, t.approver, tool, params)
+	}
+	if err != nil {
 		if fn := t.ErrorHook(); fn != nil {
 			fn(ctx, t, input, err)
 		}
 		return nil, err
 	}
-	tool, params, err := t.selector(in)
+	out, err := tools.Invoke(ctx, tool, params)
 	if err != nil {
 		if fn := t.ErrorHook(); fn != nil {
 			fn(ctx, t, input, err)
 		}
 		return nil, err
 	}
-  out, err := tool.RunAnonymous(ctx, params)
-  if err != nil {
-		if fn := t.ErrorHook(); fn != nil {
-			fn(ctx, t, input, err)
-		}
-    return nil, err
-  }
 	if fn := t.EndHook(); fn != nil {
 		fn(ctx, t, input, out)
 	}
-  return out, nil
+	return out, nil
 }
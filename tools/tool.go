@@ -3,6 +3,9 @@ package tools
 import (
 	"context"
 
+	"github.com/bububa/atomic-agents/components"
+	"github.com/bububa/atomic-agents/components/interceptor"
+	"github.com/bububa/atomic-agents/components/tracing"
 	"github.com/bububa/atomic-agents/schema"
 )
 
@@ -25,3 +28,20 @@ type AnonymousTool interface {
 	ITool
 	RunAnonymous(context.Context, any) (any, error)
 }
+
+// Invoke runs the tool through its interceptors in a tool call span
+func Invoke(ctx context.Context, tool AnonymousTool, input any) (any, error) {
+	ctx, span := tracing.Start(ctx, tracing.ToolCallSpan, tracing.ToolNameKey.String(tool.Title()))
+	var interceptors []interceptor.Interceptor
+	if v, ok := tool.(interface {
+		Interceptors() []interceptor.Interceptor
+	}); ok {
+		interceptors = v.Interceptors()
+	}
+	call := &interceptor.Call{Kind: interceptor.ToolCall, Name: tool.Title(), Target: tool}
+	output, err := interceptor.Run(ctx, call, interceptors, input, nil, func(ctx context.Context, input any, _ *components.LLMResponse) (any, error) {
+		return tool.RunAnonymous(ctx, input)
+	})
+	span.EndErr(err)
+	return output, err
+}